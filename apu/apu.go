@@ -0,0 +1,121 @@
+package apu
+
+import (
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// The APU is the audio half of the 2A03, the chip that also holds the NES's CPU. It has five
+// channels, each built from a few simple units (timers, sequencers, counters) that are clocked
+// either by the CPU clock or by the frame counter:
+//     - two pulse channels, see pulse.go
+//     - a triangle channel, see triangle.go
+//     - a noise channel, see noise.go
+//     - a delta modulation channel (DMC) playing samples from memory, see dmc.go
+//
+// The frame counter (see frame.go) clocks the envelopes, sweeps and counters at fixed points
+// of a roughly 60 Hz sequence, and can raise an IRQ at the end of it. Most timers are clocked
+// every other CPU cycle, an APU cycle. The channels are controlled by registers at
+// 0x4000-0x4017 on the CPU bus, of which only 0x4015 can be read.
+
+// CPU is the part of the 2A03's CPU the APU is connected to
+type CPU interface {
+	DataBus() uint8
+	RequestDMCDMA(address uint16, done func(data uint8))
+	SetIRQ(source cpu.IRQSource, asserted bool)
+}
+
+// RP2A03 represents the state of the APU
+type RP2A03 struct {
+	CPU    CPU        // CPU whose bus the registers are on
+	Region ppu.Region // TV system, which selects the noise and DMC period tables
+	Mixer  *Mixer     // Receives the output of the channels, if not nil
+
+	pulse1   pulse
+	pulse2   pulse
+	triangle triangle
+	noise    noise
+	dmc      dmc
+	frame    frameCounter
+	cycle    uint64 // CPU cycles since power on
+}
+
+// Create2A03 returns an instance of the APU
+func Create2A03() RP2A03 {
+	return RP2A03{
+		pulse1: pulse{onesComplement: true},
+		pulse2: pulse{},
+		noise:  noise{shift: 1, period: ntscNoisePeriods[0]},
+		dmc:    dmc{period: ntscDMCRates[0], bits: 8, silence: true},
+	}
+}
+
+// Reset puts the APU in the state it has after the reset button is pressed, which silences
+// every channel and restarts the frame counter
+func (a *RP2A03) Reset() {
+	a.writeStatus(0)
+	a.dmc.level &= 0x01
+	a.frame.irq = false
+	a.writeFrameCounter(a.frame.lastWritten, a.cycle%2 == 1)
+	a.updateIRQ()
+}
+
+// Clock advances the APU by one CPU cycle
+func (a *RP2A03) Clock() {
+	a.clockFrameCounter()
+	a.pulse1.length.update()
+	a.pulse2.length.update()
+	a.triangle.length.update()
+	a.noise.length.update()
+	if a.cycle%2 == 1 {
+		a.pulse1.clockTimer()
+		a.pulse2.clockTimer()
+	}
+	a.triangle.clockTimer()
+	a.noise.clockTimer()
+	a.dmc.clockTimer(a.CPU)
+	a.cycle++
+
+	if a.Mixer != nil {
+		a.Mixer.update(a.levels())
+	}
+
+	a.updateIRQ()
+}
+
+// levels returns the current output of each channel
+func (a *RP2A03) levels() [NumChannels]uint8 {
+	return [NumChannels]uint8{
+		a.pulse1.output(),
+		a.pulse2.output(),
+		a.triangle.output(),
+		a.noise.output(),
+		a.dmc.output(),
+	}
+}
+
+// quarterFrame clocks the envelopes and the triangle's linear counter, four times per frame
+func (a *RP2A03) quarterFrame() {
+	a.pulse1.envelope.clock()
+	a.pulse2.envelope.clock()
+	a.triangle.clockLinear()
+	a.noise.envelope.clock()
+}
+
+// halfFrame clocks the length counters and sweep units, twice per frame
+func (a *RP2A03) halfFrame() {
+	a.pulse1.length.clock()
+	a.pulse1.clockSweep()
+	a.pulse2.length.clock()
+	a.pulse2.clockSweep()
+	a.triangle.length.clock()
+	a.noise.length.clock()
+}
+
+// updateIRQ drives the CPU's IRQ input from the interrupt flags
+func (a *RP2A03) updateIRQ() {
+	if a.CPU != nil {
+		a.CPU.SetIRQ(cpu.IRQFrameCounter, a.frame.irq)
+		a.CPU.SetIRQ(cpu.IRQDMC, a.dmc.irq)
+	}
+}
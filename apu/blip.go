@@ -0,0 +1,90 @@
+package apu
+
+import "math"
+
+// Band-limited synthesis
+// ----------------------
+// The APU's output changes at the CPU clock rate, far above any audio sample rate, and is
+// made of sharp steps. Sampling it directly aliases the harmonics of the steps back into the
+// audible range. Instead each change of the output is added to the output samples as a band-
+// limited step: the step response of a low-pass filter just below the Nyquist frequency,
+// placed at the exact fractional sample position of the change. Only changes cost any work,
+// which is cheap since most CPU cycles change nothing.
+//
+// As in Blip_Buffer, the buffer holds the differences between consecutive samples, so adding a
+// step only touches the few samples around it (a windowed sinc impulse), and the samples are
+// recovered by summing the differences when a frame ends.
+
+const (
+	blipPhases = 32   // Fractional positions of a step between two samples
+	blipTaps   = 16   // Width of the impulse in samples
+	blipCutoff = 0.45 // Cutoff frequency relative to the sample rate
+)
+
+type blipBuffer struct {
+	ratio      float64 // Output samples per clock
+	offset     float64 // Position of the first clock of the frame, in samples
+	diff       []float64
+	integrator float64
+	kernel     [blipPhases + 1][blipTaps]float64
+}
+
+// newBlipBuffer returns a buffer resampling from clockRate to sampleRate
+func newBlipBuffer(clockRate float64, sampleRate float64) *blipBuffer {
+	b := &blipBuffer{ratio: sampleRate / clockRate}
+
+	// windowed sinc impulse, normalized so that every step adds exactly its height
+	for phase := range b.kernel {
+		sum := 0.0
+		for k := range b.kernel[phase] {
+			t := float64(k) - blipTaps/2 + 1 - float64(phase)/blipPhases
+			x := 2 * blipCutoff * t
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			w := 2 * math.Pi * t / blipTaps
+			window := 0.42 + 0.5*math.Cos(w) + 0.08*math.Cos(2*w)
+			b.kernel[phase][k] = sinc * window
+			sum += b.kernel[phase][k]
+		}
+		for k := range b.kernel[phase] {
+			b.kernel[phase][k] /= sum
+		}
+	}
+	return b
+}
+
+// addDelta adds a step of the given height at a clock of the current frame
+func (b *blipBuffer) addDelta(clock int, delta float64) {
+	pos := b.offset + float64(clock)*b.ratio
+	i := int(pos)
+	phase := int((pos-float64(i))*blipPhases + 0.5)
+
+	for len(b.diff) < i+blipTaps {
+		b.diff = append(b.diff, 0)
+	}
+	for k, h := range b.kernel[phase] {
+		b.diff[i+k] += delta * h
+	}
+}
+
+// endFrame ends the frame after the given number of clocks and appends the samples it
+// completed to out. Steps near the end of the frame spill into the samples of the next.
+func (b *blipBuffer) endFrame(clocks int, out []float64) []float64 {
+	end := b.offset + float64(clocks)*b.ratio
+	n := int(end)
+
+	for len(b.diff) < n {
+		b.diff = append(b.diff, 0)
+	}
+	for _, d := range b.diff[:n] {
+		b.integrator += d
+		out = append(out, b.integrator)
+	}
+
+	remaining := copy(b.diff, b.diff[n:])
+	b.diff = b.diff[:remaining]
+	b.offset = end - float64(n)
+	return out
+}
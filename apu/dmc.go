@@ -0,0 +1,176 @@
+package apu
+
+import "github.com/cbertinato/go-nes/ppu"
+
+// Delta modulation channel
+// ------------------------
+// The DMC plays 1-bit delta encoded samples from CPU memory. Its 7-bit output level is moved
+// up or down by 2 for each bit of a sample byte, one bit each time the timer reaches 0, and
+// stays within 0-127. The timer period comes from a table of 16 rates, which differ between
+// NTSC and PAL. The Dendy uses the NTSC table.
+//
+// Samples are read one byte at a time into a sample buffer by a DMA, which halts the CPU for
+// a few cycles (see cpu/dma.go). The output unit takes a byte from the buffer every 8 bits and
+// the next byte is fetched as soon as the buffer is empty. When the buffer is empty at the
+// start of a byte the output unit stays silent for 8 bits, holding its level.
+//
+// A sample starts at 0xC000 + 64 * address and is 16 * length + 1 bytes long. The address
+// wraps from 0xFFFF to 0x8000. At the end of a sample the channel either restarts it or stops,
+// raising an IRQ if enabled.
+//
+// Writing the output level directly through 0x4011 lets games play PCM audio by writing a
+// level every few cycles, with the sample playback disabled.
+//
+// Registers, at 0x4010-0x4013:
+//     - IL-- RRRR: IRQ enabled, loop, rate index. Clearing the IRQ enable clears the IRQ.
+//     - -DDD DDDD: output level
+//     - AAAA AAAA: sample address
+//     - LLLL LLLL: sample length
+
+// Timer periods in CPU cycles
+var (
+	ntscDMCRates = [16]uint16{428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54}
+	palDMCRates  = [16]uint16{398, 354, 316, 298, 276, 236, 210, 198, 176, 148, 132, 118, 98, 78, 66, 50}
+)
+
+type dmc struct {
+	irqEnabled bool
+	irq        bool
+	loop       bool
+	period     uint16
+	timer      uint16
+	level      uint8 // Output level, 0-127
+
+	sampleAddr   uint16 // Start of the sample
+	sampleLength uint16 // Length of the sample in bytes
+
+	// Memory reader
+	addr      uint16 // Address of the next byte of the sample
+	remaining uint16 // Bytes of the sample left to read
+	buffer    uint8
+	full      bool // The sample buffer holds a byte
+	fetching  bool // A DMA has been requested and has not completed
+
+	// Output unit
+	shift   uint8
+	bits    uint8 // Bits left in the shift register
+	silence bool
+}
+
+// write stores a value to one of the channel's four registers. The timer periods depend on
+// the region.
+func (d *dmc) write(reg uint16, data uint8, region ppu.Region) {
+	switch reg {
+	case 0:
+		d.irqEnabled = data&0x80 != 0
+		if !d.irqEnabled {
+			d.irq = false
+		}
+		d.loop = data&0x40 != 0
+		if region == ppu.PAL {
+			d.period = palDMCRates[data&0x0F]
+		} else {
+			d.period = ntscDMCRates[data&0x0F]
+		}
+	case 1:
+		d.level = data & 0x7F
+	case 2:
+		d.sampleAddr = 0xC000 | uint16(data)<<6
+	case 3:
+		d.sampleLength = uint16(data)<<4 | 0x0001
+	}
+}
+
+// setEnabled starts the sample if it is not already playing, or stops it. Either way the IRQ
+// is cleared.
+func (d *dmc) setEnabled(enabled bool, c CPU) {
+	d.irq = false
+	if !enabled {
+		d.remaining = 0
+		return
+	}
+	if d.remaining == 0 {
+		d.restart()
+		d.fetch(c)
+	}
+}
+
+// restart returns the memory reader to the start of the sample
+func (d *dmc) restart() {
+	d.addr = d.sampleAddr
+	d.remaining = d.sampleLength
+}
+
+// active returns true while bytes of the sample remain to be read
+func (d *dmc) active() bool {
+	return d.remaining > 0
+}
+
+// fetch requests the next byte of the sample if the buffer is empty
+func (d *dmc) fetch(c CPU) {
+	if d.full || d.fetching || d.remaining == 0 || c == nil {
+		return
+	}
+	d.fetching = true
+	c.RequestDMCDMA(d.addr, d.fill)
+}
+
+// fill stores a byte read by the DMA in the sample buffer and advances the memory reader
+func (d *dmc) fill(data uint8) {
+	d.fetching = false
+	d.buffer = data
+	d.full = true
+
+	d.addr++
+	if d.addr == 0x0000 {
+		d.addr = 0x8000
+	}
+
+	d.remaining--
+	if d.remaining == 0 {
+		if d.loop {
+			d.restart()
+		} else if d.irqEnabled {
+			d.irq = true
+		}
+	}
+}
+
+// clockTimer is called every CPU cycle
+func (d *dmc) clockTimer(c CPU) {
+	if d.timer > 0 {
+		d.timer--
+		return
+	}
+	d.timer = d.period - 1
+
+	if !d.silence {
+		if d.shift&0x01 != 0 {
+			if d.level <= 125 {
+				d.level += 2
+			}
+		} else if d.level >= 2 {
+			d.level -= 2
+		}
+	}
+	d.shift >>= 1
+
+	if d.bits > 0 {
+		d.bits--
+	}
+	if d.bits == 0 {
+		// start of a new output cycle
+		d.bits = 8
+		d.silence = !d.full
+		if d.full {
+			d.shift = d.buffer
+			d.full = false
+			d.fetch(c)
+		}
+	}
+}
+
+// output returns the current level of the channel, 0-127
+func (d *dmc) output() uint8 {
+	return d.level
+}
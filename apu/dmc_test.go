@@ -0,0 +1,114 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/cpu"
+)
+
+// TestDMCFetch checks that sample bytes are fetched through the CPU from the sample address,
+// and that the end of a sample raises an IRQ
+func TestDMCFetch(t *testing.T) {
+	c := testCPU{}
+	a := Create2A03()
+	a.CPU = &c
+
+	a.Write(0x4010, 0x8F) // IRQ, fastest rate
+	a.Write(0x4012, 0x01) // 0xC040
+	a.Write(0x4013, 0x01) // 17 bytes
+	a.Write(0x4015, StatusDMC)
+
+	if len(c.reads) != 1 || c.reads[0] != 0xC040 {
+		t.Fatalf("Expected a fetch from 0xC040 when enabled, got %x", c.reads)
+	}
+
+	for i := 0; i < 54*8*17; i++ {
+		a.Clock()
+	}
+
+	if len(c.reads) != 17 || c.reads[16] != 0xC050 {
+		t.Errorf("Expected 17 fetches up to 0xC050, got %x", c.reads)
+	}
+
+	if a.Read(0x4015, false)&(StatusDMC|StatusDMCIRQ) != StatusDMCIRQ {
+		t.Errorf("Expected the sample to end with an IRQ")
+	}
+	if c.irq != cpu.IRQDMC {
+		t.Errorf("IRQ line not asserted")
+	}
+
+	a.Write(0x4015, 0x00)
+	if c.irq != 0 {
+		t.Errorf("IRQ not cleared by writing 0x4015")
+	}
+}
+
+// TestDMCLoop checks that a looping sample restarts without an IRQ, and that the address wraps
+// to 0x8000
+func TestDMCLoop(t *testing.T) {
+	c := testCPU{}
+	a := Create2A03()
+	a.CPU = &c
+
+	a.Write(0x4010, 0xCF) // IRQ, loop
+	a.Write(0x4012, 0xFF) // 0xFFC0
+	a.Write(0x4013, 0x04) // 65 bytes
+	a.Write(0x4015, StatusDMC)
+
+	for i := 0; i < 54*8*70; i++ {
+		a.Clock()
+	}
+
+	if c.reads[64] != 0x8000 {
+		t.Errorf("Expected the address to wrap to 0x8000, got %#04x", c.reads[64])
+	}
+	if c.reads[65] != 0xFFC0 {
+		t.Errorf("Expected the sample to restart at 0xFFC0, got %#04x", c.reads[65])
+	}
+	if c.irq&cpu.IRQDMC != 0 {
+		t.Errorf("IRQ raised by a looping sample")
+	}
+}
+
+// TestDMCOutput checks that the level follows the sample bits, stays within range, and can be
+// written directly
+func TestDMCOutput(t *testing.T) {
+	c := testCPU{}
+	c.memory[0xC000] = 0x0F // 4 bits up, 4 bits down
+	c.memory[0xC001] = 0xFF
+	a := Create2A03()
+	a.CPU = &c
+
+	a.Write(0x4010, 0x0F)
+	a.Write(0x4011, 0x40)
+	a.Write(0x4012, 0x00)
+	a.Write(0x4013, 0x00) // 1 byte
+	a.Write(0x4015, StatusDMC)
+
+	// the first output cycle is silent, the sample byte plays in the second
+	for i := 0; i < 54*8; i++ {
+		a.Clock()
+	}
+	if a.dmc.output() != 0x40 {
+		t.Errorf("Expected the level to hold while silent, got %#02x", a.dmc.output())
+	}
+
+	for i := 0; i < 54*4; i++ {
+		a.Clock()
+	}
+	if a.dmc.output() != 0x48 {
+		t.Errorf("Expected level %#02x, got %#02x", 0x48, a.dmc.output())
+	}
+
+	for i := 0; i < 54*4; i++ {
+		a.Clock()
+	}
+	if a.dmc.output() != 0x40 {
+		t.Errorf("Expected level %#02x, got %#02x", 0x40, a.dmc.output())
+	}
+
+	a.Write(0x4011, 0xFF)
+	if a.dmc.output() != 0x7F {
+		t.Errorf("Expected direct write to set level %#02x, got %#02x", 0x7F, a.dmc.output())
+	}
+}
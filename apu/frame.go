@@ -0,0 +1,118 @@
+package apu
+
+import "github.com/cbertinato/go-nes/ppu"
+
+// Frame counter
+// -------------
+// The frame counter divides the CPU clock into quarter frames, which clock the envelopes and
+// the triangle's linear counter, and half frames, which also clock the length counters and
+// sweep units. Despite the name it is not synchronized with the PPU. It has two sequences,
+// selected by bit 7 of 0x4017, with steps at these CPU cycles on NTSC:
+//
+//     4-step: 7457 Q, 14913 QH, 22371 Q, 29829 QH, and the IRQ flag set on 29828-29830
+//     5-step: 7457 Q, 14913 QH, 22371 Q, 37281 QH
+//
+// where the last cycle of a sequence (29830 or 37282) is cycle 0 of the next. PAL steps are
+// further apart. Only the 4-step sequence raises the frame IRQ, unless inhibited by bit 6 of
+// 0x4017, and the flag stays set until 0x4015 is read or the inhibit bit is set.
+//
+// Writing 0x4017 restarts the sequence after a delay of 3 CPU cycles if the write is on an
+// APU cycle, or 4 if it is between APU cycles. Selecting the 5-step sequence also clocks the
+// quarter and half frame units when it restarts. A reset behaves as if the last value were
+// written again.
+
+// Bits of 0x4017
+const (
+	FrameIRQInhibit uint8 = 0x40 // Disable the frame IRQ
+	FrameFiveStep   uint8 = 0x80 // 5-step sequence
+)
+
+// frameSteps holds the cycles of the steps of a sequence
+type frameSteps struct {
+	quarter [4]int // Quarter frame steps, the second and fourth are also half frames
+	irq     int    // First cycle of the 3 on which the 4-step sequence sets the IRQ flag
+	length  int    // Cycle at which the sequence restarts
+}
+
+var (
+	ntscFourStep = frameSteps{[4]int{7457, 14913, 22371, 29829}, 29828, 29830}
+	ntscFiveStep = frameSteps{[4]int{7457, 14913, 22371, 37281}, 0, 37282}
+	palFourStep  = frameSteps{[4]int{8313, 16627, 24939, 33253}, 33252, 33254}
+	palFiveStep  = frameSteps{[4]int{8313, 16627, 24939, 41565}, 0, 41566}
+)
+
+type frameCounter struct {
+	fiveStep    bool
+	inhibit     bool
+	irq         bool
+	cycle       int // CPU cycles since the start of the sequence
+	resetDelay  int // CPU cycles until a write to 0x4017 restarts the sequence, 0 if none
+	lastWritten uint8
+}
+
+// steps returns the step cycles of the selected sequence
+func (f *frameCounter) steps(region ppu.Region) *frameSteps {
+	switch {
+	case region == ppu.PAL && f.fiveStep:
+		return &palFiveStep
+	case region == ppu.PAL:
+		return &palFourStep
+	case f.fiveStep:
+		return &ntscFiveStep
+	}
+	return &ntscFourStep
+}
+
+// writeFrameCounter handles a write to 0x4017. oddCycle is true when the write lands on an APU
+// cycle.
+func (a *RP2A03) writeFrameCounter(data uint8, oddCycle bool) {
+	f := &a.frame
+	f.lastWritten = data
+	f.fiveStep = data&FrameFiveStep != 0
+	f.inhibit = data&FrameIRQInhibit != 0
+	if f.inhibit {
+		f.irq = false
+	}
+
+	if oddCycle {
+		f.resetDelay = 3
+	} else {
+		f.resetDelay = 4
+	}
+}
+
+// clockFrameCounter is called every CPU cycle
+func (a *RP2A03) clockFrameCounter() {
+	f := &a.frame
+	steps := f.steps(a.Region)
+
+	if f.resetDelay > 0 {
+		f.resetDelay--
+		if f.resetDelay == 0 {
+			f.cycle = 0
+			if f.fiveStep {
+				a.quarterFrame()
+				a.halfFrame()
+			}
+			return
+		}
+	}
+
+	f.cycle++
+	for i, c := range steps.quarter {
+		if f.cycle == c {
+			a.quarterFrame()
+			if i%2 == 1 {
+				a.halfFrame()
+			}
+		}
+	}
+
+	if !f.fiveStep && !f.inhibit && f.cycle >= steps.irq && f.cycle <= steps.irq+2 {
+		f.irq = true
+	}
+
+	if f.cycle == steps.length {
+		f.cycle = 0
+	}
+}
@@ -0,0 +1,203 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// restartFrame writes 0x4017 and clocks the APU until the sequence restarts
+func restartFrame(a *RP2A03, data uint8) {
+	a.Write(0x4017, data)
+	for a.frame.resetDelay > 0 {
+		a.Clock()
+	}
+}
+
+// halfFrames clocks the APU for n cycles and returns the cycles, counted from the restart of
+// the sequence, on which pulse 1's length counter was clocked
+func halfFrames(a *RP2A03, n int) []int {
+	var clocks []int
+	for i := 1; i <= n; i++ {
+		a.Write(0x4003, 0xF8) // reload the length counter with 30
+		a.Clock()
+		if a.pulse1.length.counter != 30 {
+			clocks = append(clocks, i)
+		}
+	}
+	return clocks
+}
+
+// TestFrameSequences checks the cycles of the half frame steps of both sequences in both
+// regions
+func TestFrameSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   ppu.Region
+		data     uint8
+		expected []int
+	}{
+		{"NTSC 4-step", ppu.NTSC, 0x40, []int{14913, 29829, 29830 + 14913}},
+		{"NTSC 5-step", ppu.NTSC, 0xC0, []int{14913, 37281, 37282 + 14913}},
+		{"PAL 4-step", ppu.PAL, 0x40, []int{16627, 33253, 33254 + 16627}},
+		{"PAL 5-step", ppu.PAL, 0xC0, []int{16627, 41565, 41566 + 16627}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Create2A03()
+			a.Region = tt.region
+			a.Write(0x4015, StatusPulse1)
+			restartFrame(&a, tt.data)
+
+			clocks := halfFrames(&a, tt.expected[2])
+			if len(clocks) != 3 {
+				t.Fatalf("Expected half frames at %v, got %v", tt.expected, clocks)
+			}
+			for i := range clocks {
+				if clocks[i] != tt.expected[i] {
+					t.Errorf("Expected half frames at %v, got %v", tt.expected, clocks)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestFiveStepImmediateClock checks that selecting the 5-step sequence clocks the half frame
+// units when the sequence restarts
+func TestFiveStepImmediateClock(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusPulse1)
+	a.Write(0x4003, 0xF8)
+
+	restartFrame(&a, 0x80)
+	if a.pulse1.length.counter != 29 {
+		t.Errorf("Expected the length counter to be clocked, got %d", a.pulse1.length.counter)
+	}
+
+	restartFrame(&a, 0x00)
+	if a.pulse1.length.counter != 29 {
+		t.Errorf("Length counter clocked by selecting the 4-step sequence")
+	}
+}
+
+// TestFrameIRQ checks when the frame IRQ flag is set and how it is cleared
+func TestFrameIRQ(t *testing.T) {
+	c := testCPU{}
+	a := Create2A03()
+	a.CPU = &c
+	restartFrame(&a, 0x00)
+
+	for i := 0; i < 29827; i++ {
+		a.Clock()
+	}
+	if a.Read(0x4015, true)&StatusFrameIRQ != 0 {
+		t.Fatalf("IRQ flag set early")
+	}
+
+	a.Clock()
+	if a.Read(0x4015, true)&StatusFrameIRQ == 0 || c.irq != cpu.IRQFrameCounter {
+		t.Fatalf("IRQ flag not set on cycle 29828")
+	}
+
+	// the flag is set again on the next 2 cycles
+	a.Read(0x4015, false)
+	a.Clock()
+	if a.Read(0x4015, false)&StatusFrameIRQ == 0 {
+		t.Errorf("IRQ flag not set again on cycle 29829")
+	}
+	if c.irq != 0 {
+		t.Errorf("IRQ not released by reading 0x4015")
+	}
+
+	a.Clock()
+	if a.Read(0x4015, false)&StatusFrameIRQ == 0 {
+		t.Errorf("IRQ flag not set again on cycle 29830")
+	}
+	a.Clock()
+	if a.Read(0x4015, true)&StatusFrameIRQ != 0 {
+		t.Errorf("IRQ flag set after cycle 29830")
+	}
+
+	// inhibit clears the flag and stops it being set
+	a.frame.irq = true
+	a.Write(0x4017, FrameIRQInhibit)
+	if a.Read(0x4015, true)&StatusFrameIRQ != 0 || c.irq != 0 {
+		t.Errorf("IRQ flag not cleared by inhibit")
+	}
+	for i := 0; i < 30000; i++ {
+		a.Clock()
+	}
+	if a.Read(0x4015, true)&StatusFrameIRQ != 0 {
+		t.Errorf("IRQ flag set while inhibited")
+	}
+}
+
+// TestFrameWriteJitter checks that writes on consecutive cycles restart the sequence on the
+// same cycle, 3 cycles after a write on an APU cycle and 4 after one between APU cycles
+func TestFrameWriteJitter(t *testing.T) {
+	restartCycle := func(clocks int) uint64 {
+		a := Create2A03()
+		for i := 0; i < clocks; i++ {
+			a.Clock()
+		}
+		restartFrame(&a, 0x00)
+		return a.cycle
+	}
+
+	even := restartCycle(10)
+	odd := restartCycle(11)
+	if even != 14 || odd != 14 {
+		t.Errorf("Expected both writes to restart the sequence on cycle 14, got %d and %d", even, odd)
+	}
+}
+
+// toHalfFrame clocks the APU until the next cycle is a half frame step
+func toHalfFrame(a *RP2A03) {
+	steps := a.frame.steps(a.Region)
+	for a.frame.cycle+1 != steps.quarter[1] && a.frame.cycle+1 != steps.quarter[3] {
+		a.Clock()
+	}
+}
+
+// TestLengthWriteOnHalfFrame checks that length counter writes on the cycle of a half frame
+// take effect after it: a reload is ignored if the counter is decremented, but not if it is 0,
+// and the clock sees the old halt flag
+func TestLengthWriteOnHalfFrame(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusPulse1)
+	a.Write(0x4003, 0xF8) // 30
+	restartFrame(&a, 0x40)
+
+	toHalfFrame(&a)
+	a.Write(0x4003, 0x18) // 2
+	a.Clock()
+	if a.pulse1.length.counter != 29 {
+		t.Errorf("Expected the reload to be ignored, got %d", a.pulse1.length.counter)
+	}
+
+	a.Write(0x4015, 0x00)
+	a.Write(0x4015, StatusPulse1)
+	toHalfFrame(&a)
+	a.Write(0x4003, 0x18)
+	a.Clock()
+	if a.pulse1.length.counter != 2 {
+		t.Errorf("Expected the reload of a counter at 0, got %d", a.pulse1.length.counter)
+	}
+
+	toHalfFrame(&a)
+	a.Write(0x4000, 0x20) // halt
+	a.Clock()
+	if a.pulse1.length.counter != 1 {
+		t.Errorf("Expected the clock to see the old halt flag, got %d", a.pulse1.length.counter)
+	}
+
+	toHalfFrame(&a)
+	a.Write(0x4000, 0x00)
+	a.Clock()
+	if a.pulse1.length.counter != 1 {
+		t.Errorf("Expected the clock to see the old halt flag, got %d", a.pulse1.length.counter)
+	}
+}
@@ -0,0 +1,269 @@
+package apu
+
+import (
+	"math"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Mixer
+// -----
+// The NES mixes its channels with resistor networks whose output is not a linear sum. The
+// pulse channels share one network and the triangle, noise and DMC another:
+//
+//     pulse = 95.52 / (8128 / (pulse1 + pulse2) + 100)
+//     tnd   = 163.67 / (24329 / (3 * triangle + 2 * noise + dmc) + 100)
+//
+// which are precomputed as lookup tables. The sum of the two ranges from 0 to about 1.
+//
+// The mixed signal is resampled to the host sample rate by band-limited synthesis (see
+// blip.go), then passes through the filters of the NES's audio output: two high-pass filters
+// at 90 Hz and 440 Hz, which remove the DC offset, and a low-pass filter at 14 kHz.
+//
+// Each channel has a volume and can be muted. A volume scales the channel's output before the
+// lookup, so channels keep their nonlinear interaction.
+//
+// Sound chips on the cartridge, such as the Sunsoft 5B or the Namco 163, are mixed in through
+// the expansion audio pins of the cartridge connector. Their output is added to that of the APU
+// after its lookup tables, as the chips have their own DACs.
+//
+// The mixer can also output each channel on its own (a stem), as mixed by a copy of the mixer
+// with every other channel muted. Stems only hold the APU's channels.
+
+// Channel identifies an APU channel
+type Channel int
+
+const (
+	Pulse1 Channel = iota
+	Pulse2
+	Triangle
+	Noise
+	DMC
+)
+
+// NumChannels is the number of APU channels
+const NumChannels = 5
+
+var (
+	pulseTable [31]float64
+	tndTable   [203]float64
+)
+
+func init() {
+	for n := 1; n < len(pulseTable); n++ {
+		pulseTable[n] = 95.52 / (8128.0/float64(n) + 100)
+	}
+	for n := 1; n < len(tndTable); n++ {
+		tndTable[n] = 163.67 / (24329.0/float64(n) + 100)
+	}
+}
+
+// lookup returns the value of a table at a fractional index, interpolating between entries.
+// Volumes above 1 can index past the end of the table, where the last entry is extrapolated.
+func lookup(table []float64, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	i := int(x)
+	if i >= len(table)-1 {
+		return table[len(table)-1] * x / float64(len(table)-1)
+	}
+	frac := x - float64(i)
+	return table[i] + (table[i+1]-table[i])*frac
+}
+
+// Expansion is a sound chip on the cartridge
+type Expansion interface {
+	// Output returns the current output of the chip, on the scale of the mixed APU output, where
+	// both pulse channels at full volume give about 0.26
+	Output() float64
+}
+
+// Mixer mixes the APU channels and resamples them to a host sample rate. Attach it to an APU
+// with the APU's Mixer field and call EndFrame at the end of each video frame to collect the
+// samples of the frame.
+type Mixer struct {
+	Volume [NumChannels]float64 // Volume of each channel, 1 is unchanged
+	Mute   [NumChannels]bool    // Silence a channel
+
+	Expansion       Expansion // Sound chip on the cartridge, if not nil
+	ExpansionVolume float64   // Volume of the expansion chip, 1 is unchanged
+
+	region     ppu.Region
+	sampleRate int
+	blip       *blipBuffer
+	stems      [NumChannels]*Mixer
+	levels     [NumChannels]uint8   // Channel outputs at the last clock
+	apuLevel   float64              // Mixed output of the APU channels at the last clock
+	level      float64              // Mixed output at the last clock
+	clock      int                  // Clocks since the start of the frame
+	volume     [NumChannels]float64 // Volume and Mute at the last clock
+	mute       [NumChannels]bool
+
+	// Filter state
+	highPass90  highPass
+	highPass440 highPass
+	lowPass14k  lowPass
+
+	samples []float64
+	float32 []float32
+	int16   []int16
+}
+
+// NewMixer returns a mixer for the CPU clock rate of a region producing sampleRate samples
+// per second, typically 44100 or 48000
+func NewMixer(region ppu.Region, sampleRate int) *Mixer {
+	m := &Mixer{
+		ExpansionVolume: 1,
+		region:          region,
+		sampleRate:      sampleRate,
+		blip:            newBlipBuffer(region.CPUClock(), float64(sampleRate)),
+		highPass90:      newHighPass(90, sampleRate),
+		highPass440:     newHighPass(440, sampleRate),
+		lowPass14k:      newLowPass(14000, sampleRate),
+	}
+	for i := range m.Volume {
+		m.Volume[i] = 1
+		m.volume[i] = 1
+	}
+	return m
+}
+
+// SampleRate returns the number of samples per second of the output
+func (m *Mixer) SampleRate() int {
+	return m.sampleRate
+}
+
+// EnableStems makes the mixer also output each channel on its own, see Stem
+func (m *Mixer) EnableStems() {
+	for ch := range m.stems {
+		if m.stems[ch] != nil {
+			continue
+		}
+		stem := NewMixer(m.region, m.sampleRate)
+		for other := range stem.Mute {
+			stem.Mute[other] = other != ch
+		}
+		stem.mute = stem.Mute
+		m.stems[ch] = stem
+	}
+}
+
+// Stem returns the mixer producing the output of a single channel, or nil if stems are not
+// enabled. Its samples are ready when those of m are.
+func (m *Mixer) Stem(ch Channel) *Mixer {
+	return m.stems[ch]
+}
+
+// mix returns the output of the NES for the given channel levels
+func (m *Mixer) mix(levels [NumChannels]uint8) float64 {
+	var scaled [NumChannels]float64
+	for i, l := range levels {
+		if !m.Mute[i] {
+			scaled[i] = float64(l) * m.Volume[i]
+		}
+	}
+	pulse := lookup(pulseTable[:], scaled[Pulse1]+scaled[Pulse2])
+	tnd := lookup(tndTable[:], 3*scaled[Triangle]+2*scaled[Noise]+scaled[DMC])
+	return pulse + tnd
+}
+
+// update is called by the APU every CPU cycle with the outputs of the channels. The expansion
+// chip is read at the same time.
+func (m *Mixer) update(levels [NumChannels]uint8) {
+	settings := m.Volume != m.volume || m.Mute != m.mute
+	if levels != m.levels || settings {
+		m.levels = levels
+		m.volume = m.Volume
+		m.mute = m.Mute
+		m.apuLevel = m.mix(levels)
+	}
+
+	level := m.apuLevel
+	if m.Expansion != nil {
+		level += m.Expansion.Output() * m.ExpansionVolume
+	}
+	if level != m.level {
+		m.blip.addDelta(m.clock, level-m.level)
+		m.level = level
+	}
+	m.clock++
+
+	for _, stem := range m.stems {
+		if stem != nil {
+			stem.update(levels)
+		}
+	}
+}
+
+// EndFrame completes the samples of the clocks since the last call. They can then be read
+// with Float32 or Int16 until the next call.
+func (m *Mixer) EndFrame() {
+	for _, stem := range m.stems {
+		if stem != nil {
+			stem.EndFrame()
+		}
+	}
+
+	m.samples = m.blip.endFrame(m.clock, m.samples[:0])
+	m.clock = 0
+
+	m.float32 = m.float32[:0]
+	m.int16 = m.int16[:0]
+	for _, s := range m.samples {
+		s = m.highPass90.filter(s)
+		s = m.highPass440.filter(s)
+		s = m.lowPass14k.filter(s)
+		s = math.Max(-1, math.Min(1, s))
+
+		m.float32 = append(m.float32, float32(s))
+		m.int16 = append(m.int16, int16(math.Round(s*math.MaxInt16)))
+	}
+}
+
+// Float32 returns the samples of the last frame, from -1 to 1
+func (m *Mixer) Float32() []float32 {
+	return m.float32
+}
+
+// Int16 returns the samples of the last frame as signed 16-bit PCM
+func (m *Mixer) Int16() []int16 {
+	return m.int16
+}
+
+// highPass is a first order high-pass filter
+type highPass struct {
+	alpha float64
+	prevX float64
+	prevY float64
+}
+
+func newHighPass(cutoff float64, sampleRate int) highPass {
+	rc := 1 / (2 * math.Pi * cutoff)
+	dt := 1 / float64(sampleRate)
+	return highPass{alpha: rc / (rc + dt)}
+}
+
+func (f *highPass) filter(x float64) float64 {
+	y := f.alpha * (f.prevY + x - f.prevX)
+	f.prevX = x
+	f.prevY = y
+	return y
+}
+
+// lowPass is a first order low-pass filter
+type lowPass struct {
+	alpha float64
+	prevY float64
+}
+
+func newLowPass(cutoff float64, sampleRate int) lowPass {
+	rc := 1 / (2 * math.Pi * cutoff)
+	dt := 1 / float64(sampleRate)
+	return lowPass{alpha: dt / (rc + dt)}
+}
+
+func (f *lowPass) filter(x float64) float64 {
+	f.prevY += f.alpha * (x - f.prevY)
+	return f.prevY
+}
@@ -0,0 +1,184 @@
+package apu
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// TestMix checks the nonlinear mixing of the channels and the per channel volume
+func TestMix(t *testing.T) {
+	m := NewMixer(ppu.NTSC, 48000)
+
+	tests := []struct {
+		name     string
+		levels   [NumChannels]uint8
+		expected float64
+	}{
+		{"silence", [NumChannels]uint8{}, 0},
+		{"pulses", [NumChannels]uint8{15, 15, 0, 0, 0}, 95.52 / (8128.0/30 + 100)},
+		{"tnd", [NumChannels]uint8{0, 0, 15, 15, 127}, 163.67 / (24329.0/202 + 100)},
+	}
+
+	for _, tt := range tests {
+		if out := m.mix(tt.levels); math.Abs(out-tt.expected) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, out)
+		}
+	}
+
+	m.Volume[Pulse1] = 0.5
+	m.Mute[Pulse2] = true
+	expected := 95.52 / (8128.0/7.5 + 100)
+	if out := m.mix([NumChannels]uint8{15, 15, 0, 0, 0}); math.Abs(out-expected) > 1e-4 {
+		t.Errorf("Expected %v with pulse 1 at half volume and pulse 2 muted, got %v", expected, out)
+	}
+}
+
+// TestBlipStep checks that a step is resampled to a band-limited step of the same height
+func TestBlipStep(t *testing.T) {
+	b := newBlipBuffer(1000000, 48000)
+	b.addDelta(100, 0.5)
+	samples := b.endFrame(10000, nil)
+
+	if len(samples) != 480 {
+		t.Fatalf("Expected 480 samples, got %d", len(samples))
+	}
+	if samples[0] != 0 {
+		t.Errorf("Expected silence before the step, got %v", samples[0])
+	}
+	if math.Abs(samples[len(samples)-1]-0.5) > 1e-9 {
+		t.Errorf("Expected the step to settle at 0.5, got %v", samples[len(samples)-1])
+	}
+}
+
+// TestMixerTone checks the number of samples produced and the frequency of a pulse wave
+func TestMixerTone(t *testing.T) {
+	for _, rate := range []int{44100, 48000} {
+		a := Create2A03()
+		a.Mixer = NewMixer(ppu.NTSC, rate)
+		a.Write(0x4015, StatusPulse1)
+		a.Write(0x4000, 0xBF) // 50% duty, halt, constant volume 15
+		a.Write(0x4002, 0xFD)
+		a.Write(0x4003, 0x00) // period 253, 440.4 Hz
+
+		// one second, in frames of 29780.5 CPU cycles
+		clock := ppu.NTSC.CPUClock()
+		var samples []float32
+		for frame := 1; frame <= 60; frame++ {
+			for a.cycle < uint64(float64(frame)*clock/60) {
+				a.Clock()
+			}
+			a.Mixer.EndFrame()
+			samples = append(samples, a.Mixer.Float32()...)
+
+			if len(a.Mixer.Int16()) != len(a.Mixer.Float32()) {
+				t.Fatalf("Int16 and Float32 have different lengths")
+			}
+		}
+
+		if len(samples) < rate-1 || len(samples) > rate {
+			t.Errorf("%d Hz: expected %d samples in one second, got %d", rate, rate, len(samples))
+		}
+
+		// the high-pass filters let the wave decay towards 0 between edges, so rising edges are
+		// counted with some hysteresis
+		crossings := 0
+		low := false
+		for _, s := range samples {
+			if s < -0.02 {
+				low = true
+			} else if s > 0.02 && low {
+				low = false
+				crossings++
+			}
+		}
+		if crossings < 438 || crossings > 442 {
+			t.Errorf("%d Hz: expected a 440 Hz tone, got %d cycles", rate, crossings)
+		}
+	}
+}
+
+// TestMixerStems checks that each stem holds only its own channel
+func TestMixerStems(t *testing.T) {
+	a := Create2A03()
+	a.Mixer = NewMixer(ppu.NTSC, 48000)
+	a.Mixer.EnableStems()
+	a.Mixer.Mute[Triangle] = true // the triangle holds its output at 15 while stopped
+	a.Write(0x4015, StatusPulse1)
+	a.Write(0x4000, 0xBF)
+	a.Write(0x4002, 0xFD)
+	a.Write(0x4003, 0x00)
+
+	for i := 0; i < 29781; i++ {
+		a.Clock()
+	}
+	a.Mixer.EndFrame()
+
+	mix := a.Mixer.Float32()
+	pulse := a.Mixer.Stem(Pulse1).Float32()
+	if len(pulse) != len(mix) {
+		t.Fatalf("Expected %d samples in the stem, got %d", len(mix), len(pulse))
+	}
+	for i := range mix {
+		if pulse[i] != mix[i] {
+			t.Fatalf("Expected the pulse 1 stem to match the mix when only pulse 1 plays")
+		}
+	}
+
+	for _, s := range a.Mixer.Stem(Pulse2).Float32() {
+		if s != 0 {
+			t.Fatalf("Expected the pulse 2 stem to be silent, got %v", s)
+		}
+	}
+}
+
+// squareChip is an expansion chip with a square wave output, toggling every half period
+type squareChip struct {
+	halfPeriod int
+	clock      int
+}
+
+func (s *squareChip) Output() float64 {
+	s.clock++
+	if (s.clock/s.halfPeriod)%2 == 0 {
+		return 0.1
+	}
+	return 0
+}
+
+// TestMixerExpansion checks that an expansion chip is mixed in at its volume, and left out of
+// the stems
+func TestMixerExpansion(t *testing.T) {
+	for _, volume := range []float64{1, 0} {
+		a := Create2A03()
+		a.Mixer = NewMixer(ppu.NTSC, 48000)
+		a.Mixer.EnableStems()
+		a.Mixer.Mute[Triangle] = true
+		a.Mixer.Expansion = &squareChip{halfPeriod: 2000}
+		a.Mixer.ExpansionVolume = volume
+
+		for i := 0; i < 29781; i++ {
+			a.Clock()
+		}
+		a.Mixer.EndFrame()
+
+		peak := float32(0)
+		for _, s := range a.Mixer.Float32() {
+			if s > peak {
+				peak = s
+			}
+		}
+		if volume == 1 && peak < 0.05 {
+			t.Errorf("Expected the expansion chip to be heard, got a peak of %v", peak)
+		}
+		if volume == 0 && peak != 0 {
+			t.Errorf("Expected silence at volume 0, got a peak of %v", peak)
+		}
+		for _, s := range a.Mixer.Stem(Pulse1).Float32() {
+			if s != 0 {
+				t.Fatalf("Expected the expansion chip to be left out of the stems, got %v", s)
+			}
+		}
+	}
+}
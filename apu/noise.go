@@ -0,0 +1,80 @@
+package apu
+
+import "github.com/cbertinato/go-nes/ppu"
+
+// Noise channel
+// -------------
+// The noise channel outputs pseudo-random bits from a 15-bit linear feedback shift register.
+// Each time the timer reaches 0 the register shifts right, and the bit shifted in at the top
+// is bit 0 XOR bit 1, or bit 0 XOR bit 6 in short mode. Short mode repeats after 93 or 31
+// steps instead of 32767, which sounds metallic rather than like noise. The channel is silent
+// while bit 0 is set, and otherwise outputs the envelope volume.
+//
+// The timer period is chosen from a table of 16 periods, which differ between NTSC and PAL.
+// The Dendy uses the NTSC table.
+//
+// Registers, at 0x400C-0x400F:
+//     - --LC VVVV: loop envelope and halt length counter, constant volume, volume or envelope
+//       period
+//     - unused
+//     - M--- PPPP: short mode, period index
+//     - LLLL L---: length counter load. Restarts the envelope.
+
+// Timer periods in CPU cycles
+var (
+	ntscNoisePeriods = [16]uint16{4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068}
+	palNoisePeriods  = [16]uint16{4, 8, 14, 30, 60, 88, 118, 148, 188, 236, 354, 472, 708, 944, 1890, 3778}
+)
+
+type noise struct {
+	short    bool
+	period   uint16
+	timer    uint16
+	shift    uint16 // Linear feedback shift register
+	envelope envelope
+	length   lengthCounter
+}
+
+// write stores a value to one of the channel's four registers. The timer periods depend on
+// the region.
+func (n *noise) write(reg uint16, data uint8, region ppu.Region) {
+	switch reg {
+	case 0:
+		n.length.setHalt(data&0x20 != 0)
+		n.envelope.write(data)
+	case 2:
+		n.short = data&0x80 != 0
+		if region == ppu.PAL {
+			n.period = palNoisePeriods[data&0x0F]
+		} else {
+			n.period = ntscNoisePeriods[data&0x0F]
+		}
+	case 3:
+		n.length.load(data)
+		n.envelope.start = true
+	}
+}
+
+// clockTimer is called every CPU cycle
+func (n *noise) clockTimer() {
+	if n.timer > 0 {
+		n.timer--
+		return
+	}
+	n.timer = n.period - 1
+
+	tap := uint16(1)
+	if n.short {
+		tap = 6
+	}
+	feedback := (n.shift ^ n.shift>>tap) & 0x01
+	n.shift = n.shift>>1 | feedback<<14
+}
+
+// output returns the current level of the channel, 0-15
+func (n *noise) output() uint8 {
+	if !n.length.active() || n.shift&0x01 != 0 {
+		return 0
+	}
+	return n.envelope.volume()
+}
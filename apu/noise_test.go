@@ -0,0 +1,58 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// lfsrPeriod clocks the noise shift register until it returns to its starting value
+func lfsrPeriod(n *noise) int {
+	start := n.shift
+	for i := 1; i <= 1<<15; i++ {
+		for n.timer > 0 {
+			n.clockTimer()
+		}
+		n.clockTimer()
+		if n.shift == start {
+			return i
+		}
+	}
+	return 0
+}
+
+// TestNoiseModes checks the sequence length of both modes of the shift register
+func TestNoiseModes(t *testing.T) {
+	a := Create2A03()
+
+	a.Write(0x400E, 0x00)
+	if p := lfsrPeriod(&a.noise); p != 32767 {
+		t.Errorf("Expected long mode to repeat after 32767 steps, got %d", p)
+	}
+
+	a.Write(0x400E, 0x80)
+	if p := lfsrPeriod(&a.noise); p != 31 && p != 93 {
+		t.Errorf("Expected short mode to repeat after 31 or 93 steps, got %d", p)
+	}
+}
+
+// TestNoisePeriods checks that the period table depends on the region
+func TestNoisePeriods(t *testing.T) {
+	tests := []struct {
+		region ppu.Region
+		period uint16
+	}{
+		{ppu.NTSC, 4068},
+		{ppu.PAL, 3778},
+		{ppu.Dendy, 4068},
+	}
+
+	for _, tt := range tests {
+		a := Create2A03()
+		a.Region = tt.region
+		a.Write(0x400E, 0x0F)
+		if a.noise.period != tt.period {
+			t.Errorf("Region %d: expected period %d, got %d", tt.region, tt.period, a.noise.period)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package apu
+
+// Pulse channels
+// --------------
+// Each pulse channel outputs a square wave with one of four duty cycles. An 11-bit timer
+// clocked every APU cycle divides the clock by its period + 1, and each time it reaches 0 it
+// steps an 8 step sequencer through the waveform of the duty cycle, so the frequency of the
+// wave is CPU clock / (16 * (period + 1)). The volume comes from the envelope generator.
+//
+// The sweep unit changes the period over time for pitch bends. It continuously computes a
+// target period by adding or subtracting the period shifted right by the shift count, and
+// when clocked twice per frame by the frame counter it moves the period to the target. When
+// subtracting, pulse 1 adds the ones' complement of the change and pulse 2 the two's
+// complement, so pulse 1 ends up one lower.
+//
+// The channel is muted, whether or not the sweep is enabled, when:
+//     - the period is below 8, which would be an ultrasonic frequency
+//     - the target period overflows 11 bits
+//     - the length counter has reached 0
+//
+// Registers, at 0x4000-0x4003 for pulse 1 and 0x4004-0x4007 for pulse 2:
+//     - DDLC VVVV: duty, loop envelope and halt length counter, constant volume, volume or
+//       envelope period
+//     - EPPP NSSS: sweep enabled, divider period, negate, shift count
+//     - TTTT TTTT: low 8 bits of the timer period
+//     - LLLL LTTT: length counter load and high 3 bits of the timer period. Restarts the
+//       envelope and the sequencer.
+
+// dutyTable holds the waveforms of the four duty cycles, read from step 0 with the sequencer
+// counting down
+var dutyTable = [4][8]uint8{
+	{0, 1, 0, 0, 0, 0, 0, 0}, // 12.5%
+	{0, 1, 1, 0, 0, 0, 0, 0}, // 25%
+	{0, 1, 1, 1, 1, 0, 0, 0}, // 50%
+	{1, 0, 0, 1, 1, 1, 1, 1}, // 25% negated
+}
+
+type pulse struct {
+	onesComplement bool // The sweep negates with ones' complement (pulse 1)
+
+	duty     uint8
+	step     uint8 // Sequencer position, 0-7
+	period   uint16
+	timer    uint16
+	envelope envelope
+	length   lengthCounter
+
+	sweepEnabled bool
+	sweepPeriod  uint8
+	sweepNegate  bool
+	sweepShift   uint8
+	sweepDivider uint8
+	sweepReload  bool
+}
+
+// write stores a value to one of the channel's four registers
+func (p *pulse) write(reg uint16, data uint8) {
+	switch reg {
+	case 0:
+		p.duty = data >> 6
+		p.length.setHalt(data&0x20 != 0)
+		p.envelope.write(data)
+	case 1:
+		p.sweepEnabled = data&0x80 != 0
+		p.sweepPeriod = (data >> 4) & 0x07
+		p.sweepNegate = data&0x08 != 0
+		p.sweepShift = data & 0x07
+		p.sweepReload = true
+	case 2:
+		p.period = p.period&0x0700 | uint16(data)
+	case 3:
+		p.period = p.period&0x00FF | uint16(data&0x07)<<8
+		p.length.load(data)
+		p.envelope.start = true
+		p.step = 0
+	}
+}
+
+// clockTimer is called every APU cycle
+func (p *pulse) clockTimer() {
+	if p.timer > 0 {
+		p.timer--
+		return
+	}
+	p.timer = p.period
+	p.step = (p.step - 1) & 0x07
+}
+
+// targetPeriod returns the period the sweep unit would move to
+func (p *pulse) targetPeriod() int {
+	period := int(p.period)
+	change := period >> p.sweepShift
+	if !p.sweepNegate {
+		return period + change
+	}
+
+	target := period - change
+	if p.onesComplement {
+		target--
+	}
+	if target < 0 {
+		target = 0
+	}
+	return target
+}
+
+// muted returns true when the period is out of range, whether or not the sweep is enabled
+func (p *pulse) muted() bool {
+	return p.period < 8 || p.targetPeriod() > 0x07FF
+}
+
+// clockSweep is called twice per frame by the frame counter. A shift count of 0 never updates
+// the period, but the channel can still be muted by the target.
+func (p *pulse) clockSweep() {
+	if p.sweepDivider == 0 && p.sweepEnabled && p.sweepShift != 0 && !p.muted() {
+		p.period = uint16(p.targetPeriod())
+	}
+
+	if p.sweepDivider == 0 || p.sweepReload {
+		p.sweepDivider = p.sweepPeriod
+		p.sweepReload = false
+	} else {
+		p.sweepDivider--
+	}
+}
+
+// output returns the current level of the channel, 0-15
+func (p *pulse) output() uint8 {
+	if !p.length.active() || p.muted() || dutyTable[p.duty][p.step] == 0 {
+		return 0
+	}
+	return p.envelope.volume()
+}
@@ -0,0 +1,144 @@
+package apu
+
+import (
+	"testing"
+)
+
+// TestSweepNegate checks that pulse 1 subtracts one more than pulse 2 when negating
+func TestSweepNegate(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusPulse1|StatusPulse2)
+
+	for _, base := range []uint16{0x4000, 0x4004} {
+		a.Write(base+1, 0x89) // enabled, period 0, negate, shift 1
+		a.Write(base+2, 0x00)
+		a.Write(base+3, 0x01) // period 0x100
+	}
+
+	a.halfFrame()
+
+	if a.pulse1.period != 0x7F {
+		t.Errorf("Expected pulse 1 period = %#03x, got %#03x", 0x7F, a.pulse1.period)
+	}
+	if a.pulse2.period != 0x80 {
+		t.Errorf("Expected pulse 2 period = %#03x, got %#03x", 0x80, a.pulse2.period)
+	}
+}
+
+// TestSweepDivider checks that the period is only updated when the sweep divider reaches 0
+func TestSweepDivider(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusPulse2)
+	a.Write(0x4005, 0xA1) // enabled, period 2, shift 1
+	a.Write(0x4006, 0x40)
+	a.Write(0x4007, 0x00) // period 0x40
+
+	expected := []uint16{0x60, 0x60, 0x60, 0x90}
+	for i, e := range expected {
+		a.halfFrame()
+		if a.pulse2.period != e {
+			t.Errorf("Clock %d: expected period = %#03x, got %#03x", i, e, a.pulse2.period)
+		}
+	}
+}
+
+// TestPulseMuting checks that low periods and sweep overflow silence the channel, even with
+// the sweep disabled
+func TestPulseMuting(t *testing.T) {
+	tests := []struct {
+		name   string
+		sweep  uint8
+		period uint16
+		muted  bool
+	}{
+		{"period below 8", 0x00, 7, true},
+		{"period 8", 0x00, 8, false},
+		{"overflow with sweep disabled", 0x01, 0x0600, true},
+		{"no overflow when negating", 0x09, 0x0600, false},
+		{"shift 0 overflows", 0x00, 0x0400, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Create2A03()
+			a.Write(0x4015, StatusPulse1)
+			a.Write(0x4000, 0xBF) // 50% duty, constant volume 15
+			a.Write(0x4001, tt.sweep)
+			a.Write(0x4002, uint8(tt.period))
+			a.Write(0x4003, uint8(tt.period>>8))
+
+			if a.pulse1.muted() != tt.muted {
+				t.Errorf("Expected muted = %v", tt.muted)
+			}
+
+			// the output is 0 for the whole cycle when muted
+			loud := false
+			for i := 0; i < 16*(int(tt.period)+1); i++ {
+				a.Clock()
+				loud = loud || a.pulse1.output() != 0
+			}
+			if loud == tt.muted {
+				t.Errorf("Expected output %v", map[bool]string{true: "silent", false: "audible"}[tt.muted])
+			}
+		})
+	}
+}
+
+// TestDuty checks the waveform of each duty cycle
+func TestDuty(t *testing.T) {
+	expected := [4]int{1, 2, 4, 6}
+
+	for duty, high := range expected {
+		a := Create2A03()
+		a.Write(0x4015, StatusPulse1)
+		a.Write(0x4000, uint8(duty)<<6|0x3F)
+		a.Write(0x4002, 8)
+		a.Write(0x4003, 0x00)
+		a.Clock() // load the length counter, without clocking the timer
+
+		// one sample per sequencer step, every 9 APU cycles
+		count := 0
+		for step := 0; step < 8; step++ {
+			if a.pulse1.output() != 0 {
+				count++
+			}
+			for i := 0; i < 18; i++ {
+				a.Clock()
+			}
+		}
+		if count != high {
+			t.Errorf("Duty %d: expected %d high steps, got %d", duty, high, count)
+		}
+	}
+}
+
+// TestEnvelope checks that the envelope decays one step per period + 1 clocks and loops
+func TestEnvelope(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusPulse1)
+	a.Write(0x4000, 0x21) // loop, period 1
+	a.Write(0x4003, 0x00)
+
+	a.quarterFrame() // start
+	if v := a.pulse1.envelope.volume(); v != 15 {
+		t.Fatalf("Expected volume 15 after restart, got %d", v)
+	}
+
+	for i := 0; i < 30; i++ {
+		a.quarterFrame()
+	}
+	if v := a.pulse1.envelope.volume(); v != 0 {
+		t.Errorf("Expected volume 0, got %d", v)
+	}
+
+	a.quarterFrame()
+	a.quarterFrame()
+	if v := a.pulse1.envelope.volume(); v != 15 {
+		t.Errorf("Expected the envelope to loop to 15, got %d", v)
+	}
+
+	a.Write(0x4000, 0x17) // constant volume 7
+	if v := a.pulse1.envelope.volume(); v != 7 {
+		t.Errorf("Expected constant volume 7, got %d", v)
+	}
+}
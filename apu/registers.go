@@ -0,0 +1,119 @@
+package apu
+
+// CPU-visible registers
+// ---------------------
+// The APU registers are mapped at 0x4000-0x4017:
+//     - 0x4000-0x4003 pulse 1 (write)
+//     - 0x4004-0x4007 pulse 2 (write)
+//     - 0x4008-0x400B triangle (write)
+//     - 0x400C-0x400F noise (write)
+//     - 0x4010-0x4013 DMC (write)
+//     - 0x4015        status (read/write)
+//     - 0x4017        frame counter (write)
+//
+// 0x4014 (OAM DMA), 0x4016 (controller strobe) and reads of 0x4017 (controller 2) share the
+// range but belong to other devices, which are attached to the bus on top of the APU. Every
+// register but 0x4015 is write only and reads back open bus.
+
+const (
+	regPulse1   uint16 = 0x4000
+	regPulse2   uint16 = 0x4004
+	regTriangle uint16 = 0x4008
+	regNoise    uint16 = 0x400C
+	regDMC      uint16 = 0x4010
+	regStatus   uint16 = 0x4015
+	regFrame    uint16 = 0x4017
+)
+
+// Status bits of 0x4015
+const (
+	StatusPulse1   uint8 = 1 << iota // Pulse 1 enabled / length counter active
+	StatusPulse2                     // Pulse 2 enabled / length counter active
+	StatusTriangle                   // Triangle enabled / length counter active
+	StatusNoise                      // Noise enabled / length counter active
+	StatusDMC                        // DMC enabled / sample bytes remaining
+	_
+	StatusFrameIRQ // Frame interrupt (read only)
+	StatusDMCIRQ   // DMC interrupt (read only)
+)
+
+// Read returns the value of the register mapped at a CPU address. A readOnly read returns
+// the same value without the side effects of the access, for use by debuggers.
+func (a *RP2A03) Read(address uint16, readOnly bool) uint8 {
+	if address == regStatus {
+		return a.readStatus(readOnly)
+	}
+	return a.openBus()
+}
+
+// Write stores a value to the register mapped at a CPU address
+func (a *RP2A03) Write(address uint16, data uint8) {
+	switch {
+	case address >= regPulse1 && address < regPulse2:
+		a.pulse1.write(address-regPulse1, data)
+	case address >= regPulse2 && address < regTriangle:
+		a.pulse2.write(address-regPulse2, data)
+	case address >= regTriangle && address < regNoise:
+		a.triangle.write(address-regTriangle, data)
+	case address >= regNoise && address < regDMC:
+		a.noise.write(address-regNoise, data, a.Region)
+	case address >= regDMC && address < regDMC+4:
+		a.dmc.write(address-regDMC, data, a.Region)
+	case address == regStatus:
+		a.writeStatus(data)
+	case address == regFrame:
+		a.writeFrameCounter(data, a.cycle%2 == 1)
+	}
+	a.updateIRQ()
+}
+
+// openBus returns the last value on the CPU data bus
+func (a *RP2A03) openBus() uint8 {
+	if a.CPU == nil {
+		return 0
+	}
+	return a.CPU.DataBus()
+}
+
+// readStatus returns a bit per channel that is set while its length counter is active or, for
+// the DMC, while sample bytes remain, and the interrupt flags. Bit 5 is not driven. Reading
+// clears the frame interrupt flag.
+func (a *RP2A03) readStatus(readOnly bool) uint8 {
+	data := a.openBus() & 0x20
+	if a.pulse1.length.active() {
+		data |= StatusPulse1
+	}
+	if a.pulse2.length.active() {
+		data |= StatusPulse2
+	}
+	if a.triangle.length.active() {
+		data |= StatusTriangle
+	}
+	if a.noise.length.active() {
+		data |= StatusNoise
+	}
+	if a.dmc.active() {
+		data |= StatusDMC
+	}
+	if a.frame.irq {
+		data |= StatusFrameIRQ
+	}
+	if a.dmc.irq {
+		data |= StatusDMCIRQ
+	}
+
+	if !readOnly {
+		a.frame.irq = false
+		a.updateIRQ()
+	}
+	return data
+}
+
+// writeStatus enables and disables the channels and clears the DMC interrupt
+func (a *RP2A03) writeStatus(data uint8) {
+	a.pulse1.length.setEnabled(data&StatusPulse1 != 0)
+	a.pulse2.length.setEnabled(data&StatusPulse2 != 0)
+	a.triangle.length.setEnabled(data&StatusTriangle != 0)
+	a.noise.length.setEnabled(data&StatusNoise != 0)
+	a.dmc.setEnabled(data&StatusDMC != 0, a.CPU)
+}
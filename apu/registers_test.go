@@ -0,0 +1,93 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/cpu"
+)
+
+// testCPU drives a fixed value on the data bus, completes DMC DMAs immediately from its
+// memory and records the level of the IRQ line
+type testCPU struct {
+	data   uint8
+	memory [64 * 1024]uint8
+	reads  []uint16
+	irq    cpu.IRQSource
+}
+
+func (c *testCPU) DataBus() uint8 {
+	return c.data
+}
+
+func (c *testCPU) RequestDMCDMA(address uint16, done func(data uint8)) {
+	c.reads = append(c.reads, address)
+	done(c.memory[address])
+}
+
+func (c *testCPU) SetIRQ(source cpu.IRQSource, asserted bool) {
+	if asserted {
+		c.irq |= source
+	} else {
+		c.irq &^= source
+	}
+}
+
+// TestLengthCounter checks that the length counter is loaded from the table only while the
+// channel is enabled, counts down unless halted and is cleared by disabling the channel
+func TestLengthCounter(t *testing.T) {
+	a := Create2A03()
+
+	a.Write(0x4003, 0x08) // index 1, 254
+	a.Clock()
+	if a.Read(0x4015, false)&StatusPulse1 != 0 {
+		t.Errorf("Length counter loaded while the channel is disabled")
+	}
+
+	a.Write(0x4015, StatusPulse1)
+	a.Write(0x4003, 0x18) // index 3, 2
+	a.Clock()
+	if a.pulse1.length.counter != 2 {
+		t.Fatalf("Expected length 2, got %d", a.pulse1.length.counter)
+	}
+
+	a.Write(0x4000, 0x20) // halt
+	a.Clock()
+	a.halfFrame()
+	if a.pulse1.length.counter != 2 {
+		t.Errorf("Length counter decremented while halted")
+	}
+
+	a.Write(0x4000, 0x00)
+	a.Clock()
+	a.halfFrame()
+	a.halfFrame()
+	if a.Read(0x4015, false)&StatusPulse1 != 0 {
+		t.Errorf("Length counter still active after 2 clocks")
+	}
+
+	a.Write(0x4003, 0x08)
+	a.Clock()
+	a.Write(0x4015, 0x00)
+	if a.pulse1.length.counter != 0 {
+		t.Errorf("Length counter not cleared by disabling the channel")
+	}
+}
+
+// TestStatusRead checks the channel bits of 0x4015 and that the other registers are open bus
+func TestStatusRead(t *testing.T) {
+	c := testCPU{data: 0xFF}
+	a := Create2A03()
+	a.CPU = &c
+
+	a.Write(0x4015, StatusPulse1|StatusPulse2)
+	a.Write(0x4007, 0x00)
+	a.Clock()
+
+	if data := a.Read(0x4015, false); data != 0x20|StatusPulse2 {
+		t.Errorf("Expected status = %#02x, got %#02x", 0x20|StatusPulse2, data)
+	}
+
+	if data := a.Read(0x4000, false); data != 0xFF {
+		t.Errorf("Expected open bus %#02x, got %#02x", 0xFF, data)
+	}
+}
@@ -0,0 +1,86 @@
+package apu
+
+// Triangle channel
+// ----------------
+// The triangle channel steps a 32 step sequencer through a triangle wave of levels 15 down to
+// 0 and back up to 15. Its 11-bit timer is clocked every CPU cycle rather than every APU cycle,
+// so the wave is an octave lower than a pulse wave with the same period: CPU clock /
+// (32 * (period + 1)). It has no volume control.
+//
+// Besides the length counter, a linear counter stops the sequencer when it reaches 0. It is
+// clocked four times per frame and reloaded while its reload flag is set. A write to the last
+// register sets the flag, and it is cleared on the next clock unless the control flag is set.
+//
+// Stopping the sequencer leaves the output at its current level instead of dropping it to 0,
+// which avoids a pop. Games silence the channel with a period of 0 or 1, which on hardware
+// produces an ultrasonic wave that averages to a constant level. The sequencer is held at
+// those periods as well, since stepping it would only add aliasing noise.
+//
+// Registers, at 0x4008-0x400B:
+//     - CRRR RRRR: control (halts the length counter), linear counter reload value
+//     - unused
+//     - TTTT TTTT: low 8 bits of the timer period
+//     - LLLL LTTT: length counter load and high 3 bits of the timer period. Sets the linear
+//       counter reload flag.
+
+type triangle struct {
+	control bool // Halt the length counter and keep reloading the linear counter
+	step    uint8
+	period  uint16
+	timer   uint16
+	length  lengthCounter
+
+	linearReload  uint8
+	linearCounter uint8
+	reloadFlag    bool
+}
+
+// write stores a value to one of the channel's four registers
+func (t *triangle) write(reg uint16, data uint8) {
+	switch reg {
+	case 0:
+		t.control = data&0x80 != 0
+		t.length.setHalt(t.control)
+		t.linearReload = data & 0x7F
+	case 2:
+		t.period = t.period&0x0700 | uint16(data)
+	case 3:
+		t.period = t.period&0x00FF | uint16(data&0x07)<<8
+		t.length.load(data)
+		t.reloadFlag = true
+	}
+}
+
+// clockTimer is called every CPU cycle
+func (t *triangle) clockTimer() {
+	if t.timer > 0 {
+		t.timer--
+		return
+	}
+	t.timer = t.period
+
+	if t.length.active() && t.linearCounter > 0 && t.period >= 2 {
+		t.step = (t.step + 1) & 0x1F
+	}
+}
+
+// clockLinear is called four times per frame by the frame counter
+func (t *triangle) clockLinear() {
+	if t.reloadFlag {
+		t.linearCounter = t.linearReload
+	} else if t.linearCounter > 0 {
+		t.linearCounter--
+	}
+
+	if !t.control {
+		t.reloadFlag = false
+	}
+}
+
+// output returns the current level of the channel, 0-15
+func (t *triangle) output() uint8 {
+	if t.step < 16 {
+		return 15 - t.step
+	}
+	return t.step - 16
+}
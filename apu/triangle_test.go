@@ -0,0 +1,85 @@
+package apu
+
+import (
+	"testing"
+)
+
+// TestTriangleSequence checks the 32 step waveform and that the sequencer only runs while
+// both counters are active
+func TestTriangleSequence(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusTriangle)
+	a.Write(0x4008, 0x10) // linear counter 16
+	a.Write(0x400A, 0x02)
+	a.Write(0x400B, 0x00) // period 2
+
+	if a.triangle.linearCounter != 0 {
+		t.Fatalf("Linear counter loaded before being clocked")
+	}
+
+	// the sequencer does not run until the linear counter is reloaded
+	for i := 0; i < 30; i++ {
+		a.Clock()
+	}
+	if a.triangle.output() != 15 {
+		t.Fatalf("Sequencer ran with the linear counter at 0")
+	}
+
+	a.quarterFrame()
+	var levels []uint8
+	for i := 0; i < 32; i++ {
+		levels = append(levels, a.triangle.output())
+		for j := 0; j < 3; j++ {
+			a.Clock()
+		}
+	}
+
+	for i, l := range levels {
+		expected := uint8(15 - i)
+		if i >= 16 {
+			expected = uint8(i - 16)
+		}
+		if l != expected {
+			t.Errorf("Step %d: expected %d, got %d", i, expected, l)
+		}
+	}
+}
+
+// TestLinearCounter checks the reload flag and the control flag
+func TestLinearCounter(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusTriangle)
+	a.Write(0x4008, 0x03)
+	a.Write(0x400B, 0x00)
+
+	a.quarterFrame() // reload
+	a.quarterFrame()
+	if a.triangle.linearCounter != 2 {
+		t.Errorf("Expected linear counter 2, got %d", a.triangle.linearCounter)
+	}
+
+	a.Write(0x4008, 0x83) // control
+	a.Write(0x400B, 0x00)
+	a.quarterFrame()
+	a.quarterFrame()
+	if a.triangle.linearCounter != 3 {
+		t.Errorf("Expected the linear counter to keep reloading with control set, got %d", a.triangle.linearCounter)
+	}
+}
+
+// TestTriangleUltrasonic checks that the sequencer is held at periods below 2
+func TestTriangleUltrasonic(t *testing.T) {
+	a := Create2A03()
+	a.Write(0x4015, StatusTriangle)
+	a.Write(0x4008, 0x7F)
+	a.Write(0x400A, 0x01)
+	a.Write(0x400B, 0x00)
+	a.quarterFrame()
+
+	for i := 0; i < 100; i++ {
+		a.Clock()
+	}
+	if a.triangle.output() != 15 {
+		t.Errorf("Sequencer ran at an ultrasonic period")
+	}
+}
@@ -0,0 +1,125 @@
+package apu
+
+// Shared units
+// ------------
+// The envelope generator and the length counter are used by several channels.
+//
+// The envelope generator produces a volume that decays from 15 to 0, one step each time its
+// divider reaches 0. The divider is clocked four times per frame, so its period sets the speed
+// of the decay. The envelope is restarted by a write to the channel's last register, and in
+// loop mode it returns to 15 after reaching 0. In constant volume mode the divider period is
+// output as the volume instead.
+//
+// The length counter silences a channel once it has counted down to 0. It is loaded from a
+// table of note lengths by a write to the channel's last register, counts down twice per
+// frame unless halted, and is cleared when the channel is disabled through 0x4015. Writes to
+// the halt flag and reloads take effect after the frame counter has run in the same cycle, so
+// a half frame clock sees the old halt flag, and a reload on a cycle that decrements the
+// counter is ignored.
+
+// lengthTable holds the values loaded into a length counter, indexed by bits 3-7 of the
+// channel's last register
+var lengthTable = [32]uint8{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+type envelope struct {
+	start    bool  // Restart the envelope on the next clock
+	loop     bool  // Return to 15 after reaching 0, shared with the length counter halt
+	constant bool  // Output the period as a constant volume
+	period   uint8 // Divider period, or the volume in constant volume mode
+	divider  uint8
+	decay    uint8 // Decay level, 15 down to 0
+}
+
+// write sets the envelope from the low 6 bits of a channel's first register
+func (e *envelope) write(data uint8) {
+	e.loop = data&0x20 != 0
+	e.constant = data&0x10 != 0
+	e.period = data & 0x0F
+}
+
+// clock is called four times per frame by the frame counter
+func (e *envelope) clock() {
+	if e.start {
+		e.start = false
+		e.decay = 15
+		e.divider = e.period
+		return
+	}
+
+	if e.divider > 0 {
+		e.divider--
+		return
+	}
+	e.divider = e.period
+	if e.decay > 0 {
+		e.decay--
+	} else if e.loop {
+		e.decay = 15
+	}
+}
+
+// volume returns the output of the envelope, 0-15
+func (e *envelope) volume() uint8 {
+	if e.constant {
+		return e.period
+	}
+	return e.decay
+}
+
+type lengthCounter struct {
+	enabled bool // Set through 0x4015, the counter is held at 0 while disabled
+	halt    bool
+	counter uint8
+
+	newHalt bool  // Halt flag written this cycle
+	reload  uint8 // Value written this cycle, 0 if none
+	clocked bool  // Decremented this cycle
+}
+
+// load sets the counter from the table index in bits 3-7 of data, if the channel is enabled,
+// at the end of the cycle
+func (l *lengthCounter) load(data uint8) {
+	if l.enabled {
+		l.reload = lengthTable[data>>3]
+	}
+}
+
+// setHalt sets the halt flag at the end of the cycle
+func (l *lengthCounter) setHalt(halt bool) {
+	l.newHalt = halt
+}
+
+// setEnabled enables or disables the channel. Disabling it clears the counter.
+func (l *lengthCounter) setEnabled(enabled bool) {
+	l.enabled = enabled
+	if !enabled {
+		l.counter = 0
+		l.reload = 0
+	}
+}
+
+// clock is called twice per frame by the frame counter
+func (l *lengthCounter) clock() {
+	if l.counter > 0 && !l.halt {
+		l.counter--
+		l.clocked = true
+	}
+}
+
+// update applies the writes of the cycle, once the frame counter has run
+func (l *lengthCounter) update() {
+	if l.reload != 0 && !l.clocked {
+		l.counter = l.reload
+	}
+	l.halt = l.newHalt
+	l.reload = 0
+	l.clocked = false
+}
+
+// active returns true while the counter has not reached 0
+func (l *lengthCounter) active() bool {
+	return l.counter > 0
+}
@@ -0,0 +1,112 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Reading audio files
+// -------------------
+// ReadWAV reads the samples of a WAV file as floats from -1 to 1, for tapes and other recorded
+// input. It accepts 8, 16, 24 and 32-bit PCM and 32-bit float files. Files with more than one
+// channel are mixed down to mono.
+
+// ReadWAV returns the samples and sample rate of a WAV file
+func ReadWAV(r io.Reader) ([]float32, int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, errors.New("audio: not a WAV file")
+	}
+
+	le := binary.LittleEndian
+	var tag, channels, bits uint16
+	var rate int
+	var haveFormat bool
+	for chunks := data[12:]; len(chunks) >= 8; {
+		id := string(chunks[0:4])
+		size := int(le.Uint32(chunks[4:]))
+		chunks = chunks[8:]
+		if size > len(chunks) {
+			size = len(chunks) // Truncated, or sizes left at 0 by a recorder that did not finish
+		}
+		chunk := chunks[:size]
+		chunks = chunks[min(size+size%2, len(chunks)):]
+
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, 0, errors.New("audio: WAV format chunk too short")
+			}
+			tag = le.Uint16(chunk[0:])
+			channels = le.Uint16(chunk[2:])
+			rate = int(le.Uint32(chunk[4:]))
+			bits = le.Uint16(chunk[14:])
+			if tag == 0xFFFE && len(chunk) >= 26 {
+				tag = le.Uint16(chunk[24:]) // WAVE_FORMAT_EXTENSIBLE sub-format
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, 0, errors.New("audio: WAV data before format")
+			}
+			samples, err := decode(chunk, tag, int(channels), int(bits))
+			return samples, rate, err
+		}
+	}
+	return nil, 0, errors.New("audio: WAV file without data")
+}
+
+// LoadWAV reads a WAV file from disk, see ReadWAV
+func LoadWAV(path string) ([]float32, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	return ReadWAV(f)
+}
+
+// decode converts interleaved samples to mono floats
+func decode(data []byte, tag uint16, channels int, bits int) ([]float32, error) {
+	size := bits / 8
+	if channels == 0 || size == 0 {
+		return nil, errors.New("audio: invalid WAV format")
+	}
+
+	var sample func(b []byte) float32
+	switch {
+	case tag == wavFormatPCM && bits == 8:
+		sample = func(b []byte) float32 { return (float32(b[0]) - 128) / 128 }
+	case tag == wavFormatPCM && bits == 16:
+		sample = func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / 32768 }
+	case tag == wavFormatPCM && bits == 24:
+		sample = func(b []byte) float32 {
+			return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+	case tag == wavFormatPCM && bits == 32:
+		sample = func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	case tag == wavFormatFloat && bits == 32:
+		sample = func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+	default:
+		return nil, fmt.Errorf("audio: unsupported WAV format %d with %d bits", tag, bits)
+	}
+
+	frame := size * channels
+	samples := make([]float32, len(data)/frame)
+	for i := range samples {
+		var sum float32
+		for ch := 0; ch < channels; ch++ {
+			sum += sample(data[i*frame+ch*size:])
+		}
+		samples[i] = sum / float32(channels)
+	}
+	return samples, nil
+}
@@ -0,0 +1,65 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadWAV checks that files written by WAVWriter read back
+func TestReadWAV(t *testing.T) {
+	for _, format := range []Format{Int16, Float32} {
+		path := filepath.Join(t.TempDir(), "out.wav")
+		w, err := CreateWAV(path, 22050, format)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w.WriteSamples([]float32{0, 0.5, -0.5})
+		w.Close()
+
+		samples, rate, err := LoadWAV(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if rate != 22050 || len(samples) != 3 {
+			t.Fatalf("Expected 3 samples at 22050 Hz, got %d at %d Hz", len(samples), rate)
+		}
+		for i, e := range []float32{0, 0.5, -0.5} {
+			if d := samples[i] - e; d > 0.001 || d < -0.001 {
+				t.Errorf("Format %d, sample %d: expected %v, got %v", format, i, e, samples[i])
+			}
+		}
+	}
+}
+
+// TestReadWAVStereo checks that 8-bit stereo files are mixed down to mono
+func TestReadWAVStereo(t *testing.T) {
+	le := binary.LittleEndian
+	var b []byte
+	b = append(b, "RIFF"...)
+	b = le.AppendUint32(b, 0)
+	b = append(b, "WAVEfmt "...)
+	b = le.AppendUint32(b, 16)
+	b = le.AppendUint16(b, wavFormatPCM)
+	b = le.AppendUint16(b, 2)
+	b = le.AppendUint32(b, 8000)
+	b = le.AppendUint32(b, 16000)
+	b = le.AppendUint16(b, 2)
+	b = le.AppendUint16(b, 8)
+	b = append(b, "data"...)
+	b = le.AppendUint32(b, 4)
+	b = append(b, 0xC0, 0xC0, 0x00, 0x80)
+
+	samples, rate, err := ReadWAV(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rate != 8000 || len(samples) != 2 || samples[0] != 0.5 || samples[1] != -0.5 {
+		t.Errorf("Unexpected samples %v at %d Hz", samples, rate)
+	}
+
+	if _, _, err := ReadWAV(bytes.NewReader([]byte("RIFX"))); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
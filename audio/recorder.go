@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"errors"
+
+	"github.com/cbertinato/go-nes/apu"
+)
+
+// Recording
+// ---------
+// A Recorder copies the output of an APU mixer to sinks once per frame: the mix, and
+// optionally each channel as a separate stem. Since it follows emulated frames rather than
+// the host clock, recordings are identical whether the emulator runs in real time or
+// fast-forwards without a display.
+
+// Recorder writes the output of a mixer to files
+type Recorder struct {
+	mixer *apu.Mixer
+	mix   Sink
+	stems [apu.NumChannels]Sink
+}
+
+// NewRecorder returns a recorder of the mixed output to mix and of each channel to the
+// matching entry of stems. Either may be nil, as may any entry of stems. Recording stems
+// enables them on the mixer.
+func NewRecorder(m *apu.Mixer, mix Sink, stems [apu.NumChannels]Sink) *Recorder {
+	for _, s := range stems {
+		if s != nil {
+			m.EnableStems()
+			break
+		}
+	}
+	return &Recorder{mixer: m, mix: mix, stems: stems}
+}
+
+// RecordWAV creates a recorder writing WAV files: the mix to path, and each channel to its own
+// file if stemPaths is not nil. Empty stem paths are skipped.
+func RecordWAV(m *apu.Mixer, path string, stemPaths *[apu.NumChannels]string, format Format) (*Recorder, error) {
+	var sinks []Sink
+	create := func(path string) (Sink, error) {
+		w, err := CreateWAV(path, m.SampleRate(), format)
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, w)
+		return w, nil
+	}
+
+	var mix Sink
+	var stems [apu.NumChannels]Sink
+	var err error
+	if path != "" {
+		if mix, err = create(path); err != nil {
+			return nil, err
+		}
+	}
+	if stemPaths != nil {
+		for ch, p := range stemPaths {
+			if p == "" {
+				continue
+			}
+			if stems[ch], err = create(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return NewRecorder(m, mix, stems), nil
+}
+
+// EndFrame writes the samples of the frame. It must be called after the mixer's EndFrame.
+func (r *Recorder) EndFrame() error {
+	if r.mix != nil {
+		if err := r.mix.WriteSamples(r.mixer.Float32()); err != nil {
+			return err
+		}
+	}
+	for ch, s := range r.stems {
+		if s != nil {
+			if err := s.WriteSamples(r.mixer.Stem(apu.Channel(ch)).Float32()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close completes and closes every file
+func (r *Recorder) Close() error {
+	var err error
+	if r.mix != nil {
+		err = r.mix.Close()
+	}
+	for _, s := range r.stems {
+		if s != nil {
+			err = errors.Join(err, s.Close())
+		}
+	}
+	return err
+}
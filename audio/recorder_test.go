@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbertinato/go-nes/apu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// TestRecordWAV checks that the mix and the requested stems are recorded frame by frame
+func TestRecordWAV(t *testing.T) {
+	dir := t.TempDir()
+	a := apu.Create2A03()
+	a.Mixer = apu.NewMixer(ppu.NTSC, 48000)
+
+	var stems [apu.NumChannels]string
+	stems[apu.Pulse1] = filepath.Join(dir, "pulse1.wav")
+	stems[apu.DMC] = filepath.Join(dir, "dmc.wav")
+
+	r, err := RecordWAV(a.Mixer, filepath.Join(dir, "mix.wav"), &stems, Int16)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	a.Write(0x4015, apu.StatusPulse1)
+	a.Write(0x4000, 0xBF)
+	a.Write(0x4003, 0x01)
+
+	samples := 0
+	for frame := 0; frame < 10; frame++ {
+		for i := 0; i < 29781; i++ {
+			a.Clock()
+		}
+		a.Mixer.EndFrame()
+		samples += len(a.Mixer.Float32())
+		if err := r.EndFrame(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"mix.wav", "pulse1.wav", "dmc.wav"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if info.Size() != int64(44+samples*2) {
+			t.Errorf("%s: expected %d bytes, got %d", name, 44+samples*2, info.Size())
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "triangle.wav")); err == nil {
+		t.Errorf("Unexpected stem recorded")
+	}
+}
@@ -0,0 +1,200 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// Audio files
+// -----------
+// Samples are written as mono PCM, either 16-bit signed integers or 32-bit IEEE floats, in
+// little-endian order:
+//     - WAVWriter writes a WAV file. The sizes in the header are only known once every sample
+//       has been written, so the file must be seekable and closed with Close.
+//     - RawWriter writes the samples alone, for tools that are told the format separately
+
+// Format is the encoding of the samples of a file
+type Format int
+
+const (
+	Int16   Format = iota // 16-bit signed integer
+	Float32               // 32-bit IEEE float
+)
+
+// bytesPerSample returns the size of a sample
+func (f Format) bytesPerSample() int {
+	if f == Float32 {
+		return 4
+	}
+	return 2
+}
+
+// encode appends samples in the format to buf
+func (f Format) encode(buf []byte, samples []float32) []byte {
+	for _, s := range samples {
+		if f == Float32 {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(s))
+			continue
+		}
+		v := math.Max(-1, math.Min(1, float64(s)))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(math.Round(v*math.MaxInt16))))
+	}
+	return buf
+}
+
+// Sink receives the samples of an audio stream
+type Sink interface {
+	WriteSamples(samples []float32) error
+	Close() error
+}
+
+// RawWriter writes samples without a header
+type RawWriter struct {
+	w      io.Writer
+	format Format
+	buf    []byte
+}
+
+// NewRawWriter returns a writer of samples in the given format to w. Close closes w if it is
+// an io.Closer.
+func NewRawWriter(w io.Writer, format Format) *RawWriter {
+	return &RawWriter{w: w, format: format}
+}
+
+// WriteSamples writes samples from -1 to 1
+func (r *RawWriter) WriteSamples(samples []float32) error {
+	r.buf = r.format.encode(r.buf[:0], samples)
+	_, err := r.w.Write(r.buf)
+	return err
+}
+
+// Close closes the underlying writer if it is an io.Closer
+func (r *RawWriter) Close() error {
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// CreateRaw creates a raw PCM file
+func CreateRaw(path string, format Format) (*RawWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRawWriter(f, format), nil
+}
+
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+// WAVWriter writes samples to a WAV file
+type WAVWriter struct {
+	w          io.WriteSeeker
+	format     Format
+	sampleRate int
+	samples    int // Samples written so far
+	buf        []byte
+	err        error
+}
+
+// NewWAVWriter returns a writer of a mono WAV file to w. The header is written immediately
+// and completed by Close, which also closes w if it is an io.Closer.
+func NewWAVWriter(w io.WriteSeeker, sampleRate int, format Format) (*WAVWriter, error) {
+	wav := &WAVWriter{w: w, format: format, sampleRate: sampleRate}
+	if _, err := w.Write(wav.header()); err != nil {
+		return nil, err
+	}
+	return wav, nil
+}
+
+// CreateWAV creates a WAV file
+func CreateWAV(path string, sampleRate int, format Format) (*WAVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	wav, err := NewWAVWriter(f, sampleRate, format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return wav, nil
+}
+
+// header returns the RIFF header and the chunks before the samples. Float files have a fact
+// chunk with the number of samples, as their format requires.
+func (w *WAVWriter) header() []byte {
+	le := binary.LittleEndian
+	size := w.format.bytesPerSample()
+	data := uint32(w.samples * size)
+
+	tag := uint16(wavFormatPCM)
+	if w.format == Float32 {
+		tag = wavFormatFloat
+	}
+
+	fmtChunk := le.AppendUint16(nil, tag)
+	fmtChunk = le.AppendUint16(fmtChunk, 1) // mono
+	fmtChunk = le.AppendUint32(fmtChunk, uint32(w.sampleRate))
+	fmtChunk = le.AppendUint32(fmtChunk, uint32(w.sampleRate*size))
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(size))
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(size*8))
+	if w.format == Float32 {
+		fmtChunk = le.AppendUint16(fmtChunk, 0) // no extension
+	}
+
+	var chunks []byte
+	chunks = append(chunks, "fmt "...)
+	chunks = le.AppendUint32(chunks, uint32(len(fmtChunk)))
+	chunks = append(chunks, fmtChunk...)
+	if w.format == Float32 {
+		chunks = append(chunks, "fact"...)
+		chunks = le.AppendUint32(chunks, 4)
+		chunks = le.AppendUint32(chunks, uint32(w.samples))
+	}
+	chunks = append(chunks, "data"...)
+	chunks = le.AppendUint32(chunks, data)
+
+	var h []byte
+	h = append(h, "RIFF"...)
+	h = le.AppendUint32(h, uint32(4+len(chunks))+data)
+	h = append(h, "WAVE"...)
+	return append(h, chunks...)
+}
+
+// WriteSamples writes samples from -1 to 1
+func (w *WAVWriter) WriteSamples(samples []float32) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.buf = w.format.encode(w.buf[:0], samples)
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.err = err
+		return err
+	}
+	w.samples += len(samples)
+	return nil
+}
+
+// Close completes the header with the number of samples written and closes the underlying
+// writer if it is an io.Closer
+func (w *WAVWriter) Close() error {
+	err := w.err
+	if err == nil {
+		if _, err = w.w.Seek(0, io.SeekStart); err == nil {
+			_, err = w.w.Write(w.header())
+		}
+	}
+
+	if c, ok := w.w.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWAVInt16 checks the header and samples of a 16-bit file
+func TestWAVInt16(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	w, err := CreateWAV(path, 44100, Int16)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w.WriteSamples([]float32{0, 1, -1})
+	w.WriteSamples([]float32{0.5, 2})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != 44+10 {
+		t.Fatalf("Expected %d bytes, got %d", 54, len(data))
+	}
+
+	le := binary.LittleEndian
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" || string(data[36:40]) != "data" {
+		t.Errorf("Invalid chunk IDs")
+	}
+	if size := le.Uint32(data[4:]); size != uint32(len(data)-8) {
+		t.Errorf("Expected RIFF size %d, got %d", len(data)-8, size)
+	}
+	if tag, rate, bits := le.Uint16(data[20:]), le.Uint32(data[24:]), le.Uint16(data[34:]); tag != 1 || rate != 44100 || bits != 16 {
+		t.Errorf("Expected 16-bit PCM at 44100 Hz, got format %d at %d Hz, %d bits", tag, rate, bits)
+	}
+	if size := le.Uint32(data[40:]); size != 10 {
+		t.Errorf("Expected data size 10, got %d", size)
+	}
+
+	expected := []int16{0, 32767, -32767, 16384, 32767}
+	for i, e := range expected {
+		if s := int16(le.Uint16(data[44+i*2:])); s != e {
+			t.Errorf("Sample %d: expected %d, got %d", i, e, s)
+		}
+	}
+}
+
+// TestWAVFloat32 checks the format and fact chunk of a float file
+func TestWAVFloat32(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	w, err := CreateWAV(path, 48000, Float32)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w.WriteSamples([]float32{0.25, -0.75})
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	le := binary.LittleEndian
+	if tag, bits := le.Uint16(data[20:]), le.Uint16(data[34:]); tag != 3 || bits != 32 {
+		t.Errorf("Expected 32-bit float, got format %d, %d bits", tag, bits)
+	}
+
+	fact := bytes.Index(data, []byte("fact"))
+	if fact < 0 || le.Uint32(data[fact+8:]) != 2 {
+		t.Fatalf("Expected a fact chunk with 2 samples")
+	}
+
+	samples := data[len(data)-8:]
+	for i, e := range []float32{0.25, -0.75} {
+		if s := math.Float32frombits(le.Uint32(samples[i*4:])); s != e {
+			t.Errorf("Sample %d: expected %v, got %v", i, e, s)
+		}
+	}
+}
+
+// TestRaw checks that raw files hold only the samples
+func TestRaw(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRawWriter(&buf, Int16)
+	w.WriteSamples([]float32{1, -0.5})
+
+	if !bytes.Equal(buf.Bytes(), []byte{0xFF, 0x7F, 0x00, 0xC0}) {
+		t.Errorf("Unexpected raw data % x", buf.Bytes())
+	}
+}
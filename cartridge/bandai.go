@@ -0,0 +1,182 @@
+package cartridge
+
+import (
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Bandai FCG
+// ----------
+// Bandai's FCG-1 and FCG-2 chips and their successor, the LZ93D50, have 16 registers selected
+// by the low 4 bits of the address:
+//     - 0-7: the 1k CHR banks. On mapper 153, bit 0 selects the 256k half of PRG ROM instead.
+//     - 8: the 16k PRG bank at 0x8000, 0xC000 being the last bank
+//     - 9: mirroring, vertical, horizontal or one of the single screens
+//     - A: IRQ control, bit 0 enabling the counter. A write acknowledges the IRQ, and on the
+//       LZ93D50 copies the latch to the counter.
+//     - B-C: the low and high bytes of the IRQ counter on the FCG, or of its latch on the
+//       LZ93D50
+//     - D: the EEPROM lines, SCL in bit 5, SDA in bit 6, and bit 7 set to read. Mapper 153
+//       has PRG RAM instead, enabled by bit 5.
+//
+// The FCG's registers are at 0x6000-0x7FFF and the LZ93D50's at 0x8000-0xFFFF. Mapper 16 covers
+// both, submapper 4 being the FCG and submapper 5 the LZ93D50, and submapper 0 decodes both
+// ranges, each acting as its chip. Mapper 159 is the LZ93D50 with an X24C01, and mapper 16 has a
+// 24C02 unless it is an FCG or a NES 2.0 header declares no EEPROM. Reading 0x6000-0x7FFF gives
+// the EEPROM's SDA in bit 4.
+//
+// The 16-bit IRQ counter counts down every CPU cycle while enabled, and raises the IRQ when it
+// reaches 0.
+
+// Bandai is a Bandai FCG or LZ93D50 cartridge
+type Bandai struct {
+	*board
+	eeprom *eeprom
+	fcg    bool // Registers at 0x6000-0x7FFF
+	lz93   bool // Registers at 0x8000-0xFFFF
+
+	chrBanks   [8]uint8
+	prgBank    uint8
+	ramEnabled bool
+	irqEnabled bool
+	counter    uint16
+	latch      uint16
+}
+
+func newBandai(b *board) *Bandai {
+	f := &Bandai{board: b, fcg: true, lz93: true}
+	switch {
+	case b.header.Mapper == 153:
+		f.fcg = false
+	case b.header.Mapper == 159:
+		f.fcg = false
+		f.eeprom = newX24C01()
+	case b.header.Submapper == 4:
+		f.lz93 = false
+	case b.header.Submapper == 5:
+		f.fcg = false
+		fallthrough
+	default:
+		if !b.header.NES2 || b.header.PRGNVRAM > 0 {
+			f.eeprom = new24C02()
+		}
+	}
+	return f
+}
+
+// SaveRAM returns the EEPROM, or PRG RAM on mapper 153, see nes.SaveRAM
+func (f *Bandai) SaveRAM() []uint8 {
+	if f.eeprom != nil {
+		return f.eeprom.data
+	}
+	return f.board.SaveRAM()
+}
+
+func (f *Bandai) PRG() cpu.Bus { return prgBus{f} }
+func (f *Bandai) CHR() ppu.Bus { return chrBus{f} }
+
+func (f *Bandai) Clock() {
+	if f.irqEnabled {
+		f.counter--
+		if f.counter == 0 {
+			f.setIRQ(true)
+		}
+	}
+}
+
+func (f *Bandai) readPRG(address uint16, readOnly bool) uint8 {
+	switch {
+	case address >= 0xC000:
+		return f.prgROM[f.prgOffset(-1)+int(address&0x3FFF)]
+	case address >= 0x8000:
+		return f.prgROM[f.prgOffset(int(f.prgBank))+int(address&0x3FFF)]
+	case address >= 0x6000 && f.header.Mapper == 153:
+		if f.ramEnabled {
+			return f.readRAM(address)
+		}
+	case address >= 0x6000 && f.eeprom != nil:
+		return f.openBus()&^0x10 | boolBit(f.eeprom.read(), 4)
+	}
+	return f.openBus()
+}
+
+// prgOffset returns the offset of a 16k PRG bank, within the 256k half of PRG ROM selected by
+// the CHR registers on mapper 153
+func (f *Bandai) prgOffset(number int) int {
+	if f.header.Mapper != 153 {
+		return bank(len(f.prgROM), number, 0x4000)
+	}
+	outer := bank(len(f.prgROM), int(f.chrBanks[0]&0x01), 0x40000)
+	return outer + bank(min(len(f.prgROM), 0x40000), number, 0x4000)
+}
+
+func (f *Bandai) writePRG(address uint16, data uint8) {
+	switch {
+	case address >= 0x8000 && f.lz93:
+		f.writeRegister(address, data, false)
+	case address >= 0x6000 && address < 0x8000 && f.fcg:
+		f.writeRegister(address, data, true)
+	case address >= 0x6000 && address < 0x8000 && f.ramEnabled:
+		f.writeRAM(address, data)
+	}
+}
+
+// writeRegister writes a register, as the FCG if fcg is set, or the LZ93D50
+func (f *Bandai) writeRegister(address uint16, data uint8, fcg bool) {
+	switch r := address & 0x0F; {
+	case r < 8:
+		f.chrBanks[r] = data
+		if f.header.Mapper == 153 {
+			// the outer bank bit is shared by all the registers
+			for i := range f.chrBanks {
+				f.chrBanks[i] = f.chrBanks[i]&^0x01 | data&0x01
+			}
+		}
+	case r == 0x08:
+		f.prgBank = data & 0x0F
+	case r == 0x09:
+		f.setMirroring([]ppu.Mirroring{ppu.Vertical, ppu.Horizontal, ppu.SingleScreenA, ppu.SingleScreenB}[data&0x03])
+	case r == 0x0A:
+		f.irqEnabled = data&0x01 != 0
+		if !fcg {
+			f.counter = f.latch
+		}
+		f.setIRQ(false)
+	case r == 0x0B:
+		f.latch = f.latch&0xFF00 | uint16(data)
+		if fcg {
+			f.counter = f.counter&0xFF00 | uint16(data)
+		}
+	case r == 0x0C:
+		f.latch = f.latch&0x00FF | uint16(data)<<8
+		if fcg {
+			f.counter = f.counter&0x00FF | uint16(data)<<8
+		}
+	case r == 0x0D:
+		switch {
+		case f.header.Mapper == 153:
+			f.ramEnabled = data&0x20 != 0
+		case f.eeprom != nil:
+			// SDA is released while reading
+			f.eeprom.write(data&0x20 != 0, data&0xC0 != 0)
+		}
+	}
+}
+
+// chrOffset returns the offset in CHR of a PPU address. Mapper 153 has 8k of CHR RAM, not banked.
+func (f *Bandai) chrOffset(address uint16) int {
+	if f.header.Mapper == 153 {
+		return int(address&0x1FFF) % len(f.chr)
+	}
+	return bank(len(f.chr), int(f.chrBanks[address>>10&0x07]), 0x400) + int(address&0x03FF)
+}
+
+func (f *Bandai) readCHR(address uint16, readOnly bool) uint8 {
+	return f.chr[f.chrOffset(address)]
+}
+
+func (f *Bandai) writeCHR(address uint16, data uint8) {
+	if f.chrRAM {
+		f.chr[f.chrOffset(address)] = data
+	}
+}
@@ -0,0 +1,131 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// newBandaiConsole returns a console with a Bandai cartridge of 256k PRG ROM and 128k CHR ROM
+func newBandaiConsole(t *testing.T, header ...uint8) *nes.Console {
+	data := inesFile(t, header...)
+	markBanks(t, data, 0x4000, 0x400)
+	irqProgram(t, data)
+	cart, err := Load(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return nes.NewConsole(cart, ppu.Model2C02, 0)
+}
+
+// TestBandai checks the banks and mirroring through both register ranges of submapper 0
+func TestBandai(t *testing.T) {
+	c := newBandaiConsole(t, 16, 16, 0x00, 0x10, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	c.Bus.Write(0x8008, 0x03)
+	if data := c.Bus.Read(0x8010, true); data != 3 {
+		t.Errorf("Expected bank 3 at 0x8000, got %d", data)
+	}
+	if data := c.Bus.Read(0xC010, true); data != 15 {
+		t.Errorf("Expected the last bank at 0xC000, got %d", data)
+	}
+	c.Bus.Write(0x6018, 0x05)
+	if data := c.Bus.Read(0x8010, true); data != 5 {
+		t.Errorf("Expected the FCG registers at 0x6000, got bank %d", data)
+	}
+
+	c.Bus.Write(0x8007, 0x2A)
+	if data := c.PPUBus.Read(0x1C10, true); data != 0x2A {
+		t.Errorf("Expected CHR bank 0x2A at 0x1C00, got %#02x", data)
+	}
+
+	c.Bus.Write(0x8009, 0x03)
+	if c.PPUBus.Mirroring() != ppu.SingleScreenB {
+		t.Errorf("Expected single screen mirroring, got %v", c.PPUBus.Mirroring())
+	}
+}
+
+// TestBandaiIRQ checks the counter of the LZ93D50, loaded from its latch, and of the FCG,
+// written directly
+func TestBandaiIRQ(t *testing.T) {
+	c := newBandaiConsole(t, 16, 16, 0x00, 0x10, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	c.Bus.Write(0x800B, 150)
+	c.Bus.Write(0x800C, 0)
+	if n := runUntilIRQ(t, c, 1000); n >= 0 {
+		t.Fatalf("Expected no IRQ before enabled, got one after %d cycles", n)
+	}
+	c.Bus.Write(0x800A, 0x01)
+	if n := runUntilIRQ(t, c, 1000); n < 150 || n > 170 {
+		t.Errorf("Expected an IRQ about 150 cycles later, got %d", n)
+	}
+
+	c = newBandaiConsole(t, 16, 16, 0x00, 0x10, 0, 0, 0, 0, 0, 0, 0, 0)
+	c.Bus.Write(0x600B, 100)
+	c.Bus.Write(0x600C, 0)
+	c.Bus.Write(0x600A, 0x01)
+	if n := runUntilIRQ(t, c, 1000); n < 100 || n > 120 {
+		t.Errorf("Expected an IRQ about 100 cycles later, got %d", n)
+	}
+}
+
+// TestBandaiEEPROM checks that the EEPROM is driven through register D and read at 0x6000
+func TestBandaiEEPROM(t *testing.T) {
+	c := newBandaiConsole(t, 16, 16, 0x02, 0x10, 0, 0, 0, 0, 0, 0, 0, 0)
+	b := i2c{
+		lines: func(scl bool, sda bool) {
+			c.Bus.Write(0x800D, boolBit(scl, 5)|boolBit(sda, 6)|boolBit(sda, 7))
+		},
+		sda: func() bool { return c.Bus.Read(0x6000, false)&0x10 != 0 },
+	}
+
+	b.start()
+	b.send(0xA0)
+	b.send(0x10)
+	b.send(0x42)
+	b.stop()
+
+	b.start()
+	b.send(0xA0)
+	b.send(0x10)
+	b.start()
+	b.send(0xA1)
+	if data := b.receive(false); data != 0x42 {
+		t.Errorf("Expected 0x42 from the EEPROM, got %#02x", data)
+	}
+	b.stop()
+
+	if ram := c.Cartridge.(nes.SaveRAM).SaveRAM(); len(ram) != 256 || ram[0x10] != 0x42 {
+		t.Errorf("Expected the EEPROM to be saved")
+	}
+}
+
+// TestBandai153 checks the outer PRG bank and PRG RAM of mapper 153
+func TestBandai153(t *testing.T) {
+	// 512k PRG ROM, CHR RAM
+	c := newBandaiConsole(t, 32, 0, 0x92, 0x90, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	c.Bus.Write(0x8008, 0x02)
+	c.Bus.Write(0x8000, 0x01)
+	if data := c.Bus.Read(0x8010, true); data != 18 {
+		t.Errorf("Expected bank 2 of the second 256k at 0x8000, got %d", data)
+	}
+	if data := c.Bus.Read(0xC010, true); data != 31 {
+		t.Errorf("Expected the last bank of the second 256k at 0xC000, got %d", data)
+	}
+	c.Bus.Write(0x8000, 0x00)
+	if data := c.Bus.Read(0xC010, true); data != 15 {
+		t.Errorf("Expected the last bank of the first 256k at 0xC000, got %d", data)
+	}
+
+	c.Bus.Write(0x6000, 0xAB)
+	if data := c.Bus.Read(0x6000, true); data == 0xAB {
+		t.Errorf("Expected PRG RAM to be disabled")
+	}
+	c.Bus.Write(0x800D, 0x20)
+	c.Bus.Write(0x6000, 0xAB)
+	if data := c.Bus.Read(0x6000, true); data != 0xAB {
+		t.Errorf("Expected PRG RAM, got %#02x", data)
+	}
+}
@@ -0,0 +1,175 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Cartridges
+// ----------
+// Load builds a cartridge from a .nes file, choosing the board by the mapper number in its
+// header. Every board implements nes.Cartridge and nes.Mapper. The boards share a board struct
+// holding their memories and the slot they are connected to, and implement the memory
+// interface for their side of the connector, which PRG and CHR expose as a cpu.Bus and a
+// ppu.Bus.
+//
+// Banks are numbered from the start of a memory and wrap around its size, so that bank
+// registers with more bits than a board has memory behave as mirrors, as on the hardware.
+
+// Load returns the cartridge of a .nes file
+func Load(data []uint8) (nes.Cartridge, error) {
+	h, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	prg, chr, err := h.split(data)
+	if err != nil {
+		return nil, err
+	}
+	b := newBoard(h, prg, chr)
+	if h.Trainer {
+		copy(b.prgRAM[0x1000:], data[headerSize:headerSize+trainerSize])
+	}
+
+	switch h.Mapper {
+	case 0:
+		return newNROM(b), nil
+	case 16, 153, 159:
+		return newBandai(b), nil
+	case 19:
+		return newN163(b), nil
+	case 69:
+		return newFME7(b), nil
+	}
+	return nil, fmt.Errorf("cartridge: mapper %d is not supported", h.Mapper)
+}
+
+// memory is a board's side of the cartridge connector
+type memory interface {
+	readPRG(address uint16, readOnly bool) uint8
+	writePRG(address uint16, data uint8)
+	readCHR(address uint16, readOnly bool) uint8
+	writeCHR(address uint16, data uint8)
+}
+
+// prgBus is the CPU side of a board
+type prgBus struct {
+	m memory
+}
+
+func (b prgBus) Read(address uint16, readOnly bool) uint8 { return b.m.readPRG(address, readOnly) }
+func (b prgBus) Write(address uint16, data uint8)         { b.m.writePRG(address, data) }
+
+// chrBus is the PPU side of a board
+type chrBus struct {
+	m memory
+}
+
+func (b chrBus) Read(address uint16, readOnly bool) uint8 { return b.m.readCHR(address, readOnly) }
+func (b chrBus) Write(address uint16, data uint8)         { b.m.writeCHR(address, data) }
+
+// board holds the memories of a cartridge and the slot it is connected to, and implements the
+// parts of nes.Mapper that most boards do not need
+type board struct {
+	header    Header
+	prgROM    []uint8
+	prgRAM    []uint8 // Volatile and battery-backed PRG RAM, at 0x6000-0x7FFF on most boards
+	chr       []uint8 // CHR ROM, or CHR RAM if chrRAM
+	chrRAM    bool
+	mirroring ppu.Mirroring
+	slot      nes.Slot
+}
+
+// newBoard returns the board described by a header. CHR RAM is allocated if there is no CHR ROM.
+func newBoard(h Header, prg []uint8, chr []uint8) *board {
+	b := &board{
+		header:    h,
+		prgROM:    prg,
+		prgRAM:    make([]uint8, h.PRGRAM+h.PRGNVRAM),
+		chr:       chr,
+		mirroring: h.Mirroring,
+	}
+	if len(chr) == 0 {
+		b.chr = make([]uint8, max(h.CHRRAM+h.CHRNVRAM, 8*1024))
+		b.chrRAM = true
+	}
+	if h.Trainer && len(b.prgRAM) < 0x2000 {
+		b.prgRAM = append(b.prgRAM, make([]uint8, 0x2000-len(b.prgRAM))...)
+	}
+	return b
+}
+
+func (b *board) Mirroring() ppu.Mirroring {
+	return b.mirroring
+}
+
+func (b *board) Connect(slot nes.Slot) {
+	b.slot = slot
+}
+
+// SaveRAM returns PRG RAM if the cartridge has a battery, see nes.SaveRAM
+func (b *board) SaveRAM() []uint8 {
+	if b.header.PRGNVRAM == 0 {
+		return nil
+	}
+	return b.prgRAM
+}
+
+func (b *board) Reset()                    {}
+func (b *board) Clock()                    {}
+func (b *board) PPUAddress(address uint16) {}
+
+// openBus returns the last value on the CPU data bus, for addresses the board does not drive
+func (b *board) openBus() uint8 {
+	if b.slot.CPU == nil {
+		return 0
+	}
+	return b.slot.CPU.DataBus()
+}
+
+// setIRQ drives the CPU's IRQ input
+func (b *board) setIRQ(asserted bool) {
+	if b.slot.CPU != nil {
+		b.slot.CPU.SetIRQ(cpu.IRQMapper, asserted)
+	}
+}
+
+// setMirroring switches the nametable mirroring
+func (b *board) setMirroring(m ppu.Mirroring) {
+	b.mirroring = m
+	if b.slot.PPUBus != nil {
+		b.slot.PPUBus.SetMirroring(m)
+	}
+}
+
+// bank returns the offset in a memory of size bytes of a bank of bankSize bytes. Negative
+// numbers count from the end, -1 being the last bank.
+func bank(size int, number int, bankSize int) int {
+	banks := size / bankSize
+	if banks == 0 {
+		return 0
+	}
+	number %= banks
+	if number < 0 {
+		number += banks
+	}
+	return number * bankSize
+}
+
+// readRAM reads PRG RAM at 0x6000-0x7FFF, mirrored if smaller, or open bus without RAM
+func (b *board) readRAM(address uint16) uint8 {
+	if len(b.prgRAM) == 0 {
+		return b.openBus()
+	}
+	return b.prgRAM[int(address-0x6000)%len(b.prgRAM)]
+}
+
+// writeRAM writes PRG RAM at 0x6000-0x7FFF
+func (b *board) writeRAM(address uint16, data uint8) {
+	if len(b.prgRAM) > 0 {
+		b.prgRAM[int(address-0x6000)%len(b.prgRAM)] = data
+	}
+}
@@ -0,0 +1,342 @@
+package cartridge
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Header database
+// ---------------
+// Many .nes files in circulation have a wrong mapper, mirroring or RAM size in their header,
+// from dumps made before those were understood. The NES 2.0 XML database describes the board of
+// every known dump, keyed by the CRC32 and SHA-1 of its ROM, which is PRG ROM followed by CHR
+// ROM. Each game is an element like:
+//
+//     <game>
+//       <!-- name -->
+//       <prgrom size="131072" crc32="..." sha1="..."/>
+//       <chrrom size="131072" crc32="..." sha1="..."/>
+//       <rom size="262144" crc32="..." sha1="..."/>
+//       <prgnvram size="8192"/>
+//       <pcb mapper="4" submapper="0" mirroring="H" battery="1"/>
+//       <console type="0" region="0"/>
+//     </game>
+//
+// The database is read from a file, so that it can be updated without rebuilding. CorrectHeader
+// looks a file up by its ROM and rewrites its header as NES 2.0 from the database, reporting the
+// fields it changed, before the file is loaded.
+
+// Game is the entry of a dump in the header database
+type Game struct {
+	Name       string
+	Header     Header
+	Console    int // NES 2.0 console type: 0 NES, 1 Vs. System, 2 PlayChoice-10, 3 and up extended
+	Region     int // NES 2.0 timing: 0 NTSC, 1 PAL, 2 multi-region, 3 Dendy
+	VsHardware int
+	VsPPU      int
+	MiscROMs   int
+	Expansion  int // Default expansion device
+}
+
+// Database is the NES 2.0 header database, indexed by the CRC32 and SHA-1 of ROMs
+type Database struct {
+	Games []*Game
+	crc32 map[uint32]*Game
+	sha1  map[[sha1.Size]uint8]*Game
+}
+
+// xmlGame is a game element of the database, of which only the attributes used are decoded
+type xmlGame struct {
+	Name     string  `xml:",comment"`
+	ROM      xmlROM  `xml:"rom"`
+	PRGROM   xmlROM  `xml:"prgrom"`
+	CHRROM   xmlROM  `xml:"chrrom"`
+	PRGRAM   xmlSize `xml:"prgram"`
+	PRGNVRAM xmlSize `xml:"prgnvram"`
+	CHRRAM   xmlSize `xml:"chrram"`
+	CHRNVRAM xmlSize `xml:"chrnvram"`
+	PCB      struct {
+		Mapper    int    `xml:"mapper,attr"`
+		Submapper int    `xml:"submapper,attr"`
+		Mirroring string `xml:"mirroring,attr"`
+		Battery   int    `xml:"battery,attr"`
+	} `xml:"pcb"`
+	Console struct {
+		Type   int `xml:"type,attr"`
+		Region int `xml:"region,attr"`
+	} `xml:"console"`
+	Vs struct {
+		Hardware int `xml:"hardware,attr"`
+		PPU      int `xml:"ppu,attr"`
+	} `xml:"vs"`
+	MiscROM struct {
+		Number int `xml:"number,attr"`
+	} `xml:"miscrom"`
+	Expansion struct {
+		Type int `xml:"type,attr"`
+	} `xml:"expansion"`
+}
+
+type xmlROM struct {
+	Size  int    `xml:"size,attr"`
+	CRC32 string `xml:"crc32,attr"`
+	SHA1  string `xml:"sha1,attr"`
+}
+
+type xmlSize struct {
+	Size int `xml:"size,attr"`
+}
+
+// databaseMirroring maps the mirroring attribute of the database
+var databaseMirroring = map[string]ppu.Mirroring{
+	"H": ppu.Horizontal,
+	"V": ppu.Vertical,
+	"4": ppu.FourScreen,
+}
+
+// ParseDatabase parses the NES 2.0 XML database
+func ParseDatabase(data []uint8) (*Database, error) {
+	var doc struct {
+		Games []xmlGame `xml:"game"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("cartridge: %w in database", err)
+	}
+
+	d := &Database{crc32: map[uint32]*Game{}, sha1: map[[sha1.Size]uint8]*Game{}}
+	for i, g := range doc.Games {
+		mirroring, ok := databaseMirroring[g.PCB.Mirroring]
+		if !ok {
+			return nil, fmt.Errorf("cartridge: game %d of the database has mirroring %q", i+1, g.PCB.Mirroring)
+		}
+		game := &Game{
+			Name: strings.TrimSpace(g.Name),
+			Header: Header{
+				Mapper:    g.PCB.Mapper,
+				Submapper: g.PCB.Submapper,
+				PRGROM:    g.PRGROM.Size,
+				CHRROM:    g.CHRROM.Size,
+				PRGRAM:    g.PRGRAM.Size,
+				PRGNVRAM:  g.PRGNVRAM.Size,
+				CHRRAM:    g.CHRRAM.Size,
+				CHRNVRAM:  g.CHRNVRAM.Size,
+				Mirroring: mirroring,
+				Battery:   g.PCB.Battery != 0,
+				NES2:      true,
+			},
+			Console:    g.Console.Type,
+			Region:     g.Console.Region,
+			VsHardware: g.Vs.Hardware,
+			VsPPU:      g.Vs.PPU,
+			MiscROMs:   g.MiscROM.Number,
+			Expansion:  g.Expansion.Type,
+		}
+
+		crc, err := strconv.ParseUint(g.ROM.CRC32, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("cartridge: game %d of the database has CRC32 %q", i+1, g.ROM.CRC32)
+		}
+		var sum [sha1.Size]uint8
+		if n, err := hex.Decode(sum[:], []uint8(g.ROM.SHA1)); err != nil || n != len(sum) {
+			return nil, fmt.Errorf("cartridge: game %d of the database has SHA-1 %q", i+1, g.ROM.SHA1)
+		}
+		d.Games = append(d.Games, game)
+		d.crc32[uint32(crc)] = game
+		d.sha1[sum] = game
+	}
+	return d, nil
+}
+
+// ReadDatabase reads the NES 2.0 XML database from a file
+func ReadDatabase(path string) (*Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ParseDatabase(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+	return d, nil
+}
+
+// Lookup returns the game whose ROM, PRG ROM followed by CHR ROM, has the same SHA-1, or else the
+// same CRC32, or nil if there is none
+func (d *Database) Lookup(rom []uint8) *Game {
+	if g := d.sha1[sha1.Sum(rom)]; g != nil {
+		return g
+	}
+	return d.crc32[crc32.ChecksumIEEE(rom)]
+}
+
+// find returns the game of a .nes file, looking up everything after the header and trainer, or
+// the ROM sizes given by the header if the file has more than that
+func (d *Database) find(data []uint8) *Game {
+	h, err := ParseHeader(data)
+	if err != nil {
+		return nil
+	}
+	start := headerSize
+	if h.Trainer {
+		start += trainerSize
+	}
+	if start > len(data) {
+		return nil
+	}
+	if g := d.Lookup(data[start:]); g != nil {
+		return g
+	}
+	if prg, chr, err := h.split(data); err == nil && len(prg)+len(chr) < len(data)-start {
+		return d.Lookup(data[start : start+len(prg)+len(chr)])
+	}
+	return nil
+}
+
+// Correction is a header field that the database corrected
+type Correction struct {
+	Field string
+	From  string
+	To    string
+}
+
+func (c Correction) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, c.From, c.To)
+}
+
+// CorrectHeader returns the 16 byte header of a .nes file rewritten as NES 2.0 from its entry in
+// the database, and the fields that changed. The header is returned unchanged if the file is not
+// in the database. The trainer flag is kept, as it decides where the ROM is in the file.
+func (d *Database) CorrectHeader(data []uint8) ([]uint8, []Correction, error) {
+	old, err := ParseHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := append([]uint8(nil), data[:headerSize]...)
+	game := d.find(data)
+	if game == nil {
+		return header, nil, nil
+	}
+	if err := game.encode(header); err != nil {
+		return nil, nil, err
+	}
+
+	h, err := ParseHeader(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	var corrections []Correction
+	field := func(name string, from, to any) {
+		if f, t := fmt.Sprint(from), fmt.Sprint(to); f != t {
+			corrections = append(corrections, Correction{name, f, t})
+		}
+	}
+	field("mapper", old.Mapper, h.Mapper)
+	field("submapper", old.Submapper, h.Submapper)
+	field("PRG ROM", old.PRGROM, h.PRGROM)
+	field("CHR ROM", old.CHRROM, h.CHRROM)
+	field("PRG RAM", old.PRGRAM, h.PRGRAM)
+	field("PRG NVRAM", old.PRGNVRAM, h.PRGNVRAM)
+	field("CHR RAM", old.CHRRAM, h.CHRRAM)
+	field("CHR NVRAM", old.CHRNVRAM, h.CHRNVRAM)
+	field("mirroring", mirroringNames[old.Mirroring], mirroringNames[h.Mirroring])
+	field("battery", old.Battery, h.Battery)
+	field("console", consoleNames[data[7]&0x03], consoleNames[header[7]&0x03])
+	field("timing", timingNames[oldTiming(data)], timingNames[header[12]&0x03])
+	return header, corrections, nil
+}
+
+var (
+	mirroringNames = map[ppu.Mirroring]string{
+		ppu.Horizontal: "horizontal",
+		ppu.Vertical:   "vertical",
+		ppu.FourScreen: "four-screen",
+	}
+	consoleNames = []string{"NES", "Vs. System", "PlayChoice-10", "extended"}
+	timingNames  = []string{"NTSC", "PAL", "multi-region", "Dendy"}
+)
+
+// oldTiming returns the NES 2.0 timing of a header, which iNES 1.0 headers can only flag as PAL
+func oldTiming(header []uint8) uint8 {
+	if header[7]&0x0C == 0x08 {
+		return header[12] & 0x03
+	}
+	return header[9] & 0x01
+}
+
+// encode writes a game into a NES 2.0 header, keeping the magic and the trainer flag
+func (g *Game) encode(header []uint8) error {
+	h := g.Header
+	prgLo, prgHi, err := encodeROMSize(h.PRGROM, 16*1024)
+	if err != nil {
+		return err
+	}
+	chrLo, chrHi, err := encodeROMSize(h.CHRROM, 8*1024)
+	if err != nil {
+		return err
+	}
+
+	header[4], header[5] = prgLo, chrLo
+	header[6] = header[6]&0x04 | uint8(h.Mapper&0x0F)<<4
+	switch h.Mirroring {
+	case ppu.Vertical:
+		header[6] |= 0x01
+	case ppu.FourScreen:
+		header[6] |= 0x08
+	}
+	if h.Battery {
+		header[6] |= 0x02
+	}
+	header[7] = uint8(h.Mapper&0xF0) | 0x08 | uint8(min(g.Console, 3))
+	header[8] = uint8(h.Submapper)<<4 | uint8(h.Mapper>>8&0x0F)
+	header[9] = chrHi<<4 | prgHi
+	header[10] = encodeRAMSize(h.PRGNVRAM)<<4 | encodeRAMSize(h.PRGRAM)
+	header[11] = encodeRAMSize(h.CHRNVRAM)<<4 | encodeRAMSize(h.CHRRAM)
+	header[12] = uint8(g.Region & 0x03)
+	switch {
+	case g.Console == 1:
+		header[13] = uint8(g.VsHardware&0x0F)<<4 | uint8(g.VsPPU&0x0F)
+	case g.Console >= 3:
+		header[13] = uint8(g.Console & 0x0F)
+	default:
+		header[13] = 0
+	}
+	header[14] = uint8(g.MiscROMs & 0x03)
+	header[15] = uint8(g.Expansion & 0x3F)
+	return nil
+}
+
+// encodeROMSize returns the size byte and high nibble of a NES 2.0 ROM size, in units if it can,
+// or else as an exponent and multiplier
+func encodeROMSize(size int, unit int) (lo uint8, hi uint8, err error) {
+	if n := size / unit; size%unit == 0 && n < 0xF00 {
+		return uint8(n), uint8(n >> 8), nil
+	}
+	for e := 0; e < 64; e++ {
+		for m := 0; m < 4; m++ {
+			if 1<<e*(m*2+1) == size {
+				return uint8(e<<2 | m), 0x0F, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("cartridge: ROM size of %d bytes does not fit a NES 2.0 header", size)
+}
+
+// encodeRAMSize returns the NES 2.0 shift count of a RAM size, rounded up
+func encodeRAMSize(size int) uint8 {
+	if size == 0 {
+		return 0
+	}
+	shift := uint8(1)
+	for 64<<shift < size && shift < 0x0F {
+		shift++
+	}
+	return shift
+}
@@ -0,0 +1,113 @@
+package cartridge
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// databaseXML returns a database with a game for the ROM of a .nes file: MMC3 with 8k of
+// battery-backed PRG RAM, vertical mirroring, on a PAL console
+func databaseXML(data []uint8) []uint8 {
+	rom := data[headerSize:]
+	return []uint8(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<nes20db date="2024-01-01">
+  <game>
+    <!-- Game (Europe) -->
+    <prgrom size="32768" crc32="00000000" sha1="0000000000000000000000000000000000000000"/>
+    <chrrom size="8192" crc32="00000000" sha1="0000000000000000000000000000000000000000"/>
+    <rom size="%d" crc32="%08X" sha1="%X"/>
+    <prgnvram size="8192"/>
+    <pcb mapper="4" submapper="0" mirroring="V" battery="1"/>
+    <console type="0" region="1"/>
+  </game>
+</nes20db>`, len(rom), crc32.ChecksumIEEE(rom), sha1.Sum(rom)))
+}
+
+// TestDatabase checks that a game is found by its ROM and its header corrected
+func TestDatabase(t *testing.T) {
+	data := inesFile(t, 2, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	path := filepath.Join(t.TempDir(), "nes20db.xml")
+	if err := os.WriteFile(path, databaseXML(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ReadDatabase(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if g := d.Lookup(data[headerSize:]); g == nil || g.Name != "Game (Europe)" || g.Header.Mapper != 4 {
+		t.Fatalf("Expected the game to be found, got %+v", g)
+	}
+
+	header, corrections, err := d.CorrectHeader(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Correction{
+		{"mapper", "0", "4"},
+		{"PRG RAM", "8192", "0"},
+		{"PRG NVRAM", "0", "8192"},
+		{"mirroring", "horizontal", "vertical"},
+		{"battery", "false", "true"},
+		{"timing", "NTSC", "PAL"},
+	}
+	if fmt.Sprint(corrections) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, corrections)
+	}
+	h, err := ParseHeader(header)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := (Header{Mapper: 4, PRGROM: 0x8000, CHRROM: 0x2000, PRGNVRAM: 0x2000, Mirroring: ppu.Vertical,
+		Battery: true, NES2: true}); h != want {
+		t.Errorf("Expected %+v, got %+v", want, h)
+	}
+	if ppu.ModelFromHeader(header) != ppu.Model2C07 {
+		t.Errorf("Expected a PAL header")
+	}
+
+	// junk after the ROM, and a file not in the database
+	if _, corrections, _ := d.CorrectHeader(append(data, 0xFF)); len(corrections) == 0 {
+		t.Errorf("Expected a file with junk after the ROM to be found")
+	}
+	other := inesFile(t, 1, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	if header, corrections, err := d.CorrectHeader(other); err != nil || corrections != nil ||
+		string(header) != string(other[:headerSize]) {
+		t.Errorf("Expected the header of an unknown file to be unchanged, got % x, %v (%v)", header, corrections, err)
+	}
+}
+
+// TestParseDatabase checks the errors of ParseDatabase
+func TestParseDatabase(t *testing.T) {
+	for _, db := range []string{
+		`<nes20db><game>`,
+		`<nes20db><game><rom crc32="XYZ" sha1="00"/><pcb mirroring="H"/></game></nes20db>`,
+		`<nes20db><game><rom crc32="1234" sha1="00"/><pcb mirroring="H"/></game></nes20db>`,
+		`<nes20db><game><rom crc32="1234" sha1="00"/><pcb mirroring="X"/></game></nes20db>`,
+	} {
+		if _, err := ParseDatabase([]uint8(db)); err == nil {
+			t.Errorf("Expected an error for %s", db)
+		}
+	}
+}
+
+// TestEncodeROMSize checks that ROM sizes read back from the header
+func TestEncodeROMSize(t *testing.T) {
+	for _, size := range []int{0, 0x4000, 0x4000 * 0x100, 3 * 0x1000, 1 << 30} {
+		lo, hi, err := encodeROMSize(size, 0x4000)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if n := romSize(lo, hi, 0x4000); n != size {
+			t.Errorf("Expected %d, got %d", size, n)
+		}
+	}
+	if _, _, err := encodeROMSize(9*0x1000, 0x4000); err == nil {
+		t.Errorf("Expected an error for a size that does not fit")
+	}
+}
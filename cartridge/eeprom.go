@@ -0,0 +1,169 @@
+package cartridge
+
+// Serial EEPROM
+// -------------
+// Bandai boards save to an I²C EEPROM whose clock (SCL) and data (SDA) lines the CPU drives
+// bit by bit. SDA is open drain: the EEPROM pulls it low to acknowledge a byte or send a 0 bit,
+// and releases it otherwise. A transfer starts with SDA falling while SCL is high, and stops
+// with SDA rising while SCL is high. Between these, the receiver samples SDA on each rise of SCL,
+// and the sender changes it while SCL is low. Each byte is 8 bits followed by an acknowledge
+// bit from the receiver, low to acknowledge.
+//
+// The two chips address their memory differently:
+//     - 24C02, 256 bytes: a device address byte 1010xxxR, MSB first, then for a write the word
+//       address and the data. A read sends the bytes from the current address, so reading from
+//       an address is a write of the word address without data, then a new start and a read.
+//     - X24C01, 128 bytes: the 7-bit word address and the R/W bit in one byte, LSB first, with
+//       the data also sent LSB first
+//
+// Writes stay within a page, 8 bytes on the 24C02 and 4 on the X24C01, wrapping around it.
+// Reads continue through the whole memory while the CPU acknowledges each byte.
+
+type eepromState int
+
+const (
+	eepromIdle    eepromState = iota // Waiting for a start
+	eepromDevice                     // Receiving the device address byte of a 24C02
+	eepromAddress                    // Receiving the word address
+	eepromWrite                      // Receiving data
+	eepromRead                       // Sending data
+)
+
+type eeprom struct {
+	data     []uint8
+	x24c01   bool
+	pageSize int
+
+	scl, sda bool // Lines as last driven by the CPU
+	out      bool // SDA as driven by the EEPROM, high when released
+	state    eepromState
+	shift    uint8
+	bit      int  // Bits clocked in the current byte, 8 being the acknowledge bit
+	sending  bool // The current byte is sent by the EEPROM
+	address  int
+}
+
+// new24C02 returns a 256 byte 24C02
+func new24C02() *eeprom {
+	return &eeprom{data: make([]uint8, 256), pageSize: 8, out: true}
+}
+
+// newX24C01 returns a 128 byte X24C01
+func newX24C01() *eeprom {
+	return &eeprom{data: make([]uint8, 128), x24c01: true, pageSize: 4, out: true}
+}
+
+// write sets the lines driven by the CPU
+func (e *eeprom) write(scl bool, sda bool) {
+	switch {
+	case e.scl && scl && e.sda && !sda:
+		e.start()
+	case e.scl && scl && !e.sda && sda:
+		e.state = eepromIdle
+		e.out = true
+	case !e.scl && scl:
+		e.rise(sda)
+	case e.scl && !scl:
+		e.fall()
+	}
+	e.scl, e.sda = scl, sda
+}
+
+// read returns SDA as driven by the EEPROM
+func (e *eeprom) read() bool {
+	return e.out
+}
+
+func (e *eeprom) start() {
+	e.state = eepromDevice
+	if e.x24c01 {
+		e.state = eepromAddress
+	}
+	e.bit = 0
+	e.sending = false
+	e.out = true
+}
+
+// rise samples SDA on a rise of SCL
+func (e *eeprom) rise(sda bool) {
+	switch {
+	case e.bit < 8 && !e.sending:
+		b := uint8(0)
+		if sda {
+			b = 1
+		}
+		if e.x24c01 {
+			e.shift = e.shift>>1 | b<<7
+		} else {
+			e.shift = e.shift<<1 | b
+		}
+	case e.bit == 8 && e.sending && sda:
+		// not acknowledged, the CPU wants no more
+		e.state = eepromIdle
+	}
+	e.bit++
+}
+
+// fall changes SDA on a fall of SCL
+func (e *eeprom) fall() {
+	switch {
+	case e.bit == 8:
+		if e.sending {
+			e.out = true
+		} else {
+			e.out = !e.receive()
+		}
+	case e.bit == 9:
+		e.bit = 0
+		e.out = true
+		e.sending = e.state == eepromRead
+		if e.sending {
+			e.shift = e.data[e.address]
+			e.address = (e.address + 1) % len(e.data)
+			e.out = e.sendBit()
+		}
+	case e.sending && e.bit > 0:
+		e.out = e.sendBit()
+	}
+}
+
+// sendBit returns the bit of the byte being sent that goes out next
+func (e *eeprom) sendBit() bool {
+	if e.x24c01 {
+		return e.shift>>e.bit&1 != 0
+	}
+	return e.shift>>(7-e.bit)&1 != 0
+}
+
+// receive handles a received byte and returns whether to acknowledge it
+func (e *eeprom) receive() bool {
+	switch e.state {
+	case eepromDevice:
+		if e.shift&0xF0 != 0xA0 {
+			e.state = eepromIdle
+			return false
+		}
+		e.state = eepromAddress
+		if e.shift&0x01 != 0 {
+			e.state = eepromRead
+		}
+	case eepromAddress:
+		if e.x24c01 {
+			e.address = int(e.shift & 0x7F)
+			e.state = eepromWrite
+			if e.shift&0x80 != 0 {
+				e.state = eepromRead
+			}
+			return true
+		}
+		e.address = int(e.shift)
+		e.state = eepromWrite
+	case eepromWrite:
+		e.data[e.address] = e.shift
+		page := e.address - e.address%e.pageSize
+		e.address = page + (e.address+1)%e.pageSize
+	default:
+		return false
+	}
+	return true
+}
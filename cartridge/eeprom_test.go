@@ -0,0 +1,126 @@
+package cartridge
+
+import "testing"
+
+// i2c drives the lines of an EEPROM as a game does
+type i2c struct {
+	lines    func(scl bool, sda bool) // Sets SCL and SDA
+	sda      func() bool              // Reads SDA
+	lsbFirst bool
+}
+
+// eepromI2C returns an i2c driving an EEPROM directly
+func eepromI2C(e *eeprom) i2c {
+	return i2c{lines: e.write, sda: e.read, lsbFirst: e.x24c01}
+}
+
+func (b i2c) start() {
+	b.lines(false, true)
+	b.lines(true, true)
+	b.lines(true, false)
+	b.lines(false, false)
+}
+
+func (b i2c) stop() {
+	b.lines(false, false)
+	b.lines(true, false)
+	b.lines(true, true)
+}
+
+// send sends a byte and returns whether it was acknowledged
+func (b i2c) send(data uint8) bool {
+	for i := 0; i < 8; i++ {
+		bit := data>>(7-i)&1 != 0
+		if b.lsbFirst {
+			bit = data>>i&1 != 0
+		}
+		b.lines(false, bit)
+		b.lines(true, bit)
+		b.lines(false, bit)
+	}
+	b.lines(false, true)
+	b.lines(true, true)
+	ack := !b.sda()
+	b.lines(false, true)
+	return ack
+}
+
+// receive receives a byte and acknowledges it if more are wanted
+func (b i2c) receive(more bool) uint8 {
+	data := uint8(0)
+	for i := 0; i < 8; i++ {
+		b.lines(true, true)
+		bit := uint8(0)
+		if b.sda() {
+			bit = 1
+		}
+		if b.lsbFirst {
+			data |= bit << i
+		} else {
+			data = data<<1 | bit
+		}
+		b.lines(false, true)
+	}
+	b.lines(false, !more)
+	b.lines(true, !more)
+	b.lines(false, !more)
+	return data
+}
+
+// Test24C02 checks writes, page wrapping and random and sequential reads
+func Test24C02(t *testing.T) {
+	e := new24C02()
+	b := eepromI2C(e)
+
+	b.start()
+	if !b.send(0xA0) || !b.send(0x36) {
+		t.Fatalf("Expected the device and word addresses to be acknowledged")
+	}
+	for _, data := range []uint8{0x11, 0x22, 0x33} {
+		b.send(data)
+	}
+	b.stop()
+	if e.data[0x36] != 0x11 || e.data[0x37] != 0x22 || e.data[0x30] != 0x33 {
+		t.Errorf("Expected a write wrapping around its page, got % x", e.data[0x30:0x38])
+	}
+
+	b.start()
+	if b.send(0x50) {
+		t.Errorf("Expected another device address not to be acknowledged")
+	}
+	b.stop()
+
+	b.start()
+	b.send(0xA0)
+	b.send(0x36)
+	b.start()
+	b.send(0xA1)
+	if first, second := b.receive(true), b.receive(false); first != 0x11 || second != 0x22 {
+		t.Errorf("Expected 0x11 0x22, got %#02x %#02x", first, second)
+	}
+	b.stop()
+}
+
+// TestX24C01 checks writes and reads of the X24C01, addressed LSB first
+func TestX24C01(t *testing.T) {
+	e := newX24C01()
+	b := eepromI2C(e)
+
+	b.start()
+	if !b.send(0x05) {
+		t.Fatalf("Expected the word address to be acknowledged")
+	}
+	b.send(0xA5)
+	b.send(0x5A)
+	b.stop()
+	if e.data[0x05] != 0xA5 || e.data[0x06] != 0x5A {
+		t.Errorf("Expected 0xA5 0x5A at 0x05, got % x", e.data[0x05:0x07])
+	}
+
+	b.start()
+	b.send(0x80 | 0x05)
+	if first, second := b.receive(true), b.receive(false); first != 0xA5 || second != 0x5A {
+		t.Errorf("Expected 0xA5 0x5A, got %#02x %#02x", first, second)
+	}
+	b.stop()
+}
@@ -0,0 +1,470 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+	"github.com/cbertinato/go-nes/rom"
+)
+
+// Famicom Disk System
+// -------------------
+// The FDS is a RAM adapter plugged into the cartridge slot and a disk drive. The adapter has 32k
+// of PRG RAM at 0x6000-0xDFFF, the 8k BIOS at 0xE000-0xFFFF, 8k of CHR RAM, the sound channel of
+// fdsaudio.go and these registers:
+//     - 0x4020-0x4021: the 16-bit reload value of the timer IRQ
+//     - 0x4022: timer IRQ control, bit 0 to repeat and bit 1 to enable, which reloads the counter
+//     - 0x4023: bit 0 enables the disk registers and bit 1 the sound registers
+//     - 0x4024: the byte to write to the disk
+//     - 0x4025: drive control: motor on (bit 0), transfer reset (bit 1), read mode (bit 2),
+//       horizontal mirroring (bit 3), CRC transfer (bit 4), transfer of a block rather than a gap
+//       (bit 6) and transfer IRQ (bit 7)
+//     - 0x4030: read: the timer IRQ (bit 0), a byte transferred (bit 1), CRC error (bit 4) and
+//       end of the disk (bit 6). Reading acknowledges both IRQs.
+//     - 0x4031: read: the byte read from the disk
+//     - 0x4032: read: no disk (bit 0), not ready (bit 1) and write protected (bit 2)
+//     - 0x4033: read: the expansion port, bit 7 being set while the battery is good
+//
+// The timer counts down every CPU cycle while enabled, and raises the IRQ and reloads when it
+// passes 0. Unless it repeats, it is then disabled.
+//
+// The drive reads or writes the disk in the raw form of fdsdisk.go, a byte every 150 CPU cycles
+// while the motor is on, starting from the beginning after a delay whenever the head has reached
+// the end of the disk. While bit 6 of 0x4025 is clear the drive is in a gap: reading waits for
+// the start mark of the next block, and writing writes zeros. Every other byte sets the transfer
+// flag and raises the IRQ if enabled, and the CPU acknowledges it by accessing 0x4024 or 0x4031.
+// The adapter checks the CRC of each block it reads, and writes the CRC after each block when
+// bit 4 is set.
+//
+// Disks are changed with Eject and Insert, or with InsertSide, which leaves the drive empty
+// long enough for the BIOS to notice. The changes the games make are kept in memory and can be
+// saved as an IPS patch of the image with SaveDiff, and applied again with LoadDiff, leaving the
+// image itself untouched.
+
+const (
+	fdsByteCycles   = 150     // CPU cycles to transfer a byte
+	fdsRewindCycles = 50000   // CPU cycles for the head to return to the start of the disk
+	fdsInsertCycles = 1789773 // CPU cycles without a disk when changing sides, about a second
+)
+
+// NoDisk is the side of an FDS without a disk inserted
+const NoDisk = -1
+
+// FDS is a Famicom Disk System with a disk image
+type FDS struct {
+	*board
+	audio fdsAudio
+
+	image  []uint8   // The .fds file as loaded, before any writes
+	header []uint8   // fwNES header of the image, or nil
+	sides  [][]uint8 // Raw sides, see expandSide
+
+	side        int // Inserted side, or NoDisk
+	nextSide    int // Side to insert once insertDelay has passed
+	insertDelay int
+
+	diskEnabled  bool
+	soundEnabled bool
+
+	timerReload  uint16
+	timerCounter uint16
+	timerRepeat  bool
+	timerEnabled bool
+	timerIRQ     bool
+
+	motorOn       bool
+	resetTransfer bool
+	readMode      bool
+	crcTransfer   bool
+	transfer      bool // 0x4025 bit 6, in a block rather than a gap
+	transferIRQ   bool
+	diskIRQ       bool
+	transferred   bool // Byte transfer flag
+	position      int  // Position of the head in the raw side
+	delay         int  // CPU cycles until the next byte
+	endOfHead     bool
+	scanning      bool // The head is moving through the disk
+	gapEnded      bool // The start mark of the current block has been read
+	crc           uint16
+	lastCRC       bool // crcTransfer at the last byte written
+	readData      uint8
+	writeData     uint8
+}
+
+// LoadFDS returns an FDS with the disk of a .fds file, with or without a fwNES header, and the
+// 8k BIOS ROM. The first side is inserted.
+func LoadFDS(image []uint8, bios []uint8) (*FDS, error) {
+	if err := checkBIOS(bios); err != nil {
+		return nil, err
+	}
+	sides, header, err := parseFDS(image)
+	if err != nil {
+		return nil, err
+	}
+
+	h := Header{PRGRAM: 0x8000, CHRRAM: 0x2000, Mirroring: ppu.Horizontal}
+	f := &FDS{
+		board:     newBoard(h, bios, nil),
+		image:     image,
+		header:    header,
+		side:      0,
+		endOfHead: true,
+	}
+	for _, side := range sides {
+		f.sides = append(f.sides, expandSide(side))
+	}
+	f.audio.reset()
+	return f, nil
+}
+
+// ReadBIOS reads the FDS BIOS ROM from a file, usually disksys.rom
+func ReadBIOS(path string) ([]uint8, error) {
+	bios, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBIOS(bios); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+	return bios, nil
+}
+
+func (f *FDS) PRG() cpu.Bus { return prgBus{f} }
+func (f *FDS) CHR() ppu.Bus { return chrBus{f} }
+
+// Output returns the output of the sound channel, see apu.Expansion
+func (f *FDS) Output() float64 {
+	return f.audio.output()
+}
+
+// Sides returns the number of disk sides in the image, 2 for each double-sided disk
+func (f *FDS) Sides() int {
+	return len(f.sides)
+}
+
+// Side returns the inserted side, or NoDisk
+func (f *FDS) Side() int {
+	return f.side
+}
+
+// Eject removes the disk from the drive
+func (f *FDS) Eject() {
+	f.side = NoDisk
+	f.insertDelay = 0
+	f.motorOn = false
+	f.scanning = false
+}
+
+// Insert puts a side of a disk in the drive, which must be empty
+func (f *FDS) Insert(side int) error {
+	if side < 0 || side >= len(f.sides) {
+		return fmt.Errorf("cartridge: no side %d, the image has %d", side, len(f.sides))
+	}
+	if f.side != NoDisk {
+		return errors.New("cartridge: a disk is already inserted")
+	}
+	f.side = side
+	f.endOfHead = true
+	return nil
+}
+
+// InsertSide changes the disk to a side, ejecting the one inserted and leaving the drive empty
+// for about a second, as the BIOS only notices a change if it sees the drive empty
+func (f *FDS) InsertSide(side int) error {
+	if side < 0 || side >= len(f.sides) {
+		return fmt.Errorf("cartridge: no side %d, the image has %d", side, len(f.sides))
+	}
+	f.Eject()
+	f.nextSide = side
+	f.insertDelay = fdsInsertCycles
+	return nil
+}
+
+// Image returns the .fds file of the disk as the games have written it
+func (f *FDS) Image() []uint8 {
+	sides := make([][]uint8, len(f.sides))
+	for i, raw := range f.sides {
+		sides[i] = packSide(raw)
+	}
+	return fdsImage(sides, f.header)
+}
+
+// SaveDiff writes the changes made to the disk as an IPS patch of the image, or removes the file
+// if there are none
+func (f *FDS) SaveDiff(path string) error {
+	patch := rom.CreateIPS(f.image, f.Image())
+	if patch == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, patch, 0o644)
+}
+
+// LoadDiff applies a patch written by SaveDiff to the disk, if the file exists. It is meant to be
+// called before the console is powered on.
+func (f *FDS) LoadDiff(path string) error {
+	patch, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	image, err := rom.ApplyIPS(f.image, patch)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+	sides, _, err := parseFDS(image)
+	if err != nil {
+		return fmt.Errorf("%w, patched by %s", err, path)
+	}
+	if len(sides) != len(f.sides) {
+		return fmt.Errorf("cartridge: %s changes the number of sides", path)
+	}
+	for i, side := range sides {
+		f.sides[i] = expandSide(side)
+	}
+	return nil
+}
+
+func (f *FDS) Clock() {
+	f.clockTimer()
+	f.clockDrive()
+	f.audio.clock()
+}
+
+// clockTimer clocks the timer IRQ
+func (f *FDS) clockTimer() {
+	if !f.timerEnabled {
+		return
+	}
+	if f.timerCounter > 0 {
+		f.timerCounter--
+		return
+	}
+	f.timerIRQ = true
+	f.updateIRQ()
+	f.timerCounter = f.timerReload
+	if !f.timerRepeat {
+		f.timerEnabled = false
+	}
+}
+
+// clockDrive moves the disk under the head
+func (f *FDS) clockDrive() {
+	if f.insertDelay > 0 {
+		f.insertDelay--
+		if f.insertDelay == 0 {
+			f.Insert(f.nextSide)
+		}
+		return
+	}
+	if f.side == NoDisk {
+		return
+	}
+	if !f.motorOn {
+		f.endOfHead = true
+		f.scanning = false
+		return
+	}
+	if f.resetTransfer && !f.scanning {
+		return
+	}
+	if f.endOfHead {
+		f.delay = fdsRewindCycles
+		f.endOfHead = false
+		f.position = 0
+		f.gapEnded = false
+		return
+	}
+	if f.delay > 0 {
+		f.delay--
+		return
+	}
+
+	f.scanning = true
+	raw := f.sides[f.side]
+	if f.readMode {
+		f.readByte(raw[f.position])
+	} else {
+		raw[f.position] = f.writeByte()
+	}
+	f.lastCRC = f.crcTransfer
+
+	f.position++
+	if f.position >= len(raw) {
+		// end of the disk, the drive stops until the motor is started again
+		f.motorOn = false
+		f.endOfHead = true
+	} else {
+		f.delay = fdsByteCycles - 1
+	}
+}
+
+// readByte handles a byte read from the disk
+func (f *FDS) readByte(data uint8) {
+	switch {
+	case !f.transfer:
+		f.gapEnded = false
+		f.crc = 0
+		return
+	case !f.gapEnded:
+		// the start mark
+		if data != 0 {
+			f.gapEnded = true
+			f.crc = updateFDSCRC(0, data)
+		}
+		return
+	}
+	f.crc = updateFDSCRC(f.crc, data)
+	f.readData = data
+	f.byteTransferred()
+}
+
+// writeByte returns the byte to write to the disk
+func (f *FDS) writeByte() uint8 {
+	f.gapEnded = false
+	if f.crcTransfer {
+		if !f.lastCRC {
+			f.crc = updateFDSCRC(f.crc, 0)
+			f.crc = updateFDSCRC(f.crc, 0)
+		}
+		data := uint8(f.crc)
+		f.crc >>= 8
+		return data
+	}
+
+	f.byteTransferred()
+	data := f.writeData
+	if !f.transfer {
+		data = 0
+		f.crc = 0
+	}
+	f.crc = updateFDSCRC(f.crc, data)
+	return data
+}
+
+// byteTransferred sets the transfer flag and raises the IRQ if enabled
+func (f *FDS) byteTransferred() {
+	f.transferred = true
+	if f.transferIRQ {
+		f.diskIRQ = true
+		f.updateIRQ()
+	}
+}
+
+// updateIRQ drives the IRQ from the timer and the drive
+func (f *FDS) updateIRQ() {
+	f.setIRQ(f.timerIRQ || f.diskIRQ)
+}
+
+func (f *FDS) readPRG(address uint16, readOnly bool) uint8 {
+	switch {
+	case address >= 0xE000:
+		return f.prgROM[address&0x1FFF]
+	case address >= 0x6000:
+		return f.readRAM(address)
+	case address >= 0x4040 && address < 0x4098 && f.soundEnabled:
+		data, mask := f.audio.read(address)
+		return f.openBus()&^mask | data
+	case address >= 0x4030 && address < 0x4034 && f.diskEnabled:
+		return f.readRegister(address, readOnly)
+	}
+	return f.openBus()
+}
+
+// readRegister reads a disk register
+func (f *FDS) readRegister(address uint16, readOnly bool) uint8 {
+	open := f.openBus()
+	switch address {
+	case 0x4030:
+		data := boolBit(f.timerIRQ, 0) | boolBit(f.transferred, 1) | boolBit(f.crc != 0, 4) |
+			boolBit(f.endOfHead, 6)
+		if !readOnly {
+			f.timerIRQ, f.diskIRQ, f.transferred = false, false, false
+			f.updateIRQ()
+		}
+		return open&0x2C | data
+	case 0x4031:
+		if !readOnly {
+			f.transferred, f.diskIRQ = false, false
+			f.updateIRQ()
+		}
+		return f.readData
+	case 0x4032:
+		empty := f.side == NoDisk
+		return open&0xF8 | boolBit(empty, 0) | boolBit(empty || !f.scanning, 1) | boolBit(empty, 2)
+	}
+	// battery good
+	return open&0x7F | 0x80
+}
+
+func (f *FDS) writePRG(address uint16, data uint8) {
+	switch {
+	case address >= 0xE000:
+		// BIOS ROM
+	case address >= 0x6000:
+		f.writeRAM(address, data)
+	case address == 0x4023:
+		f.diskEnabled = data&0x01 != 0
+		f.soundEnabled = data&0x02 != 0
+		if !f.diskEnabled {
+			f.timerEnabled = false
+			f.timerIRQ, f.diskIRQ = false, false
+			f.updateIRQ()
+		}
+	case address >= 0x4040 && address < 0x408B && f.soundEnabled:
+		f.audio.write(address, data)
+	case address >= 0x4020 && address < 0x4027 && f.diskEnabled:
+		f.writeRegister(address, data)
+	}
+}
+
+// writeRegister writes a disk register
+func (f *FDS) writeRegister(address uint16, data uint8) {
+	switch address {
+	case 0x4020:
+		f.timerReload = f.timerReload&0xFF00 | uint16(data)
+	case 0x4021:
+		f.timerReload = f.timerReload&0x00FF | uint16(data)<<8
+	case 0x4022:
+		f.timerRepeat = data&0x01 != 0
+		f.timerEnabled = data&0x02 != 0
+		if f.timerEnabled {
+			f.timerCounter = f.timerReload
+		} else {
+			f.timerIRQ = false
+			f.updateIRQ()
+		}
+	case 0x4024:
+		f.writeData = data
+		f.transferred, f.diskIRQ = false, false
+		f.updateIRQ()
+	case 0x4025:
+		f.motorOn = data&0x01 != 0
+		f.resetTransfer = data&0x02 != 0
+		f.readMode = data&0x04 != 0
+		f.crcTransfer = data&0x10 != 0
+		f.transfer = data&0x40 != 0
+		f.transferIRQ = data&0x80 != 0
+		m := ppu.Vertical
+		if data&0x08 != 0 {
+			m = ppu.Horizontal
+		}
+		f.setMirroring(m)
+		f.diskIRQ = false
+		f.updateIRQ()
+	}
+}
+
+func (f *FDS) readCHR(address uint16, readOnly bool) uint8 {
+	return f.chr[address&0x1FFF]
+}
+
+func (f *FDS) writeCHR(address uint16, data uint8) {
+	f.chr[address&0x1FFF] = data
+}
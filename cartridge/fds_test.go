@@ -0,0 +1,241 @@
+package cartridge
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// fdsSide returns a side with the disk header, a file count of 1, and one file of 3 bytes
+func fdsSide() []uint8 {
+	side := append([]uint8(nil), fdsDiskID...)
+	side = append(side, make([]uint8, 56-len(side))...)
+	side = append(side, 2, 1)
+	side = append(side, 3, 0, 0, 'F', 'I', 'L', 'E', ' ', ' ', ' ', ' ', 0x00, 0x60, 3, 0, 0)
+	side = append(side, 4, 0xA1, 0xA2, 0xA3)
+	return append(side, make([]uint8, fdsSideSize-len(side))...)
+}
+
+// fdsBIOS returns a BIOS with irqProgram's program, enabling IRQs and looping, with the IRQ
+// handler at 0xE100
+func fdsBIOS() []uint8 {
+	bios := make([]uint8, 0x2000)
+	copy(bios, []uint8{0x58, 0x4C, 0x01, 0xE0})
+	copy(bios[0x100:], []uint8{0x4C, 0x00, 0xE1})
+	copy(bios[0x1FFC:], []uint8{0x00, 0xE0, 0x00, 0xE1})
+	return bios
+}
+
+// newFDS returns an FDS with a two-sided disk, with a fwNES header
+func newFDS(t *testing.T) (*FDS, []uint8) {
+	image := append([]uint8("FDS\x1A\x02"), make([]uint8, 11)...)
+	image = append(image, fdsSide()...)
+	image = append(image, fdsSide()...)
+	f, err := LoadFDS(image, fdsBIOS())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return f, image
+}
+
+// nextByte clocks an FDS until it has transferred a byte
+func nextByte(t *testing.T, f *FDS) {
+	for i := 0; i < fdsRewindCycles+(fdsFirstGap+2)*fdsByteCycles; i++ {
+		f.Clock()
+		if f.readPRG(0x4030, true)&0x02 != 0 {
+			return
+		}
+	}
+	t.Fatalf("Expected a byte to be transferred")
+}
+
+// TestLoadFDS checks images with and without a header, and the BIOS size
+func TestLoadFDS(t *testing.T) {
+	f, image := newFDS(t)
+	if f.Sides() != 2 || f.Side() != 0 {
+		t.Errorf("Expected 2 sides and the first inserted, got %d and %d", f.Sides(), f.Side())
+	}
+	if !bytes.Equal(f.Image(), image) {
+		t.Errorf("Expected the image to be unchanged")
+	}
+
+	if f, err := LoadFDS(image[fdsHeaderSize:], fdsBIOS()); err != nil || f.Sides() != 2 {
+		t.Errorf("Expected an image without a header to load, got %v", err)
+	}
+	if _, err := LoadFDS(image[:len(image)-1], fdsBIOS()); err == nil {
+		t.Errorf("Expected an error for a truncated image")
+	}
+	if _, err := LoadFDS(image, make([]uint8, 0x1000)); err == nil {
+		t.Errorf("Expected an error for a short BIOS")
+	}
+}
+
+// TestFDSSides checks that the raw form of a side packs back to the side, and the CRCs in it
+func TestFDSSides(t *testing.T) {
+	side := fdsSide()
+	raw := expandSide(side)
+	if !bytes.Equal(packSide(raw), side) {
+		t.Errorf("Expected the raw side to pack back to the side")
+	}
+
+	// the disk header, after the gap and the start mark
+	block := raw[fdsFirstGap : fdsFirstGap+1+56+2]
+	crc := uint16(0)
+	for _, b := range block {
+		crc = updateFDSCRC(crc, b)
+	}
+	if crc != 0 {
+		t.Errorf("Expected the CRC of a block and its CRC to be 0, got %#04x", crc)
+	}
+}
+
+// TestFDSDrive reads the disk header, then rewrites the file count block and saves the change
+func TestFDSDrive(t *testing.T) {
+	f, image := newFDS(t)
+	f.writePRG(0x4023, 0x01)
+
+	// motor on, read mode, in a block
+	f.writePRG(0x4025, 0x45)
+	header := make([]uint8, 56)
+	for i := range header {
+		nextByte(t, f)
+		header[i] = f.readPRG(0x4031, false)
+	}
+	if !bytes.HasPrefix(header, fdsDiskID) {
+		t.Errorf("Expected to read the disk header, got %q", header[:16])
+	}
+	f.writePRG(0x4025, 0x55) // CRC
+	nextByte(t, f)
+	f.readPRG(0x4031, false)
+	nextByte(t, f)
+	if data := f.readPRG(0x4030, false); data&0x10 != 0 {
+		t.Errorf("Expected no CRC error, got %#02x", data)
+	}
+
+	// write the gap, then a file count of 5
+	f.writePRG(0x4025, 0x01)
+	for i := 0; i < fdsGap; i++ {
+		nextByte(t, f)
+		f.writePRG(0x4024, 0x00)
+	}
+	f.writePRG(0x4024, 0x80)
+	f.writePRG(0x4025, 0x41)
+	for _, data := range []uint8{0x02, 0x05} {
+		nextByte(t, f)
+		f.writePRG(0x4024, data)
+	}
+	nextByte(t, f)
+	f.writePRG(0x4025, 0x51) // CRC
+	f.Clock()
+	for i := 0; i < 2*fdsByteCycles; i++ {
+		f.Clock()
+	}
+	f.writePRG(0x4025, 0x00)
+
+	modified := f.Image()
+	if block := modified[fdsHeaderSize+56 : fdsHeaderSize+58]; block[0] != 0x02 || block[1] != 0x05 {
+		t.Fatalf("Expected a file count of 5, got % x", block)
+	}
+	if !bytes.Equal(modified[fdsHeaderSize+58:], image[fdsHeaderSize+58:]) {
+		t.Errorf("Expected the rest of the disk to be unchanged")
+	}
+	raw := f.sides[0]
+	start := fdsFirstGap + 1 + 56 + 2 + fdsGap
+	if crc := fdsCRC([]uint8{0x02, 0x05}); raw[start+3] != uint8(crc) || raw[start+4] != uint8(crc>>8) {
+		t.Errorf("Expected the CRC %#04x after the block, got % x", crc, raw[start+3:start+5])
+	}
+
+	path := filepath.Join(t.TempDir(), "game.ips")
+	if err := f.SaveDiff(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	g, err := LoadFDS(image, fdsBIOS())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := g.LoadDiff(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(g.Image(), modified) {
+		t.Errorf("Expected the saved diff to reproduce the disk")
+	}
+}
+
+// TestFDSSideChange checks that InsertSide leaves the drive empty for a while
+func TestFDSSideChange(t *testing.T) {
+	f, _ := newFDS(t)
+	f.writePRG(0x4023, 0x01)
+
+	if err := f.InsertSide(2); err == nil {
+		t.Errorf("Expected an error for a missing side")
+	}
+	if err := f.InsertSide(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.Side() != NoDisk || f.readPRG(0x4032, true)&0x01 == 0 {
+		t.Errorf("Expected the drive to be empty")
+	}
+	for i := 0; i < fdsInsertCycles; i++ {
+		f.Clock()
+	}
+	if f.Side() != 1 || f.readPRG(0x4032, true)&0x01 != 0 {
+		t.Errorf("Expected side 1 to be inserted, got %d", f.Side())
+	}
+	if err := f.Insert(0); err == nil {
+		t.Errorf("Expected an error inserting into a full drive")
+	}
+}
+
+// TestFDSTimer checks the timer IRQ
+func TestFDSTimer(t *testing.T) {
+	f, _ := newFDS(t)
+	c := nes.NewConsole(f, ppu.Model2C02, 0)
+
+	c.Bus.Write(0x4023, 0x01)
+	c.Bus.Write(0x4020, 200)
+	c.Bus.Write(0x4021, 0)
+	if n := runUntilIRQ(t, c, 1000); n >= 0 {
+		t.Fatalf("Expected no IRQ before enabled, got one after %d cycles", n)
+	}
+	c.Bus.Write(0x4022, 0x02)
+	if n := runUntilIRQ(t, c, 1000); n < 200 || n > 220 {
+		t.Errorf("Expected an IRQ about 200 cycles later, got %d", n)
+	}
+	if c.Bus.Read(0x4030, false)&0x01 == 0 {
+		t.Errorf("Expected the timer IRQ flag")
+	}
+	if c.Bus.Read(0x4030, false)&0x01 != 0 {
+		t.Errorf("Expected reading 0x4030 to acknowledge the IRQ")
+	}
+}
+
+// TestFDSAudio checks that the wave is played at its pitch and volume, and the pitch bent by the
+// modulation unit
+func TestFDSAudio(t *testing.T) {
+	var a fdsAudio
+	a.reset()
+	a.write(0x4089, 0x80)
+	for i := uint16(0); i < 64; i++ {
+		a.write(0x4040+i, uint8(i))
+	}
+	a.write(0x4089, 0x00)
+	a.write(0x4080, 0x80|0x20) // gain 32
+	a.write(0x4082, 0x00)
+	a.write(0x4083, 0x08) // 32 cycles per sample
+
+	for i := 0; i < 64*32; i++ {
+		a.clock()
+		if expected := (i + 1) / 32 % 64 * 32; a.level != expected {
+			t.Fatalf("Expected %d after %d cycles, got %d", expected, i+1, a.level)
+		}
+	}
+
+	a.write(0x4084, 0x80|0x10) // modulation gain 16
+	a.write(0x4085, 0x01)
+	if p := a.wavePitch(); p != 0x820 {
+		t.Errorf("Expected the pitch to be bent to 0x820, got %#04x", p)
+	}
+}
@@ -0,0 +1,228 @@
+package cartridge
+
+// FDS audio
+// ---------
+// The RAM adapter has one wavetable channel, playing 64 6-bit samples, whose pitch a modulation
+// unit bends. Its registers are enabled by bit 1 of 0x4023:
+//     - 0x4040-0x407F: the waveform, writable while bit 7 of 0x4089 is set
+//     - 0x4080: the volume envelope, bit 7 set for a fixed gain of bits 0-5, otherwise bit 6 set
+//       for rising and bits 0-5 the speed
+//     - 0x4082-0x4083: the 12-bit pitch. Bit 6 of 0x4083 stops both envelopes, and bit 7 stops
+//       the wave and rewinds it.
+//     - 0x4084: the modulation envelope, as 0x4080
+//     - 0x4085: the 7-bit signed modulation counter
+//     - 0x4086-0x4087: the 12-bit modulation frequency. Bit 7 of 0x4087 stops the modulation
+//       unit, which allows writes to its table.
+//     - 0x4088: appends a 3-bit step to the 32 entry modulation table
+//     - 0x4089: bit 7 enables waveform writes and holds the output, bits 0-1 are the master
+//       volume, 2/2, 2/3, 2/4 or 2/5
+//     - 0x408A: the envelope speed multiplier
+//     - 0x4090 and 0x4092: read the volume and modulation gains
+//
+// The envelopes move their gain by one every 8 * (speed + 1) * multiplier CPU cycles, between
+// 0 and 32 for the volume and 0 and 63 for the modulation. Every CPU cycle the modulation
+// frequency is added to a 16-bit accumulator, and each overflow applies the next table entry to
+// the counter: 0, +1, +2, +4, reset to 0, -4, -2 or -1. The counter and the modulation gain
+// bend the pitch, which is added to the wave's accumulator every CPU cycle, its top 6 bits
+// giving the sample. The output is the sample times the volume gain, at most 32, times the
+// master volume.
+
+// fdsScale scales the FDS output to the APU's, about 0.2 at full volume
+const fdsScale = 0.2 / (63 * 32)
+
+// fdsModSteps are the changes to the modulation counter of the table entries, 4 resetting it
+var fdsModSteps = [8]int{0, 1, 2, 4, 0, -4, -2, -1}
+
+// fdsMasterVolumes are the master volumes of 0x4089, in 30ths
+var fdsMasterVolumes = [4]int{30, 20, 15, 12}
+
+// fdsEnvelope is the volume or modulation envelope
+type fdsEnvelope struct {
+	fixed   bool
+	rising  bool
+	speed   uint8
+	gain    uint8
+	counter int // CPU cycles until the next step
+}
+
+// write writes 0x4080 or 0x4084
+func (e *fdsEnvelope) write(data uint8) {
+	e.fixed = data&0x80 != 0
+	e.rising = data&0x40 != 0
+	e.speed = data & 0x3F
+	if e.fixed {
+		e.gain = data & 0x3F
+	}
+}
+
+// clock is called every CPU cycle with the envelope speed multiplier
+func (e *fdsEnvelope) clock(multiplier uint8) {
+	if e.fixed {
+		return
+	}
+	if e.counter > 0 {
+		e.counter--
+		return
+	}
+	e.counter = 8*(int(e.speed)+1)*int(multiplier) - 1
+	switch {
+	case e.rising && e.gain < 32:
+		e.gain++
+	case !e.rising && e.gain > 0:
+		e.gain--
+	}
+}
+
+type fdsAudio struct {
+	wave       [64]uint8
+	waveWrite  bool // 0x4089 bit 7
+	master     uint8
+	multiplier uint8
+	volume     fdsEnvelope
+	mod        fdsEnvelope
+	envHalted  bool
+
+	pitch     uint16
+	waveHalt  bool
+	wavePhase uint32 // 6.16 fixed point position in the waveform
+	level     int
+
+	modTable   [64]uint8 // Each entry of the 32 entry table twice
+	modPos     uint8
+	modFreq    uint16
+	modHalt    bool
+	modPhase   uint32
+	modCounter int // -64 to 63
+}
+
+// reset returns the channel to its power on state
+func (a *fdsAudio) reset() {
+	*a = fdsAudio{multiplier: 0xE8, waveHalt: true, modHalt: true}
+}
+
+// read reads 0x4040-0x4097, returning the bits it drives and a mask of them
+func (a *fdsAudio) read(address uint16) (uint8, uint8) {
+	switch {
+	case address >= 0x4040 && address < 0x4080:
+		return a.wave[address-0x4040], 0x3F
+	case address == 0x4090:
+		return a.volume.gain, 0x3F
+	case address == 0x4092:
+		return a.mod.gain, 0x3F
+	}
+	return 0, 0
+}
+
+// write writes 0x4040-0x408A
+func (a *fdsAudio) write(address uint16, data uint8) {
+	switch {
+	case address >= 0x4040 && address < 0x4080:
+		if a.waveWrite {
+			a.wave[address-0x4040] = data & 0x3F
+		}
+	case address == 0x4080:
+		a.volume.write(data)
+	case address == 0x4082:
+		a.pitch = a.pitch&0x0F00 | uint16(data)
+	case address == 0x4083:
+		a.pitch = a.pitch&0x00FF | uint16(data&0x0F)<<8
+		a.envHalted = data&0x40 != 0
+		a.waveHalt = data&0x80 != 0
+		if a.waveHalt {
+			a.wavePhase = 0
+		}
+	case address == 0x4084:
+		a.mod.write(data)
+	case address == 0x4085:
+		a.modCounter = (int(data&0x7F)+64)&0x7F - 64
+	case address == 0x4086:
+		a.modFreq = a.modFreq&0x0F00 | uint16(data)
+	case address == 0x4087:
+		a.modFreq = a.modFreq&0x00FF | uint16(data&0x0F)<<8
+		a.modHalt = data&0x80 != 0
+		if a.modHalt {
+			a.modPhase = 0
+		}
+	case address == 0x4088:
+		if a.modHalt {
+			a.modTable[a.modPos] = data & 0x07
+			a.modTable[a.modPos+1] = data & 0x07
+			a.modPos = (a.modPos + 2) & 0x3F
+		}
+	case address == 0x4089:
+		a.waveWrite = data&0x80 != 0
+		a.master = data & 0x03
+	case address == 0x408A:
+		a.multiplier = data
+	}
+}
+
+// clock is called every CPU cycle
+func (a *fdsAudio) clock() {
+	if !a.envHalted && !a.waveHalt && a.multiplier > 0 {
+		a.volume.clock(a.multiplier)
+		a.mod.clock(a.multiplier)
+	}
+
+	if !a.modHalt && a.modFreq > 0 {
+		a.modPhase += uint32(a.modFreq)
+		if a.modPhase >= 0x10000 {
+			a.modPhase &= 0xFFFF
+			a.stepModulation()
+		}
+	}
+
+	if !a.waveHalt {
+		a.wavePhase = (a.wavePhase + uint32(a.wavePitch())) & 0x3FFFFF
+	}
+	if !a.waveWrite {
+		gain := int(min(a.volume.gain, 32))
+		a.level = int(a.wave[a.wavePhase>>16]) * gain * fdsMasterVolumes[a.master] / 30
+	}
+}
+
+// stepModulation applies the next modulation table entry to the counter
+func (a *fdsAudio) stepModulation() {
+	step := a.modTable[a.modPos]
+	a.modPos = (a.modPos + 1) & 0x3F
+	if step == 4 {
+		a.modCounter = 0
+		return
+	}
+	a.modCounter += fdsModSteps[step]
+	// wrap to 7 bits
+	a.modCounter = (a.modCounter+64)&0x7F - 64
+}
+
+// wavePitch returns the pitch bent by the modulation unit
+func (a *fdsAudio) wavePitch() int {
+	temp := a.modCounter * int(a.mod.gain)
+	remainder := temp & 0x0F
+	temp >>= 4
+	if remainder > 0 && temp&0x80 == 0 {
+		if a.modCounter < 0 {
+			temp--
+		} else {
+			temp += 2
+		}
+	}
+	switch {
+	case temp >= 192:
+		temp -= 256
+	case temp < -64:
+		temp += 256
+	}
+
+	temp *= int(a.pitch)
+	remainder = temp & 0x3F
+	temp >>= 6
+	if remainder >= 32 {
+		temp++
+	}
+	return max(int(a.pitch)+temp, 0)
+}
+
+// output returns the output of the channel
+func (a *fdsAudio) output() float64 {
+	return float64(a.level) * fdsScale
+}
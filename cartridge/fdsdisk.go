@@ -0,0 +1,164 @@
+package cartridge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// FDS disk images
+// ---------------
+// A .fds file holds the sides of one or more disks, 65500 bytes each, optionally after a 16 byte
+// fwNES header of "FDS\x1A", the number of sides and 11 zero bytes. A side is a list of blocks,
+// each starting with its type:
+//     - 1: the 56 byte disk header, starting "\x01*NINTENDO-HVC*"
+//     - 2: the 2 byte file count
+//     - 3: a 16 byte file header, with the size of the file in bytes 13-14
+//     - 4: the file, 1 + size bytes
+//
+// followed by zeros up to the end of the side. The .fds file leaves out what is on the disk
+// between the blocks, which the drive needs to see: a gap of zero bits before each block, 28300
+// before the first one and 976 before the others, a start mark byte of 0x80, and a CRC after the
+// block. The sides are expanded to this raw form for the drive, and packed back into .fds form
+// when the image is saved.
+
+const (
+	fdsHeaderSize = 16
+	fdsSideSize   = 65500
+	fdsFirstGap   = 28300 / 8 // Bytes of gap before the first block
+	fdsGap        = 976 / 8   // Bytes of gap between blocks
+)
+
+var (
+	fdsMagic  = []byte("FDS\x1A")
+	fdsDiskID = []byte("\x01*NINTENDO-HVC*")
+	errNotFDS = errors.New("cartridge: not an FDS disk image")
+)
+
+// parseFDS returns the sides of a .fds file and its fwNES header, or nil if it has none
+func parseFDS(image []uint8) ([][]uint8, []uint8, error) {
+	var header []uint8
+	if bytes.HasPrefix(image, fdsMagic) {
+		header = image[:min(fdsHeaderSize, len(image))]
+		image = image[len(header):]
+	}
+	if len(image) == 0 || len(image)%fdsSideSize != 0 || !bytes.HasPrefix(image, fdsDiskID) {
+		return nil, nil, errNotFDS
+	}
+
+	var sides [][]uint8
+	for len(image) > 0 {
+		sides = append(sides, image[:fdsSideSize])
+		image = image[fdsSideSize:]
+	}
+	return sides, header, nil
+}
+
+// fdsBlockSize returns the size of the block at the start of data, whose file header, if it is
+// a file, was the block before
+func fdsBlockSize(data []uint8, previous []uint8) int {
+	switch data[0] {
+	case 1:
+		return 56
+	case 2:
+		return 2
+	case 3:
+		return 16
+	case 4:
+		if len(previous) == 16 && previous[0] == 3 {
+			return 1 + (int(previous[13]) | int(previous[14])<<8)
+		}
+	}
+	return 0
+}
+
+// expandSide returns the raw form of a side, with gaps, start marks and CRCs
+func expandSide(side []uint8) []uint8 {
+	raw := make([]uint8, fdsFirstGap, 2*fdsSideSize)
+	var previous []uint8
+	for i := 0; i < len(side); {
+		size := fdsBlockSize(side[i:], previous)
+		if size == 0 || i+size > len(side) {
+			// the rest of the side is empty
+			raw = append(raw, side[i:]...)
+			break
+		}
+		block := side[i : i+size]
+		crc := fdsCRC(block)
+		raw = append(raw, 0x80)
+		raw = append(raw, block...)
+		raw = append(raw, uint8(crc), uint8(crc>>8))
+		raw = append(raw, make([]uint8, fdsGap)...)
+		previous = block
+		i += size
+	}
+	return raw
+}
+
+// packSide returns the .fds form of a raw side, reading blocks for as long as they are followed
+// by a gap and a start mark
+func packSide(raw []uint8) []uint8 {
+	side := make([]uint8, 0, fdsSideSize)
+	var previous []uint8
+	for i := 0; ; {
+		for i < len(raw) && raw[i] == 0 {
+			i++
+		}
+		if i+1 >= len(raw) || raw[i] != 0x80 {
+			break
+		}
+		i++
+		size := fdsBlockSize(raw[i:], previous)
+		if size == 0 || i+size > len(raw) || len(side)+size > fdsSideSize {
+			break
+		}
+		previous = raw[i : i+size]
+		side = append(side, previous...)
+		i += size + 2
+	}
+	return append(side, make([]uint8, fdsSideSize-len(side))...)
+}
+
+// fdsCRC returns the CRC written after a block, which the RAM adapter computes over the start
+// mark, the block and the CRC itself, giving 0 if there is no error
+func fdsCRC(block []uint8) uint16 {
+	crc := uint16(0)
+	crc = updateFDSCRC(crc, 0x80)
+	for _, b := range block {
+		crc = updateFDSCRC(crc, b)
+	}
+	crc = updateFDSCRC(crc, 0)
+	return updateFDSCRC(crc, 0)
+}
+
+// updateFDSCRC adds a byte, LSB first, to the CRC of the RAM adapter
+func updateFDSCRC(crc uint16, data uint8) uint16 {
+	for bit := 0; bit < 8; bit++ {
+		carry := crc & 0x01
+		crc >>= 1
+		if carry != 0 {
+			crc ^= 0x8408
+		}
+		if data>>bit&1 != 0 {
+			crc ^= 0x8000
+		}
+	}
+	return crc
+}
+
+// fdsImage returns the .fds file of a list of sides after a fwNES header, which may be nil
+func fdsImage(sides [][]uint8, header []uint8) []uint8 {
+	image := append([]uint8(nil), header...)
+	for _, side := range sides {
+		image = append(image, side...)
+	}
+	return image
+}
+
+// checkBIOS returns an error if a BIOS is not the 8k of the FDS BIOS
+func checkBIOS(bios []uint8) error {
+	if len(bios) != 0x2000 {
+		return fmt.Errorf("cartridge: the FDS BIOS is 8192 bytes, not %d", len(bios))
+	}
+	return nil
+}
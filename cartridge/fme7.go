@@ -0,0 +1,131 @@
+package cartridge
+
+import (
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Sunsoft FME-7
+// -------------
+// The FME-7 (mapper 69) has a command register at 0x8000-0x9FFF selecting which of 16
+// registers a write to 0xA000-0xBFFF sets:
+//     - 0-7: the 1k CHR banks
+//     - 8: the 8k bank at 0x6000-0x7FFF, ROM or, with bit 6 set, PRG RAM enabled by bit 7
+//     - 9-B: the 8k PRG banks at 0x8000, 0xA000 and 0xC000, 0xE000 being the last bank
+//     - C: mirroring, vertical, horizontal or one of the single screens
+//     - D: IRQ control, bit 0 enabling the IRQ and bit 7 the counter. A write acknowledges
+//       the IRQ.
+//     - E-F: the low and high bytes of the IRQ counter
+//
+// The 16-bit IRQ counter counts down every CPU cycle while enabled, and raises the IRQ when it
+// wraps from 0 to 0xFFFF. The Sunsoft 5B is an FME-7 with a sound chip, whose registers are
+// selected by a write to 0xC000-0xDFFF and written at 0xE000-0xFFFF (see sunsoft5b.go). The
+// sound registers exist on every mapper 69 board here, and are silent until written.
+
+// FME7 is a Sunsoft FME-7 or 5B cartridge
+type FME7 struct {
+	*board
+	audio sunsoft5b
+
+	command        uint8
+	chrBanks       [8]uint8
+	prgBanks       [4]uint8 // 0x6000, 0x8000, 0xA000 and 0xC000
+	irqEnabled     bool
+	counterEnabled bool
+	counter        uint16
+}
+
+func newFME7(b *board) *FME7 {
+	f := &FME7{board: b}
+	f.audio.reset()
+	return f
+}
+
+func (f *FME7) PRG() cpu.Bus { return prgBus{f} }
+func (f *FME7) CHR() ppu.Bus { return chrBus{f} }
+
+// Output returns the output of the 5B's sound chip, see apu.Expansion
+func (f *FME7) Output() float64 {
+	return f.audio.output()
+}
+
+func (f *FME7) Clock() {
+	if f.counterEnabled {
+		f.counter--
+		if f.counter == 0xFFFF && f.irqEnabled {
+			f.setIRQ(true)
+		}
+	}
+	f.audio.clock()
+}
+
+func (f *FME7) readPRG(address uint16, readOnly bool) uint8 {
+	switch {
+	case address >= 0xE000:
+		return f.prgROM[bank(len(f.prgROM), -1, 0x2000)+int(address&0x1FFF)]
+	case address >= 0x8000:
+		b := f.prgBanks[(address-0x6000)>>13]
+		return f.prgROM[bank(len(f.prgROM), int(b&0x3F), 0x2000)+int(address&0x1FFF)]
+	case address >= 0x6000:
+		b := f.prgBanks[0]
+		switch {
+		case b&0x40 == 0:
+			return f.prgROM[bank(len(f.prgROM), int(b&0x3F), 0x2000)+int(address&0x1FFF)]
+		case b&0x80 != 0:
+			return f.readRAM(address)
+		}
+	}
+	return f.openBus()
+}
+
+func (f *FME7) writePRG(address uint16, data uint8) {
+	switch {
+	case address >= 0xE000:
+		f.audio.write(data)
+	case address >= 0xC000:
+		f.audio.selectRegister(data)
+	case address >= 0xA000:
+		f.writeRegister(data)
+	case address >= 0x8000:
+		f.command = data & 0x0F
+	case address >= 0x6000:
+		if f.prgBanks[0]&0xC0 == 0xC0 {
+			f.writeRAM(address, data)
+		}
+	}
+}
+
+// writeRegister writes the register selected by the command register
+func (f *FME7) writeRegister(data uint8) {
+	switch c := f.command; {
+	case c < 8:
+		f.chrBanks[c] = data
+	case c < 0x0C:
+		f.prgBanks[c-8] = data
+	case c == 0x0C:
+		f.setMirroring([]ppu.Mirroring{ppu.Vertical, ppu.Horizontal, ppu.SingleScreenA, ppu.SingleScreenB}[data&0x03])
+	case c == 0x0D:
+		f.irqEnabled = data&0x01 != 0
+		f.counterEnabled = data&0x80 != 0
+		f.setIRQ(false)
+	case c == 0x0E:
+		f.counter = f.counter&0xFF00 | uint16(data)
+	case c == 0x0F:
+		f.counter = f.counter&0x00FF | uint16(data)<<8
+	}
+}
+
+// chrOffset returns the offset in CHR of a PPU address
+func (f *FME7) chrOffset(address uint16) int {
+	return bank(len(f.chr), int(f.chrBanks[address>>10&0x07]), 0x400) + int(address&0x03FF)
+}
+
+func (f *FME7) readCHR(address uint16, readOnly bool) uint8 {
+	return f.chr[f.chrOffset(address)]
+}
+
+func (f *FME7) writeCHR(address uint16, data uint8) {
+	if f.chrRAM {
+		f.chr[f.chrOffset(address)] = data
+	}
+}
@@ -0,0 +1,116 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// fme7Register writes an FME-7 register through the command register
+func fme7Register(c *nes.Console, register uint8, data uint8) {
+	c.Bus.Write(0x8000, register)
+	c.Bus.Write(0xA000, data)
+}
+
+// TestFME7 checks the PRG and CHR banks, PRG RAM and mirroring
+func TestFME7(t *testing.T) {
+	// 128k PRG ROM, 16k CHR ROM
+	data := inesFile(t, 8, 2, 0x50, 0x40, 0, 0, 0, 0, 0, 0, 0, 0)
+	markBanks(t, data, 0x2000, 0x400)
+	irqProgram(t, data)
+	cart, err := Load(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c := nes.NewConsole(cart, ppu.Model2C02, 0)
+
+	fme7Register(c, 0x09, 0x03)
+	if data := c.Bus.Read(0x8010, true); data != 3 {
+		t.Errorf("Expected bank 3 at 0x8000, got %d", data)
+	}
+	if data := c.Bus.Read(0xE010, true); data != 15 {
+		t.Errorf("Expected the last bank at 0xE000, got %d", data)
+	}
+
+	fme7Register(c, 0x08, 0x02)
+	if data := c.Bus.Read(0x6010, true); data != 2 {
+		t.Errorf("Expected ROM at 0x6000, got %d", data)
+	}
+	fme7Register(c, 0x08, 0xC0)
+	c.Bus.Write(0x6010, 0xAB)
+	if data := c.Bus.Read(0x6010, true); data != 0xAB {
+		t.Errorf("Expected PRG RAM at 0x6000, got %#02x", data)
+	}
+
+	fme7Register(c, 0x05, 0x0B)
+	if data := c.PPUBus.Read(0x1410, true); data != 11 {
+		t.Errorf("Expected CHR bank 11 at 0x1400, got %d", data)
+	}
+	fme7Register(c, 0x05, 0x14)
+	if data := c.PPUBus.Read(0x1410, true); data != 4 {
+		t.Errorf("Expected CHR bank 20 to wrap to bank 4, got %d", data)
+	}
+
+	fme7Register(c, 0x0C, 0x01)
+	if c.PPUBus.Mirroring() != ppu.Horizontal {
+		t.Errorf("Expected horizontal mirroring, got %v", c.PPUBus.Mirroring())
+	}
+}
+
+// TestFME7IRQ checks that the IRQ is raised when the counter wraps
+func TestFME7IRQ(t *testing.T) {
+	data := inesFile(t, 8, 2, 0x50, 0x40, 0, 0, 0, 0, 0, 0, 0, 0)
+	irqProgram(t, data)
+	cart, err := Load(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c := nes.NewConsole(cart, ppu.Model2C02, 0)
+
+	fme7Register(c, 0x0E, 200)
+	fme7Register(c, 0x0F, 0)
+	fme7Register(c, 0x0D, 0x80) // counter without the IRQ
+	if n := runUntilIRQ(t, c, 1000); n >= 0 {
+		t.Fatalf("Expected no IRQ while disabled, got one after %d cycles", n)
+	}
+
+	fme7Register(c, 0x0E, 200)
+	fme7Register(c, 0x0F, 0)
+	fme7Register(c, 0x0D, 0x81)
+	if n := runUntilIRQ(t, c, 1000); n < 200 || n > 220 {
+		t.Errorf("Expected an IRQ about 201 cycles later, got %d", n)
+	}
+}
+
+// TestSunsoft5B checks that a tone is heard only once enabled, and that its period is 32 times
+// the tone period
+func TestSunsoft5B(t *testing.T) {
+	var s sunsoft5b
+	s.reset()
+	for _, w := range [][2]uint8{{0, 10}, {1, 0}, {7, 0xFF}, {8, 0x0F}} {
+		s.selectRegister(w[0])
+		s.write(w[1])
+	}
+	for i := 0; i < 1000; i++ {
+		s.clock()
+		if s.output() != sunsoft5bScale {
+			t.Fatalf("Expected a disabled tone to output its volume, got %f", s.output())
+		}
+	}
+
+	s.selectRegister(7)
+	s.write(0xFE) // tone A
+	changes := 0
+	last := s.output()
+	for i := 0; i < 320*10; i++ {
+		s.clock()
+		if s.output() != last {
+			changes++
+			last = s.output()
+		}
+	}
+	if changes != 20 {
+		t.Errorf("Expected 10 periods of 320 cycles, got %d changes", changes)
+	}
+}
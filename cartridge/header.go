@@ -0,0 +1,139 @@
+package cartridge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// iNES header
+// -----------
+// A .nes file is a 16 byte header, an optional 512 byte trainer, PRG ROM and CHR ROM. The header
+// describes the board:
+//     - bytes 4 and 5: PRG ROM in 16k units and CHR ROM in 8k units, no CHR ROM meaning 8k of
+//       CHR RAM
+//     - byte 6: mirroring (bit 0 set for vertical), battery (bit 1), trainer (bit 2),
+//       four-screen (bit 3) and the low nibble of the mapper number
+//     - byte 7: the next nibble of the mapper number, and the NES 2.0 identifier in bits 2-3
+//     - byte 8: PRG RAM in 8k units, 0 meaning 8k
+//
+// NES 2.0 headers extend this:
+//     - byte 8: the top nibble of the 12-bit mapper number and the submapper
+//     - byte 9: the high nibbles of the ROM sizes. A nibble of 0xF means that the size byte holds
+//       an exponent and a multiplier instead: 2^E * (M*2 + 1) bytes.
+//     - bytes 10 and 11: PRG RAM and CHR RAM, volatile in the low nibble and battery-backed in
+//       the high nibble, as a shift count: 64 << n bytes, or none for 0
+//
+// Old dumping tools wrote their name into bytes 7-15. A header that is not NES 2.0 and has
+// anything in bytes 12-15 is taken to be one of those, and only the low nibble of the mapper
+// number is used.
+
+const (
+	headerSize  = 16
+	trainerSize = 512
+)
+
+var inesMagic = []byte("NES\x1A")
+
+// Header describes a cartridge from its iNES or NES 2.0 header. Sizes are in bytes.
+type Header struct {
+	Mapper    int
+	Submapper int
+	PRGROM    int
+	CHRROM    int
+	PRGRAM    int // Volatile PRG RAM
+	PRGNVRAM  int // Battery-backed PRG RAM, or EEPROM
+	CHRRAM    int
+	CHRNVRAM  int
+	Mirroring ppu.Mirroring
+	Battery   bool
+	Trainer   bool
+	NES2      bool
+}
+
+// ParseHeader parses the 16 byte header at the start of a .nes file
+func ParseHeader(data []uint8) (Header, error) {
+	if len(data) < headerSize || !bytes.HasPrefix(data, inesMagic) {
+		return Header{}, errors.New("cartridge: not an iNES file")
+	}
+
+	h := Header{
+		Mapper:  int(data[6] >> 4),
+		Battery: data[6]&0x02 != 0,
+		Trainer: data[6]&0x04 != 0,
+		NES2:    data[7]&0x0C == 0x08,
+	}
+	switch {
+	case data[6]&0x08 != 0:
+		h.Mirroring = ppu.FourScreen
+	case data[6]&0x01 != 0:
+		h.Mirroring = ppu.Vertical
+	default:
+		h.Mirroring = ppu.Horizontal
+	}
+
+	if h.NES2 {
+		h.Mapper |= int(data[7]&0xF0) | int(data[8]&0x0F)<<8
+		h.Submapper = int(data[8] >> 4)
+		h.PRGROM = romSize(data[4], data[9]&0x0F, 16*1024)
+		h.CHRROM = romSize(data[5], data[9]>>4, 8*1024)
+		h.PRGRAM = ramSize(data[10] & 0x0F)
+		h.PRGNVRAM = ramSize(data[10] >> 4)
+		h.CHRRAM = ramSize(data[11] & 0x0F)
+		h.CHRNVRAM = ramSize(data[11] >> 4)
+		return h, nil
+	}
+
+	if !bytes.Equal(data[12:16], []uint8{0, 0, 0, 0}) {
+		// name of a dumping tool in bytes 7-15
+		data = append(data[:7:7], make([]uint8, 9)...)
+	}
+	h.Mapper |= int(data[7] & 0xF0)
+	h.PRGROM = int(data[4]) * 16 * 1024
+	h.CHRROM = int(data[5]) * 8 * 1024
+	if h.CHRROM == 0 {
+		h.CHRRAM = 8 * 1024
+	}
+	ram := int(data[8]) * 8 * 1024
+	if ram == 0 {
+		ram = 8 * 1024
+	}
+	if h.Battery {
+		h.PRGNVRAM = ram
+	} else {
+		h.PRGRAM = ram
+	}
+	return h, nil
+}
+
+// romSize returns the size of a ROM from the low byte and high nibble of its NES 2.0 size
+func romSize(lo uint8, hi uint8, unit int) int {
+	if hi == 0x0F {
+		return 1 << (lo >> 2) * (int(lo&0x03)*2 + 1)
+	}
+	return (int(hi)<<8 | int(lo)) * unit
+}
+
+// ramSize returns the size of a RAM from its NES 2.0 shift count
+func ramSize(shift uint8) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
+
+// split returns the PRG ROM and CHR ROM of a .nes file, skipping the header and trainer
+func (h Header) split(data []uint8) (prg []uint8, chr []uint8, err error) {
+	start := headerSize
+	if h.Trainer {
+		start += trainerSize
+	}
+	if len(data) < start+h.PRGROM+h.CHRROM {
+		return nil, nil, fmt.Errorf("cartridge: file is %d bytes, the header needs %d", len(data), start+h.PRGROM+h.CHRROM)
+	}
+	prg = data[start : start+h.PRGROM]
+	chr = data[start+h.PRGROM : start+h.PRGROM+h.CHRROM]
+	return prg, chr, nil
+}
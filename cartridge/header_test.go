@@ -0,0 +1,150 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// inesFile returns a .nes file with a header of bytes 4-15 and PRG and CHR filled with their
+// offsets' low bytes, as large as the header says
+func inesFile(t *testing.T, header ...uint8) []uint8 {
+	data := append([]uint8("NES\x1A"), header...)
+	h, err := ParseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Trainer {
+		data = append(data, make([]uint8, trainerSize)...)
+	}
+	for i := 0; i < h.PRGROM+h.CHRROM; i++ {
+		data = append(data, uint8(i))
+	}
+	return data
+}
+
+// markBanks writes the number of each bank of PRG ROM and CHR ROM into its byte at 0x10, PRG in
+// banks of prgBank bytes and CHR in banks of chrBank bytes
+func markBanks(t *testing.T, data []uint8, prgBank int, chrBank int) {
+	h, err := ParseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < h.PRGROM/prgBank; i++ {
+		data[headerSize+i*prgBank+0x10] = uint8(i)
+	}
+	for i := 0; i < h.CHRROM/chrBank; i++ {
+		data[headerSize+h.PRGROM+i*chrBank+0x10] = uint8(i)
+	}
+}
+
+// irqProgram writes a program into the last 8k of PRG ROM of a .nes file, which is at
+// 0xE000-0xFFFF on every board, that enables IRQs and loops, with an IRQ handler looping at 0xE100
+func irqProgram(t *testing.T, data []uint8) {
+	h, err := ParseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := headerSize + h.PRGROM - 0x2000
+	copy(data[last:], []uint8{0x58, 0x4C, 0x01, 0xE0})        // CLI, JMP $E001
+	copy(data[last+0x100:], []uint8{0x4C, 0x00, 0xE1})        // JMP $E100
+	copy(data[last+0x1FFC:], []uint8{0x00, 0xE0, 0x00, 0xE1}) // reset and IRQ vectors
+}
+
+// runUntilIRQ runs a console for up to a number of CPU cycles and returns the cycles it took for
+// the CPU to enter the IRQ handler of irqProgram, or -1 if it did not
+func runUntilIRQ(t *testing.T, c *nes.Console, cycles int) int {
+	for n := 0; n < cycles; {
+		if c.CPU.PC >= 0xE100 {
+			return n
+		}
+		step, err := c.StepInstruction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += step
+	}
+	return -1
+}
+
+// TestParseHeader checks the sizes, mapper and mirroring of iNES and NES 2.0 headers
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []uint8
+		expected Header
+	}{
+		{
+			"iNES", []uint8{2, 1, 0x41, 0x10, 0, 0, 0, 0, 0, 0, 0, 0},
+			Header{Mapper: 0x14, PRGROM: 0x8000, CHRROM: 0x2000, PRGRAM: 0x2000, Mirroring: ppu.Vertical},
+		},
+		{
+			"iNES battery and CHR RAM", []uint8{1, 0, 0x0A, 0, 2, 0, 0, 0, 0, 0, 0, 0},
+			Header{PRGROM: 0x4000, PRGNVRAM: 0x4000, CHRRAM: 0x2000, Mirroring: ppu.FourScreen, Battery: true},
+		},
+		{
+			"iNES with a tool name", []uint8{1, 1, 0x10, 'D', 'i', 's', 'k', 'D', 'u', 'd', 'e', '!'},
+			Header{Mapper: 1, PRGROM: 0x4000, CHRROM: 0x2000, PRGRAM: 0x2000},
+		},
+		{
+			"NES 2.0", []uint8{0x10, 0x00, 0x52, 0x48, 0x21, 0x01, 0x70, 0x07, 0, 0, 0, 0},
+			Header{Mapper: 0x145, Submapper: 2, PRGROM: 0x110 * 0x4000, CHRROM: 0, PRGNVRAM: 0x2000,
+				CHRRAM: 0x2000, Battery: true, NES2: true},
+		},
+		{
+			"NES 2.0 exponent", []uint8{0x0F, 0x00, 0x00, 0x08, 0, 0x0F, 0, 0, 0, 0, 0, 0},
+			Header{PRGROM: 8 * 7, NES2: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHeader(append([]uint8("NES\x1A"), tt.header...))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if h != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, h)
+			}
+		})
+	}
+
+	if _, err := ParseHeader([]uint8("NES\x1A")); err == nil {
+		t.Errorf("Expected an error for a short header")
+	}
+}
+
+// TestLoad checks the errors of Load
+func TestLoad(t *testing.T) {
+	if _, err := Load(inesFile(t, 1, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	data := inesFile(t, 2, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	if _, err := Load(data[:len(data)-1]); err == nil {
+		t.Errorf("Expected an error for a truncated file")
+	}
+	if _, err := Load(inesFile(t, 1, 1, 0xF0, 0xF0, 0, 0, 0, 0, 0, 0, 0, 0)); err == nil {
+		t.Errorf("Expected an error for an unsupported mapper")
+	}
+}
+
+// TestSaveRAM checks that PRG RAM is saved only with a battery
+func TestSaveRAM(t *testing.T) {
+	c, err := Load(inesFile(t, 1, 1, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ram := c.(nes.SaveRAM).SaveRAM(); len(ram) != 0x2000 {
+		t.Errorf("Expected 8k of save RAM with a battery, got %d bytes", len(ram))
+	}
+
+	c, err = Load(inesFile(t, 1, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ram := c.(nes.SaveRAM).SaveRAM(); ram != nil {
+		t.Errorf("Expected no save RAM without a battery, got %d bytes", len(ram))
+	}
+}
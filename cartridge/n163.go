@@ -0,0 +1,246 @@
+package cartridge
+
+import (
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Namco 163
+// ---------
+// The Namco 163 (mapper 19) has 128 bytes of internal RAM, holding the registers and wavetables
+// of its sound channels, and registers at:
+//     - 0x4800-0x4FFF: the internal RAM data port
+//     - 0x5000-0x57FF: the low 8 bits of the IRQ counter
+//     - 0x5800-0x5FFF: the high 7 bits of the IRQ counter, and the IRQ enable in bit 7
+//     - 0x8000-0xBFFF: eight 1k CHR banks. Banks 0xE0-0xFF select a page of CIRAM instead,
+//       unless disabled by 0xE800.
+//     - 0xC000-0xDFFF: the four 1k nametables, from CHR ROM or, for 0xE0-0xFF, CIRAM
+//     - 0xE000-0xE7FF: the 8k PRG bank at 0x8000, bit 6 disabling sound
+//     - 0xE800-0xEFFF: the 8k PRG bank at 0xA000, bits 6 and 7 disabling CIRAM at 0x0000 and
+//       0x1000
+//     - 0xF000-0xF7FF: the 8k PRG bank at 0xC000, 0xE000 being the last bank
+//     - 0xF800-0xFFFF: the internal RAM address in bits 0-6, incremented by each access to the
+//       data port if bit 7 is set. Also write protects PRG RAM, unless bits 4-7 are 0100, in
+//       which bits 0-3 protect its 2k quarters.
+//
+// Each bank register is 0x800 bytes wide. The 15-bit IRQ counter counts up every CPU cycle
+// while enabled, raises the IRQ when it reaches 0x7FFF and stops there. Writing it
+// acknowledges the IRQ.
+
+// n163Banks are the 8 CHR and 4 nametable bank registers
+const n163Banks = 12
+
+// N163 is a Namco 163 cartridge
+type N163 struct {
+	*board
+	audio n163Audio
+
+	banks      [n163Banks]uint8
+	prgBanks   [3]uint8
+	chrCIRAM   uint8 // 0xE800 bits 6-7, disabling CIRAM in CHR
+	address    uint8 // Internal RAM address, 0xF800
+	irqEnabled bool
+	counter    uint16
+}
+
+func newN163(b *board) *N163 {
+	return &N163{board: b}
+}
+
+func (n *N163) PRG() cpu.Bus { return prgBus{n} }
+func (n *N163) CHR() ppu.Bus { return chrBus{n} }
+
+// Connect also maps the nametables
+func (n *N163) Connect(slot nes.Slot) {
+	n.board.Connect(slot)
+	if slot.PPUBus != nil {
+		slot.PPUBus.Attach(0x2000, 0x3EFF, chrBus{n})
+	}
+}
+
+// Output returns the output of the wavetable channels, see apu.Expansion
+func (n *N163) Output() float64 {
+	return n.audio.output()
+}
+
+func (n *N163) Clock() {
+	if n.irqEnabled && n.counter < 0x7FFF {
+		n.counter++
+		if n.counter == 0x7FFF {
+			n.setIRQ(true)
+		}
+	}
+	n.audio.clock()
+}
+
+func (n *N163) readPRG(address uint16, readOnly bool) uint8 {
+	switch {
+	case address >= 0xE000:
+		return n.prgROM[bank(len(n.prgROM), -1, 0x2000)+int(address&0x1FFF)]
+	case address >= 0x8000:
+		b := n.prgBanks[(address-0x8000)>>13]
+		return n.prgROM[bank(len(n.prgROM), int(b&0x3F), 0x2000)+int(address&0x1FFF)]
+	case address >= 0x6000:
+		return n.readRAM(address)
+	case address >= 0x5800:
+		return uint8(n.counter>>8) | boolBit(n.irqEnabled, 7)
+	case address >= 0x5000:
+		return uint8(n.counter)
+	case address >= 0x4800:
+		data := n.audio.ram[n.address&0x7F]
+		if !readOnly {
+			n.increment()
+		}
+		return data
+	}
+	return n.openBus()
+}
+
+func (n *N163) writePRG(address uint16, data uint8) {
+	switch {
+	case address >= 0xF800:
+		n.address = data
+	case address >= 0xF000:
+		n.prgBanks[2] = data
+	case address >= 0xE800:
+		n.prgBanks[1] = data
+		n.chrCIRAM = data & 0xC0
+	case address >= 0xE000:
+		n.prgBanks[0] = data
+		n.audio.disabled = data&0x40 != 0
+	case address >= 0x8000:
+		n.banks[(address-0x8000)>>11] = data
+	case address >= 0x6000:
+		if n.ramWritable(address) {
+			n.writeRAM(address, data)
+		}
+	case address >= 0x5800:
+		n.counter = n.counter&0x00FF | uint16(data&0x7F)<<8
+		n.irqEnabled = data&0x80 != 0
+		n.setIRQ(false)
+	case address >= 0x5000:
+		n.counter = n.counter&0x7F00 | uint16(data)
+		n.setIRQ(false)
+	case address >= 0x4800:
+		n.audio.write(n.address&0x7F, data)
+		n.increment()
+	}
+}
+
+// increment advances the internal RAM address after an access to the data port, if enabled
+func (n *N163) increment() {
+	if n.address&0x80 != 0 {
+		n.address = 0x80 | (n.address+1)&0x7F
+	}
+}
+
+// ramWritable returns whether 0xF800 allows writes to PRG RAM at an address
+func (n *N163) ramWritable(address uint16) bool {
+	return n.address&0xF0 == 0x40 && n.address&(1<<((address-0x6000)>>11)) == 0
+}
+
+// chrMemory returns the memory and offset of a PPU address below 0x3F00, and whether it is
+// CIRAM
+func (n *N163) chrMemory(address uint16) ([]uint8, int, bool) {
+	if address >= 0x2000 {
+		address &= 0x2FFF
+	}
+	b := n.banks[address>>10]
+	ciram := b >= 0xE0
+	if address < 0x1000 && n.chrCIRAM&0x40 != 0 || address < 0x2000 && address >= 0x1000 && n.chrCIRAM&0x80 != 0 {
+		ciram = false
+	}
+	if ciram && n.slot.PPUBus != nil {
+		return n.slot.PPUBus.CIRAM(), int(b&0x01)*0x400 + int(address&0x03FF), true
+	}
+	return n.chr, bank(len(n.chr), int(b), 0x400) + int(address&0x03FF), false
+}
+
+func (n *N163) readCHR(address uint16, readOnly bool) uint8 {
+	m, offset, _ := n.chrMemory(address)
+	return m[offset]
+}
+
+func (n *N163) writeCHR(address uint16, data uint8) {
+	if m, offset, ciram := n.chrMemory(address); ciram || n.chrRAM {
+		m[offset] = data
+	}
+}
+
+// boolBit returns a byte with a bit set if b is true
+func boolBit(b bool, bit int) uint8 {
+	if b {
+		return 1 << bit
+	}
+	return 0
+}
+
+// Namco 163 audio
+// ---------------
+// The sound channels are in the top of the internal RAM, channel n at 0x40 + 8n:
+//     - +0, +2, +4 bits 0-1: the 18-bit frequency
+//     - +1, +3, +5: the 24-bit phase, whose top byte is the position in the waveform
+//     - +4 bits 2-7: the waveform length, 256 - 4 * L samples
+//     - +6: the address of the waveform in samples
+//     - +7: the 4-bit volume, and in channel 7 the number of channels minus one in bits 4-6
+//
+// Samples are 4 bits, two to a byte, low nibble first, and centred on 8. The enabled channels
+// are the last ones, channel 7 down. The chip updates one channel every 15 CPU cycles, adding
+// its frequency to its phase, and outputs the channels in turn rather than mixing them, so
+// every channel is louder when fewer are enabled.
+
+// n163Scale scales the output of a channel to the APU's, about 0.18 at full volume with one
+// channel enabled
+const n163Scale = 0.0015
+
+type n163Audio struct {
+	ram      [128]uint8
+	disabled bool
+	divider  int // CPU cycles since the last channel update
+	channel  int // Channel updated last
+	level    int // Output of the channel updated last
+}
+
+// write writes the internal RAM
+func (a *n163Audio) write(address uint8, data uint8) {
+	a.ram[address] = data
+}
+
+// channels returns the number of enabled channels
+func (a *n163Audio) channels() int {
+	return int(a.ram[0x7F]>>4&0x07) + 1
+}
+
+// clock is called every CPU cycle
+func (a *n163Audio) clock() {
+	a.divider++
+	if a.divider < 15 {
+		return
+	}
+	a.divider = 0
+
+	a.channel--
+	if a.channel < 8-a.channels() {
+		a.channel = 7
+	}
+	r := a.ram[0x40+a.channel*8 : 0x48+a.channel*8]
+
+	frequency := uint32(r[0]) | uint32(r[2])<<8 | uint32(r[4]&0x03)<<16
+	phase := uint32(r[1]) | uint32(r[3])<<8 | uint32(r[5])<<16
+	length := (256 - uint32(r[4]&0xFC)) << 16
+	phase = (phase + frequency) % length
+	r[1], r[3], r[5] = uint8(phase), uint8(phase>>8), uint8(phase>>16)
+
+	sample := uint8(phase>>16) + r[6]
+	nibble := a.ram[sample>>1] >> (4 * (sample & 1)) & 0x0F
+	a.level = (int(nibble) - 8) * int(r[7]&0x0F)
+}
+
+// output returns the output of the channel updated last
+func (a *n163Audio) output() float64 {
+	if a.disabled {
+		return 0
+	}
+	return float64(a.level) * n163Scale
+}
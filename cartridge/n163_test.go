@@ -0,0 +1,141 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// newN163Console returns a console with a Namco 163 cartridge of 128k PRG ROM and 128k CHR ROM
+func newN163Console(t *testing.T) *nes.Console {
+	data := inesFile(t, 8, 16, 0x30, 0x10, 0, 0, 0, 0, 0, 0, 0, 0)
+	markBanks(t, data, 0x2000, 0x400)
+	irqProgram(t, data)
+	cart, err := Load(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return nes.NewConsole(cart, ppu.Model2C02, 0)
+}
+
+// TestN163 checks the PRG and CHR banks, and CIRAM in the nametables and pattern tables
+func TestN163(t *testing.T) {
+	c := newN163Console(t)
+
+	c.Bus.Write(0xE800, 0x05)
+	if data := c.Bus.Read(0xA010, true); data != 5 {
+		t.Errorf("Expected bank 5 at 0xA000, got %d", data)
+	}
+	if data := c.Bus.Read(0xE010, true); data != 15 {
+		t.Errorf("Expected the last bank at 0xE000, got %d", data)
+	}
+
+	c.Bus.Write(0x8800, 0x21)
+	if data := c.PPUBus.Read(0x0410, true); data != 0x21 {
+		t.Errorf("Expected CHR bank 0x21 at 0x0400, got %#02x", data)
+	}
+
+	// nametables from CIRAM, as single screen, and from CHR ROM
+	for i := uint16(0); i < 4; i++ {
+		c.Bus.Write(0xC000+i*0x800, 0xE1)
+	}
+	c.PPUBus.Write(0x2C05, 0x77)
+	if data := c.PPUBus.CIRAM()[0x405]; data != 0x77 {
+		t.Errorf("Expected the second page of CIRAM at 0x2C00, got %#02x", data)
+	}
+	if data := c.PPUBus.Read(0x2005, true); data != 0x77 {
+		t.Errorf("Expected the second page of CIRAM at 0x2000, got %#02x", data)
+	}
+	c.Bus.Write(0xC000, 0x03)
+	if data := c.PPUBus.Read(0x2010, true); data != 3 {
+		t.Errorf("Expected CHR bank 3 at 0x2000, got %d", data)
+	}
+
+	// CIRAM in the pattern tables, unless disabled
+	c.Bus.Write(0x8000, 0xE1)
+	if data := c.PPUBus.Read(0x0005, true); data != 0x77 {
+		t.Errorf("Expected CIRAM at 0x0000, got %#02x", data)
+	}
+	c.Bus.Write(0xE800, 0x45)
+	if data := c.PPUBus.Read(0x0010, true); data != 0xE1&0x7F {
+		t.Errorf("Expected CHR bank 0x61 at 0x0000 with CIRAM disabled, got %#02x", data)
+	}
+}
+
+// TestN163RAM checks the internal RAM data port and the PRG RAM write protection
+func TestN163RAM(t *testing.T) {
+	c := newN163Console(t)
+
+	c.Bus.Write(0xF800, 0x80|0x7E)
+	c.Bus.Write(0x4800, 0x11)
+	c.Bus.Write(0x4800, 0x22)
+	c.Bus.Write(0x4800, 0x33)
+	c.Bus.Write(0xF800, 0x80|0x7E)
+	for _, expected := range []uint8{0x11, 0x22, 0x33} {
+		if data := c.Bus.Read(0x4800, false); data != expected {
+			t.Errorf("Expected %#02x from the data port, got %#02x", expected, data)
+		}
+	}
+
+	c.Bus.Write(0x6000, 0xAA)
+	if data := c.Bus.Read(0x6000, true); data == 0xAA {
+		t.Errorf("Expected PRG RAM to be write protected")
+	}
+	c.Bus.Write(0xF800, 0x42) // protect 0x6800-0x6FFF
+	c.Bus.Write(0x6000, 0xAA)
+	c.Bus.Write(0x6800, 0xAA)
+	if a, b := c.Bus.Read(0x6000, true), c.Bus.Read(0x6800, true); a != 0xAA || b == 0xAA {
+		t.Errorf("Expected only 0x6800-0x6FFF to be protected, got %#02x and %#02x", a, b)
+	}
+}
+
+// TestN163IRQ checks that the IRQ is raised when the counter reaches 0x7FFF
+func TestN163IRQ(t *testing.T) {
+	c := newN163Console(t)
+
+	c.Bus.Write(0x5000, 0x00)
+	c.Bus.Write(0x5800, 0x7F) // disabled
+	if n := runUntilIRQ(t, c, 1000); n >= 0 {
+		t.Fatalf("Expected no IRQ while disabled, got one after %d cycles", n)
+	}
+
+	c.Bus.Write(0x5000, 0xFF-100)
+	c.Bus.Write(0x5800, 0xFF)
+	if n := runUntilIRQ(t, c, 1000); n < 100 || n > 120 {
+		t.Errorf("Expected an IRQ about 100 cycles later, got %d", n)
+	}
+	if lo, hi := c.Bus.Read(0x5000, true), c.Bus.Read(0x5800, true); lo != 0xFF || hi != 0xFF {
+		t.Errorf("Expected the counter to stop at 0x7FFF, got %#02x%02x", hi, lo)
+	}
+}
+
+// TestN163Audio checks that a channel plays its waveform at its volume
+func TestN163Audio(t *testing.T) {
+	var a n163Audio
+	// a waveform of 4 samples, 0, 15, 8, 8, played one sample per update
+	a.write(0x00, 0xF0)
+	a.write(0x01, 0x88)
+	a.write(0x7C, 0xFD) // length 4, phase += 0x10000
+	a.write(0x7F, 0x0A) // one channel, volume 10
+
+	var levels []float64
+	for i := 0; i < 15*4; i++ {
+		a.clock()
+		if i%15 == 14 {
+			levels = append(levels, a.output()/n163Scale)
+		}
+	}
+	expected := []float64{70, 0, 0, -80}
+	for i := range expected {
+		if levels[i] != expected[i] {
+			t.Errorf("Expected levels %v, got %v", expected, levels)
+			break
+		}
+	}
+
+	a.disabled = true
+	if a.output() != 0 {
+		t.Errorf("Expected no output when disabled")
+	}
+}
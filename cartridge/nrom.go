@@ -0,0 +1,46 @@
+package cartridge
+
+import (
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// NROM is mapper 0, the board without a mapper: 16k or 32k of PRG ROM at 0x8000-0xFFFF, a 16k
+// ROM appearing twice, and 8k of CHR ROM or RAM. Family BASIC has PRG RAM at 0x6000-0x7FFF, which
+// the header declares. Mirroring is soldered.
+type NROM struct {
+	*board
+}
+
+func newNROM(b *board) *NROM {
+	return &NROM{b}
+}
+
+func (n *NROM) PRG() cpu.Bus { return prgBus{n} }
+func (n *NROM) CHR() ppu.Bus { return chrBus{n} }
+
+func (n *NROM) readPRG(address uint16, readOnly bool) uint8 {
+	switch {
+	case address >= 0x8000 && len(n.prgROM) > 0:
+		return n.prgROM[int(address-0x8000)%len(n.prgROM)]
+	case address >= 0x6000:
+		return n.readRAM(address)
+	}
+	return n.openBus()
+}
+
+func (n *NROM) writePRG(address uint16, data uint8) {
+	if address >= 0x6000 && address < 0x8000 {
+		n.writeRAM(address, data)
+	}
+}
+
+func (n *NROM) readCHR(address uint16, readOnly bool) uint8 {
+	return n.chr[int(address&0x1FFF)%len(n.chr)]
+}
+
+func (n *NROM) writeCHR(address uint16, data uint8) {
+	if n.chrRAM {
+		n.chr[int(address&0x1FFF)%len(n.chr)] = data
+	}
+}
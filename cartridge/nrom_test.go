@@ -0,0 +1,59 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/nes"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// TestNROM checks the mirroring of 16k of PRG ROM, PRG RAM and CHR RAM
+func TestNROM(t *testing.T) {
+	data := inesFile(t, 1, 0, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	data[16+0x3FFC], data[16+0x3FFD] = 0x34, 0x92 // reset vector
+	cart, err := Load(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c := nes.NewConsole(cart, ppu.Model2C02, 0)
+
+	if c.CPU.PC != 0x9234 {
+		t.Errorf("Expected the reset vector of the mirrored ROM, got %#04x", c.CPU.PC)
+	}
+	if a, b := c.Bus.Read(0x8123, true), c.Bus.Read(0xC123, true); a != 0x23 || b != 0x23 {
+		t.Errorf("Expected 16k to be mirrored, got %#02x and %#02x", a, b)
+	}
+	if cart.Mirroring() != ppu.Vertical {
+		t.Errorf("Expected vertical mirroring")
+	}
+
+	c.Bus.Write(0x6010, 0xAB)
+	if data := c.Bus.Read(0x6010, true); data != 0xAB {
+		t.Errorf("Expected PRG RAM, got %#02x", data)
+	}
+	c.Bus.Write(0x8000, 0xAB)
+	if data := c.Bus.Read(0x8000, true); data != 0x00 {
+		t.Errorf("Expected PRG ROM to ignore writes, got %#02x", data)
+	}
+
+	c.PPUBus.Write(0x1FF0, 0xCD)
+	if data := c.PPUBus.Read(0x1FF0, true); data != 0xCD {
+		t.Errorf("Expected CHR RAM, got %#02x", data)
+	}
+}
+
+// TestNROMCHRROM checks that CHR ROM ignores writes
+func TestNROMCHRROM(t *testing.T) {
+	cart, err := Load(inesFile(t, 2, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	chr := cart.CHR()
+	chr.Write(0x0010, 0xFF)
+	if data := chr.Read(0x0010, true); data != 0x10 {
+		t.Errorf("Expected CHR ROM to ignore writes, got %#02x", data)
+	}
+	if cart.Mirroring() != ppu.Horizontal {
+		t.Errorf("Expected horizontal mirroring")
+	}
+}
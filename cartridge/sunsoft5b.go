@@ -0,0 +1,196 @@
+package cartridge
+
+import "math"
+
+// Sunsoft 5B audio
+// ----------------
+// The 5B's sound chip is a YM2149F, a variant of the AY-3-8910, clocked at the CPU clock. It has
+// three square wave channels, a noise generator and an envelope generator shared by the
+// channels, set by 14 registers:
+//     - 0-5: the 12-bit tone periods of channels A, B and C, low byte first
+//     - 6: the 5-bit noise period
+//     - 7: bits 0-2 disable the tone of each channel, bits 3-5 its noise
+//     - 8-A: the volume of each channel, or the envelope if bit 4 is set
+//     - B-C: the 16-bit envelope period
+//     - D: the envelope shape, whose write restarts the envelope
+//
+// The tone and noise counters are clocked every 16 CPU cycles, and a square wave toggles every
+// period, for a frequency of CPU / (32 * period). The noise is a 17-bit LFSR stepped at half
+// that rate. The envelope has 32 steps, one every 8 * period CPU cycles, and its shape decides
+// whether it rises or falls, and whether it repeats, alternates or holds at the end. A channel
+// outputs its volume while both its tone and its noise, where enabled, are high.
+//
+// Volumes are logarithmic, 1.5 dB per envelope step and 3 dB per step of the 4-bit volumes.
+
+// sunsoft5bScale scales the output of a channel at full volume to about that of a pulse channel
+const sunsoft5bScale = 0.15
+
+// sunsoft5bLevels holds the output of each of the 32 envelope levels
+var sunsoft5bLevels [32]float64
+
+func init() {
+	for i := 1; i < len(sunsoft5bLevels); i++ {
+		sunsoft5bLevels[i] = math.Pow(10, float64(i-31)*1.5/20)
+	}
+}
+
+// Envelope shape bits of register D
+const (
+	envHold      uint8 = 0x01
+	envAlternate uint8 = 0x02
+	envAttack    uint8 = 0x04
+	envContinue  uint8 = 0x08
+)
+
+type sunsoft5b struct {
+	register uint8
+	regs     [16]uint8
+	divider  int // CPU cycles
+
+	toneCounters [3]uint16
+	tones        [3]bool
+	noiseCounter uint8
+	noiseHalf    bool
+	lfsr         uint32
+
+	envCounter uint16
+	envStep    uint8 // 0-31
+	envAttack  bool  // Rising rather than falling
+	envHolding bool
+	envHeld    uint8 // Level held at the end of the envelope
+}
+
+// reset returns the chip to its power on state
+func (s *sunsoft5b) reset() {
+	*s = sunsoft5b{lfsr: 1}
+}
+
+// selectRegister selects the register the next write goes to. Writes with the high nibble set
+// select nothing.
+func (s *sunsoft5b) selectRegister(data uint8) {
+	s.register = data
+}
+
+// write writes the selected register
+func (s *sunsoft5b) write(data uint8) {
+	if s.register > 0x0F {
+		return
+	}
+	s.regs[s.register] = data
+	if s.register == 0x0D {
+		s.envStep = 0
+		s.envAttack = data&envAttack != 0
+		s.envHolding = false
+		s.envCounter = 0
+	}
+}
+
+// tonePeriod returns the period of a channel's tone
+func (s *sunsoft5b) tonePeriod(ch int) uint16 {
+	return uint16(s.regs[ch*2]) | uint16(s.regs[ch*2+1]&0x0F)<<8
+}
+
+// clock is called every CPU cycle
+func (s *sunsoft5b) clock() {
+	s.divider++
+	if s.divider%8 == 0 {
+		s.clockEnvelope()
+	}
+	if s.divider%16 != 0 {
+		return
+	}
+	s.divider = 0
+
+	for ch := range s.tones {
+		s.toneCounters[ch]++
+		if s.toneCounters[ch] >= max(s.tonePeriod(ch), 1) {
+			s.toneCounters[ch] = 0
+			s.tones[ch] = !s.tones[ch]
+		}
+	}
+
+	s.noiseCounter++
+	if s.noiseCounter >= max(s.regs[6]&0x1F, 1) {
+		s.noiseCounter = 0
+		s.noiseHalf = !s.noiseHalf
+		if s.noiseHalf {
+			bit := (s.lfsr ^ s.lfsr>>3) & 1
+			s.lfsr = s.lfsr>>1 | bit<<16
+		}
+	}
+}
+
+// clockEnvelope is called every 8 CPU cycles
+func (s *sunsoft5b) clockEnvelope() {
+	if s.envHolding {
+		return
+	}
+	s.envCounter++
+	if s.envCounter < max(uint16(s.regs[0x0B])|uint16(s.regs[0x0C])<<8, 1) {
+		return
+	}
+	s.envCounter = 0
+
+	if s.envStep < 31 {
+		s.envStep++
+		return
+	}
+
+	// end of a cycle
+	shape := s.regs[0x0D]
+	switch {
+	case shape&envContinue == 0:
+		s.envHolding = true
+		s.envHeld = 0
+	case shape&envHold != 0:
+		if shape&envAlternate != 0 {
+			s.envAttack = !s.envAttack
+		}
+		s.envHolding = true
+		s.envHeld = 0
+		if s.envAttack {
+			s.envHeld = 31
+		}
+	default:
+		if shape&envAlternate != 0 {
+			s.envAttack = !s.envAttack
+		}
+		s.envStep = 0
+	}
+}
+
+// envelope returns the level of the envelope, 0-31
+func (s *sunsoft5b) envelope() uint8 {
+	switch {
+	case s.envHolding:
+		return s.envHeld
+	case s.envAttack:
+		return s.envStep
+	}
+	return 31 - s.envStep
+}
+
+// output returns the sum of the outputs of the three channels
+func (s *sunsoft5b) output() float64 {
+	mixer := s.regs[7]
+	noise := s.lfsr&1 != 0
+	out := 0.0
+	for ch := range s.tones {
+		tone := s.tones[ch] || mixer&(1<<ch) != 0
+		n := noise || mixer&(8<<ch) != 0
+		if !tone || !n {
+			continue
+		}
+
+		volume := s.regs[8+ch]
+		level := s.envelope()
+		if volume&0x10 == 0 {
+			level = 0
+			if v := volume & 0x0F; v > 0 {
+				level = v*2 + 1
+			}
+		}
+		out += sunsoft5bLevels[level]
+	}
+	return out * sunsoft5bScale
+}
@@ -0,0 +1,171 @@
+// Command nsf2wav renders the songs of an NSF or NSFe file to WAV files without a display or
+// an audio device.
+//
+// Usage:
+//
+//	nsf2wav [flags] file.nsf
+//
+// Every song is rendered, in the file's playlist order if it has one, unless -song selects one.
+// Songs are written next to the input as name-NN.wav, numbered from 1, or to -o when a single
+// song is rendered. Songs last as long as their NSFe metadata says, or -length, and then fade
+// out over their fade time, or -fade.
+//
+// Only the 2A03's own channels are played. Files that use an expansion sound chip are rendered
+// without it, with a warning.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cbertinato/go-nes/apu"
+	"github.com/cbertinato/go-nes/audio"
+	"github.com/cbertinato/go-nes/nsf"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+func main() {
+	song := flag.Int("song", 0, "song to render, from 1, or 0 for all")
+	out := flag.String("o", "", "output file when rendering a single song")
+	length := flag.Duration("length", 2*time.Minute+30*time.Second, "length of songs without one")
+	fade := flag.Duration("fade", 8*time.Second, "fade out of songs without one")
+	rate := flag.Int("rate", 48000, "sample rate in Hz")
+	pal := flag.Bool("pal", false, "play at PAL speed rather than in the file's region")
+	float := flag.Bool("float", false, "write 32-bit float samples instead of 16-bit PCM")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: nsf2wav [flags] file.nsf")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+	if *rate < 8000 || *rate > 192000 {
+		fail(fmt.Errorf("sample rate %d is not between 8000 and 192000 Hz", *rate))
+	}
+
+	f, err := nsf.LoadFile(path)
+	if err != nil {
+		fail(err)
+	}
+	if chips := chipNames(f.Chips); len(chips) > 0 {
+		fmt.Fprintf(os.Stderr, "nsf2wav: warning: %s audio is not emulated and will be silent\n",
+			strings.Join(chips, ", "))
+	}
+
+	songs := f.Playlist
+	if len(songs) == 0 {
+		for i := 0; i < f.Songs; i++ {
+			songs = append(songs, i)
+		}
+	}
+	if *song > 0 {
+		songs = []int{*song - 1}
+	}
+
+	region := f.NativeRegion()
+	if *pal {
+		region = ppu.PAL
+	}
+	format := audio.Int16
+	if *float {
+		format = audio.Float32
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, s := range songs {
+		name := fmt.Sprintf("%s-%02d.wav", base, s+1)
+		if *out != "" && len(songs) == 1 {
+			name = *out
+		}
+
+		track := f.Track(s)
+		if track.Length == 0 {
+			track.Length = *length
+		}
+		if track.Fade < 0 {
+			track.Fade = *fade
+		}
+
+		if err := render(f, s, region, *rate, format, track, name); err != nil {
+			fail(err)
+		}
+		fmt.Printf("%s: song %d, %v\n", name, s+1, track.Length+track.Fade)
+	}
+}
+
+// render writes a song to a WAV file
+func render(f *nsf.File, song int, region ppu.Region, rate int, format audio.Format, track nsf.Track, path string) error {
+	p := nsf.NewPlayer(f, region, rate)
+	if err := p.Select(song); err != nil {
+		return err
+	}
+
+	w, err := audio.CreateWAV(path, rate, format)
+	if err != nil {
+		return err
+	}
+	fader := &fader{
+		Sink:  w,
+		start: int(track.Length.Seconds() * float64(rate)),
+		end:   int((track.Length + track.Fade).Seconds() * float64(rate)),
+	}
+	r := audio.NewRecorder(p.Mixer, fader, [apu.NumChannels]audio.Sink{})
+
+	for fader.n < fader.end {
+		if err := p.Step(); err != nil {
+			r.Close()
+			return err
+		}
+		if err := r.EndFrame(); err != nil {
+			r.Close()
+			return err
+		}
+	}
+	return r.Close()
+}
+
+// fader fades out the samples written to a sink from start to end and drops those after it
+type fader struct {
+	audio.Sink
+	n     int // Samples written
+	start int
+	end   int
+}
+
+func (f *fader) WriteSamples(samples []float32) error {
+	out := make([]float32, 0, len(samples))
+	for _, s := range samples {
+		if f.n >= f.end {
+			break
+		}
+		if f.n >= f.start {
+			s *= float32(f.end-f.n) / float32(f.end-f.start)
+		}
+		out = append(out, s)
+		f.n++
+	}
+	return f.Sink.WriteSamples(out)
+}
+
+// chipNames returns the names of the expansion sound chips in a mask of nsf.Chip bits
+func chipNames(chips uint8) []string {
+	names := []string{"VRC6", "VRC7", "FDS", "MMC5", "N163", "Sunsoft 5B", "VT02"}
+	var out []string
+	for i, name := range names {
+		if chips&(1<<i) != 0 {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// fail prints an error and exits
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "nsf2wav:", err)
+	os.Exit(1)
+}
@@ -20,15 +20,17 @@ package cpu
 
 // IMM (immediate): The next byte is to be used as a value.
 func (c *MOS6502) imm() uint8 {
-	c.fetched = c.A
+	c.absAddr = c.PC
+	c.fetched = c.read(c.PC)
+	c.PC++
 	return 0
 }
 
 // IMP (implied): In the implied addressing mode, the address containing the operand is implicitly stated
-// in the operation code of the instruction.
+// in the operation code of the instruction. Instructions that operate on the accumulator, such as
+// ASL A, use this mode with the accumulator as the operand.
 func (c *MOS6502) imp() uint8 {
-	c.absAddr = c.PC
-	c.PC++
+	c.fetched = c.A
 	return 0
 }
 
@@ -152,7 +154,7 @@ func (c *MOS6502) izX() uint8 {
 	ptr := (val + uint16(c.X)) & 0x00FF
 
 	lo := uint16(c.read(ptr))
-	hi := uint16(c.read(ptr + 1))
+	hi := uint16(c.read((ptr + 1) & 0x00FF))
 
 	c.absAddr = (hi << 8) | lo
 
@@ -167,8 +169,9 @@ func (c *MOS6502) izX() uint8 {
 // result is the high 8 bits of the effective address. 
 func (c *MOS6502) izY() uint8 {
 	val := uint16(c.read(c.PC)) & 0x00FF
+	c.PC++
 	lo := uint16(c.read(val))
-	hi := uint16(c.read(val + 1))
+	hi := uint16(c.read((val + 1) & 0x00FF))
 
 	c.absAddr = (hi << 8) | lo
 	c.absAddr += uint16(c.Y)
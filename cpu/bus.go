@@ -12,14 +12,63 @@ type DevBus struct {
 }
 
 func (b *DevBus) Read(address uint16, readOnly bool) uint8 {
-    if address >= 0 && address < 0xFFFF {
-        return b.ram[address]
-    }
-    return 0
+    return b.ram[address]
 }
 
 func (b *DevBus) Write(address uint16, data uint8) {
-    if address >= 0 && address < 0xFFFF {
-        b.ram[address] = data
+    b.ram[address] = data
+}
+
+// mapping associates a range of CPU addresses with a device
+type mapping struct {
+    lo     uint16
+    hi     uint16
+    device Bus
+}
+
+// MappedBus is the CPU bus of the NES. The 2k of internal RAM is mirrored through
+// 0x0000-0x1FFF and every other address range is routed to the device attached to it.
+// Reads from addresses that nothing drives return the last value the CPU saw on the data bus
+// (open bus), or 0 without a CPU.
+type MappedBus struct {
+    ram      [2 * 1024]uint8 // 2k of internal RAM
+    mappings []mapping
+    CPU      *MOS6502 // CPU whose data bus is read back at unmapped addresses
+}
+
+// Attach maps the inclusive address range lo-hi to a device. Devices receive the full CPU
+// address and are responsible for their own mirroring. Ranges attached later take precedence
+// over earlier ones where they overlap.
+func (b *MappedBus) Attach(lo uint16, hi uint16, device Bus) {
+    b.mappings = append([]mapping{{lo, hi, device}}, b.mappings...)
+}
+
+// device returns the device mapped at an address, or nil if the address is unmapped
+func (b *MappedBus) device(address uint16) Bus {
+    for _, m := range b.mappings {
+        if address >= m.lo && address <= m.hi {
+            return m.device
+        }
+    }
+    return nil
+}
+
+func (b *MappedBus) Read(address uint16, readOnly bool) uint8 {
+    var data uint8
+    if address < 0x2000 {
+        data = b.ram[address&0x07FF]
+    } else if d := b.device(address); d != nil {
+        data = d.Read(address, readOnly)
+    } else if b.CPU != nil {
+        data = b.CPU.dataBus
     }
-}
\ No newline at end of file
+    return data
+}
+
+func (b *MappedBus) Write(address uint16, data uint8) {
+    if address < 0x2000 {
+        b.ram[address&0x07FF] = data
+    } else if d := b.device(address); d != nil {
+        d.Write(address, data)
+    }
+}
@@ -0,0 +1,42 @@
+package cpu
+
+import (
+	"testing"
+)
+
+// TestMappedBusRAMMirror checks that the internal RAM is mirrored through 0x1FFF
+func TestMappedBusRAMMirror(t *testing.T) {
+	b := MappedBus{}
+
+	b.Write(0x0801, 0xAB)
+
+	for _, addr := range []uint16{0x0001, 0x0801, 0x1001, 0x1801} {
+		if data := b.Read(addr, false); data != 0xAB {
+			t.Errorf("Expected %#02x at %#04x, got %#02x", 0xAB, addr, data)
+		}
+	}
+}
+
+// TestMappedBusAttach checks that accesses are routed to attached devices and that unmapped
+// reads return the CPU's data bus (open bus)
+func TestMappedBusAttach(t *testing.T) {
+	c := Create6502()
+	b := MappedBus{CPU: &c}
+	c.Bus = &b
+	d := DevBus{}
+	b.Attach(0x6000, 0x7FFF, &d)
+
+	b.Write(0x6000, 0xCA)
+	if d.ram[0x6000] != 0xCA {
+		t.Errorf("Write not routed to device")
+	}
+
+	d.ram[0x7FFE] = 0xFE
+	if data := c.read(0x7FFE); data != 0xFE {
+		t.Errorf("Expected %#02x, got %#02x", 0xFE, data)
+	}
+
+	if data := b.Read(0x5000, false); data != 0xFE {
+		t.Errorf("Expected open bus %#02x, got %#02x", 0xFE, data)
+	}
+}
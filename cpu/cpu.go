@@ -5,14 +5,14 @@ package cpu
 // Instruction represents a single 6502 instruction
 type Instruction struct {
 	name     string
-	op       func() uint8
+	op       func(*MOS6502) uint8
 	addrMode string
 	cycles   uint8
 }
 
 // MOS6502 represents the state of the CPU
 type MOS6502 struct {
-	Bus            *DevBus
+	Bus            Bus
 	A              uint8         // Accumulator
 	X              uint8         // X register
 	Y              uint8         // Y register
@@ -26,6 +26,25 @@ type MOS6502 struct {
 	relAddr        uint16
 	opcode         uint8
 	addrModeLookup map[string]func(*MOS6502) uint8
+	nmiLine        bool      // Level of the NMI input, true when asserted
+	nmiPrev        bool      // Level of the NMI input at the previous clock
+	nmiPending     bool      // An NMI edge has been detected and is waiting to be serviced
+	irqLines       IRQSource // Sources currently asserting the IRQ input
+	clockCount     uint64    // Number of cycles since power on
+	dataBus        uint8     // Last value driven on the data bus
+	lastRead       uint16    // Address of the last read made by an instruction
+	jammed         bool      // Halted on an opcode that cannot be executed
+
+	// DMA state, see dma.go
+	oamDMA     bool
+	oamDMAAddr uint16
+	oamDMAHalt bool
+	oamDMAFull bool // A byte has been read and is waiting to be written
+	oamDMAData uint8
+	dmcDMA     bool
+	dmcDMAAddr uint16
+	dmcDMAWait uint8
+	dmcDMADone func(uint8)
 }
 
 // CPU is the primary interface for the 6502 emulator
@@ -53,13 +72,27 @@ const (
 )
 
 func (c *MOS6502) read(address uint16) uint8 {
-	return c.Bus.Read(address, false)
+	c.lastRead = address
+	c.dataBus = c.Bus.Read(address, false)
+	return c.dataBus
 }
 
 func (c *MOS6502) write(address uint16, data uint8) {
+	c.dataBus = data
 	c.Bus.Write(address, data)
 }
 
+// Cycles returns the number of cycles the CPU has been clocked since power on
+func (c *MOS6502) Cycles() uint64 {
+	return c.clockCount
+}
+
+// DataBus returns the last value driven on the data bus by the CPU or a device. Registers
+// return it for the bits they do not drive (open bus).
+func (c *MOS6502) DataBus() uint8 {
+	return c.dataBus
+}
+
 // GetFlag returns the value of the specified flag
 func (c *MOS6502) GetFlag(f uint8) uint8 {
 	return c.Status & f
@@ -75,12 +108,65 @@ func (c *MOS6502) SetFlag(f uint8, cond bool) {
 	}
 }
 
-// Perform one clock cycle of computation
-func (c *MOS6502) clock() {
+// SetNMI drives the NMI input of the CPU. The line is edge sensitive: an interrupt is latched
+// when the line goes from released to asserted, and serviced once the current instruction has
+// completed. Devices such as the PPU hold the line asserted for as long as their interrupt
+// condition is true.
+func (c *MOS6502) SetNMI(asserted bool) {
+	c.nmiLine = asserted
+}
+
+// IRQSource identifies a device driving the shared IRQ input
+type IRQSource uint8
+
+const (
+	IRQFrameCounter IRQSource = 1 << iota // APU frame counter
+	IRQDMC                                // APU delta modulation channel
+	IRQMapper                             // Cartridge hardware
+)
+
+// SetIRQ drives the IRQ input of the CPU on behalf of a source. The line is level sensitive and
+// shared: it is asserted for as long as any source asserts it, and an interrupt is serviced
+// after each instruction while it is asserted and the interrupt disable flag is clear.
+func (c *MOS6502) SetIRQ(source IRQSource, asserted bool) {
+	if asserted {
+		c.irqLines |= source
+	} else {
+		c.irqLines &^= source
+	}
+}
+
+// Clock performs one clock cycle of computation
+func (c *MOS6502) Clock() {
+	// RDY is held low by a DMA unit, which uses the bus while the CPU is halted
+	if c.cycles == 0 && c.dmaActive() {
+		c.dmaClock()
+		c.clockCount++
+		c.sampleNMI()
+		return
+	}
+
+	if c.jammed {
+		c.clockCount++
+		return
+	}
+
 	// When the cycle counter has reached 0, the instruction is complete and the next is ready
 	// to be executed
-	if c.cycles == 0 {
+	if c.cycles == 0 && c.nmiPending {
+		c.nmiPending = false
+		c.nmi()
+	} else if c.cycles == 0 && c.irqLines != 0 && c.GetFlag(I) == 0 {
+		c.irq()
+	} else if c.cycles == 0 {
 		c.opcode = c.read(c.PC)
+		if !c.implemented(c.opcode) {
+			// Like the 6502's KIL opcodes, an opcode that cannot be executed halts the CPU with
+			// PC left pointing at it
+			c.jammed = true
+			c.clockCount++
+			return
+		}
 		instruction := c.opLookup[c.opcode]
 		c.PC++
 
@@ -94,7 +180,7 @@ func (c *MOS6502) clock() {
 		addrModeCycles := c.addrModeLookup[instruction.addrMode](c)
 
 		// Get additional instruction cycles
-		addlOpCycles := instruction.op()
+		addlOpCycles := instruction.op(c)
 
 		c.cycles += (addrModeCycles & addlOpCycles)
 
@@ -102,6 +188,104 @@ func (c *MOS6502) clock() {
 	}
 
 	c.cycles--
+	c.clockCount++
+	c.sampleNMI()
+}
+
+// implemented returns whether an opcode has an entry in the lookup table
+func (c *MOS6502) implemented(opcode uint8) bool {
+	return int(opcode) < len(c.opLookup) && c.opLookup[opcode].op != nil
+}
+
+// Jammed returns whether the CPU has halted on an opcode it cannot execute, which PC points
+// to. Only a reset restarts it.
+func (c *MOS6502) Jammed() bool {
+	return c.jammed
+}
+
+// Complete returns whether the current instruction and any DMA have completed, so that the
+// next cycle begins a new instruction or interrupt
+func (c *MOS6502) Complete() bool {
+	return c.cycles == 0 && !c.dmaActive()
+}
+
+// Reset performs the reset sequence, which runs at power on and when the reset button is
+// pressed. It goes through the motions of an interrupt with writes disabled: the stack pointer
+// is decremented by 3 without anything being pushed, interrupts are disabled and execution
+// continues from the address stored in the reset vector at 0xFFFC. Pending interrupts and DMA
+// are abandoned and a jammed CPU is restarted; the other registers keep their values.
+func (c *MOS6502) Reset() {
+	c.SP -= 3
+	c.SetFlag(I, true)
+	c.SetFlag(U, true)
+
+	lo := uint16(c.read(0xFFFC))
+	hi := uint16(c.read(0xFFFD))
+	c.PC = hi<<8 | lo
+
+	c.jammed = false
+	c.nmiPending = false
+	c.oamDMA = false
+	c.dmcDMA = false
+	c.cycles = 7
+}
+
+// sampleNMI latches an NMI edge. The NMI input is sampled at the end of every cycle, so a
+// pulse that is released again before then is never seen.
+func (c *MOS6502) sampleNMI() {
+	if c.nmiLine && !c.nmiPrev {
+		c.nmiPending = true
+	}
+	c.nmiPrev = c.nmiLine
+}
+
+// Push a byte onto the stack. The stack lives in page 1 and grows downwards.
+func (c *MOS6502) push(data uint8) {
+	c.write(0x0100+uint16(c.SP), data)
+	c.SP--
+}
+
+// Pull a byte from the stack
+func (c *MOS6502) pull() uint8 {
+	c.SP++
+	return c.read(0x0100 + uint16(c.SP))
+}
+
+// NMI (non-maskable interrupt): the program counter and status register are pushed onto the
+// stack and execution continues from the address stored in the NMI vector at 0xFFFA. Unlike
+// IRQ, the interrupt disable flag does not prevent this from happening.
+func (c *MOS6502) nmi() {
+	c.push(uint8(c.PC >> 8))
+	c.push(uint8(c.PC & 0x00FF))
+
+	c.SetFlag(B, false)
+	c.SetFlag(U, true)
+	c.push(c.Status)
+	c.SetFlag(I, true)
+
+	lo := uint16(c.read(0xFFFA))
+	hi := uint16(c.read(0xFFFB))
+	c.PC = hi<<8 | lo
+
+	c.cycles = 7
+}
+
+// IRQ (interrupt request): like NMI, but execution continues from the address stored in the
+// IRQ vector at 0xFFFE, and the interrupt is ignored while the interrupt disable flag is set
+func (c *MOS6502) irq() {
+	c.push(uint8(c.PC >> 8))
+	c.push(uint8(c.PC & 0x00FF))
+
+	c.SetFlag(B, false)
+	c.SetFlag(U, true)
+	c.push(c.Status)
+	c.SetFlag(I, true)
+
+	lo := uint16(c.read(0xFFFE))
+	hi := uint16(c.read(0xFFFF))
+	c.PC = hi<<8 | lo
+
+	c.cycles = 7
 }
 
 // Create6502 returns an instance of the CPU
@@ -123,14 +307,16 @@ func Create6502() MOS6502 {
 		"IZX": (*MOS6502).izX,
 		"IZY": (*MOS6502).izY,
 	}
+	c.opLookup = instructionSet[:]
 
 	return c
 }
 
 // Fetch retrieves data given an address and stores it in the instance variable "fetched" and
-// returns it as well.
+// returns it as well. In the immediate and implied modes the operand has already been fetched
+// by the addressing mode.
 func (c *MOS6502) fetch() uint8 {
-	if c.opLookup[c.opcode].addrMode != "IMM" {
+	if mode := c.opLookup[c.opcode].addrMode; mode != "IMM" && mode != "IMP" {
 		c.fetched = c.read(c.absAddr)
 	}
 	return c.fetched
@@ -4,18 +4,16 @@ import (
 	"testing"
 )
 
-// TestCycle exercises the clock() function
+// TestCycle exercises the Clock() function
 func TestCycle(t *testing.T) {
 
 	testAddrModeFunc := func(c *MOS6502) uint8 {
 		return 0
 	}
 
-	testOpFunc := func(c *MOS6502) func() uint8 {
-		return func() uint8 {
-			c.A = 0xDE
-			return 0
-		}
+	testOpFunc := func(c *MOS6502) uint8 {
+		c.A = 0xDE
+		return 0
 	}
 
 	b := DevBus{}
@@ -23,7 +21,7 @@ func TestCycle(t *testing.T) {
 
 	instruction := Instruction{
 		name: "test",
-		op: testOpFunc(&c),
+		op: testOpFunc,
 		addrMode: "testAddrMode",
 		cycles: 3,
 	}
@@ -42,7 +40,7 @@ func TestCycle(t *testing.T) {
 	expectedCycles := instruction.cycles - 1
 
 	for i := 1; i <= int(instruction.cycles - 1); i++ {
-		c.clock()
+		c.Clock()
 		if c.cycles != expectedCycles {
 			t.Errorf("Got cycles=%d, expected %d", c.cycles, expectedCycles)
 		}
@@ -60,10 +58,8 @@ func TestCycleExtraCycles(t *testing.T) {
 		return 1
 	}
 
-	testOpFunc := func(c *MOS6502) func() uint8 {
-		return func() uint8 {
-			return 1
-		}
+	testOpFunc := func(c *MOS6502) uint8 {
+		return 1
 	}
 
 	b := DevBus{}
@@ -71,7 +67,7 @@ func TestCycleExtraCycles(t *testing.T) {
 
 	instruction := Instruction{
 		name: "test",
-		op: testOpFunc(&c),
+		op: testOpFunc,
 		addrMode: "testAddrMode",
 		cycles: 3,
 	}
@@ -84,10 +80,161 @@ func TestCycleExtraCycles(t *testing.T) {
 	}
 
 	expectedCycles := instruction.cycles
-	c.clock()
+	c.Clock()
 	
 	if c.cycles != expectedCycles {
 		t.Errorf("Got cycles=%d, expected %d", c.cycles, expectedCycles)
 	}
 }
 
+
+// TestNMI checks that an NMI edge is serviced once the current instruction completes
+func TestNMI(t *testing.T) {
+	b := DevBus{}
+	c := MOS6502{Bus: &b}
+
+	b.ram[0xFFFA] = 0xEF
+	b.ram[0xFFFB] = 0xBE
+	c.PC = 0xCAFE
+	c.SP = 0xFD
+	c.cycles = 2
+
+	c.SetNMI(true)
+	c.Clock()
+	c.Clock()
+
+	if c.PC != 0xCAFE {
+		t.Errorf("NMI serviced before the instruction completed")
+	}
+
+	c.Clock()
+
+	if c.PC != 0xBEEF {
+		t.Errorf("Expected PC = %#04x, got %#04x", 0xBEEF, c.PC)
+	}
+
+	if b.ram[0x01FD] != 0xCA || b.ram[0x01FC] != 0xFE {
+		t.Errorf("Return address not pushed: got %#02x%02x", b.ram[0x01FD], b.ram[0x01FC])
+	}
+
+	if c.GetFlag(I) == 0 {
+		t.Errorf("Interrupt disable flag not set")
+	}
+
+	// 7 cycles, the first of which has run
+	if c.cycles != 6 {
+		t.Errorf("Expected cycles = %d, got %d", 6, c.cycles)
+	}
+
+	if c.SP != 0xFA {
+		t.Errorf("Expected SP = %#02x, got %#02x", 0xFA, c.SP)
+	}
+}
+
+// TestIRQ checks that IRQ is serviced while the line is asserted and the interrupt disable
+// flag is clear, and ignored otherwise
+func TestIRQ(t *testing.T) {
+	b := DevBus{}
+	c := MOS6502{Bus: &b}
+
+	b.ram[0xFFFE] = 0xEF
+	b.ram[0xFFFF] = 0xBE
+	c.PC = 0xCAFE
+	c.SP = 0xFD
+	c.cycles = 1
+	c.SetFlag(I, true)
+
+	c.SetIRQ(IRQDMC, true)
+	c.Clock()
+	c.cycles = 1 // as if another instruction had been executed
+	c.Clock()
+
+	if c.PC != 0xCAFE {
+		t.Fatalf("IRQ serviced with interrupts disabled")
+	}
+
+	c.SetFlag(I, false)
+	c.SetIRQ(IRQFrameCounter, true)
+	c.SetIRQ(IRQDMC, false)
+	c.Clock()
+
+	if c.PC != 0xBEEF {
+		t.Errorf("Expected PC = %#04x, got %#04x", 0xBEEF, c.PC)
+	}
+
+	if b.ram[0x01FB]&B != 0 {
+		t.Errorf("Break flag pushed by IRQ")
+	}
+
+	if c.GetFlag(I) == 0 {
+		t.Errorf("Interrupt disable flag not set")
+	}
+
+	if c.cycles != 6 {
+		t.Errorf("Expected cycles = %d, got %d", 6, c.cycles)
+	}
+}
+
+// TestReset checks that the reset sequence loads the reset vector without writing the stack
+func TestReset(t *testing.T) {
+	b := DevBus{}
+	c := Create6502()
+	c.Bus = &b
+
+	b.ram[0xFFFC] = 0x00
+	b.ram[0xFFFD] = 0x80
+	c.RequestOAMDMA(0x02)
+	c.Reset()
+
+	if c.PC != 0x8000 {
+		t.Errorf("Expected PC = %#04x, got %#04x", 0x8000, c.PC)
+	}
+	if c.SP != 0xFD {
+		t.Errorf("Expected SP = %#02x, got %#02x", 0xFD, c.SP)
+	}
+	if c.GetFlag(I) == 0 {
+		t.Errorf("Interrupt disable flag not set")
+	}
+	if b.ram[0x0100] != 0 || b.ram[0x01FF] != 0 {
+		t.Errorf("Reset wrote to the stack")
+	}
+
+	for i := 0; i < 7; i++ {
+		if c.Complete() {
+			t.Fatalf("Reset completed after %d cycles", i)
+		}
+		c.Clock()
+	}
+	if !c.Complete() {
+		t.Errorf("Expected reset to take 7 cycles")
+	}
+}
+
+// TestJam checks that an opcode missing from the lookup table halts the CPU until a reset
+func TestJam(t *testing.T) {
+	b := DevBus{}
+	c := Create6502()
+	c.Bus = &b
+	c.PC = 0x8000
+	b.ram[0x8000] = 0x02 // KIL
+	b.ram[0xFFFA] = 0x00
+	b.ram[0xFFFB] = 0x90
+
+	c.Clock()
+	if !c.Jammed() || c.PC != 0x8000 {
+		t.Fatalf("Expected the CPU to jam at %#04x, got PC = %#04x", 0x8000, c.PC)
+	}
+
+	c.SetNMI(true)
+	for i := 0; i < 10; i++ {
+		c.Clock()
+	}
+	if c.PC != 0x8000 || c.Cycles() != 11 {
+		t.Errorf("Expected the CPU to stay halted, got PC = %#04x after %d cycles", c.PC, c.Cycles())
+	}
+
+	c.Reset()
+	if c.Jammed() {
+		t.Errorf("Expected a reset to restart the CPU")
+	}
+}
@@ -0,0 +1,104 @@
+package cpu
+
+// DMA
+// ---
+// The 2A03 has two DMA units that take over the bus by pulling the CPU's RDY line low. The CPU
+// halts on its next read cycle and repeats that read until RDY is released.
+//     - OAM DMA is started by writing a page number to 0x4014 and copies the 256 bytes of that
+//       page to OAMDATA (0x2004)
+//     - DMC DMA is started by the APU when the delta modulation channel needs a sample byte
+//
+// The bus alternates between get (read) and put (write) cycles. A DMA only reads on get
+// cycles, so it may need an alignment cycle before it can start:
+//     - OAM DMA: 1 halt cycle, 1 alignment cycle if the halt falls on a get cycle, then 256
+//       get/put pairs, for 513 or 514 cycles
+//     - DMC DMA: 1 halt cycle, 1 dummy cycle, 1 alignment cycle if needed and the get, for 3
+//       or 4 cycles
+//
+// When both run at once the halt and dummy cycles of the DMC DMA overlap the OAM transfer.
+// The DMC get takes the place of an OAM get, and OAM DMA needs one more cycle to realign,
+// so the DMC usually steals 2 cycles. Near the end of an OAM DMA it can steal 1 or 3.
+//
+// This CPU executes an instruction on its first cycle, so DMA begins once the current
+// instruction has completed, and the halted read is the last read made by that instruction.
+// Repeating it produces the double read bug: a DMC fetch that lands on a read of 0x2007 or
+// 0x4016 reads the register again, skipping a byte of VRAM or a controller bit.
+
+const oamData uint16 = 0x2004
+
+// OAMDMA is the register at 0x4014 that starts an OAM DMA. It should be attached to the CPU
+// bus on top of whatever maps the rest of 0x4000-0x401F.
+type OAMDMA struct {
+	CPU *MOS6502
+}
+
+// Read returns open bus, the register is write only
+func (d *OAMDMA) Read(address uint16, readOnly bool) uint8 {
+	return d.CPU.dataBus
+}
+
+// Write starts a transfer of the page given by data to OAM
+func (d *OAMDMA) Write(address uint16, data uint8) {
+	d.CPU.RequestOAMDMA(data)
+}
+
+// RequestOAMDMA schedules a transfer of the 256 bytes at page<<8 to OAMDATA
+func (c *MOS6502) RequestOAMDMA(page uint8) {
+	c.oamDMA = true
+	c.oamDMAAddr = uint16(page) << 8
+	c.oamDMAHalt = true
+	c.oamDMAFull = false
+}
+
+// RequestDMCDMA schedules a fetch of a DMC sample byte. done is called with the byte once it
+// has been read.
+func (c *MOS6502) RequestDMCDMA(address uint16, done func(data uint8)) {
+	c.dmcDMA = true
+	c.dmcDMAAddr = address
+	c.dmcDMAWait = 2 // halt and dummy cycles
+	c.dmcDMADone = done
+}
+
+// dmaActive returns true when a DMA unit holds RDY low
+func (c *MOS6502) dmaActive() bool {
+	return c.oamDMA || c.dmcDMA
+}
+
+// dmaClock performs one cycle of DMA while the CPU is halted
+func (c *MOS6502) dmaClock() {
+	get := c.clockCount&1 == 0
+
+	switch {
+	case c.dmcDMA && c.dmcDMAWait == 0 && get:
+		data := c.Bus.Read(c.dmcDMAAddr, false)
+		c.dataBus = data
+		c.dmcDMA = false
+		c.dmcDMADone(data)
+	case c.oamDMA && c.oamDMAHalt:
+		c.oamDMAHalt = false
+		c.dummyRead()
+	case c.oamDMA && get && !c.oamDMAFull:
+		c.oamDMAData = c.Bus.Read(c.oamDMAAddr, false)
+		c.dataBus = c.oamDMAData
+		c.oamDMAFull = true
+	case c.oamDMA && !get && c.oamDMAFull:
+		c.Bus.Write(oamData, c.oamDMAData)
+		c.oamDMAFull = false
+		c.oamDMAAddr++
+		if c.oamDMAAddr&0x00FF == 0 {
+			c.oamDMA = false
+		}
+	default:
+		// halt, dummy and alignment cycles
+		c.dummyRead()
+	}
+
+	if c.dmcDMA && c.dmcDMAWait > 0 {
+		c.dmcDMAWait--
+	}
+}
+
+// dummyRead repeats the CPU's last read while it is halted
+func (c *MOS6502) dummyRead() {
+	c.dataBus = c.Bus.Read(c.lastRead, false)
+}
@@ -0,0 +1,159 @@
+package cpu
+
+import (
+	"testing"
+)
+
+// dmaBus records the writes to OAMDATA and counts the reads of each address
+type dmaBus struct {
+	DevBus
+	oam   []uint8
+	reads map[uint16]int
+}
+
+func (b *dmaBus) Read(address uint16, readOnly bool) uint8 {
+	b.reads[address]++
+	return b.DevBus.Read(address, readOnly)
+}
+
+func (b *dmaBus) Write(address uint16, data uint8) {
+	if address == 0x2004 {
+		b.oam = append(b.oam, data)
+	}
+	b.DevBus.Write(address, data)
+}
+
+// runDMA clocks the CPU until no DMA is active and returns the number of cycles taken
+func runDMA(c *MOS6502) int {
+	cycles := 0
+	for c.dmaActive() {
+		c.Clock()
+		cycles++
+	}
+	return cycles
+}
+
+// TestOAMDMA checks that OAM DMA copies a page to OAMDATA in 513 or 514 cycles depending on
+// the alignment of the halt cycle
+func TestOAMDMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  uint64
+		cycles int
+	}{
+		{"put cycle", 1, 513},
+		{"get cycle", 0, 514},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := dmaBus{reads: map[uint16]int{}}
+			c := MOS6502{Bus: &b}
+			for i := 0; i < 256; i++ {
+				b.ram[0x0300+i] = uint8(i)
+			}
+			c.clockCount = tt.start
+
+			d := OAMDMA{CPU: &c}
+			d.Write(0x4014, 0x03)
+
+			if cycles := runDMA(&c); cycles != tt.cycles {
+				t.Errorf("Expected %d cycles, got %d", tt.cycles, cycles)
+			}
+
+			if len(b.oam) != 256 {
+				t.Fatalf("Expected 256 bytes written to OAMDATA, got %d", len(b.oam))
+			}
+
+			for i, data := range b.oam {
+				if data != uint8(i) {
+					t.Errorf("Expected byte %d = %#02x, got %#02x", i, i, data)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestDMCDMA checks that a DMC fetch stalls the CPU for 3 or 4 cycles
+func TestDMCDMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  uint64
+		cycles int
+	}{
+		{"get cycle", 0, 3},
+		{"put cycle", 1, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := dmaBus{reads: map[uint16]int{}}
+			c := MOS6502{Bus: &b}
+			b.ram[0xC000] = 0xAB
+			c.clockCount = tt.start
+
+			var sample uint8
+			c.RequestDMCDMA(0xC000, func(data uint8) { sample = data })
+
+			if cycles := runDMA(&c); cycles != tt.cycles {
+				t.Errorf("Expected %d cycles, got %d", tt.cycles, cycles)
+			}
+
+			if sample != 0xAB {
+				t.Errorf("Expected sample = %#02x, got %#02x", 0xAB, sample)
+			}
+		})
+	}
+}
+
+// TestDMCDuringOAMDMA checks that a DMC fetch in the middle of an OAM DMA steals 2 cycles
+func TestDMCDuringOAMDMA(t *testing.T) {
+	b := dmaBus{reads: map[uint16]int{}}
+	c := MOS6502{Bus: &b}
+	c.clockCount = 1
+
+	c.RequestOAMDMA(0x03)
+	for i := 0; i < 100; i++ {
+		c.Clock()
+	}
+	c.RequestDMCDMA(0xC000, func(data uint8) {})
+
+	if cycles := 100 + runDMA(&c); cycles != 513+2 {
+		t.Errorf("Expected %d cycles, got %d", 513+2, cycles)
+	}
+
+	if len(b.oam) != 256 {
+		t.Errorf("Expected 256 bytes written to OAMDATA, got %d", len(b.oam))
+	}
+}
+
+// TestDMCDoubleRead checks that the halted CPU repeats its last read
+func TestDMCDoubleRead(t *testing.T) {
+	b := dmaBus{reads: map[uint16]int{}}
+	c := MOS6502{Bus: &b}
+
+	c.read(0x2007)
+	c.RequestDMCDMA(0xC000, func(data uint8) {})
+	runDMA(&c)
+
+	if b.reads[0x2007] < 2 {
+		t.Errorf("Expected 0x2007 to be read again during DMA, got %d reads", b.reads[0x2007])
+	}
+}
+
+// TestDMAWaitsForInstruction checks that a DMA does not start until the current instruction
+// has completed
+func TestDMAWaitsForInstruction(t *testing.T) {
+	b := dmaBus{reads: map[uint16]int{}}
+	c := MOS6502{Bus: &b}
+	c.cycles = 2
+
+	c.RequestOAMDMA(0x03)
+	c.Clock()
+	c.Clock()
+
+	if len(b.oam) != 0 || c.cycles != 0 {
+		t.Errorf("DMA started before the instruction completed")
+	}
+}
@@ -44,9 +44,16 @@ package cpu
 // 1  1  1  0  0
 func (c *MOS6502) adc() uint8 {
 	c.fetch()
+	c.add(c.fetched)
 
+	// this operation could potentially get an extra cycle
+	return 1
+}
+
+// add adds a value and the carry to the accumulator, for ADC, SBC and RRA
+func (c *MOS6502) add(m uint8) {
 	accum := uint16(c.A)
-	mem := uint16(c.fetched)
+	mem := uint16(m)
 	carry := uint16(c.GetFlag(C))
 	res := accum + mem + carry
 	// ANDing with 0x80 extracts the sign bit
@@ -59,9 +66,6 @@ func (c *MOS6502) adc() uint8 {
 	c.SetFlag(V, v != 0)
 
 	c.A = uint8(res & 0x00FF)
-
-	// this operation could potentially get an extra cycle
-	return 1
 }
 
 // SBC subtract memory from accumulator with borrow
@@ -82,21 +86,466 @@ func (c *MOS6502) adc() uint8 {
 
 func (c *MOS6502) sbc() uint8 {
 	c.fetch()
+	c.add(c.fetched ^ 0xff) // invert the value in memory
 
-	accum := uint16(c.A)
-	mem := uint16(c.fetched) ^ 0x00ff // invert the value in memory
-	carry := uint16(c.GetFlag(C))
-	res := accum + mem + carry
-	v := ((accum ^ res) &^ (accum ^ mem)) & 0x80
+	// this operation could potentially get an extra cycle
+	return 1
+}
 
-	// set flags
-	c.SetFlag(Z, res&0x00ff == 0)
-	c.SetFlag(C, res > 0x00ff)
-	c.SetFlag(N, res&0x80 != 0)
-	c.SetFlag(V, v != 0)
+// setZN sets the zero and negative flags from a result
+func (c *MOS6502) setZN(v uint8) {
+	c.SetFlag(Z, v == 0)
+	c.SetFlag(N, v&0x80 != 0)
+}
 
-	c.A = uint8(res & 0x00ff)
+// modify stores the result of a read-modify-write instruction, to the accumulator in the
+// implied mode or else back to memory. Like the 6502, it writes the unmodified value first.
+func (c *MOS6502) modify(result uint8) {
+	if c.opLookup[c.opcode].addrMode == "IMP" {
+		c.A = result
+		return
+	}
+	c.write(c.absAddr, c.fetched)
+	c.write(c.absAddr, result)
+}
 
-	// this operation could potentially get an extra cycle
+// compare sets the flags as for a subtraction of memory from a register, for CMP, CPX and CPY
+func (c *MOS6502) compare(reg uint8, m uint8) {
+	c.SetFlag(C, reg >= m)
+	c.setZN(reg - m)
+}
+
+// branch adds the relative address to PC if cond is true. A branch taken adds 1 cycle, or 2
+// if it lands on another page.
+func (c *MOS6502) branch(cond bool) uint8 {
+	if cond {
+		c.cycles++
+		target := c.PC + c.relAddr
+		if target&0xFF00 != c.PC&0xFF00 {
+			c.cycles++
+		}
+		c.PC = target
+	}
+	return 0
+}
+
+// Logical and arithmetic
+// ----------------------
+//      AND  A & M -> A                  N Z
+//      ORA  A | M -> A                  N Z
+//      EOR  A ^ M -> A                  N Z
+//      BIT  A & M, M7 -> N, M6 -> V     N Z V
+//      CMP  A - M                       N Z C
+//      CPX  X - M                       N Z C
+//      CPY  Y - M                       N Z C
+
+func (c *MOS6502) and() uint8 {
+	c.A &= c.fetch()
+	c.setZN(c.A)
+	return 1
+}
+
+func (c *MOS6502) ora() uint8 {
+	c.A |= c.fetch()
+	c.setZN(c.A)
+	return 1
+}
+
+func (c *MOS6502) eor() uint8 {
+	c.A ^= c.fetch()
+	c.setZN(c.A)
+	return 1
+}
+
+func (c *MOS6502) bit() uint8 {
+	m := c.fetch()
+	c.SetFlag(Z, c.A&m == 0)
+	c.SetFlag(N, m&0x80 != 0)
+	c.SetFlag(V, m&0x40 != 0)
+	return 0
+}
+
+func (c *MOS6502) cmp() uint8 {
+	c.compare(c.A, c.fetch())
+	return 1
+}
+
+func (c *MOS6502) cpx() uint8 {
+	c.compare(c.X, c.fetch())
+	return 0
+}
+
+func (c *MOS6502) cpy() uint8 {
+	c.compare(c.Y, c.fetch())
+	return 0
+}
+
+// Shifts, increments and decrements
+// ---------------------------------
+// These read, modify and write back memory, or the accumulator in the implied mode.
+//      ASL  C <- [76543210] <- 0        N Z C
+//      LSR  0 -> [76543210] -> C        N Z C
+//      ROL  C <- [76543210] <- C        N Z C
+//      ROR  C -> [76543210] -> C        N Z C
+//      INC  M + 1 -> M                  N Z
+//      DEC  M - 1 -> M                  N Z
+
+func (c *MOS6502) asl() uint8 {
+	m := c.fetch()
+	r := m << 1
+	c.SetFlag(C, m&0x80 != 0)
+	c.setZN(r)
+	c.modify(r)
+	return 0
+}
+
+func (c *MOS6502) lsr() uint8 {
+	m := c.fetch()
+	r := m >> 1
+	c.SetFlag(C, m&0x01 != 0)
+	c.setZN(r)
+	c.modify(r)
+	return 0
+}
+
+func (c *MOS6502) rol() uint8 {
+	m := c.fetch()
+	r := m<<1 | c.GetFlag(C)
+	c.SetFlag(C, m&0x80 != 0)
+	c.setZN(r)
+	c.modify(r)
+	return 0
+}
+
+func (c *MOS6502) ror() uint8 {
+	m := c.fetch()
+	r := m>>1 | c.GetFlag(C)<<7
+	c.SetFlag(C, m&0x01 != 0)
+	c.setZN(r)
+	c.modify(r)
+	return 0
+}
+
+func (c *MOS6502) inc() uint8 {
+	r := c.fetch() + 1
+	c.setZN(r)
+	c.modify(r)
+	return 0
+}
+
+func (c *MOS6502) dec() uint8 {
+	r := c.fetch() - 1
+	c.setZN(r)
+	c.modify(r)
+	return 0
+}
+
+func (c *MOS6502) inx() uint8 {
+	c.X++
+	c.setZN(c.X)
+	return 0
+}
+
+func (c *MOS6502) iny() uint8 {
+	c.Y++
+	c.setZN(c.Y)
+	return 0
+}
+
+func (c *MOS6502) dex() uint8 {
+	c.X--
+	c.setZN(c.X)
+	return 0
+}
+
+func (c *MOS6502) dey() uint8 {
+	c.Y--
+	c.setZN(c.Y)
+	return 0
+}
+
+// Loads, stores and transfers
+// ---------------------------
+//      LDA, LDX, LDY  M -> register     N Z
+//      STA, STX, STY  register -> M
+//      TAX, TAY, TXA, TYA, TSX          N Z
+//      TXS  X -> SP
+
+func (c *MOS6502) lda() uint8 {
+	c.A = c.fetch()
+	c.setZN(c.A)
+	return 1
+}
+
+func (c *MOS6502) ldx() uint8 {
+	c.X = c.fetch()
+	c.setZN(c.X)
+	return 1
+}
+
+func (c *MOS6502) ldy() uint8 {
+	c.Y = c.fetch()
+	c.setZN(c.Y)
 	return 1
 }
+
+func (c *MOS6502) sta() uint8 {
+	c.write(c.absAddr, c.A)
+	return 0
+}
+
+func (c *MOS6502) stx() uint8 {
+	c.write(c.absAddr, c.X)
+	return 0
+}
+
+func (c *MOS6502) sty() uint8 {
+	c.write(c.absAddr, c.Y)
+	return 0
+}
+
+func (c *MOS6502) tax() uint8 {
+	c.X = c.A
+	c.setZN(c.X)
+	return 0
+}
+
+func (c *MOS6502) tay() uint8 {
+	c.Y = c.A
+	c.setZN(c.Y)
+	return 0
+}
+
+func (c *MOS6502) txa() uint8 {
+	c.A = c.X
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) tya() uint8 {
+	c.A = c.Y
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) tsx() uint8 {
+	c.X = c.SP
+	c.setZN(c.X)
+	return 0
+}
+
+func (c *MOS6502) txs() uint8 {
+	c.SP = c.X
+	return 0
+}
+
+// Stack
+// -----
+// PHP pushes the status with the B and U flags set, and PLP ignores them when pulling.
+//      PHA, PHP, PLA (N Z), PLP
+
+func (c *MOS6502) pha() uint8 {
+	c.push(c.A)
+	return 0
+}
+
+func (c *MOS6502) php() uint8 {
+	c.push(c.Status | B | U)
+	return 0
+}
+
+func (c *MOS6502) pla() uint8 {
+	c.A = c.pull()
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) plp() uint8 {
+	c.Status = c.pull()&^B | U
+	return 0
+}
+
+// Jumps, subroutines and interrupts
+// ---------------------------------
+// JSR pushes the address of its last byte, which RTS increments when pulling it. BRK skips a
+// padding byte and pushes the status with the B flag set, which is how a handler tells it from
+// an IRQ sharing the vector at 0xFFFE.
+//      JMP, JSR, RTS, BRK, RTI
+
+func (c *MOS6502) jmp() uint8 {
+	c.PC = c.absAddr
+	return 0
+}
+
+func (c *MOS6502) jsr() uint8 {
+	ret := c.PC - 1
+	c.push(uint8(ret >> 8))
+	c.push(uint8(ret & 0x00FF))
+	c.PC = c.absAddr
+	return 0
+}
+
+func (c *MOS6502) rts() uint8 {
+	lo := uint16(c.pull())
+	hi := uint16(c.pull())
+	c.PC = (hi<<8 | lo) + 1
+	return 0
+}
+
+func (c *MOS6502) brk() uint8 {
+	c.PC++
+	c.push(uint8(c.PC >> 8))
+	c.push(uint8(c.PC & 0x00FF))
+	c.push(c.Status | B | U)
+	c.SetFlag(I, true)
+
+	lo := uint16(c.read(0xFFFE))
+	hi := uint16(c.read(0xFFFF))
+	c.PC = hi<<8 | lo
+	return 0
+}
+
+func (c *MOS6502) rti() uint8 {
+	c.Status = c.pull()&^B | U
+	lo := uint16(c.pull())
+	hi := uint16(c.pull())
+	c.PC = hi<<8 | lo
+	return 0
+}
+
+// Branches
+// --------
+// The branches use relative addressing and take 2 cycles, plus those added by branch.
+
+func (c *MOS6502) bcc() uint8 { return c.branch(c.GetFlag(C) == 0) }
+func (c *MOS6502) bcs() uint8 { return c.branch(c.GetFlag(C) != 0) }
+func (c *MOS6502) bne() uint8 { return c.branch(c.GetFlag(Z) == 0) }
+func (c *MOS6502) beq() uint8 { return c.branch(c.GetFlag(Z) != 0) }
+func (c *MOS6502) bpl() uint8 { return c.branch(c.GetFlag(N) == 0) }
+func (c *MOS6502) bmi() uint8 { return c.branch(c.GetFlag(N) != 0) }
+func (c *MOS6502) bvc() uint8 { return c.branch(c.GetFlag(V) == 0) }
+func (c *MOS6502) bvs() uint8 { return c.branch(c.GetFlag(V) != 0) }
+
+// Flags
+// -----
+// The decimal flag can be set and cleared, but the 2A03 has no decimal mode.
+
+func (c *MOS6502) clc() uint8 { c.SetFlag(C, false); return 0 }
+func (c *MOS6502) sec() uint8 { c.SetFlag(C, true); return 0 }
+func (c *MOS6502) cli() uint8 { c.SetFlag(I, false); return 0 }
+func (c *MOS6502) sei() uint8 { c.SetFlag(I, true); return 0 }
+func (c *MOS6502) cld() uint8 { c.SetFlag(D, false); return 0 }
+func (c *MOS6502) sed() uint8 { c.SetFlag(D, true); return 0 }
+func (c *MOS6502) clv() uint8 { c.SetFlag(V, false); return 0 }
+
+// NOP does nothing. The unofficial NOPs with an operand take the cycles of a read in their
+// addressing mode, including the extra cycle of a page crossing.
+func (c *MOS6502) nop() uint8 {
+	return 1
+}
+
+// Unofficial instructions
+// -----------------------
+// The opcodes that the 6502 does not document decode as combinations of the official
+// instructions. The stable ones are used by some games and music drivers:
+//      LAX  M -> A, X                   N Z
+//      SAX  A & X -> M
+//      SLO  ASL M, then ORA             N Z C
+//      RLA  ROL M, then AND             N Z C
+//      SRE  LSR M, then EOR             N Z C
+//      RRA  ROR M, then ADC             N Z C V
+//      DCP  DEC M, then CMP             N Z C
+//      ISC  INC M, then SBC             N Z C V
+//      ANC  AND #, bit 7 -> C           N Z C
+//      ALR  AND #, then LSR A           N Z C
+//      ARR  AND #, then ROR A           N Z C V
+//      AXS  (A & X) - # -> X            N Z C
+//
+// The unstable ones, whose results depend on the chip, and the KIL opcodes are left out of
+// the lookup table, so that they jam the CPU.
+
+func (c *MOS6502) lax() uint8 {
+	c.A = c.fetch()
+	c.X = c.A
+	c.setZN(c.A)
+	return 1
+}
+
+func (c *MOS6502) sax() uint8 {
+	c.write(c.absAddr, c.A&c.X)
+	return 0
+}
+
+func (c *MOS6502) slo() uint8 {
+	c.asl()
+	c.A |= c.fetched << 1
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) rla() uint8 {
+	carry := c.GetFlag(C)
+	c.rol()
+	c.A &= c.fetched<<1 | carry
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) sre() uint8 {
+	c.lsr()
+	c.A ^= c.fetched >> 1
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) rra() uint8 {
+	carry := c.GetFlag(C)
+	c.ror()
+	c.add(c.fetched>>1 | carry<<7)
+	return 0
+}
+
+func (c *MOS6502) dcp() uint8 {
+	r := c.fetch() - 1
+	c.modify(r)
+	c.compare(c.A, r)
+	return 0
+}
+
+func (c *MOS6502) isc() uint8 {
+	r := c.fetch() + 1
+	c.modify(r)
+	c.add(r ^ 0xFF)
+	return 0
+}
+
+func (c *MOS6502) anc() uint8 {
+	c.and()
+	c.SetFlag(C, c.A&0x80 != 0)
+	return 0
+}
+
+func (c *MOS6502) alr() uint8 {
+	c.A &= c.fetch()
+	c.SetFlag(C, c.A&0x01 != 0)
+	c.A >>= 1
+	c.setZN(c.A)
+	return 0
+}
+
+func (c *MOS6502) arr() uint8 {
+	c.A = (c.A&c.fetch())>>1 | c.GetFlag(C)<<7
+	c.setZN(c.A)
+	c.SetFlag(C, c.A&0x40 != 0)
+	c.SetFlag(V, (c.A>>6^c.A>>5)&0x01 != 0)
+	return 0
+}
+
+func (c *MOS6502) axs() uint8 {
+	ax := c.A & c.X
+	m := c.fetch()
+	c.SetFlag(C, ax >= m)
+	c.X = ax - m
+	c.setZN(c.X)
+	return 0
+}
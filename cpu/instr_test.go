@@ -27,7 +27,7 @@ func TestAdc(t *testing.T) {
 			c.opLookup = []Instruction{
 				Instruction{
 					name:     "ADC",
-					op:       func() uint8 { return 1 },
+					op:       func(*MOS6502) uint8 { return 1 },
 					addrMode: "IMM",
 					cycles:   1,
 				},
@@ -75,7 +75,7 @@ func TestSbc(t *testing.T) {
 			c.opLookup = []Instruction{
 				Instruction{
 					name:     "SBC",
-					op:       func() uint8 { return 1 },
+					op:       func(*MOS6502) uint8 { return 1 },
 					addrMode: "IMM",
 					cycles:   1,
 				},
@@ -105,3 +105,120 @@ func TestSbc(t *testing.T) {
 		})
 	}
 }
+
+// run loads a program at 0x8000 and clocks the CPU until it jams on a KIL opcode
+func run(t *testing.T, program []uint8) (*MOS6502, *DevBus) {
+	b := &DevBus{}
+	c := Create6502()
+	c.Bus = b
+	c.SP = 0xFD
+	c.PC = 0x8000
+	copy(b.ram[0x8000:], program)
+	copy(b.ram[0x8000+len(program):], []uint8{0x02}) // KIL
+
+	for i := 0; !c.Jammed(); i++ {
+		if i == 10000 {
+			t.Fatalf("Program did not end, PC = %#04x", c.PC)
+		}
+		c.Clock()
+	}
+	return &c, b
+}
+
+// TestProgram checks a loop, a subroutine call and the cycles they take
+func TestProgram(t *testing.T) {
+	c, b := run(t, []uint8{
+		0xA2, 0x0A, // LDX #10
+		0xA9, 0x00, // LDA #0
+		0x18,       // loop: CLC
+		0x69, 0x03, // ADC #3
+		0xCA,       // DEX
+		0xD0, 0xFA, // BNE loop
+		0x8D, 0x00, 0x02, // STA $0200
+		0x20, 0x13, 0x80, // JSR sub
+		0x4C, 0x15, 0x80, // JMP end
+		0xE8, // sub: INX
+		0x60, // RTS
+	})
+
+	if b.ram[0x0200] != 30 {
+		t.Errorf("Expected 30 stored, got %d", b.ram[0x0200])
+	}
+	if c.X != 1 || c.SP != 0xFD {
+		t.Errorf("Expected X = 1 and SP = 0xFD after the subroutine, got %d and %#02x", c.X, c.SP)
+	}
+	if c.PC != 0x8015 {
+		t.Errorf("Expected to end at %#04x, got %#04x", 0x8015, c.PC)
+	}
+
+	// 2 + 2 + 10 loops of 9 less the branch not taken + 4 + 6 + 3 + 2 + 6, and the KIL
+	if c.Cycles() != 2+2+89+4+6+3+2+6+1 {
+		t.Errorf("Expected %d cycles, got %d", 2+2+89+4+6+3+2+6+1, c.Cycles())
+	}
+}
+
+// TestInstructions checks the results and flags of single instructions
+func TestInstructions(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []uint8
+		a       uint8
+		x       uint8
+		status  uint8
+		cycles  uint64
+	}{
+		{"ASL A", []uint8{0xA9, 0x81, 0x0A}, 0x02, 0, C, 4},
+		{"ROR A", []uint8{0x38, 0xA9, 0x02, 0x6A}, 0x81, 0, N, 6},
+		{"LSR memory", []uint8{0xA9, 0x03, 0x85, 0x10, 0x46, 0x10, 0xA5, 0x10}, 0x01, 0, C, 2 + 3 + 5 + 3},
+		{"CMP equal", []uint8{0xA9, 0x40, 0xC9, 0x40}, 0x40, 0, Z | C, 4},
+		{"BIT", []uint8{0xA9, 0xC0, 0x85, 0x10, 0xA9, 0x01, 0x24, 0x10}, 0x01, 0, Z | V | N, 2 + 3 + 2 + 3},
+		{"LDA page crossing", []uint8{0xA2, 0xFF, 0xBD, 0x01, 0x02}, 0x00, 0xFF, Z, 2 + 5},
+		{"LAX", []uint8{0xA9, 0x99, 0x85, 0x10, 0xA9, 0x00, 0xA7, 0x10}, 0x99, 0x99, N, 2 + 3 + 2 + 3},
+		{"DCP", []uint8{0xA9, 0x05, 0x85, 0x10, 0xC7, 0x10}, 0x05, 0, C, 2 + 3 + 5},
+		{"PHP PLA", []uint8{0x38, 0x08, 0x68}, C | B | U, 0, C, 2 + 3 + 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := run(t, tt.program)
+			if c.A != tt.a || c.X != tt.x {
+				t.Errorf("Expected A = %#02x and X = %#02x, got %#02x and %#02x", tt.a, tt.x, c.A, c.X)
+			}
+			if c.Status&^(U|I) != tt.status {
+				t.Errorf("Expected status = %08b, got %08b", tt.status, c.Status&^(U|I))
+			}
+			if c.Cycles() != tt.cycles+1 {
+				t.Errorf("Expected %d cycles, got %d", tt.cycles, c.Cycles()-1)
+			}
+		})
+	}
+}
+
+// TestBRK checks that BRK pushes the address past its padding byte and the status with the B
+// flag, and that RTI returns there
+func TestBRK(t *testing.T) {
+	b := &DevBus{}
+	c := Create6502()
+	c.Bus = b
+	c.SP = 0xFD
+	c.PC = 0x8000
+	b.ram[0x8000] = 0x00 // BRK
+	b.ram[0x8002] = 0x02 // KIL
+	b.ram[0xFFFE] = 0x00
+	b.ram[0xFFFF] = 0x90
+	b.ram[0x9000] = 0x40 // RTI
+
+	for !c.Jammed() {
+		c.Clock()
+	}
+	if c.PC != 0x8002 {
+		t.Errorf("Expected RTI to return to %#04x, got %#04x", 0x8002, c.PC)
+	}
+	if b.ram[0x01FD] != 0x80 || b.ram[0x01FC] != 0x02 || b.ram[0x01FB]&B == 0 {
+		t.Errorf("Expected 0x8002 and the B flag pushed, got %#02x%02x %08b",
+			b.ram[0x01FD], b.ram[0x01FC], b.ram[0x01FB])
+	}
+	if c.Cycles() != 7+6+1 {
+		t.Errorf("Expected %d cycles, got %d", 7+6+1, c.Cycles())
+	}
+}
@@ -0,0 +1,261 @@
+package cpu
+
+// Opcodes
+// -------
+// instructionSet maps each opcode to its instruction, addressing mode and base number of cycles.
+// The rows are the high nibble of the opcode and the columns the low nibble. Entries without an
+// instruction are the KIL opcodes and the unstable unofficial ones, which jam the CPU.
+var instructionSet = [256]Instruction{
+	// 0x0_
+	0x00: {"BRK", (*MOS6502).brk, "IMP", 7},
+	0x01: {"ORA", (*MOS6502).ora, "IZX", 6},
+	0x03: {"SLO", (*MOS6502).slo, "IZX", 8},
+	0x04: {"NOP", (*MOS6502).nop, "ZP0", 3},
+	0x05: {"ORA", (*MOS6502).ora, "ZP0", 3},
+	0x06: {"ASL", (*MOS6502).asl, "ZP0", 5},
+	0x07: {"SLO", (*MOS6502).slo, "ZP0", 5},
+	0x08: {"PHP", (*MOS6502).php, "IMP", 3},
+	0x09: {"ORA", (*MOS6502).ora, "IMM", 2},
+	0x0A: {"ASL", (*MOS6502).asl, "IMP", 2},
+	0x0B: {"ANC", (*MOS6502).anc, "IMM", 2},
+	0x0C: {"NOP", (*MOS6502).nop, "ABS", 4},
+	0x0D: {"ORA", (*MOS6502).ora, "ABS", 4},
+	0x0E: {"ASL", (*MOS6502).asl, "ABS", 6},
+	0x0F: {"SLO", (*MOS6502).slo, "ABS", 6},
+	// 0x1_
+	0x10: {"BPL", (*MOS6502).bpl, "REL", 2},
+	0x11: {"ORA", (*MOS6502).ora, "IZY", 5},
+	0x13: {"SLO", (*MOS6502).slo, "IZY", 8},
+	0x14: {"NOP", (*MOS6502).nop, "ZPX", 4},
+	0x15: {"ORA", (*MOS6502).ora, "ZPX", 4},
+	0x16: {"ASL", (*MOS6502).asl, "ZPX", 6},
+	0x17: {"SLO", (*MOS6502).slo, "ZPX", 6},
+	0x18: {"CLC", (*MOS6502).clc, "IMP", 2},
+	0x19: {"ORA", (*MOS6502).ora, "ABY", 4},
+	0x1A: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0x1B: {"SLO", (*MOS6502).slo, "ABY", 7},
+	0x1C: {"NOP", (*MOS6502).nop, "ABX", 4},
+	0x1D: {"ORA", (*MOS6502).ora, "ABX", 4},
+	0x1E: {"ASL", (*MOS6502).asl, "ABX", 7},
+	0x1F: {"SLO", (*MOS6502).slo, "ABX", 7},
+	// 0x2_
+	0x20: {"JSR", (*MOS6502).jsr, "ABS", 6},
+	0x21: {"AND", (*MOS6502).and, "IZX", 6},
+	0x23: {"RLA", (*MOS6502).rla, "IZX", 8},
+	0x24: {"BIT", (*MOS6502).bit, "ZP0", 3},
+	0x25: {"AND", (*MOS6502).and, "ZP0", 3},
+	0x26: {"ROL", (*MOS6502).rol, "ZP0", 5},
+	0x27: {"RLA", (*MOS6502).rla, "ZP0", 5},
+	0x28: {"PLP", (*MOS6502).plp, "IMP", 4},
+	0x29: {"AND", (*MOS6502).and, "IMM", 2},
+	0x2A: {"ROL", (*MOS6502).rol, "IMP", 2},
+	0x2B: {"ANC", (*MOS6502).anc, "IMM", 2},
+	0x2C: {"BIT", (*MOS6502).bit, "ABS", 4},
+	0x2D: {"AND", (*MOS6502).and, "ABS", 4},
+	0x2E: {"ROL", (*MOS6502).rol, "ABS", 6},
+	0x2F: {"RLA", (*MOS6502).rla, "ABS", 6},
+	// 0x3_
+	0x30: {"BMI", (*MOS6502).bmi, "REL", 2},
+	0x31: {"AND", (*MOS6502).and, "IZY", 5},
+	0x33: {"RLA", (*MOS6502).rla, "IZY", 8},
+	0x34: {"NOP", (*MOS6502).nop, "ZPX", 4},
+	0x35: {"AND", (*MOS6502).and, "ZPX", 4},
+	0x36: {"ROL", (*MOS6502).rol, "ZPX", 6},
+	0x37: {"RLA", (*MOS6502).rla, "ZPX", 6},
+	0x38: {"SEC", (*MOS6502).sec, "IMP", 2},
+	0x39: {"AND", (*MOS6502).and, "ABY", 4},
+	0x3A: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0x3B: {"RLA", (*MOS6502).rla, "ABY", 7},
+	0x3C: {"NOP", (*MOS6502).nop, "ABX", 4},
+	0x3D: {"AND", (*MOS6502).and, "ABX", 4},
+	0x3E: {"ROL", (*MOS6502).rol, "ABX", 7},
+	0x3F: {"RLA", (*MOS6502).rla, "ABX", 7},
+	// 0x4_
+	0x40: {"RTI", (*MOS6502).rti, "IMP", 6},
+	0x41: {"EOR", (*MOS6502).eor, "IZX", 6},
+	0x43: {"SRE", (*MOS6502).sre, "IZX", 8},
+	0x44: {"NOP", (*MOS6502).nop, "ZP0", 3},
+	0x45: {"EOR", (*MOS6502).eor, "ZP0", 3},
+	0x46: {"LSR", (*MOS6502).lsr, "ZP0", 5},
+	0x47: {"SRE", (*MOS6502).sre, "ZP0", 5},
+	0x48: {"PHA", (*MOS6502).pha, "IMP", 3},
+	0x49: {"EOR", (*MOS6502).eor, "IMM", 2},
+	0x4A: {"LSR", (*MOS6502).lsr, "IMP", 2},
+	0x4B: {"ALR", (*MOS6502).alr, "IMM", 2},
+	0x4C: {"JMP", (*MOS6502).jmp, "ABS", 3},
+	0x4D: {"EOR", (*MOS6502).eor, "ABS", 4},
+	0x4E: {"LSR", (*MOS6502).lsr, "ABS", 6},
+	0x4F: {"SRE", (*MOS6502).sre, "ABS", 6},
+	// 0x5_
+	0x50: {"BVC", (*MOS6502).bvc, "REL", 2},
+	0x51: {"EOR", (*MOS6502).eor, "IZY", 5},
+	0x53: {"SRE", (*MOS6502).sre, "IZY", 8},
+	0x54: {"NOP", (*MOS6502).nop, "ZPX", 4},
+	0x55: {"EOR", (*MOS6502).eor, "ZPX", 4},
+	0x56: {"LSR", (*MOS6502).lsr, "ZPX", 6},
+	0x57: {"SRE", (*MOS6502).sre, "ZPX", 6},
+	0x58: {"CLI", (*MOS6502).cli, "IMP", 2},
+	0x59: {"EOR", (*MOS6502).eor, "ABY", 4},
+	0x5A: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0x5B: {"SRE", (*MOS6502).sre, "ABY", 7},
+	0x5C: {"NOP", (*MOS6502).nop, "ABX", 4},
+	0x5D: {"EOR", (*MOS6502).eor, "ABX", 4},
+	0x5E: {"LSR", (*MOS6502).lsr, "ABX", 7},
+	0x5F: {"SRE", (*MOS6502).sre, "ABX", 7},
+	// 0x6_
+	0x60: {"RTS", (*MOS6502).rts, "IMP", 6},
+	0x61: {"ADC", (*MOS6502).adc, "IZX", 6},
+	0x63: {"RRA", (*MOS6502).rra, "IZX", 8},
+	0x64: {"NOP", (*MOS6502).nop, "ZP0", 3},
+	0x65: {"ADC", (*MOS6502).adc, "ZP0", 3},
+	0x66: {"ROR", (*MOS6502).ror, "ZP0", 5},
+	0x67: {"RRA", (*MOS6502).rra, "ZP0", 5},
+	0x68: {"PLA", (*MOS6502).pla, "IMP", 4},
+	0x69: {"ADC", (*MOS6502).adc, "IMM", 2},
+	0x6A: {"ROR", (*MOS6502).ror, "IMP", 2},
+	0x6B: {"ARR", (*MOS6502).arr, "IMM", 2},
+	0x6C: {"JMP", (*MOS6502).jmp, "IND", 5},
+	0x6D: {"ADC", (*MOS6502).adc, "ABS", 4},
+	0x6E: {"ROR", (*MOS6502).ror, "ABS", 6},
+	0x6F: {"RRA", (*MOS6502).rra, "ABS", 6},
+	// 0x7_
+	0x70: {"BVS", (*MOS6502).bvs, "REL", 2},
+	0x71: {"ADC", (*MOS6502).adc, "IZY", 5},
+	0x73: {"RRA", (*MOS6502).rra, "IZY", 8},
+	0x74: {"NOP", (*MOS6502).nop, "ZPX", 4},
+	0x75: {"ADC", (*MOS6502).adc, "ZPX", 4},
+	0x76: {"ROR", (*MOS6502).ror, "ZPX", 6},
+	0x77: {"RRA", (*MOS6502).rra, "ZPX", 6},
+	0x78: {"SEI", (*MOS6502).sei, "IMP", 2},
+	0x79: {"ADC", (*MOS6502).adc, "ABY", 4},
+	0x7A: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0x7B: {"RRA", (*MOS6502).rra, "ABY", 7},
+	0x7C: {"NOP", (*MOS6502).nop, "ABX", 4},
+	0x7D: {"ADC", (*MOS6502).adc, "ABX", 4},
+	0x7E: {"ROR", (*MOS6502).ror, "ABX", 7},
+	0x7F: {"RRA", (*MOS6502).rra, "ABX", 7},
+	// 0x8_
+	0x80: {"NOP", (*MOS6502).nop, "IMM", 2},
+	0x81: {"STA", (*MOS6502).sta, "IZX", 6},
+	0x82: {"NOP", (*MOS6502).nop, "IMM", 2},
+	0x83: {"SAX", (*MOS6502).sax, "IZX", 6},
+	0x84: {"STY", (*MOS6502).sty, "ZP0", 3},
+	0x85: {"STA", (*MOS6502).sta, "ZP0", 3},
+	0x86: {"STX", (*MOS6502).stx, "ZP0", 3},
+	0x87: {"SAX", (*MOS6502).sax, "ZP0", 3},
+	0x88: {"DEY", (*MOS6502).dey, "IMP", 2},
+	0x89: {"NOP", (*MOS6502).nop, "IMM", 2},
+	0x8A: {"TXA", (*MOS6502).txa, "IMP", 2},
+	0x8C: {"STY", (*MOS6502).sty, "ABS", 4},
+	0x8D: {"STA", (*MOS6502).sta, "ABS", 4},
+	0x8E: {"STX", (*MOS6502).stx, "ABS", 4},
+	0x8F: {"SAX", (*MOS6502).sax, "ABS", 4},
+	// 0x9_
+	0x90: {"BCC", (*MOS6502).bcc, "REL", 2},
+	0x91: {"STA", (*MOS6502).sta, "IZY", 6},
+	0x94: {"STY", (*MOS6502).sty, "ZPX", 4},
+	0x95: {"STA", (*MOS6502).sta, "ZPX", 4},
+	0x96: {"STX", (*MOS6502).stx, "ZPY", 4},
+	0x97: {"SAX", (*MOS6502).sax, "ZPY", 4},
+	0x98: {"TYA", (*MOS6502).tya, "IMP", 2},
+	0x99: {"STA", (*MOS6502).sta, "ABY", 5},
+	0x9A: {"TXS", (*MOS6502).txs, "IMP", 2},
+	0x9D: {"STA", (*MOS6502).sta, "ABX", 5},
+	// 0xA_
+	0xA0: {"LDY", (*MOS6502).ldy, "IMM", 2},
+	0xA1: {"LDA", (*MOS6502).lda, "IZX", 6},
+	0xA2: {"LDX", (*MOS6502).ldx, "IMM", 2},
+	0xA3: {"LAX", (*MOS6502).lax, "IZX", 6},
+	0xA4: {"LDY", (*MOS6502).ldy, "ZP0", 3},
+	0xA5: {"LDA", (*MOS6502).lda, "ZP0", 3},
+	0xA6: {"LDX", (*MOS6502).ldx, "ZP0", 3},
+	0xA7: {"LAX", (*MOS6502).lax, "ZP0", 3},
+	0xA8: {"TAY", (*MOS6502).tay, "IMP", 2},
+	0xA9: {"LDA", (*MOS6502).lda, "IMM", 2},
+	0xAA: {"TAX", (*MOS6502).tax, "IMP", 2},
+	0xAC: {"LDY", (*MOS6502).ldy, "ABS", 4},
+	0xAD: {"LDA", (*MOS6502).lda, "ABS", 4},
+	0xAE: {"LDX", (*MOS6502).ldx, "ABS", 4},
+	0xAF: {"LAX", (*MOS6502).lax, "ABS", 4},
+	// 0xB_
+	0xB0: {"BCS", (*MOS6502).bcs, "REL", 2},
+	0xB1: {"LDA", (*MOS6502).lda, "IZY", 5},
+	0xB3: {"LAX", (*MOS6502).lax, "IZY", 5},
+	0xB4: {"LDY", (*MOS6502).ldy, "ZPX", 4},
+	0xB5: {"LDA", (*MOS6502).lda, "ZPX", 4},
+	0xB6: {"LDX", (*MOS6502).ldx, "ZPY", 4},
+	0xB7: {"LAX", (*MOS6502).lax, "ZPY", 4},
+	0xB8: {"CLV", (*MOS6502).clv, "IMP", 2},
+	0xB9: {"LDA", (*MOS6502).lda, "ABY", 4},
+	0xBA: {"TSX", (*MOS6502).tsx, "IMP", 2},
+	0xBC: {"LDY", (*MOS6502).ldy, "ABX", 4},
+	0xBD: {"LDA", (*MOS6502).lda, "ABX", 4},
+	0xBE: {"LDX", (*MOS6502).ldx, "ABY", 4},
+	0xBF: {"LAX", (*MOS6502).lax, "ABY", 4},
+	// 0xC_
+	0xC0: {"CPY", (*MOS6502).cpy, "IMM", 2},
+	0xC1: {"CMP", (*MOS6502).cmp, "IZX", 6},
+	0xC2: {"NOP", (*MOS6502).nop, "IMM", 2},
+	0xC3: {"DCP", (*MOS6502).dcp, "IZX", 8},
+	0xC4: {"CPY", (*MOS6502).cpy, "ZP0", 3},
+	0xC5: {"CMP", (*MOS6502).cmp, "ZP0", 3},
+	0xC6: {"DEC", (*MOS6502).dec, "ZP0", 5},
+	0xC7: {"DCP", (*MOS6502).dcp, "ZP0", 5},
+	0xC8: {"INY", (*MOS6502).iny, "IMP", 2},
+	0xC9: {"CMP", (*MOS6502).cmp, "IMM", 2},
+	0xCA: {"DEX", (*MOS6502).dex, "IMP", 2},
+	0xCB: {"AXS", (*MOS6502).axs, "IMM", 2},
+	0xCC: {"CPY", (*MOS6502).cpy, "ABS", 4},
+	0xCD: {"CMP", (*MOS6502).cmp, "ABS", 4},
+	0xCE: {"DEC", (*MOS6502).dec, "ABS", 6},
+	0xCF: {"DCP", (*MOS6502).dcp, "ABS", 6},
+	// 0xD_
+	0xD0: {"BNE", (*MOS6502).bne, "REL", 2},
+	0xD1: {"CMP", (*MOS6502).cmp, "IZY", 5},
+	0xD3: {"DCP", (*MOS6502).dcp, "IZY", 8},
+	0xD4: {"NOP", (*MOS6502).nop, "ZPX", 4},
+	0xD5: {"CMP", (*MOS6502).cmp, "ZPX", 4},
+	0xD6: {"DEC", (*MOS6502).dec, "ZPX", 6},
+	0xD7: {"DCP", (*MOS6502).dcp, "ZPX", 6},
+	0xD8: {"CLD", (*MOS6502).cld, "IMP", 2},
+	0xD9: {"CMP", (*MOS6502).cmp, "ABY", 4},
+	0xDA: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0xDB: {"DCP", (*MOS6502).dcp, "ABY", 7},
+	0xDC: {"NOP", (*MOS6502).nop, "ABX", 4},
+	0xDD: {"CMP", (*MOS6502).cmp, "ABX", 4},
+	0xDE: {"DEC", (*MOS6502).dec, "ABX", 7},
+	0xDF: {"DCP", (*MOS6502).dcp, "ABX", 7},
+	// 0xE_
+	0xE0: {"CPX", (*MOS6502).cpx, "IMM", 2},
+	0xE1: {"SBC", (*MOS6502).sbc, "IZX", 6},
+	0xE2: {"NOP", (*MOS6502).nop, "IMM", 2},
+	0xE3: {"ISC", (*MOS6502).isc, "IZX", 8},
+	0xE4: {"CPX", (*MOS6502).cpx, "ZP0", 3},
+	0xE5: {"SBC", (*MOS6502).sbc, "ZP0", 3},
+	0xE6: {"INC", (*MOS6502).inc, "ZP0", 5},
+	0xE7: {"ISC", (*MOS6502).isc, "ZP0", 5},
+	0xE8: {"INX", (*MOS6502).inx, "IMP", 2},
+	0xE9: {"SBC", (*MOS6502).sbc, "IMM", 2},
+	0xEA: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0xEB: {"SBC", (*MOS6502).sbc, "IMM", 2},
+	0xEC: {"CPX", (*MOS6502).cpx, "ABS", 4},
+	0xED: {"SBC", (*MOS6502).sbc, "ABS", 4},
+	0xEE: {"INC", (*MOS6502).inc, "ABS", 6},
+	0xEF: {"ISC", (*MOS6502).isc, "ABS", 6},
+	// 0xF_
+	0xF0: {"BEQ", (*MOS6502).beq, "REL", 2},
+	0xF1: {"SBC", (*MOS6502).sbc, "IZY", 5},
+	0xF3: {"ISC", (*MOS6502).isc, "IZY", 8},
+	0xF4: {"NOP", (*MOS6502).nop, "ZPX", 4},
+	0xF5: {"SBC", (*MOS6502).sbc, "ZPX", 4},
+	0xF6: {"INC", (*MOS6502).inc, "ZPX", 6},
+	0xF7: {"ISC", (*MOS6502).isc, "ZPX", 6},
+	0xF8: {"SED", (*MOS6502).sed, "IMP", 2},
+	0xF9: {"SBC", (*MOS6502).sbc, "ABY", 4},
+	0xFA: {"NOP", (*MOS6502).nop, "IMP", 2},
+	0xFB: {"ISC", (*MOS6502).isc, "ABY", 7},
+	0xFC: {"NOP", (*MOS6502).nop, "ABX", 4},
+	0xFD: {"SBC", (*MOS6502).sbc, "ABX", 4},
+	0xFE: {"INC", (*MOS6502).inc, "ABX", 7},
+	0xFF: {"ISC", (*MOS6502).isc, "ABX", 7},
+}
@@ -0,0 +1,3 @@
+module github.com/cbertinato/go-nes
+
+go 1.21
@@ -0,0 +1,87 @@
+package input
+
+import "math/bits"
+
+// Arkanoid controller
+// -------------------
+// The Vaus controller that came with Arkanoid has a knob turning a potentiometer and a fire
+// button. The strobe latches the position of the knob as an 8 bit value, roughly 0x62-0xF2,
+// which reads shift out MSB first and inverted.
+//     - NES: plugs into port 2. D3 is the button, 1 when pressed, and D4 the position.
+//     - Famicom: plugs into the expansion port. D1 of 0x4016 is the button and D1 of 0x4017
+//       the position.
+//
+// The position is taken from the X of a Pointer, 0-255 across the range of the knob.
+
+const (
+	arkanoidMin = 0x62
+	arkanoidMax = 0xF2
+)
+
+// Arkanoid is the NES Arkanoid controller
+type Arkanoid struct {
+	Source PointerSource
+
+	famicom bool // Only the position is reported, on D1
+	strobe  bool
+	shift   uint8
+}
+
+// latch loads the shift register with the position of the knob, reversed so that it shifts
+// out MSB first
+func (a *Arkanoid) latch() {
+	x := 0
+	if a.Source != nil {
+		x = min(max(a.Source.Pointer().X, 0), 255)
+	}
+	position := arkanoidMin + x*(arkanoidMax-arkanoidMin)/255
+	a.shift = bits.Reverse8(^uint8(position))
+}
+
+// Write sets the strobe, latching the position while it is high
+func (a *Arkanoid) Write(data uint8) {
+	a.strobe = data&0x01 != 0
+	if a.strobe {
+		a.latch()
+	}
+}
+
+// Read returns the button on D3 and the next bit of the position on D4
+func (a *Arkanoid) Read(readOnly bool) uint8 {
+	if a.strobe && !readOnly {
+		a.latch()
+	}
+
+	bit := a.shift & 0x01
+	if !a.strobe && !readOnly {
+		a.shift >>= 1
+	}
+
+	if a.famicom {
+		return bit << 1
+	}
+	data := bit << 4
+	if a.Source != nil && a.Source.Pointer().Button {
+		data |= 0x08
+	}
+	return data
+}
+
+// arkanoidButton reports the button of the Famicom Arkanoid controller
+type arkanoidButton struct {
+	source PointerSource
+}
+
+func (b arkanoidButton) Write(data uint8) {}
+
+func (b arkanoidButton) Read(readOnly bool) uint8 {
+	if b.source != nil && b.source.Pointer().Button {
+		return 0x02
+	}
+	return 0
+}
+
+// FamicomArkanoid returns the devices of ports 1 and 2 for the Famicom Arkanoid controller
+func FamicomArkanoid(source PointerSource) (port1 Device, port2 Device) {
+	return arkanoidButton{source: source}, &Arkanoid{Source: source, famicom: true}
+}
@@ -0,0 +1,41 @@
+package input
+
+import "testing"
+
+// readPosition strobes a device and returns the 8 bit position read from a data line, undoing
+// the inversion
+func readPosition(d Device, line uint8) uint8 {
+	var position uint8
+	for _, bit := range readBits(d, 8, line) {
+		position = position<<1 | bit
+	}
+	return ^position
+}
+
+// TestArkanoid checks the range of the knob and the button of both versions
+func TestArkanoid(t *testing.T) {
+	pointer := &PointerState{}
+	nes := &Arkanoid{Source: pointer}
+	button, position := FamicomArkanoid(pointer)
+
+	for _, tt := range []struct {
+		x        int
+		expected uint8
+	}{{0, arkanoidMin}, {255, arkanoidMax}, {-10, arkanoidMin}, {128, 0xAA}} {
+		pointer.Set(Pointer{X: tt.x})
+		if p := readPosition(nes, 0x10); p != tt.expected {
+			t.Errorf("NES at %d: expected %#02x, got %#02x", tt.x, tt.expected, p)
+		}
+		if p := readPosition(position, 0x02); p != tt.expected {
+			t.Errorf("Famicom at %d: expected %#02x, got %#02x", tt.x, tt.expected, p)
+		}
+	}
+
+	pointer.Set(Pointer{Button: true})
+	if data := nes.Read(false); data&0x08 == 0 {
+		t.Errorf("Expected the button on D3, got %#02x", data)
+	}
+	if data := button.Read(false); data != 0x02 {
+		t.Errorf("Expected the button on D1, got %#02x", data)
+	}
+}
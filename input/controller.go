@@ -0,0 +1,115 @@
+package input
+
+import "sync/atomic"
+
+// Standard controller
+// -------------------
+// The standard controller holds its 8 buttons in a 4021 shift register. While the strobe (OUT0)
+// is high the register is continuously reloaded, so every read returns the state of A. When the
+// strobe goes low the state is held and each read shifts out the next button on D0, in the
+// order A, B, Select, Start, Up, Down, Left, Right. The serial input of the register is tied
+// high, so every read after the eighth returns 1.
+//
+// The state of the buttons comes from a Source, which is polled each time the controller
+// latches. A front end can use a State, updated from its own event loop, while movie replays and
+// scripts can supply the buttons of each frame with a SourceFunc.
+
+// Buttons is a set of controller buttons, one bit each in report order
+type Buttons uint8
+
+const (
+	ButtonA Buttons = 1 << iota
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonUp
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+)
+
+// Source supplies the buttons held on a controller
+type Source interface {
+	Buttons() Buttons
+}
+
+// SourceFunc adapts a function to a Source
+type SourceFunc func() Buttons
+
+// Buttons calls f
+func (f SourceFunc) Buttons() Buttons {
+	return f()
+}
+
+// State is a Source holding the buttons currently pressed. It can be updated from a goroutine
+// other than the emulator's.
+type State struct {
+	buttons atomic.Uint32
+}
+
+// Buttons returns the buttons currently pressed
+func (s *State) Buttons() Buttons {
+	return Buttons(s.buttons.Load())
+}
+
+// Set replaces the buttons pressed
+func (s *State) Set(b Buttons) {
+	s.buttons.Store(uint32(b))
+}
+
+// Press adds to the buttons pressed
+func (s *State) Press(b Buttons) {
+	for {
+		old := s.buttons.Load()
+		if s.buttons.CompareAndSwap(old, old|uint32(b)) {
+			return
+		}
+	}
+}
+
+// Release removes from the buttons pressed
+func (s *State) Release(b Buttons) {
+	for {
+		old := s.buttons.Load()
+		if s.buttons.CompareAndSwap(old, old&^uint32(b)) {
+			return
+		}
+	}
+}
+
+// Controller is the standard NES controller
+type Controller struct {
+	Source Source // Buttons of the controller, none if nil
+
+	strobe bool
+	shift  uint8
+}
+
+// latch loads the shift register from the source
+func (c *Controller) latch() {
+	c.shift = 0
+	if c.Source != nil {
+		c.shift = uint8(c.Source.Buttons())
+	}
+}
+
+// Write sets the strobe, latching the buttons while it is high
+func (c *Controller) Write(data uint8) {
+	c.strobe = data&0x01 != 0
+	if c.strobe {
+		c.latch()
+	}
+}
+
+// Read returns the next button on D0, 1 if pressed
+func (c *Controller) Read(readOnly bool) uint8 {
+	if c.strobe && !readOnly {
+		c.latch()
+	}
+
+	data := c.shift & 0x01
+	if !c.strobe && !readOnly {
+		c.shift = c.shift>>1 | 0x80
+	}
+	return data
+}
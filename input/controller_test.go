@@ -0,0 +1,105 @@
+package input
+
+import "testing"
+
+// testCPU provides the open bus value
+type testCPU struct {
+	data uint8
+}
+
+func (c *testCPU) DataBus() uint8 {
+	return c.data
+}
+
+// testBus records writes passed on by the ports
+type testBus struct {
+	writes map[uint16]uint8
+}
+
+func (b *testBus) Read(address uint16, readOnly bool) uint8 {
+	return 0
+}
+
+func (b *testBus) Write(address uint16, data uint8) {
+	b.writes[address] = data
+}
+
+// TestControllerReport checks the order of the buttons and the 1s after the eighth read
+func TestControllerReport(t *testing.T) {
+	state := &State{}
+	c := &Controller{Source: state}
+	p := &Ports{CPU: &testCPU{data: 0x40}, Port1: c}
+
+	state.Set(ButtonA | ButtonStart | ButtonRight)
+	p.Write(0x4016, 1)
+	p.Write(0x4016, 0)
+
+	expected := []uint8{1, 0, 0, 1, 0, 0, 0, 1, 1, 1, 1}
+	for i, e := range expected {
+		if data := p.Read(0x4016, false); data != 0x40|e {
+			t.Errorf("Read %d: expected %#02x, got %#02x", i, 0x40|e, data)
+		}
+	}
+}
+
+// TestControllerStrobe checks that the state of A is returned while the strobe is high and that
+// the buttons are held once it is low
+func TestControllerStrobe(t *testing.T) {
+	state := &State{}
+	c := &Controller{Source: state}
+
+	c.Write(1)
+	for i := 0; i < 3; i++ {
+		if data := c.Read(false); data != 0 {
+			t.Errorf("Expected 0 with A released, got %d", data)
+		}
+	}
+	state.Press(ButtonA)
+	if data := c.Read(false); data != 1 {
+		t.Errorf("Expected 1 with A pressed during strobe, got %d", data)
+	}
+
+	c.Write(0)
+	state.Release(ButtonA)
+	if data := c.Read(true); data != 1 {
+		t.Errorf("Expected the latched A, got %d", data)
+	}
+	if data := c.Read(false); data != 1 {
+		t.Errorf("Expected a readOnly read not to shift, got %d", data)
+	}
+	if data := c.Read(false); data != 0 {
+		t.Errorf("Expected B released, got %d", data)
+	}
+}
+
+// TestPorts checks open bus, empty ports and the routing of writes
+func TestPorts(t *testing.T) {
+	next := &testBus{writes: map[uint16]uint8{}}
+	calls := 0
+	c := &Controller{Source: SourceFunc(func() Buttons {
+		calls++
+		return ButtonB
+	})}
+	p := &Ports{CPU: &testCPU{data: 0xFF}, Next: next, Port2: c}
+
+	if data := p.Read(0x4016, false); data != 0xE0 {
+		t.Errorf("Expected 0xE0 from an empty port, got %#02x", data)
+	}
+
+	p.Write(0x4016, 0x01)
+	p.Write(0x4016, 0x00)
+	if calls != 1 {
+		t.Errorf("Expected the source to be polled once, got %d", calls)
+	}
+	if data := p.Read(0x4017, false); data != 0xE0 {
+		t.Errorf("Expected 0xE0, got %#02x", data)
+	}
+	if data := p.Read(0x4017, false); data != 0xE1 {
+		t.Errorf("Expected 0xE1 for B, got %#02x", data)
+	}
+
+	p.Write(0x4017, 0x40)
+	if next.writes[0x4017] != 0x40 || len(next.writes) != 1 {
+		t.Errorf("Expected only the write to 0x4017 to be passed on, got %v", next.writes)
+	}
+}
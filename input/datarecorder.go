@@ -0,0 +1,155 @@
+package input
+
+import (
+	"github.com/cbertinato/go-nes/audio"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Data recorder
+// -------------
+// The Famicom Data Recorder is a cassette deck connected to the keyboard, which Family BASIC and
+// a few games use to save and load data. The Famicom drives the tape's input with OUT2 of 0x4016
+// and reads the tape's output, squared up, on D1 of 0x4016. Programs produce and decode the
+// signal themselves, timing it with the CPU, so the tape is kept as audio samples and positioned
+// by counting CPU cycles since the tape started. Tapes are saved and loaded as WAV files.
+//
+// The deck is operated by the user rather than the program, through Play, Record and Stop.
+
+const tapeLevel = 0.5 // Amplitude of a recorded signal
+
+// CycleCounter counts the cycles of the CPU
+type CycleCounter interface {
+	Cycles() uint64
+}
+
+// tapeMode is what the data recorder is doing
+type tapeMode int
+
+const (
+	tapeStopped tapeMode = iota
+	tapePlaying
+	tapeRecording
+)
+
+// DataRecorder is the Famicom Data Recorder
+type DataRecorder struct {
+	Clock  CycleCounter // CPU the program runs on
+	Region ppu.Region   // TV system, which sets the rate of the CPU clock
+
+	tape  []float32
+	rate  int // Sample rate of the tape
+	mode  tapeMode
+	start uint64 // CPU cycle at which the tape started
+	out   bool   // Level of OUT2
+}
+
+// Insert replaces the tape with samples at a sample rate
+func (d *DataRecorder) Insert(samples []float32, rate int) {
+	d.Stop()
+	d.tape = samples
+	d.rate = rate
+}
+
+// LoadWAV replaces the tape with a WAV file
+func (d *DataRecorder) LoadWAV(path string) error {
+	samples, rate, err := audio.LoadWAV(path)
+	if err != nil {
+		return err
+	}
+	d.Insert(samples, rate)
+	return nil
+}
+
+// SaveWAV writes the tape to a WAV file
+func (d *DataRecorder) SaveWAV(path string) error {
+	w, err := audio.CreateWAV(path, d.rate, audio.Int16)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteSamples(d.tape); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Tape returns the samples on the tape and their sample rate
+func (d *DataRecorder) Tape() ([]float32, int) {
+	return d.tape, d.rate
+}
+
+// Play starts playing the tape from the beginning
+func (d *DataRecorder) Play() {
+	d.Stop()
+	d.mode = tapePlaying
+	d.start = d.cycles()
+}
+
+// Record starts recording a new tape at a sample rate, erasing the current one
+func (d *DataRecorder) Record(rate int) {
+	d.Stop()
+	d.tape = nil
+	d.rate = rate
+	d.mode = tapeRecording
+	d.start = d.cycles()
+}
+
+// Stop stops the tape, completing a recording
+func (d *DataRecorder) Stop() {
+	if d.mode == tapeRecording {
+		d.extend()
+	}
+	d.mode = tapeStopped
+}
+
+// cycles returns the current CPU cycle
+func (d *DataRecorder) cycles() uint64 {
+	if d.Clock == nil {
+		return 0
+	}
+	return d.Clock.Cycles()
+}
+
+// position returns the sample of the tape under the head
+func (d *DataRecorder) position() int {
+	seconds := float64(d.cycles()-d.start) / d.Region.CPUClock()
+	return int(seconds * float64(d.rate))
+}
+
+// extend records the level of OUT2 up to the current position
+func (d *DataRecorder) extend() {
+	level := float32(-tapeLevel)
+	if d.out {
+		level = tapeLevel
+	}
+	for n := d.position(); len(d.tape) < n; {
+		d.tape = append(d.tape, level)
+	}
+}
+
+// Write records OUT2
+func (d *DataRecorder) Write(data uint8) {
+	if d.mode == tapeRecording {
+		d.extend()
+	}
+	d.out = data&0x04 != 0
+}
+
+// Read returns the tape's output on D1, stopping at the end of the tape
+func (d *DataRecorder) Read(readOnly bool) uint8 {
+	if d.mode != tapePlaying {
+		return 0
+	}
+
+	i := d.position()
+	if i >= len(d.tape) {
+		if !readOnly {
+			d.Stop()
+		}
+		return 0
+	}
+	if d.tape[i] > 0 {
+		return 0x02
+	}
+	return 0
+}
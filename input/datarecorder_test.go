@@ -0,0 +1,60 @@
+package input
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// testClock is a CPU cycle counter that is advanced by hand
+type testClock struct {
+	cycles uint64
+}
+
+func (c *testClock) Cycles() uint64 {
+	return c.cycles
+}
+
+// TestDataRecorder checks that a recorded signal plays back through a WAV file
+func TestDataRecorder(t *testing.T) {
+	clock := &testClock{}
+	d := &DataRecorder{Clock: clock, Region: ppu.NTSC}
+
+	// A square wave with a period of 2000 CPU cycles
+	cpuPerSample := ppu.NTSC.CPUClock() / 8000
+	d.Record(8000)
+	for i := 0; i < 20; i++ {
+		d.Write(uint8(i%2) << 2)
+		clock.cycles += 1000
+	}
+	d.Stop()
+
+	samples, rate := d.Tape()
+	if rate != 8000 || len(samples) != int(float64(clock.cycles)/cpuPerSample) {
+		t.Fatalf("Expected %d samples at 8000 Hz, got %d at %d Hz", int(float64(clock.cycles)/cpuPerSample), len(samples), rate)
+	}
+
+	path := filepath.Join(t.TempDir(), "tape.wav")
+	if err := d.SaveWAV(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	d.Insert(nil, 0)
+	if err := d.LoadWAV(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	d.Play()
+	for i := 0; i < 20; i++ {
+		clock.cycles += 500 // middle of the half period
+		if data, expected := d.Read(false), uint8(i%2)<<1; data != expected {
+			t.Errorf("Half period %d: expected %#02x, got %#02x", i, expected, data)
+		}
+		clock.cycles += 500
+	}
+
+	clock.cycles += 1000
+	if data := d.Read(false); data != 0 || d.mode != tapeStopped {
+		t.Errorf("Expected the tape to stop at its end")
+	}
+}
@@ -0,0 +1,90 @@
+package input
+
+// Input device selection
+// ----------------------
+// Which devices a game expects is given by the default expansion device field of its NES 2.0
+// header, byte 15, whose values are used for Expansion. Front ends can also pick an Expansion
+// themselves. Devices that are not emulated connect standard controllers instead.
+
+// Expansion is a set of input devices plugged into the ports
+type Expansion uint8
+
+const (
+	ExpansionUnspecified     Expansion = 0x00
+	ExpansionStandard        Expansion = 0x01 // Standard controllers
+	ExpansionFourScore       Expansion = 0x02 // NES Four Score or Satellite
+	ExpansionFamicomFour     Expansion = 0x03 // Famicom four players adapter
+	ExpansionZapper          Expansion = 0x08 // Zapper in port 2
+	ExpansionTwoZappers      Expansion = 0x09
+	ExpansionPowerPadA       Expansion = 0x0B // Power Pad side A in port 2
+	ExpansionPowerPadB       Expansion = 0x0C
+	ExpansionFamilyTrainerA  Expansion = 0x0D
+	ExpansionFamilyTrainerB  Expansion = 0x0E
+	ExpansionArkanoid        Expansion = 0x0F // NES Arkanoid controller in port 2
+	ExpansionFamicomArkanoid Expansion = 0x10
+	ExpansionDataRecorder    Expansion = 0x20 // Famicom Data Recorder without the keyboard
+	ExpansionFamilyBASIC     Expansion = 0x23 // Family BASIC keyboard and data recorder
+)
+
+// ExpansionFromHeader returns the default expansion device of a cartridge from its 16 byte iNES
+// header. Only NES 2.0 headers have the field.
+func ExpansionFromHeader(header []uint8) Expansion {
+	if len(header) < 16 || header[7]&0x0C != 0x08 {
+		return ExpansionUnspecified
+	}
+	return Expansion(header[15] & 0x3F)
+}
+
+// Inputs are the sources the devices of an Expansion read from
+type Inputs struct {
+	Controllers [4]Source
+	Pointers    [2]PointerSource // Zappers and Arkanoid controllers
+	Mat         MatSource        // Power Pad or Family Trainer
+	Screen      Screen           // Picture the Zappers are aimed at
+	Keyboard    KeyboardSource
+	Microphone  MicrophoneSource // Famicom second controller's microphone, if not nil
+	Recorder    *DataRecorder    // Tape deck, for the front end to operate
+}
+
+// Connect plugs the devices of an Expansion into the ports, replacing those already there. The
+// microphone is added to port 1 whatever the expansion.
+func (p *Ports) Connect(e Expansion, in Inputs) {
+	controller1 := &Controller{Source: in.Controllers[0]}
+	controller2 := &Controller{Source: in.Controllers[1]}
+	p.Port1, p.Port2 = controller1, controller2
+
+	switch e {
+	case ExpansionFourScore:
+		p.Port1, p.Port2 = FourScore(in.Controllers)
+	case ExpansionFamicomFour:
+		p.Port1, p.Port2 = FamicomFourPlayer(in.Controllers)
+	case ExpansionZapper:
+		p.Port2 = &Zapper{Source: in.Pointers[0], Screen: in.Screen}
+	case ExpansionTwoZappers:
+		p.Port1 = &Zapper{Source: in.Pointers[0], Screen: in.Screen}
+		p.Port2 = &Zapper{Source: in.Pointers[1], Screen: in.Screen}
+	case ExpansionPowerPadA, ExpansionPowerPadB:
+		p.Port2 = &PowerPad{Source: in.Mat}
+	case ExpansionFamilyTrainerA, ExpansionFamilyTrainerB:
+		p.Port2 = wired{controller2, &FamilyTrainer{Source: in.Mat}}
+	case ExpansionArkanoid:
+		p.Port2 = &Arkanoid{Source: in.Pointers[0]}
+	case ExpansionFamicomArkanoid:
+		button, position := FamicomArkanoid(in.Pointers[0])
+		p.Port1 = wired{controller1, button}
+		p.Port2 = wired{controller2, position}
+	case ExpansionDataRecorder:
+		if in.Recorder != nil {
+			p.Port1 = wired{controller1, in.Recorder}
+		}
+	case ExpansionFamilyBASIC:
+		p.Port2 = wired{controller2, &Keyboard{Source: in.Keyboard}}
+		if in.Recorder != nil {
+			p.Port1 = wired{controller1, in.Recorder}
+		}
+	}
+
+	if in.Microphone != nil {
+		p.Port1 = wired{p.Port1, &Microphone{Source: in.Microphone}}
+	}
+}
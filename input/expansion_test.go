@@ -0,0 +1,93 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// TestExpansionFromHeader checks that the field is only read from NES 2.0 headers
+func TestExpansionFromHeader(t *testing.T) {
+	header := []uint8{'N', 'E', 'S', 0x1A, 2, 1, 0, 0x08, 0, 0, 0, 0, 0, 0, 0, 0xC8}
+	if e := ExpansionFromHeader(header); e != ExpansionZapper {
+		t.Errorf("Expected the Zapper, got %#02x", e)
+	}
+
+	header[7] = 0
+	if e := ExpansionFromHeader(header); e != ExpansionUnspecified {
+		t.Errorf("Expected no device from an iNES 1.0 header, got %#02x", e)
+	}
+}
+
+// TestConnect checks the devices plugged in for each expansion
+func TestConnect(t *testing.T) {
+	p := &Ports{}
+
+	p.Connect(ExpansionUnspecified, Inputs{})
+	if _, ok := p.Port1.(*Controller); !ok {
+		t.Errorf("Expected a controller in port 1, got %T", p.Port1)
+	}
+	if _, ok := p.Port2.(*Controller); !ok {
+		t.Errorf("Expected a controller in port 2, got %T", p.Port2)
+	}
+
+	p.Connect(ExpansionZapper, Inputs{})
+	if _, ok := p.Port2.(*Zapper); !ok {
+		t.Errorf("Expected a Zapper in port 2, got %T", p.Port2)
+	}
+
+	p.Connect(ExpansionPowerPadB, Inputs{})
+	if _, ok := p.Port2.(*PowerPad); !ok {
+		t.Errorf("Expected a Power Pad in port 2, got %T", p.Port2)
+	}
+
+	p.Connect(ExpansionFamicomArkanoid, Inputs{})
+	if w, ok := p.Port2.(wired); !ok || len(w) != 2 {
+		t.Errorf("Expected the controller and the Arkanoid controller in port 2, got %T", p.Port2)
+	}
+}
+
+// TestConnectFamilyBASIC checks the keyboard, the data recorder and the microphone
+func TestConnectFamilyBASIC(t *testing.T) {
+	keys := &KeyboardState{}
+	keys.Press(KeyF8)
+	p := &Ports{}
+	p.Connect(ExpansionFamilyBASIC, Inputs{
+		Keyboard:   keys,
+		Microphone: MicrophoneFunc(func() bool { return true }),
+		Recorder:   &DataRecorder{},
+	})
+
+	p.Write(0x4016, 0x05)
+	if data := p.Read(0x4017, false); data != 0x1C {
+		t.Errorf("Expected F8 pressed, got %#02x", data)
+	}
+	if data := p.Read(0x4016, false); data&0x04 == 0 {
+		t.Errorf("Expected the microphone on D2, got %#02x", data)
+	}
+}
+
+// TestFamilyBASICTape checks that the tape is read on 0x4016 while the keyboard drives 0x4017
+func TestFamilyBASICTape(t *testing.T) {
+	clock := &testClock{}
+	recorder := &DataRecorder{Clock: clock, Region: ppu.NTSC}
+	recorder.Insert([]float32{0.5, -0.5}, 1000)
+
+	keys := &KeyboardState{}
+	p := &Ports{}
+	p.Connect(ExpansionFamilyBASIC, Inputs{Keyboard: keys, Recorder: recorder})
+	p.Write(0x4016, 0x04)
+	recorder.Play()
+
+	if data := p.Read(0x4016, false); data&0x02 == 0 {
+		t.Errorf("Expected a high tape bit on D1 of 0x4016, got %#02x", data)
+	}
+	if data := p.Read(0x4017, false); data&0x1E != 0x1E {
+		t.Errorf("Expected the keyboard's released keys on 0x4017, got %#02x", data)
+	}
+
+	clock.cycles += 2000 // the second sample
+	if data := p.Read(0x4016, false); data&0x02 != 0 {
+		t.Errorf("Expected a low tape bit on D1 of 0x4016, got %#02x", data)
+	}
+}
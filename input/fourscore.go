@@ -0,0 +1,47 @@
+package input
+
+// Four player adapters
+// --------------------
+// Four player adapters chain two controllers on each port. After a strobe, each port reports 24
+// bits: the 8 buttons of its first controller, the 8 buttons of its second, then a signature
+// that lets games detect the adapter.
+//     - NES Four Score / Satellite: on D0, controllers 1 and 3 on 0x4016 with signature 0x10,
+//       controllers 2 and 4 on 0x4017 with signature 0x20
+//     - Famicom four players adapter (Hori): the same reports on D1 of the expansion port,
+//       with the signatures swapped. The hardwired controllers 1 and 2 still report on D0.
+
+// fourPlayerReport returns the 24 bit report of a port of a four player adapter
+func fourPlayerReport(first Source, second Source, signature uint8) func() uint32 {
+	return func() uint32 {
+		var report uint32
+		if first != nil {
+			report |= uint32(first.Buttons())
+		}
+		if second != nil {
+			report |= uint32(second.Buttons()) << 8
+		}
+		return report | uint32(signature)<<16 | 0xFF000000
+	}
+}
+
+// FourScore returns the devices of ports 1 and 2 for an NES Four Score with the given
+// controllers
+func FourScore(controllers [4]Source) (port1 Device, port2 Device) {
+	port1 = &shifter{load: fourPlayerReport(controllers[0], controllers[2], 0x10), out: 0x01}
+	port2 = &shifter{load: fourPlayerReport(controllers[1], controllers[3], 0x20), out: 0x01}
+	return port1, port2
+}
+
+// FamicomFourPlayer returns the devices of ports 1 and 2 for a Famicom with the four players
+// adapter, including the hardwired controllers
+func FamicomFourPlayer(controllers [4]Source) (port1 Device, port2 Device) {
+	port1 = wired{
+		&Controller{Source: controllers[0]},
+		&shifter{load: fourPlayerReport(controllers[0], controllers[2], 0x20), out: 0x02},
+	}
+	port2 = wired{
+		&Controller{Source: controllers[1]},
+		&shifter{load: fourPlayerReport(controllers[1], controllers[3], 0x10), out: 0x02},
+	}
+	return port1, port2
+}
@@ -0,0 +1,67 @@
+package input
+
+import "testing"
+
+// readBits strobes a device and returns n reads of the given data line
+func readBits(d Device, n int, line uint8) []uint8 {
+	d.Write(1)
+	d.Write(0)
+	bits := make([]uint8, n)
+	for i := range bits {
+		if d.Read(false)&line != 0 {
+			bits[i] = 1
+		}
+	}
+	return bits
+}
+
+// TestFourScore checks the reports and signatures of the NES Four Score
+func TestFourScore(t *testing.T) {
+	buttons := func(b Buttons) Source {
+		return SourceFunc(func() Buttons { return b })
+	}
+	port1, port2 := FourScore([4]Source{buttons(ButtonA), buttons(ButtonB), buttons(ButtonStart), nil})
+
+	expected1 := []uint8{
+		1, 0, 0, 0, 0, 0, 0, 0, // controller 1
+		0, 0, 0, 1, 0, 0, 0, 0, // controller 3
+		0, 0, 0, 0, 1, 0, 0, 0, // signature
+		1, 1,
+	}
+	expected2 := []uint8{
+		0, 1, 0, 0, 0, 0, 0, 0, // controller 2
+		0, 0, 0, 0, 0, 0, 0, 0, // controller 4
+		0, 0, 0, 0, 0, 1, 0, 0, // signature
+		1, 1,
+	}
+
+	for port, tt := range []struct {
+		d        Device
+		expected []uint8
+	}{{port1, expected1}, {port2, expected2}} {
+		bits := readBits(tt.d, len(tt.expected), 0x01)
+		for i, e := range tt.expected {
+			if bits[i] != e {
+				t.Errorf("Port %d, read %d: expected %d, got %d", port+1, i, e, bits[i])
+			}
+		}
+	}
+}
+
+// TestFamicomFourPlayer checks that the adapter reports on D1 with swapped signatures, and that
+// the hardwired controllers report on D0
+func TestFamicomFourPlayer(t *testing.T) {
+	a := SourceFunc(func() Buttons { return ButtonA })
+	port1, _ := FamicomFourPlayer([4]Source{a, nil, nil, nil})
+
+	port1.Write(1)
+	port1.Write(0)
+	if data := port1.Read(false); data != 0x03 {
+		t.Errorf("Expected A on D0 and D1, got %#02x", data)
+	}
+
+	bits := readBits(port1, 24, 0x02)
+	if bits[16+5] != 1 || bits[16+4] != 0 {
+		t.Errorf("Expected signature 0x20 on port 1, got %v", bits[16:])
+	}
+}
@@ -0,0 +1,192 @@
+package input
+
+import "sync/atomic"
+
+// Family BASIC keyboard
+// ---------------------
+// The Family BASIC keyboard plugs into the Famicom expansion port. Its 72 keys form a matrix of
+// 9 rows of 2 columns of 4 keys, scanned through 0x4016 and 0x4017:
+//     - OUT0: 1 resets the row to 0
+//     - OUT1: selects the column; going from 1 to 0 moves to the next row
+//     - OUT2: 1 enables the matrix
+//     - 0x4017 D1-D4: the 4 keys of the selected row and column, 0 when pressed
+//
+// Games detect the keyboard by reading 0 from D1-D4 while it is disabled. After the last row
+// every key reads as released.
+//
+// Key numbers follow the matrix: row*8 + column*4 + the data line of the key, D1 first.
+
+// Key is a key of the Family BASIC keyboard
+type Key uint8
+
+const (
+	KeyF8 Key = iota
+	KeyReturn
+	KeyLeftBracket
+	KeyRightBracket
+	KeyKana
+	KeyRightShift
+	KeyYen
+	KeyStop
+
+	KeyF7
+	KeyAt
+	KeyColon
+	KeySemicolon
+	KeyUnderscore
+	KeySlash
+	KeyMinus
+	KeyCaret
+
+	KeyF6
+	KeyO
+	KeyL
+	KeyK
+	KeyPeriod
+	KeyComma
+	KeyP
+	Key0
+
+	KeyF5
+	KeyI
+	KeyU
+	KeyJ
+	KeyM
+	KeyN
+	Key9
+	Key8
+
+	KeyF4
+	KeyY
+	KeyG
+	KeyH
+	KeyB
+	KeyV
+	Key7
+	Key6
+
+	KeyF3
+	KeyT
+	KeyR
+	KeyD
+	KeyF
+	KeyC
+	Key5
+	Key4
+
+	KeyF2
+	KeyW
+	KeyS
+	KeyA
+	KeyX
+	KeyZ
+	KeyE
+	Key3
+
+	KeyF1
+	KeyEscape
+	KeyQ
+	KeyControl
+	KeyLeftShift
+	KeyGraph
+	Key1
+	Key2
+
+	KeyClear
+	KeyUp
+	KeyRight
+	KeyLeft
+	KeyDown
+	KeySpace
+	KeyDelete
+	KeyInsert
+
+	NumKeys = iota
+)
+
+const keyboardRows = NumKeys / 8
+
+// KeyboardSource supplies the keys pressed on a keyboard
+type KeyboardSource interface {
+	Pressed(k Key) bool
+}
+
+// KeyboardState is a KeyboardSource holding the keys currently pressed. It can be updated from a
+// goroutine other than the emulator's.
+type KeyboardState struct {
+	keys [(NumKeys + 63) / 64]atomic.Uint64
+}
+
+// Pressed returns whether a key is pressed
+func (s *KeyboardState) Pressed(k Key) bool {
+	return s.keys[k/64].Load()&(1<<(k%64)) != 0
+}
+
+// Press presses keys
+func (s *KeyboardState) Press(keys ...Key) {
+	for _, k := range keys {
+		s.update(k, true)
+	}
+}
+
+// Release releases keys
+func (s *KeyboardState) Release(keys ...Key) {
+	for _, k := range keys {
+		s.update(k, false)
+	}
+}
+
+// update sets or clears the bit of a key
+func (s *KeyboardState) update(k Key, pressed bool) {
+	word := &s.keys[k/64]
+	bit := uint64(1) << (k % 64)
+	for {
+		old := word.Load()
+		next := old &^ bit
+		if pressed {
+			next = old | bit
+		}
+		if word.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Keyboard is the Family BASIC keyboard, read through 0x4017
+type Keyboard struct {
+	Source KeyboardSource
+
+	enabled bool
+	column  uint8
+	row     uint8
+}
+
+// Write resets the row, selects the column and enables the matrix
+func (k *Keyboard) Write(data uint8) {
+	column := data >> 1 & 0x01
+	if data&0x01 != 0 {
+		k.row = 0
+	} else if k.column == 1 && column == 0 && k.row < keyboardRows {
+		k.row++
+	}
+	k.column = column
+	k.enabled = data&0x04 != 0
+}
+
+// Read returns the keys of the selected row and column on D1-D4
+func (k *Keyboard) Read(readOnly bool) uint8 {
+	if !k.enabled {
+		return 0
+	}
+
+	var pressed uint8
+	if k.Source != nil && k.row < keyboardRows {
+		first := Key(k.row*8 + k.column*4)
+		for i := Key(0); i < 4; i++ {
+			if k.Source.Pressed(first + i) {
+				pressed |= 0x02 << i
+			}
+		}
+	}
+	return ^pressed & 0x1E
+}
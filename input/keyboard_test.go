@@ -0,0 +1,43 @@
+package input
+
+import "testing"
+
+// TestKeyboardScan checks a scan of the matrix as Family BASIC does it
+func TestKeyboardScan(t *testing.T) {
+	keys := &KeyboardState{}
+	keys.Press(KeyReturn, KeyStop, KeyA, KeyInsert)
+	k := &Keyboard{Source: keys}
+
+	if data := k.Read(false); data != 0 {
+		t.Errorf("Expected 0 while disabled, got %#02x", data)
+	}
+
+	var scan []uint8
+	k.Write(0x05) // reset to row 0, column 0
+	for row := 0; row < keyboardRows+1; row++ {
+		k.Write(0x04) // column 0
+		scan = append(scan, k.Read(false))
+		k.Write(0x06) // column 1
+		scan = append(scan, k.Read(false))
+	}
+
+	expected := make([]uint8, len(scan))
+	for i := range expected {
+		expected[i] = 0x1E
+	}
+	expected[0] = 0x1E &^ 0x04  // Return
+	expected[1] = 0x1E &^ 0x10  // Stop
+	expected[12] = 0x1E &^ 0x10 // A, row 6 column 0
+	expected[17] = 0x1E &^ 0x10 // Insert, row 8 column 1
+
+	for i, e := range expected {
+		if scan[i] != e {
+			t.Errorf("Row %d, column %d: expected %#02x, got %#02x", i/2, i%2, e, scan[i])
+		}
+	}
+
+	keys.Release(KeyA)
+	if keys.Pressed(KeyA) || !keys.Pressed(KeyInsert) {
+		t.Errorf("Unexpected keys after release")
+	}
+}
@@ -0,0 +1,37 @@
+package input
+
+// Microphone
+// ----------
+// The second controller of the original Famicom has a microphone in place of Select and Start.
+// Its level goes through a comparator, so games only see whether it picks up sound: D2 of 0x4016
+// is 1 while it does. Games use it for shouts and blows, so a front end can drive it from a
+// real microphone or from a key.
+
+// MicrophoneSource supplies whether a microphone picks up sound
+type MicrophoneSource interface {
+	Loud() bool
+}
+
+// MicrophoneFunc adapts a function to a MicrophoneSource
+type MicrophoneFunc func() bool
+
+// Loud calls f
+func (f MicrophoneFunc) Loud() bool {
+	return f()
+}
+
+// Microphone is the microphone of the Famicom's second controller, read through 0x4016
+type Microphone struct {
+	Source MicrophoneSource
+}
+
+// Write does nothing, the microphone ignores the OUT lines
+func (m *Microphone) Write(data uint8) {}
+
+// Read returns the microphone on D2
+func (m *Microphone) Read(readOnly bool) uint8 {
+	if m.Source != nil && m.Source.Loud() {
+		return 0x04
+	}
+	return 0
+}
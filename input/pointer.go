@@ -0,0 +1,45 @@
+package input
+
+import "sync/atomic"
+
+// Pointer is the state of a device that is aimed or moved rather than pressed: the aim of a
+// Zapper on the 256x240 picture, or the position of a paddle in X from 0 to 255. Button is the
+// trigger or fire button.
+type Pointer struct {
+	X         int
+	Y         int
+	Button    bool
+	Offscreen bool // Aimed away from the screen
+}
+
+// PointerSource supplies the state of a pointing device
+type PointerSource interface {
+	Pointer() Pointer
+}
+
+// PointerFunc adapts a function to a PointerSource
+type PointerFunc func() Pointer
+
+// Pointer calls f
+func (f PointerFunc) Pointer() Pointer {
+	return f()
+}
+
+// PointerState is a PointerSource holding the current state of a pointing device. It can be
+// updated from a goroutine other than the emulator's.
+type PointerState struct {
+	p atomic.Pointer[Pointer]
+}
+
+// Pointer returns the current state
+func (s *PointerState) Pointer() Pointer {
+	if p := s.p.Load(); p != nil {
+		return *p
+	}
+	return Pointer{Offscreen: true}
+}
+
+// Set replaces the current state
+func (s *PointerState) Set(p Pointer) {
+	s.p.Store(&p)
+}
@@ -0,0 +1,130 @@
+package input
+
+import "github.com/cbertinato/go-nes/cpu"
+
+// Controller ports
+// ----------------
+// Input devices are read through two registers:
+//     - 0x4016 write: the low 3 bits are sent to every port (OUT0-OUT2). OUT0 is the strobe that
+//       makes controllers latch their state.
+//     - 0x4016 read: port 1
+//     - 0x4017 read: port 2
+//
+// A read pulses the port's clock line, which is how serial devices shift out their next bit.
+// Only the low 5 bits are driven by the ports; the upper bits hold whatever was last on the
+// data bus, usually the high byte of the address (0x40).
+//
+// Writes to 0x4017 go to the APU frame counter, so Ports should be attached to the CPU bus over
+// 0x4016-0x4017 on top of the APU, with the APU as its Next device.
+
+const (
+	regPort1 uint16 = 0x4016
+	regPort2 uint16 = 0x4017
+)
+
+// CPU is the part of the CPU the ports are connected to
+type CPU interface {
+	DataBus() uint8
+}
+
+// Device is a peripheral plugged into a controller port
+type Device interface {
+	// Write receives the OUT lines, the low 3 bits of a write to 0x4016
+	Write(data uint8)
+	// Read returns the value of the port's data lines D0-D4 and clocks the device. A readOnly
+	// read returns the same value without clocking.
+	Read(readOnly bool) uint8
+}
+
+// Ports maps the controller registers at 0x4016-0x4017
+type Ports struct {
+	CPU   CPU     // CPU whose bus the registers are on
+	Next  cpu.Bus // Receives writes to 0x4017, normally the APU
+	Port1 Device  // Device in port 1, or nil if empty
+	Port2 Device  // Device in port 2, or nil if empty
+}
+
+// Read returns the data lines of the port mapped at a CPU address, with open bus in the bits
+// that are not driven. Empty ports read as 0.
+func (p *Ports) Read(address uint16, readOnly bool) uint8 {
+	var data uint8
+	if p.CPU != nil {
+		data = p.CPU.DataBus() & 0xE0
+	}
+
+	dev := p.Port1
+	if address == regPort2 {
+		dev = p.Port2
+	}
+	if dev != nil {
+		data |= dev.Read(readOnly) & 0x1F
+	}
+	return data
+}
+
+// Write sends the OUT lines to both ports, or passes a write to 0x4017 on to Next
+func (p *Ports) Write(address uint16, data uint8) {
+	if address == regPort2 {
+		if p.Next != nil {
+			p.Next.Write(address, data)
+		}
+		return
+	}
+
+	for _, dev := range []Device{p.Port1, p.Port2} {
+		if dev != nil {
+			dev.Write(data & 0x07)
+		}
+	}
+}
+
+// wired combines devices that share a port, as the Famicom's hardwired controllers share
+// 0x4016 and 0x4017 with the expansion port. Each device drives its own data lines, so the
+// reads are ORed together.
+type wired []Device
+
+func (w wired) Write(data uint8) {
+	for _, d := range w {
+		d.Write(data)
+	}
+}
+
+func (w wired) Read(readOnly bool) uint8 {
+	var data uint8
+	for _, d := range w {
+		data |= d.Read(readOnly)
+	}
+	return data
+}
+
+// shifter is a serial shift register clocked by port reads: it reloads while the strobe is
+// high and shifts out a bit per read, LSB first, filling with 1s. out is the data line the bits
+// are driven on.
+type shifter struct {
+	load   func() uint32
+	out    uint8
+	strobe bool
+	shift  uint32
+}
+
+func (s *shifter) Write(data uint8) {
+	s.strobe = data&0x01 != 0
+	if s.strobe {
+		s.shift = s.load()
+	}
+}
+
+func (s *shifter) Read(readOnly bool) uint8 {
+	if s.strobe && !readOnly {
+		s.shift = s.load()
+	}
+
+	var data uint8
+	if s.shift&0x01 != 0 {
+		data = s.out
+	}
+	if !s.strobe && !readOnly {
+		s.shift = s.shift>>1 | 0x80000000
+	}
+	return data
+}
@@ -0,0 +1,113 @@
+package input
+
+// Power Pad and Family Trainer
+// ----------------------------
+// The Power Pad is a floor mat with 12 buttons in 3 rows of 4, numbered 1-12 on side B. Side A
+// uses 8 of the same buttons with different labels, so a Mat reports buttons by their side B
+// number and games sort out the rest.
+//     - NES Power Pad: two shift registers that latch on the strobe like a controller. D3 reports
+//       buttons 2, 1, 5, 9, 6, 10, 11, 7 and D4 reports 4, 3, 12, 8, then both report 1s.
+//     - Famicom Family Trainer: a matrix on the expansion port. Writing 0 to OUT2, OUT1 or OUT0
+//       selects the row of buttons 1-4, 5-8 or 9-12, and reads of 0x4017 report the selected
+//       buttons on D1-D4, 0 when pressed.
+
+// Mat is a set of mat buttons, bit n set when button n+1 is pressed
+type Mat uint16
+
+// MatSource supplies the buttons pressed on a mat
+type MatSource interface {
+	Mat() Mat
+}
+
+// MatFunc adapts a function to a MatSource
+type MatFunc func() Mat
+
+// Mat calls f
+func (f MatFunc) Mat() Mat {
+	return f()
+}
+
+var (
+	powerPadLow  = []int{2, 1, 5, 9, 6, 10, 11, 7}
+	powerPadHigh = []int{4, 3, 12, 8}
+)
+
+// PowerPad is the NES Power Pad
+type PowerPad struct {
+	Source MatSource
+
+	strobe bool
+	low    uint8 // Shift register reported on D3
+	high   uint8 // Shift register reported on D4
+}
+
+// report returns the state of a list of buttons, a bit each, with 1s after the last
+func (p *PowerPad) report(m Mat, buttons []int) uint8 {
+	report := uint8(0xFF << len(buttons))
+	for i, b := range buttons {
+		if m&(1<<(b-1)) != 0 {
+			report |= 1 << i
+		}
+	}
+	return report
+}
+
+// latch loads the shift registers from the source
+func (p *PowerPad) latch() {
+	var m Mat
+	if p.Source != nil {
+		m = p.Source.Mat()
+	}
+	p.low = p.report(m, powerPadLow)
+	p.high = p.report(m, powerPadHigh)
+}
+
+// Write sets the strobe, latching the buttons while it is high
+func (p *PowerPad) Write(data uint8) {
+	p.strobe = data&0x01 != 0
+	if p.strobe {
+		p.latch()
+	}
+}
+
+// Read returns the next buttons on D3 and D4
+func (p *PowerPad) Read(readOnly bool) uint8 {
+	if p.strobe && !readOnly {
+		p.latch()
+	}
+
+	data := (p.low&0x01)<<3 | (p.high&0x01)<<4
+	if !p.strobe && !readOnly {
+		p.low = p.low>>1 | 0x80
+		p.high = p.high>>1 | 0x80
+	}
+	return data
+}
+
+// FamilyTrainer is the Famicom Family Trainer mat, read through 0x4017
+type FamilyTrainer struct {
+	Source MatSource
+
+	rows uint8 // OUT lines, a row is selected when its line is 0
+}
+
+// Write selects the rows to report
+func (f *FamilyTrainer) Write(data uint8) {
+	f.rows = data & 0x07
+}
+
+// Read returns the buttons of the selected rows on D1-D4
+func (f *FamilyTrainer) Read(readOnly bool) uint8 {
+	var m Mat
+	if f.Source != nil {
+		m = f.Source.Mat()
+	}
+
+	var pressed uint8
+	for row := 0; row < 3; row++ {
+		if f.rows&(0x04>>row) == 0 {
+			pressed |= uint8(m>>(row*4)) & 0x0F
+		}
+	}
+	return ^(pressed << 1) & 0x1E
+}
@@ -0,0 +1,48 @@
+package input
+
+import "testing"
+
+// TestPowerPad checks the order of the buttons on D3 and D4
+func TestPowerPad(t *testing.T) {
+	// Buttons 1, 3 and 7
+	mat := MatFunc(func() Mat { return 1<<0 | 1<<2 | 1<<6 })
+	p := &PowerPad{Source: mat}
+
+	low := readBits(p, 10, 0x08)
+	high := readBits(p, 10, 0x10)
+
+	expectedLow := []uint8{0, 1, 0, 0, 0, 0, 0, 1, 1, 1}
+	expectedHigh := []uint8{0, 1, 0, 0, 1, 1, 1, 1, 1, 1}
+	for i := range expectedLow {
+		if low[i] != expectedLow[i] {
+			t.Errorf("D3 read %d: expected %d, got %d", i, expectedLow[i], low[i])
+		}
+		if high[i] != expectedHigh[i] {
+			t.Errorf("D4 read %d: expected %d, got %d", i, expectedHigh[i], high[i])
+		}
+	}
+}
+
+// TestFamilyTrainer checks row selection and the inverted columns
+func TestFamilyTrainer(t *testing.T) {
+	// Buttons 1, 6 and 12
+	mat := MatFunc(func() Mat { return 1<<0 | 1<<5 | 1<<11 })
+	f := &FamilyTrainer{Source: mat}
+
+	tests := []struct {
+		out      uint8
+		expected uint8
+	}{
+		{0x03, 0x1C}, // buttons 1-4
+		{0x05, 0x1A}, // buttons 5-8
+		{0x06, 0x0E}, // buttons 9-12
+		{0x07, 0x1E}, // none
+		{0x01, 0x18}, // buttons 1-8
+	}
+	for _, tt := range tests {
+		f.Write(tt.out)
+		if data := f.Read(false); data != tt.expected {
+			t.Errorf("OUT %#02x: expected %#02x, got %#02x", tt.out, tt.expected, data)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package input
+
+import (
+	"github.com/cbertinato/go-nes/ppu"
+	"github.com/cbertinato/go-nes/video"
+)
+
+// Zapper
+// ------
+// The Zapper is a light gun with a photodiode that senses the glow of the CRT where it is
+// aimed, and a trigger. Both are reported on every read of its port:
+//     - D3: 0 while light is sensed, 1 otherwise
+//     - D4: 1 while the trigger is pulled
+//
+// The sensor responds to the beam passing the aim point and stays lit for a little over a dozen
+// scanlines as the phosphor fades. Games flash bright targets on a dark screen for a frame and
+// poll the sensor while the beam draws them, so light is sensed while the beam is within
+// zapperLines scanlines below the aim point and a pixel near it was drawn bright.
+
+const (
+	zapperLines     = 20  // Scanlines the sensor stays lit after the beam passes
+	zapperRadius    = 2   // Pixels around the aim point the sensor sees
+	zapperThreshold = 153 // Luma of a pixel bright enough to be sensed
+)
+
+// Screen is the picture a light gun is aimed at, as drawn by the PPU
+type Screen interface {
+	Frame() []uint16
+	Scanline() int
+	Dot() int
+}
+
+// Zapper is the NES Zapper light gun. The Famicom version reports the same bits through the
+// expansion port.
+type Zapper struct {
+	Source  PointerSource  // Aim and trigger
+	Screen  Screen         // Picture the gun is aimed at
+	Palette *video.Palette // Colors of the picture, the default palette if nil
+
+	defaultPalette *video.Palette
+}
+
+// Write does nothing, the Zapper ignores the OUT lines
+func (z *Zapper) Write(data uint8) {}
+
+// Read returns the light sensor on D3 and the trigger on D4
+func (z *Zapper) Read(readOnly bool) uint8 {
+	if z.Source == nil {
+		return 0x08
+	}
+
+	p := z.Source.Pointer()
+	data := uint8(0x08)
+	if !p.Offscreen && z.light(p.X, p.Y) {
+		data = 0
+	}
+	if p.Button {
+		data |= 0x10
+	}
+	return data
+}
+
+// light returns whether the sensor aimed at x, y sees light
+func (z *Zapper) light(x int, y int) bool {
+	if z.Screen == nil || x < 0 || x >= ppu.Width || y < 0 || y >= ppu.Height {
+		return false
+	}
+
+	// Pixel x is drawn at dot x+1
+	scanline, dot := z.Screen.Scanline(), z.Screen.Dot()
+	if scanline < y || scanline >= y+zapperLines || (scanline == y && dot <= x+1) {
+		return false
+	}
+
+	palette := z.Palette
+	if palette == nil {
+		if z.defaultPalette == nil {
+			z.defaultPalette = video.DefaultPalette()
+		}
+		palette = z.defaultPalette
+	}
+
+	frame := z.Screen.Frame()
+	for py := max(y-zapperRadius, 0); py <= min(y+zapperRadius, ppu.Height-1); py++ {
+		for px := max(x-zapperRadius, 0); px <= min(x+zapperRadius, ppu.Width-1); px++ {
+			c := palette[frame[py*ppu.Width+px]&0x1FF]
+			luma := (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+			if luma >= zapperThreshold {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// testScreen is a picture with the beam at a fixed position
+type testScreen struct {
+	frame    [ppu.Width * ppu.Height]uint16
+	scanline int
+	dot      int
+}
+
+func (s *testScreen) Frame() []uint16 { return s.frame[:] }
+func (s *testScreen) Scanline() int   { return s.scanline }
+func (s *testScreen) Dot() int        { return s.dot }
+
+// TestZapperLight checks that light is sensed only when the beam has recently passed a bright
+// pixel at the aim point
+func TestZapperLight(t *testing.T) {
+	screen := &testScreen{}
+	for i := range screen.frame {
+		screen.frame[i] = 0x0F // black
+	}
+	screen.frame[100*ppu.Width+100] = 0x30 // white
+
+	aim := &PointerState{}
+	z := &Zapper{Source: aim, Screen: screen}
+
+	tests := []struct {
+		name     string
+		pointer  Pointer
+		scanline int
+		dot      int
+		expected uint8
+	}{
+		{"bright", Pointer{X: 101, Y: 99}, 105, 0, 0x00},
+		{"trigger", Pointer{X: 101, Y: 99, Button: true}, 105, 0, 0x10},
+		{"dark", Pointer{X: 50, Y: 50}, 55, 0, 0x08},
+		{"before beam", Pointer{X: 100, Y: 100}, 99, 0, 0x08},
+		{"same line", Pointer{X: 100, Y: 100}, 100, 50, 0x08},
+		{"faded", Pointer{X: 100, Y: 100}, 100 + zapperLines, 0, 0x08},
+		{"offscreen", Pointer{X: 100, Y: 100, Offscreen: true, Button: true}, 105, 0, 0x18},
+	}
+	for _, tt := range tests {
+		aim.Set(tt.pointer)
+		screen.scanline, screen.dot = tt.scanline, tt.dot
+		if data := z.Read(false); data != tt.expected {
+			t.Errorf("%s: expected %#02x, got %#02x", tt.name, tt.expected, data)
+		}
+	}
+}
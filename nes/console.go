@@ -0,0 +1,263 @@
+package nes
+
+import (
+	"fmt"
+
+	"github.com/cbertinato/go-nes/apu"
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/input"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// The console
+// -----------
+// A Console wires the chips of the NES together the way the motherboard does:
+//     - CPU bus: 2k of RAM, the PPU registers at 0x2000-0x3FFF, the APU at 0x4000-0x4017 with
+//       OAM DMA at 0x4014 and the controller ports at 0x4016-0x4017 on top, and the cartridge
+//       at 0x4020-0xFFFF
+//     - PPU bus: the cartridge's pattern tables at 0x0000-0x1FFF, nametables in CIRAM mirrored
+//       as the cartridge says, and palette RAM
+//     - the PPU drives the CPU's NMI input, and the APU its IRQ input and DMC DMA
+//     - a cartridge with a sound chip, one that implements apu.Expansion, is mixed with the
+//       APU
+//
+// Every chip is clocked from the same master clock by its own divider: 12 master cycles per CPU
+// cycle and 4 per PPU dot on NTSC, for 3 dots per CPU cycle, and 16 and 5 on PAL, for 3.2. The
+// console runs a CPU cycle at a time, with the APU, followed by as many dots as the master
+// clock has advanced.
+//
+// A frame ends when the PPU has drawn the last visible scanline. OnFrame then receives the
+// picture and OnAudio the samples the APU produced since the previous frame.
+//
+// When the CPU jams on an opcode it cannot execute, the PPU and APU keep running as they do on
+// the console, but StepInstruction and StepFrame return an error until the console is reset.
+
+// Cartridge is a game cartridge
+type Cartridge interface {
+	PRG() cpu.Bus             // Mapped to 0x4020-0xFFFF of the CPU bus
+	CHR() ppu.Bus             // Mapped to 0x0000-0x1FFF of the PPU bus
+	Mirroring() ppu.Mirroring // Nametable mirroring at power on
+}
+
+// Slot is the cartridge connector: the parts of the console a cartridge can drive besides its
+// PRG and CHR
+type Slot struct {
+	CPU    *cpu.MOS6502   // IRQ input, driven through SetIRQ as cpu.IRQMapper
+	Bus    *cpu.MappedBus // CPU bus, for devices outside 0x4020-0xFFFF
+	PPUBus *ppu.MappedBus // Nametable mirroring, through SetMirroring, and nametable RAM
+}
+
+// Mapper is implemented by cartridges with hardware beyond ROM and RAM, such as IRQ counters
+// and switchable mirroring:
+//   - Connect is called at power on, after PRG and CHR have been attached
+//   - Reset is called when the reset button is pressed, for the few mappers that see the reset
+//     line or detect it from M2 stopping. Most keep their registers.
+//   - Clock is called after every CPU cycle, for mappers clocked by M2
+//   - PPUAddress is called with every address the PPU puts on its bus, for mappers that
+//     watch it, such as the MMC3 counting the rises of A12. Palette RAM is inside the PPU,
+//     so its addresses are not seen, and neither are reads made with readOnly.
+type Mapper interface {
+	Connect(slot Slot)
+	Reset()
+	Clock()
+	PPUAddress(address uint16)
+}
+
+// mapperBus is the PPU bus as seen from the PPU, passing the addresses on it to a mapper
+type mapperBus struct {
+	ppu.Bus
+	mapper Mapper
+}
+
+func (b mapperBus) Read(address uint16, readOnly bool) uint8 {
+	if !readOnly && address&0x3FFF < 0x3F00 {
+		b.mapper.PPUAddress(address & 0x3FFF)
+	}
+	return b.Bus.Read(address, readOnly)
+}
+
+func (b mapperBus) Write(address uint16, data uint8) {
+	if address&0x3FFF < 0x3F00 {
+		b.mapper.PPUAddress(address & 0x3FFF)
+	}
+	b.Bus.Write(address, data)
+}
+
+// Console is an NES with a cartridge inserted
+type Console struct {
+	CPU    cpu.MOS6502
+	Bus    cpu.MappedBus // CPU bus
+	PPU    ppu.RP2C02
+	PPUBus ppu.MappedBus
+	APU    apu.RP2A03
+	OAMDMA cpu.OAMDMA
+	Ports  input.Ports // Controller ports, into which devices are plugged
+
+	Cartridge Cartridge
+	Model     ppu.Model
+	mapper    Mapper // The cartridge, if it is a Mapper
+
+	OnFrame func(frame []uint16)    // Receives the picture, see ppu.RP2C02.Frame
+	OnAudio func(samples []float32) // Receives the samples of each frame, if there is a mixer
+
+	savePath         string  // Save file, see LoadSave
+	saved            []uint8 // Save memory as last read or written
+	saveErr          error   // Error of the last periodic flush or of PowerCycle's read
+	framesSinceFlush int
+
+	sampleRate int
+	cpuDivider int
+	ppuDivider int
+	dotClock   int  // Master cycles not yet used by the PPU
+	frameDone  bool // A frame ended on the last cycle
+}
+
+// NewConsole returns a console with a cartridge inserted and powered on. Audio is resampled to
+// sampleRate, or not produced if it is 0.
+func NewConsole(cart Cartridge, model ppu.Model, sampleRate int) *Console {
+	c := &Console{Cartridge: cart, Model: model, sampleRate: sampleRate}
+	c.PowerCycle()
+	return c
+}
+
+// connect wires the chips together
+func (c *Console) connect() {
+	region := c.Model.Region()
+	c.cpuDivider, c.ppuDivider = region.ClockDividers()
+
+	c.CPU.Bus = &c.Bus
+	c.Bus.CPU = &c.CPU
+	c.PPU.Bus = &c.PPUBus
+	c.PPU.CPU = &c.CPU
+	c.PPU.Model = c.Model
+	c.APU.CPU = &c.CPU
+	c.APU.Region = region
+	if c.sampleRate > 0 {
+		c.APU.Mixer = apu.NewMixer(region, c.sampleRate)
+	}
+	c.OAMDMA.CPU = &c.CPU
+	c.Ports.CPU = &c.CPU
+	c.Ports.Next = &c.APU
+
+	c.Bus.Attach(0x2000, 0x3FFF, &c.PPU)
+	c.Bus.Attach(0x4000, 0x4017, &c.APU)
+	c.Bus.Attach(0x4014, 0x4014, &c.OAMDMA)
+	c.Bus.Attach(0x4016, 0x4017, &c.Ports)
+	if c.Cartridge != nil {
+		c.Bus.Attach(0x4020, 0xFFFF, c.Cartridge.PRG())
+		c.PPUBus.Attach(0x0000, 0x1FFF, c.Cartridge.CHR())
+		c.PPUBus.SetMirroring(c.Cartridge.Mirroring())
+	}
+
+	c.mapper, _ = c.Cartridge.(Mapper)
+	if c.mapper != nil {
+		c.PPU.Bus = mapperBus{&c.PPUBus, c.mapper}
+		c.mapper.Connect(Slot{CPU: &c.CPU, Bus: &c.Bus, PPUBus: &c.PPUBus})
+	}
+	if e, ok := c.Cartridge.(apu.Expansion); ok && c.APU.Mixer != nil {
+		c.APU.Mixer.Expansion = e
+	}
+}
+
+// PowerCycle turns the console off and on again. Every chip starts from its power on state
+// and RAM is cleared, while the devices in the controller ports stay plugged in. The save file,
+// if any, is written and read back into the cartridge.
+func (c *Console) PowerCycle() {
+	port1, port2 := c.Ports.Port1, c.Ports.Port2
+	c.Flush()
+	if err := c.readSave(); err != nil {
+		c.saveErr = err
+	}
+
+	c.CPU = cpu.Create6502()
+	c.Bus = cpu.MappedBus{}
+	c.PPU = ppu.Create2C02()
+	c.PPUBus = ppu.MappedBus{}
+	c.APU = apu.Create2A03()
+	c.Ports = input.Ports{Port1: port1, Port2: port2}
+	c.dotClock = 0
+	c.connect()
+
+	c.CPU.Reset()
+}
+
+// Reset presses the reset button, which resets the CPU, PPU and APU, and the mapper if it
+// cares, but leaves RAM alone
+func (c *Console) Reset() {
+	c.CPU.Reset()
+	c.PPU.Reset()
+	c.APU.Reset()
+	if c.mapper != nil {
+		c.mapper.Reset()
+	}
+}
+
+// clock runs one CPU cycle and the PPU dots that fit in it
+func (c *Console) clock() {
+	c.CPU.Clock()
+	c.APU.Clock()
+	if c.mapper != nil {
+		c.mapper.Clock()
+	}
+
+	c.dotClock += c.cpuDivider
+	for c.dotClock >= c.ppuDivider {
+		c.dotClock -= c.ppuDivider
+
+		scanline := c.PPU.Scanline()
+		c.PPU.Clock()
+		if scanline == ppu.Height-1 && c.PPU.Scanline() == ppu.Height {
+			c.endFrame()
+		}
+	}
+}
+
+// endFrame hands the picture and the audio of the frame to the callbacks, and writes the save
+// file when it is due
+func (c *Console) endFrame() {
+	c.frameDone = true
+	c.flushPeriodically()
+
+	if c.OnFrame != nil {
+		c.OnFrame(c.PPU.Frame())
+	}
+	if m := c.APU.Mixer; m != nil {
+		m.EndFrame()
+		if c.OnAudio != nil {
+			c.OnAudio(m.Float32())
+		}
+	}
+}
+
+// StepInstruction runs the CPU until the current instruction, interrupt or DMA has completed and
+// returns the number of CPU cycles it took, or an error if the CPU has jammed
+func (c *Console) StepInstruction() (int, error) {
+	cycles := 0
+	for {
+		c.clock()
+		cycles++
+		if c.CPU.Complete() {
+			return cycles, c.jamError()
+		}
+	}
+}
+
+// StepFrame runs the console until the end of the next frame and returns the number of CPU
+// cycles it took, or an error if the CPU has jammed
+func (c *Console) StepFrame() (int, error) {
+	cycles := 0
+	c.frameDone = false
+	for !c.frameDone {
+		c.clock()
+		cycles++
+	}
+	return cycles, c.jamError()
+}
+
+// jamError returns an error naming the opcode the CPU has jammed on, or nil if it is running
+func (c *Console) jamError() error {
+	if !c.CPU.Jammed() {
+		return nil
+	}
+	return fmt.Errorf("nes: CPU jammed on opcode %#02x at %#04x", c.Bus.Read(c.CPU.PC, true), c.CPU.PC)
+}
@@ -0,0 +1,207 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/input"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// testCartridge has 32k of PRG ROM at 0x8000 and 8k of CHR RAM
+type testCartridge struct {
+	prg testPRG
+	chr testCHR
+}
+
+type testPRG struct {
+	rom [0x8000]uint8
+}
+
+func (p *testPRG) Read(address uint16, readOnly bool) uint8 {
+	if address < 0x8000 {
+		return 0
+	}
+	return p.rom[address-0x8000]
+}
+
+func (p *testPRG) Write(address uint16, data uint8) {}
+
+type testCHR struct {
+	ram [0x2000]uint8
+}
+
+func (c *testCHR) Read(address uint16, readOnly bool) uint8 { return c.ram[address] }
+func (c *testCHR) Write(address uint16, data uint8)         { c.ram[address] = data }
+
+func (t *testCartridge) PRG() cpu.Bus             { return &t.prg }
+func (t *testCartridge) CHR() ppu.Bus             { return &t.chr }
+func (t *testCartridge) Mirroring() ppu.Mirroring { return ppu.Vertical }
+
+// newTestCartridge returns a cartridge whose reset vector points to 0x8000, filled with the KIL
+// opcode 0x02, which jams the CPU and leaves the rest of the console running
+func newTestCartridge() *testCartridge {
+	cart := &testCartridge{}
+	for i := range cart.prg.rom {
+		cart.prg.rom[i] = 0x02
+	}
+	cart.prg.rom[0x7FFC] = 0x00
+	cart.prg.rom[0x7FFD] = 0x80
+	return cart
+}
+
+// testMapper is a cartridge that switches to horizontal mirroring when connected and records
+// the resets, CPU cycles and PPU addresses it sees
+type testMapper struct {
+	*testCartridge
+	slot      Slot
+	resets    int
+	cycles    int
+	addresses []uint16
+}
+
+func (m *testMapper) Connect(slot Slot) {
+	m.slot = slot
+	slot.PPUBus.SetMirroring(ppu.Horizontal)
+}
+
+func (m *testMapper) Reset() {
+	m.resets++
+}
+
+func (m *testMapper) Clock() {
+	m.cycles++
+}
+
+func (m *testMapper) PPUAddress(address uint16) {
+	m.addresses = append(m.addresses, address)
+}
+
+// TestMapper checks that a mapper is connected to the console and sees its clocks and resets
+func TestMapper(t *testing.T) {
+	m := &testMapper{testCartridge: newTestCartridge()}
+	c := NewConsole(m, ppu.Model2C02, 0)
+
+	if m.slot.CPU != &c.CPU || c.PPUBus.Mirroring() != ppu.Horizontal {
+		t.Fatalf("Expected the mapper to be connected")
+	}
+
+	cycles, _ := c.StepFrame()
+	if m.cycles != cycles {
+		t.Errorf("Expected %d CPU cycles, got %d", cycles, m.cycles)
+	}
+
+	// read 0x1234 through PPUDATA, then write to the palette
+	m.addresses = nil
+	c.Bus.Write(0x2006, 0x12)
+	c.Bus.Write(0x2006, 0x34)
+	c.Bus.Read(0x2007, false)
+	c.Bus.Write(0x2006, 0x3F)
+	c.Bus.Write(0x2006, 0x00)
+	c.Bus.Write(0x2007, 0x0F)
+	if len(m.addresses) != 1 || m.addresses[0] != 0x1234 {
+		t.Errorf("Expected the PPU to read 0x1234 only, got %x", m.addresses)
+	}
+
+	c.Reset()
+	if m.resets != 1 {
+		t.Errorf("Expected the mapper to see the reset")
+	}
+}
+
+// TestPowerOn checks that the console starts from the reset vector, and that a jam there is
+// reported
+func TestPowerOn(t *testing.T) {
+	c := NewConsole(newTestCartridge(), ppu.Model2C02, 0)
+	if c.CPU.PC != 0x8000 {
+		t.Errorf("Expected PC = %#04x, got %#04x", 0x8000, c.CPU.PC)
+	}
+
+	if cycles, err := c.StepInstruction(); cycles != 7 || err != nil {
+		t.Errorf("Expected the reset sequence to take 7 cycles, got %d (%v)", cycles, err)
+	}
+	if _, err := c.StepInstruction(); err == nil || c.CPU.PC != 0x8000 {
+		t.Errorf("Expected a jam at %#04x, got PC = %#04x (%v)", 0x8000, c.CPU.PC, err)
+	}
+}
+
+// TestClockRatio checks the number of PPU dots per CPU cycle in each region
+func TestClockRatio(t *testing.T) {
+	for _, tt := range []struct {
+		model ppu.Model
+		dots  int
+	}{{ppu.Model2C02, 3000}, {ppu.Model2C07, 3200}} {
+		c := NewConsole(newTestCartridge(), tt.model, 0)
+		for i := 0; i < 1000; i++ {
+			c.clock()
+		}
+		if dots := c.PPU.Scanline()*341 + c.PPU.Dot(); dots != tt.dots {
+			t.Errorf("Model %d: expected %d dots, got %d", tt.model, tt.dots, dots)
+		}
+	}
+}
+
+// TestStepFrame checks the length of frames and the callbacks
+func TestStepFrame(t *testing.T) {
+	c := NewConsole(newTestCartridge(), ppu.Model2C02, 48000)
+
+	frames, samples := 0, 0
+	c.OnFrame = func(frame []uint16) {
+		frames++
+		if len(frame) != ppu.Width*ppu.Height {
+			t.Errorf("Expected a %dx%d frame, got %d pixels", ppu.Width, ppu.Height, len(frame))
+		}
+	}
+	c.OnAudio = func(s []float32) {
+		samples += len(s)
+	}
+
+	// the CPU jams, but the frames go on
+	c.StepFrame() // the first frame starts at power on
+	for i := 0; i < 10; i++ {
+		// 262 scanlines of 341 dots, 3 per CPU cycle, with rendering disabled
+		cycles, err := c.StepFrame()
+		if cycles < 29780 || cycles > 29781 {
+			t.Errorf("Frame %d: expected 29780 or 29781 cycles, got %d", i, cycles)
+		}
+		if err == nil {
+			t.Errorf("Frame %d: expected the jam to be reported", i)
+		}
+	}
+
+	if frames != 11 {
+		t.Errorf("Expected 11 frames, got %d", frames)
+	}
+	// 48000 Hz at about 60.1 frames per second
+	if perFrame := samples / frames; perFrame < 790 || perFrame > 810 {
+		t.Errorf("Expected about 800 samples per frame, got %d", perFrame)
+	}
+}
+
+// TestResetAndPowerCycle checks what survives each
+func TestResetAndPowerCycle(t *testing.T) {
+	c := NewConsole(newTestCartridge(), ppu.Model2C02, 0)
+	controller := &input.Controller{}
+	c.Ports.Port1 = controller
+
+	c.Bus.Write(0x0010, 0x42)
+	c.StepFrame()
+	c.Reset()
+	if c.CPU.PC != 0x8000 || c.CPU.Jammed() {
+		t.Errorf("Expected the CPU to restart at %#04x after reset, got %#04x", 0x8000, c.CPU.PC)
+	}
+	if data := c.Bus.Read(0x0010, true); data != 0x42 {
+		t.Errorf("Expected RAM to survive a reset, got %#02x", data)
+	}
+
+	c.PowerCycle()
+	if data := c.Bus.Read(0x0010, true); data != 0 {
+		t.Errorf("Expected RAM to be cleared by a power cycle, got %#02x", data)
+	}
+	if c.Ports.Port1 != controller {
+		t.Errorf("Expected the controller to stay plugged in")
+	}
+	if c.PPU.FrameCount() != 0 {
+		t.Errorf("Expected the PPU to restart")
+	}
+}
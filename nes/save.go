@@ -0,0 +1,134 @@
+package nes
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Battery saves
+// -------------
+// Cartridges with a battery keep their PRG RAM while the console is off, and some save to an
+// EEPROM instead. Such cartridges implement SaveRAM, and the console keeps that memory in a
+// .sav file:
+//     - LoadSave names the file and reads it into the cartridge, and PowerCycle reads it again
+//     - the memory is written back every FlushFrames frames if it has changed, and by Flush and
+//       Close, which should be called when the emulator exits
+//
+// The file is written to a temporary file next to it and renamed over it, so that a crash while
+// writing leaves the previous save intact. The file holds the memory as it is, which is the
+// format other emulators use.
+
+// FlushFrames is the number of frames between writes of a changed save file, about 5 seconds
+const FlushFrames = 300
+
+// SaveRAM is implemented by cartridges with memory that is kept while the console is off
+type SaveRAM interface {
+	// SaveRAM returns the memory, which the console reads and writes in place, or nil if the
+	// cartridge has none
+	SaveRAM() []uint8
+}
+
+// SavePath returns the path of the save file of a ROM: the ROM's name with a .sav extension, in
+// dir, or next to the ROM if dir is empty. Compression extensions are removed first, so that
+// game.nes.gz saves to game.sav.
+func SavePath(romPath string, dir string) string {
+	name := filepath.Base(romPath)
+	if ext := strings.ToLower(filepath.Ext(name)); ext == ".gz" || ext == ".zip" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name)) + ".sav"
+	if dir == "" {
+		dir = filepath.Dir(romPath)
+	}
+	return filepath.Join(dir, name)
+}
+
+// saveRAM returns the cartridge's save memory, or nil if it has none
+func (c *Console) saveRAM() []uint8 {
+	if s, ok := c.Cartridge.(SaveRAM); ok {
+		return s.SaveRAM()
+	}
+	return nil
+}
+
+// LoadSave sets the save file of the cartridge and reads it, if it exists. It does nothing for
+// cartridges without save memory.
+func (c *Console) LoadSave(path string) error {
+	c.savePath = path
+	return c.readSave()
+}
+
+// readSave reads the save file into the cartridge's save memory
+func (c *Console) readSave() error {
+	ram := c.saveRAM()
+	if c.savePath == "" || ram == nil {
+		return nil
+	}
+	data, err := os.ReadFile(c.savePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// files of another size, from other emulators, are loaded as far as they fit
+	copy(ram, data)
+	c.saved = append(c.saved[:0], ram...)
+	return nil
+}
+
+// Flush writes the save file if the save memory has changed since it was last read or written.
+// It also returns the error of a failed periodic write or PowerCycle read, if nothing has been
+// written since.
+func (c *Console) Flush() error {
+	ram := c.saveRAM()
+	if c.savePath == "" || ram == nil || c.saved == nil || bytes.Equal(ram, c.saved) {
+		return c.saveErr
+	}
+	if err := writeAtomic(c.savePath, ram); err != nil {
+		c.saveErr = err
+		return err
+	}
+	c.saved = append(c.saved[:0], ram...)
+	c.saveErr = nil
+	return nil
+}
+
+// Close writes the save file, see Flush. The console can still be used afterwards.
+func (c *Console) Close() error {
+	return c.Flush()
+}
+
+// flushPeriodically is called at the end of every frame
+func (c *Console) flushPeriodically() {
+	c.framesSinceFlush++
+	if c.framesSinceFlush >= FlushFrames {
+		c.framesSinceFlush = 0
+		c.Flush()
+	}
+}
+
+// writeAtomic writes a file through a temporary file renamed over it, creating its directory
+func writeAtomic(path string, data []uint8) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
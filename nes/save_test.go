@@ -0,0 +1,93 @@
+package nes
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// saveCartridge is a test cartridge with 8k of save RAM
+type saveCartridge struct {
+	*testCartridge
+	ram []uint8
+}
+
+func (s *saveCartridge) SaveRAM() []uint8 { return s.ram }
+
+// TestSavePath checks the name and directory of save files
+func TestSavePath(t *testing.T) {
+	for _, tt := range []struct {
+		rom, dir, expected string
+	}{
+		{"roms/game.nes", "", "roms/game.sav"},
+		{"roms/game.nes.gz", "", "roms/game.sav"},
+		{"roms/game.zip", "saves", "saves/game.sav"},
+	} {
+		if p := SavePath(tt.rom, tt.dir); p != filepath.FromSlash(tt.expected) {
+			t.Errorf("%s in %q: expected %q, got %q", tt.rom, tt.dir, tt.expected, p)
+		}
+	}
+}
+
+// TestSave checks that the save file is read, written when the RAM changes, and read again on
+// a power cycle
+func TestSave(t *testing.T) {
+	cart := &saveCartridge{testCartridge: newTestCartridge(), ram: make([]uint8, 0x2000)}
+	c := NewConsole(cart, ppu.Model2C02, 0)
+	path := filepath.Join(t.TempDir(), "saves", "game.sav")
+
+	if err := c.LoadSave(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be written for unchanged RAM")
+	}
+
+	// written at the end of a period of frames
+	cart.ram[0x10] = 0x42
+	for i := 0; i < FlushFrames-1; i++ {
+		c.StepFrame()
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be written before %d frames", FlushFrames)
+	}
+	c.StepFrame()
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, cart.ram) {
+		t.Fatalf("Expected the RAM to be written after %d frames (%v)", FlushFrames, err)
+	}
+
+	// written by Close, and read back by a new console
+	cart.ram[0x11] = 0x43
+	if err := c.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cart.ram = make([]uint8, 0x2000)
+	c = NewConsole(cart, ppu.Model2C02, 0)
+	if err := c.LoadSave(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cart.ram[0x10] != 0x42 || cart.ram[0x11] != 0x43 {
+		t.Errorf("Expected the save file to be read, got % x", cart.ram[0x10:0x12])
+	}
+
+	// changes are kept through a power cycle
+	cart.ram[0x12] = 0x44
+	c.PowerCycle()
+	if data, err := os.ReadFile(path); err != nil || data[0x12] != 0x44 {
+		t.Errorf("Expected the RAM to be written by a power cycle (%v)", err)
+	}
+	if cart.ram[0x12] != 0x44 {
+		t.Errorf("Expected the RAM to survive a power cycle")
+	}
+
+	files, _ := os.ReadDir(filepath.Dir(path))
+	if len(files) != 1 {
+		t.Errorf("Expected only the save file in its directory, got %d files", len(files))
+	}
+}
@@ -0,0 +1,271 @@
+package nsf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// NSF files
+// ---------
+// An NSF file holds the music code and data of a game, ripped so that it can be played without
+// the rest of the game. The code is loaded into CPU memory and called through two routines:
+// init, with the song number in A and the region in X, and play, called at a fixed rate
+// (usually 60 Hz). There are two formats:
+//     - NSF: a 128 byte header followed by the data. NSF2 adds a data length so that metadata
+//       can follow the data.
+//     - NSFe: a series of chunks, each a 32-bit length, a 4 character ID and the data. Chunks
+//       whose ID starts with an upper case letter are required to play the file correctly, so
+//       an unknown one is an error, while unknown lower case chunks are skipped. NSFe adds
+//       track titles, lengths, fades and a playlist.
+//
+// Expansion audio chips are declared in the header but not emulated.
+
+// Expansion audio chips, as flagged in the header
+const (
+	ChipVRC6 uint8 = 1 << iota
+	ChipVRC7
+	ChipFDS
+	ChipMMC5
+	ChipN163
+	ChipS5B
+	ChipVT02
+)
+
+// Region flags
+const (
+	RegionPAL  uint8 = 1 << iota // Plays on PAL, or PAL only without RegionDual
+	RegionDual                   // Plays on both NTSC and PAL
+)
+
+// Track holds the metadata of a song
+type Track struct {
+	Title  string
+	Length time.Duration // 0 if unknown
+	Fade   time.Duration // -1 if unknown
+}
+
+// File is a parsed NSF or NSFe file
+type File struct {
+	Title     string
+	Artist    string
+	Copyright string
+	Ripper    string
+
+	Songs     int // Number of songs
+	StartSong int // Song to play first, from 0
+	Tracks    []Track
+	Playlist  []int // Order in which to play the songs, empty for all in order
+
+	LoadAddr uint16
+	InitAddr uint16
+	PlayAddr uint16
+	Banks    [8]uint8 // Initial banks of 0x8000-0xFFFF, if Banked
+	Banked   bool
+	Data     []uint8
+
+	NTSCRate uint16 // Period of the play routine in microseconds
+	PALRate  uint16
+	Region   uint8
+	Chips    uint8
+}
+
+const headerSize = 0x80
+
+var (
+	nsfMagic  = []byte("NESM\x1A")
+	nsfeMagic = []byte("NSFE")
+)
+
+// Load reads an NSF or NSFe file
+func Load(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, nsfMagic):
+		return parseNSF(data)
+	case bytes.HasPrefix(data, nsfeMagic):
+		return parseNSFe(data[len(nsfeMagic):])
+	}
+	return nil, errors.New("nsf: not an NSF or NSFe file")
+}
+
+// LoadFile reads an NSF or NSFe file from disk, see Load
+func LoadFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// cString returns the text of a fixed size, zero terminated field
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func parseNSF(data []byte) (*File, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("nsf: header is %d bytes, expected %d", len(data), headerSize)
+	}
+
+	le := binary.LittleEndian
+	f := &File{
+		Songs:     int(data[0x06]),
+		StartSong: int(data[0x07]) - 1,
+		LoadAddr:  le.Uint16(data[0x08:]),
+		InitAddr:  le.Uint16(data[0x0A:]),
+		PlayAddr:  le.Uint16(data[0x0C:]),
+		Title:     cString(data[0x0E:0x2E]),
+		Artist:    cString(data[0x2E:0x4E]),
+		Copyright: cString(data[0x4E:0x6E]),
+		NTSCRate:  le.Uint16(data[0x6E:]),
+		PALRate:   le.Uint16(data[0x78:]),
+		Region:    data[0x7A] & 0x03,
+		Chips:     data[0x7B],
+	}
+	copy(f.Banks[:], data[0x70:0x78])
+	f.Banked = f.Banks != [8]uint8{}
+
+	// NSF2 gives the length of the data, which may be followed by metadata. 0 means the data
+	// runs to the end of the file.
+	f.Data = data[headerSize:]
+	if data[0x05] >= 2 {
+		length := int(data[0x7D]) | int(data[0x7E])<<8 | int(data[0x7F])<<16
+		if length != 0 && length < len(f.Data) {
+			f.Data = f.Data[:length]
+		}
+	}
+
+	f.Tracks = make([]Track, f.Songs)
+	for i := range f.Tracks {
+		f.Tracks[i].Fade = -1
+	}
+	return f, f.validate()
+}
+
+func parseNSFe(data []byte) (*File, error) {
+	f := &File{NTSCRate: 16639, PALRate: 19997}
+	var info, end bool
+	var titles []string
+	var lengths, fades []int32
+
+	le := binary.LittleEndian
+	for len(data) > 0 && !end {
+		if len(data) < 8 {
+			return nil, errors.New("nsf: truncated NSFe chunk header")
+		}
+		length := le.Uint32(data)
+		id := string(data[4:8])
+		data = data[8:]
+		if uint32(len(data)) < length {
+			return nil, fmt.Errorf("nsf: truncated NSFe chunk %q", id)
+		}
+		chunk := data[:length]
+		data = data[length:]
+
+		switch id {
+		case "INFO":
+			if len(chunk) < 9 {
+				return nil, errors.New("nsf: INFO chunk too short")
+			}
+			info = true
+			f.LoadAddr = le.Uint16(chunk[0:])
+			f.InitAddr = le.Uint16(chunk[2:])
+			f.PlayAddr = le.Uint16(chunk[4:])
+			f.Region = chunk[6] & 0x03
+			f.Chips = chunk[7]
+			f.Songs = int(chunk[8])
+			if len(chunk) > 9 {
+				f.StartSong = int(chunk[9])
+			}
+		case "DATA":
+			f.Data = chunk
+		case "BANK":
+			copy(f.Banks[:], chunk)
+			f.Banked = true
+		case "RATE":
+			if len(chunk) >= 2 {
+				f.NTSCRate = le.Uint16(chunk)
+			}
+			if len(chunk) >= 4 {
+				f.PALRate = le.Uint16(chunk[2:])
+			}
+		case "NEND":
+			end = true
+		case "auth":
+			fields := bytes.SplitN(chunk, []byte{0}, 5)
+			for i, field := range fields[:min(len(fields), 4)] {
+				*[]*string{&f.Title, &f.Artist, &f.Copyright, &f.Ripper}[i] = string(field)
+			}
+		case "tlbl":
+			for _, t := range bytes.Split(bytes.TrimSuffix(chunk, []byte{0}), []byte{0}) {
+				titles = append(titles, string(t))
+			}
+		case "time", "fade":
+			var values []int32
+			for i := 0; i+4 <= len(chunk); i += 4 {
+				values = append(values, int32(le.Uint32(chunk[i:])))
+			}
+			if id == "time" {
+				lengths = values
+			} else {
+				fades = values
+			}
+		case "plst":
+			for _, song := range chunk {
+				f.Playlist = append(f.Playlist, int(song))
+			}
+		default:
+			if id[0] >= 'A' && id[0] <= 'Z' {
+				return nil, fmt.Errorf("nsf: unsupported required NSFe chunk %q", id)
+			}
+		}
+	}
+
+	if !info || f.Data == nil {
+		return nil, errors.New("nsf: NSFe file without INFO or DATA chunk")
+	}
+
+	f.Tracks = make([]Track, f.Songs)
+	for i := range f.Tracks {
+		t := &f.Tracks[i]
+		t.Fade = -1
+		if i < len(titles) {
+			t.Title = titles[i]
+		}
+		if i < len(lengths) && lengths[i] >= 0 {
+			t.Length = time.Duration(lengths[i]) * time.Millisecond
+		}
+		if i < len(fades) && fades[i] >= 0 {
+			t.Fade = time.Duration(fades[i]) * time.Millisecond
+		}
+	}
+	return f, f.validate()
+}
+
+// validate checks that the file can be played
+func (f *File) validate() error {
+	if f.Songs == 0 {
+		return errors.New("nsf: file has no songs")
+	}
+	if f.StartSong < 0 || f.StartSong >= f.Songs {
+		f.StartSong = 0
+	}
+	if f.LoadAddr < 0x8000 && !(f.Chips&ChipFDS != 0 && f.LoadAddr >= 0x6000) {
+		return fmt.Errorf("nsf: invalid load address %#04x", f.LoadAddr)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package nsf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// nsfHeader returns a header for a file of 3 songs loaded at 0x8000
+func nsfHeader() []byte {
+	h := make([]byte, headerSize)
+	copy(h, nsfMagic)
+	h[0x05] = 1
+	h[0x06] = 3
+	h[0x07] = 2
+	le := binary.LittleEndian
+	le.PutUint16(h[0x08:], 0x8000)
+	le.PutUint16(h[0x0A:], 0x8003)
+	le.PutUint16(h[0x0C:], 0x8006)
+	copy(h[0x0E:], "Title")
+	copy(h[0x2E:], "Artist")
+	copy(h[0x4E:], "2024")
+	le.PutUint16(h[0x6E:], 16639)
+	le.PutUint16(h[0x78:], 19997)
+	h[0x7A] = RegionDual
+	h[0x7B] = ChipVRC6
+	return h
+}
+
+// TestLoadNSF checks the fields of an NSF header
+func TestLoadNSF(t *testing.T) {
+	data := append(nsfHeader(), 1, 2, 3)
+	f, err := Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if f.Title != "Title" || f.Artist != "Artist" || f.Copyright != "2024" {
+		t.Errorf("Unexpected metadata %q, %q, %q", f.Title, f.Artist, f.Copyright)
+	}
+	if f.Songs != 3 || f.StartSong != 1 || len(f.Tracks) != 3 {
+		t.Errorf("Expected 3 songs starting at 1, got %d starting at %d", f.Songs, f.StartSong)
+	}
+	if f.LoadAddr != 0x8000 || f.InitAddr != 0x8003 || f.PlayAddr != 0x8006 {
+		t.Errorf("Unexpected addresses %#04x, %#04x, %#04x", f.LoadAddr, f.InitAddr, f.PlayAddr)
+	}
+	if f.Banked {
+		t.Errorf("Expected a file without bankswitching")
+	}
+	if f.Region != RegionDual || f.Chips != ChipVRC6 {
+		t.Errorf("Unexpected region %d or chips %d", f.Region, f.Chips)
+	}
+	if !bytes.Equal(f.Data, []byte{1, 2, 3}) {
+		t.Errorf("Unexpected data % x", f.Data)
+	}
+}
+
+// TestLoadNSF2 checks that the data length of NSF2 excludes the metadata that follows
+func TestLoadNSF2(t *testing.T) {
+	h := nsfHeader()
+	h[0x05] = 2
+	h[0x7D] = 2
+	f, err := Load(bytes.NewReader(append(h, 1, 2, 3, 4)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(f.Data, []byte{1, 2}) {
+		t.Errorf("Unexpected data % x", f.Data)
+	}
+}
+
+// TestLoadInvalid checks that invalid files are rejected
+func TestLoadInvalid(t *testing.T) {
+	noSongs := nsfHeader()
+	noSongs[0x06] = 0
+	badLoad := nsfHeader()
+	binary.LittleEndian.PutUint16(badLoad[0x08:], 0x4000)
+
+	for name, data := range map[string][]byte{
+		"magic":     []byte("NES\x1A"),
+		"truncated": nsfHeader()[:0x40],
+		"no songs":  noSongs,
+		"load":      badLoad,
+	} {
+		if _, err := Load(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// chunk returns an NSFe chunk
+func chunk(id string, data []byte) []byte {
+	c := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	return append(append(c, id...), data...)
+}
+
+func int32s(values ...int32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	}
+	return b
+}
+
+// TestLoadNSFe checks the chunks of an NSFe file
+func TestLoadNSFe(t *testing.T) {
+	data := append([]byte{}, nsfeMagic...)
+	data = append(data, chunk("INFO", []byte{0x00, 0x80, 0x03, 0x80, 0x06, 0x80, 0, 0, 2, 1})...)
+	data = append(data, chunk("BANK", []byte{0, 1})...)
+	data = append(data, chunk("RATE", []byte{0x10, 0x27})...)
+	data = append(data, chunk("DATA", []byte{1, 2, 3})...)
+	data = append(data, chunk("auth", []byte("Game\x00Artist\x00(c)\x00Ripper\x00"))...)
+	data = append(data, chunk("tlbl", []byte("One\x00Two\x00"))...)
+	data = append(data, chunk("time", int32s(90000, -1))...)
+	data = append(data, chunk("fade", int32s(5000))...)
+	data = append(data, chunk("plst", []byte{1, 0})...)
+	data = append(data, chunk("xtra", []byte{0})...)
+	data = append(data, chunk("NEND", nil)...)
+	data = append(data, chunk("BAD!", nil)...)
+
+	f, err := Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if f.Songs != 2 || f.StartSong != 1 {
+		t.Errorf("Expected 2 songs starting at 1, got %d starting at %d", f.Songs, f.StartSong)
+	}
+	if !f.Banked || f.Banks != [8]uint8{0, 1} {
+		t.Errorf("Unexpected banks %v", f.Banks)
+	}
+	if f.NTSCRate != 10000 || f.PALRate != 19997 {
+		t.Errorf("Unexpected rates %d, %d", f.NTSCRate, f.PALRate)
+	}
+	if f.Title != "Game" || f.Artist != "Artist" || f.Copyright != "(c)" || f.Ripper != "Ripper" {
+		t.Errorf("Unexpected metadata %q, %q, %q, %q", f.Title, f.Artist, f.Copyright, f.Ripper)
+	}
+
+	expected := []Track{
+		{Title: "One", Length: 90 * time.Second, Fade: 5 * time.Second},
+		{Title: "Two", Fade: -1},
+	}
+	for i, e := range expected {
+		if f.Tracks[i] != e {
+			t.Errorf("Track %d: expected %+v, got %+v", i, e, f.Tracks[i])
+		}
+	}
+	if len(f.Playlist) != 2 || f.Playlist[0] != 1 {
+		t.Errorf("Unexpected playlist %v", f.Playlist)
+	}
+}
+
+// TestLoadNSFeRequired checks that unknown required chunks are rejected
+func TestLoadNSFeRequired(t *testing.T) {
+	data := append([]byte{}, nsfeMagic...)
+	data = append(data, chunk("INFO", []byte{0x00, 0x80, 0x03, 0x80, 0x06, 0x80, 0, 0, 1})...)
+	data = append(data, chunk("DATA", []byte{1})...)
+	data = append(data, chunk("VRC7", []byte{0})...)
+
+	if _, err := Load(bytes.NewReader(data)); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
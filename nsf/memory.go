@@ -0,0 +1,118 @@
+package nsf
+
+import (
+	"time"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// NSF memory map
+// --------------
+// The data of a file is placed at its load address, in 0x8000-0xFFFF, with 8k of RAM at
+// 0x6000-0x7FFF for the driver. Files for the FDS sound chip may load as low as 0x6000, in
+// which case their first bytes are copied into RAM whenever it is reset. Files that set initial
+// banks are bankswitched instead: the data is split into 4k banks, padded at the front by the
+// low 12 bits of the load address, and each of the 8 slots of 0x8000-0xFFFF is selected by
+// writing a bank number to 0x5FF8-0x5FFF. Banks past the end of the data read as 0.
+//
+// Memory should be attached to a cpu.MappedBus over 0x5FF8-0xFFFF.
+
+const bankSize = 0x1000
+
+// Memory is the cartridge space of an NSF player
+type Memory struct {
+	file  *File
+	rom   []uint8 // Data laid out in 4k banks
+	slots [8]int  // Offset in rom of the bank mapped to each slot of 0x8000-0xFFFF
+	ram   [0x2000]uint8
+	init  [0x2000]uint8 // Contents of RAM after a reset, the data loaded below 0x8000
+}
+
+// NewMemory lays out the data of a file. The initial banks are selected by Reset.
+func NewMemory(f *File) *Memory {
+	m := &Memory{file: f}
+
+	data := f.Data
+	offset := int(f.LoadAddr) - 0x8000
+	if f.Banked {
+		offset = int(f.LoadAddr & 0x0FFF)
+	} else if offset < 0 {
+		n := copy(m.init[f.LoadAddr-0x6000:], data)
+		data, offset = data[n:], 0
+	}
+	size := offset + len(data)
+	size = (size + bankSize - 1) / bankSize * bankSize
+	m.rom = make([]uint8, max(size, 8*bankSize))
+	copy(m.rom[offset:], data)
+
+	m.Reset()
+	return m
+}
+
+// Reset clears the RAM, reloading any data placed there, and selects the initial banks
+func (m *Memory) Reset() {
+	m.ram = m.init
+	for i := range m.slots {
+		if m.file.Banked {
+			m.selectBank(i, m.file.Banks[i])
+		} else {
+			m.slots[i] = i * bankSize
+		}
+	}
+}
+
+// selectBank maps a bank to a slot. Out of range banks are mapped past the end of rom.
+func (m *Memory) selectBank(slot int, bank uint8) {
+	m.slots[slot] = int(bank) * bankSize
+}
+
+func (m *Memory) Read(address uint16, readOnly bool) uint8 {
+	switch {
+	case address >= 0x8000:
+		i := m.slots[(address-0x8000)/bankSize] + int(address&0x0FFF)
+		if i < len(m.rom) {
+			return m.rom[i]
+		}
+		return 0
+	case address >= 0x6000:
+		return m.ram[address-0x6000]
+	}
+	return 0
+}
+
+func (m *Memory) Write(address uint16, data uint8) {
+	switch {
+	case address >= 0x8000:
+	case address >= 0x6000:
+		m.ram[address-0x6000] = data
+	case address >= 0x5FF8 && m.file.Banked:
+		m.selectBank(int(address-0x5FF8), data)
+	}
+}
+
+// InitRegisters returns the values of A and X for a call to the init routine: the song, from
+// 0, and the region, 0 for NTSC or 1 for PAL
+func InitRegisters(song int, region ppu.Region) (a uint8, x uint8) {
+	if region == ppu.PAL {
+		x = 1
+	}
+	return uint8(song), x
+}
+
+// PlayPeriod returns the time between calls to the play routine in a region. A rate of 0 in
+// the file means the default of the region, the frame rate.
+func (f *File) PlayPeriod(region ppu.Region) time.Duration {
+	rate := f.NTSCRate
+	if region == ppu.PAL {
+		rate = f.PALRate
+	}
+	if rate == 0 {
+		return time.Duration(float64(time.Second) / region.FrameRate())
+	}
+	return time.Duration(rate) * time.Microsecond
+}
+
+// PlayCycles returns the number of CPU cycles between calls to the play routine in a region
+func (f *File) PlayCycles(region ppu.Region) int {
+	return int(f.PlayPeriod(region).Seconds()*region.CPUClock() + 0.5)
+}
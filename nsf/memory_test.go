@@ -0,0 +1,115 @@
+package nsf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// TestMemoryLinear checks that files without bankswitching are placed at their load address
+func TestMemoryLinear(t *testing.T) {
+	m := NewMemory(&File{LoadAddr: 0x8100, Data: []uint8{0xAA, 0xBB}})
+
+	if data := m.Read(0x8100, false); data != 0xAA {
+		t.Errorf("Expected 0xAA, got %#02x", data)
+	}
+	if data := m.Read(0x8101, false); data != 0xBB {
+		t.Errorf("Expected 0xBB, got %#02x", data)
+	}
+
+	m.Write(0x8100, 0)
+	m.Write(0x5FF8, 1)
+	if data := m.Read(0x8100, false); data != 0xAA {
+		t.Errorf("Expected ROM to be unchanged, got %#02x", data)
+	}
+
+	m.Write(0x6123, 0x42)
+	if data := m.Read(0x6123, false); data != 0x42 {
+		t.Errorf("Expected 0x42 from RAM, got %#02x", data)
+	}
+	m.Reset()
+	if data := m.Read(0x6123, false); data != 0 {
+		t.Errorf("Expected RAM to be cleared, got %#02x", data)
+	}
+}
+
+// TestMemoryFDS checks that data loaded below 0x8000 starts in RAM and continues in ROM
+func TestMemoryFDS(t *testing.T) {
+	data := make([]uint8, 0x2100)
+	data[0] = 0x11      // 0x6100
+	data[0x1EFF] = 0x22 // 0x7FFF
+	data[0x1F00] = 0x33 // 0x8000
+	m := NewMemory(&File{LoadAddr: 0x6100, Chips: ChipFDS, Data: data})
+
+	for _, tt := range []struct {
+		address  uint16
+		expected uint8
+	}{{0x6100, 0x11}, {0x7FFF, 0x22}, {0x8000, 0x33}} {
+		if b := m.Read(tt.address, false); b != tt.expected {
+			t.Errorf("Expected %#02x at %#04x, got %#02x", tt.expected, tt.address, b)
+		}
+	}
+
+	m.Write(0x6100, 0x42)
+	m.Reset()
+	if b := m.Read(0x6100, false); b != 0x11 {
+		t.Errorf("Expected the data to be reloaded into RAM, got %#02x", b)
+	}
+}
+
+// TestMemoryBanked checks the padding of banked data and bankswitching through 0x5FF8-0x5FFF
+func TestMemoryBanked(t *testing.T) {
+	data := make([]uint8, 2*bankSize)
+	data[0] = 0x11           // Bank 0 at 0x0100, after the padding
+	data[bankSize-0x100] = 2 // Bank 1 at 0x0000
+	f := &File{LoadAddr: 0x8100, Banked: true, Banks: [8]uint8{0, 1, 0, 0, 0, 0, 0, 1}, Data: data}
+	m := NewMemory(f)
+
+	if b := m.Read(0x8100, false); b != 0x11 {
+		t.Errorf("Expected 0x11, got %#02x", b)
+	}
+	if b := m.Read(0x9000, false); b != 2 {
+		t.Errorf("Expected 2, got %#02x", b)
+	}
+	if b := m.Read(0xF000, false); b != 2 {
+		t.Errorf("Expected 2 in slot 7, got %#02x", b)
+	}
+
+	m.Write(0x5FF8, 1)
+	m.Write(0x5FF9, 0)
+	if b := m.Read(0x8000, false); b != 2 {
+		t.Errorf("Expected bank 1 at 0x8000, got %#02x", b)
+	}
+	if b := m.Read(0x9100, false); b != 0x11 {
+		t.Errorf("Expected bank 0 at 0x9000, got %#02x", b)
+	}
+
+	m.Write(0x5FFA, 9)
+	if b := m.Read(0xA000, false); b != 0 {
+		t.Errorf("Expected 0 past the end of the data, got %#02x", b)
+	}
+
+	m.Reset()
+	if b := m.Read(0x9000, false); b != 2 {
+		t.Errorf("Expected initial banks after reset, got %#02x", b)
+	}
+}
+
+// TestPlayPeriod checks the rate of the play routine
+func TestPlayPeriod(t *testing.T) {
+	f := &File{NTSCRate: 16639, PALRate: 0}
+	if p := f.PlayPeriod(ppu.NTSC); p != 16639*time.Microsecond {
+		t.Errorf("Expected 16639us, got %v", p)
+	}
+	if p := f.PlayPeriod(ppu.PAL); p.Round(time.Millisecond) != 20*time.Millisecond {
+		t.Errorf("Expected the PAL frame rate, got %v", p)
+	}
+	if c := f.PlayCycles(ppu.NTSC); c < 29770 || c > 29790 {
+		t.Errorf("Expected about 29780 cycles, got %d", c)
+	}
+
+	if a, x := InitRegisters(2, ppu.PAL); a != 2 || x != 1 {
+		t.Errorf("Expected A=2, X=1, got %d, %d", a, x)
+	}
+}
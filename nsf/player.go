@@ -0,0 +1,167 @@
+package nsf
+
+import (
+	"fmt"
+
+	"github.com/cbertinato/go-nes/apu"
+	"github.com/cbertinato/go-nes/cpu"
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Playing
+// -------
+// A Player runs a file the way an NSF driver does, on a 2A03 without a PPU: the CPU, with 2k of
+// RAM, the APU at 0x4000-0x4017 and the file's Memory at 0x5FF8-0xFFFF. Selecting a song resets
+// the memory and the APU and calls init with the song in A and the region in X. Each Step then
+// calls play and runs the APU until the next call is due, so a step produces the samples of
+// one play period.
+//
+// Routines are called as if by JSR from returnAddr - 1, and have returned when the CPU reaches
+// returnAddr. Between calls the CPU is idle and DMC DMA reads memory directly.
+
+const returnAddr = 0x4100 // Address a routine returns to, where nothing is mapped
+
+// Player plays the songs of a file
+type Player struct {
+	File   *File
+	Region ppu.Region
+	CPU    cpu.MOS6502
+	Bus    cpu.MappedBus
+	APU    apu.RP2A03
+	Memory *Memory
+	Mixer  *apu.Mixer // Receives the output of the APU, if not nil
+
+	song    int
+	period  int  // CPU cycles between calls to play
+	running bool // A routine is running, so DMA goes through the CPU
+}
+
+// NewPlayer returns a player of a file in a region, resampling to sampleRate or producing no
+// samples if it is 0. A song must be selected before the first Step.
+func NewPlayer(f *File, region ppu.Region, sampleRate int) *Player {
+	p := &Player{File: f, Region: region, Memory: NewMemory(f)}
+	if sampleRate > 0 {
+		p.Mixer = apu.NewMixer(region, sampleRate)
+	}
+	p.period = f.PlayCycles(region)
+	return p
+}
+
+// NativeRegion returns the region a file was written for, NTSC unless it plays on PAL only
+func (f *File) NativeRegion() ppu.Region {
+	if f.Region&RegionPAL != 0 && f.Region&RegionDual == 0 {
+		return ppu.PAL
+	}
+	return ppu.NTSC
+}
+
+// Track returns the metadata of a song, with a Length of 0 and a Fade of -1 if it has none
+func (f *File) Track(song int) Track {
+	if song >= 0 && song < len(f.Tracks) {
+		return f.Tracks[song]
+	}
+	return Track{Fade: -1}
+}
+
+// Song returns the selected song
+func (p *Player) Song() int {
+	return p.song
+}
+
+// Select resets the player and starts a song, from 0, by calling init
+func (p *Player) Select(song int) error {
+	if song < 0 || song >= p.File.Songs {
+		return fmt.Errorf("nsf: no song %d in a file of %d", song, p.File.Songs)
+	}
+	p.song = song
+
+	p.CPU = cpu.Create6502()
+	p.Bus = cpu.MappedBus{}
+	p.APU = apu.Create2A03()
+	p.APU.CPU = driverCPU{p}
+	p.APU.Region = p.Region
+	p.APU.Mixer = p.Mixer
+	p.Memory.Reset()
+
+	p.CPU.Bus = &p.Bus
+	p.Bus.CPU = &p.CPU
+	p.Bus.Attach(0x4000, 0x4017, &p.APU)
+	p.Bus.Attach(0x5FF8, 0xFFFF, p.Memory)
+
+	// silence the channels and stop the frame counter's IRQ, as the driver does
+	for address := uint16(0x4000); address <= 0x4013; address++ {
+		p.Bus.Write(address, 0x00)
+	}
+	p.Bus.Write(0x4015, 0x0F)
+	p.Bus.Write(0x4017, 0x40)
+
+	p.CPU.SP = 0xFD
+	p.CPU.SetFlag(cpu.I, true)
+	p.CPU.SetFlag(cpu.U, true)
+	p.CPU.A, p.CPU.X = InitRegisters(song, p.Region)
+	p.CPU.Y = 0
+	_, err := p.call(p.File.InitAddr)
+	return err
+}
+
+// Step calls play and runs the APU until the next call is due. If the mixer is not nil, its
+// EndFrame is called at the end, so that the samples of the step can be read from it.
+func (p *Player) Step() error {
+	cycles, err := p.call(p.File.PlayAddr)
+	for ; cycles < p.period; cycles++ {
+		p.APU.Clock()
+	}
+	if p.Mixer != nil {
+		p.Mixer.EndFrame()
+	}
+	return err
+}
+
+// call runs a routine until it returns and returns the number of CPU cycles it took. Routines
+// that jam the CPU or do not return within a second are abandoned with an error.
+func (p *Player) call(address uint16) (int, error) {
+	ret := uint16(returnAddr - 1)
+	p.Bus.Write(0x0100|uint16(p.CPU.SP), uint8(ret>>8))
+	p.CPU.SP--
+	p.Bus.Write(0x0100|uint16(p.CPU.SP), uint8(ret))
+	p.CPU.SP--
+	p.CPU.PC = address
+
+	p.running = true
+	defer func() { p.running = false }()
+
+	limit := int(p.Region.CPUClock())
+	for cycles := 1; cycles <= limit; cycles++ {
+		p.CPU.Clock()
+		p.APU.Clock()
+
+		if p.CPU.Jammed() {
+			return cycles, fmt.Errorf("nsf: CPU jammed on opcode %#02x at %#04x", p.Bus.Read(p.CPU.PC, true), p.CPU.PC)
+		}
+		if p.CPU.Complete() && p.CPU.PC == returnAddr {
+			return cycles, nil
+		}
+	}
+	return limit, fmt.Errorf("nsf: routine at %#04x did not return", address)
+}
+
+// driverCPU is the CPU as seen by the APU
+type driverCPU struct {
+	p *Player
+}
+
+func (c driverCPU) DataBus() uint8 {
+	return c.p.CPU.DataBus()
+}
+
+func (c driverCPU) RequestDMCDMA(address uint16, done func(data uint8)) {
+	if c.p.running {
+		c.p.CPU.RequestDMCDMA(address, done)
+		return
+	}
+	done(c.p.Bus.Read(address, false))
+}
+
+func (c driverCPU) SetIRQ(source cpu.IRQSource, asserted bool) {
+	c.p.CPU.SetIRQ(source, asserted)
+}
@@ -0,0 +1,108 @@
+package nsf
+
+import (
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// jamFile returns a file of 3 songs whose code is the KIL opcode 0x02, which jams the CPU
+func jamFile() *File {
+	data := make([]uint8, 0x100)
+	for i := range data {
+		data[i] = 0x02
+	}
+	return &File{Songs: 3, LoadAddr: 0x8000, InitAddr: 0x8010, PlayAddr: 0x8020, NTSCRate: 16639, Data: data}
+}
+
+// TestSelect checks the registers and stack with which init is called, and that a jam is
+// reported
+func TestSelect(t *testing.T) {
+	p := NewPlayer(jamFile(), ppu.PAL, 0)
+
+	if err := p.Select(2); err == nil {
+		t.Errorf("Expected the jam to be reported")
+	}
+	if p.CPU.A != 2 || p.CPU.X != 1 || p.Song() != 2 {
+		t.Errorf("Expected A = 2 and X = 1, got A = %d and X = %d", p.CPU.A, p.CPU.X)
+	}
+	if p.CPU.PC != 0x8010 {
+		t.Errorf("Expected init to be called at %#04x, got PC = %#04x", 0x8010, p.CPU.PC)
+	}
+	if hi, lo := p.Bus.Read(0x01FD, true), p.Bus.Read(0x01FC, true); hi != 0x40 || lo != 0xFF {
+		t.Errorf("Expected the return address 0x40FF on the stack, got %#02x%02x", hi, lo)
+	}
+
+	if err := p.Select(3); err == nil {
+		t.Errorf("Expected an error for a song past the last")
+	}
+}
+
+// TestStep checks that a step lasts one play period, even when play does not run
+func TestStep(t *testing.T) {
+	p := NewPlayer(jamFile(), ppu.NTSC, 48000)
+	p.Select(0)
+
+	for i := 0; i < 3; i++ {
+		if err := p.Step(); err == nil {
+			t.Errorf("Step %d: expected the jam to be reported", i)
+		}
+		// 16639us at 48000 Hz
+		if n := len(p.Mixer.Float32()); n < 797 || n > 800 {
+			t.Errorf("Step %d: expected about 799 samples, got %d", i, n)
+		}
+	}
+}
+
+// TestPlay checks that init and play run to completion, and that the tone started by init is
+// heard
+func TestPlay(t *testing.T) {
+	data := make([]uint8, 0x100)
+	copy(data, []uint8{
+		0xA9, 0xBF, 0x8D, 0x00, 0x40, // LDA #$BF, STA $4000: constant volume 15
+		0xA9, 0xFD, 0x8D, 0x02, 0x40, // LDA #$FD, STA $4002
+		0xA9, 0x00, 0x8D, 0x03, 0x40, // LDA #$00, STA $4003
+		0x60, // RTS
+	})
+	copy(data[0x20:], []uint8{
+		0xEE, 0x00, 0x02, // INC $0200
+		0x60, // RTS
+	})
+	f := &File{Songs: 1, LoadAddr: 0x8000, InitAddr: 0x8000, PlayAddr: 0x8020, NTSCRate: 16639, Data: data}
+
+	p := NewPlayer(f, ppu.NTSC, 48000)
+	if err := p.Select(0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loud := false
+	for i := 0; i < 3; i++ {
+		if err := p.Step(); err != nil {
+			t.Fatalf("Step %d: unexpected error: %v", i, err)
+		}
+		for _, s := range p.Mixer.Float32() {
+			if s > 0.05 || s < -0.05 {
+				loud = true
+			}
+		}
+	}
+	if calls := p.Bus.Read(0x0200, true); calls != 3 {
+		t.Errorf("Expected play to be called 3 times, got %d", calls)
+	}
+	if !loud {
+		t.Errorf("Expected the tone to be heard")
+	}
+}
+
+// TestNativeRegion checks the region chosen from the region flags
+func TestNativeRegion(t *testing.T) {
+	for _, tt := range []struct {
+		flags    uint8
+		expected ppu.Region
+	}{{0, ppu.NTSC}, {RegionPAL, ppu.PAL}, {RegionPAL | RegionDual, ppu.NTSC}} {
+		f := &File{Region: tt.flags}
+		if r := f.NativeRegion(); r != tt.expected {
+			t.Errorf("Flags %#02x: expected region %d, got %d", tt.flags, tt.expected, r)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package ppu
+
+// Background rendering
+// --------------------
+// During rendering the PPU fetches four bytes for every 8 pixel tile: the nametable byte, the
+// attribute byte and the low and high bit planes of the pattern. Each fetch takes two dots, so
+// a tile is fetched every 8 dots. Tiles are fetched two tiles ahead of the pixel being output:
+// the first two tiles of a scanline are fetched on dots 321-336 of the previous scanline.
+//
+// The fetched pattern and attribute bits are loaded into 16-bit shift registers whose high
+// byte holds the tile being drawn and low byte the next tile. The registers shift once per dot
+// and fine X selects which bit is output.
+//
+// The current VRAM address v doubles as the scroll position while rendering:
+//
+//     yyy NN YYYYY XXXXX
+//     ||| || ||||| +++++-- coarse X scroll
+//     ||| || +++++-------- coarse Y scroll
+//     ||| ++-------------- nametable select
+//     +++----------------- fine Y scroll
+//
+// Coarse X is incremented after every tile fetch and fine Y at the end of the visible dots.
+// The horizontal bits are reloaded from t on dot 257, and the vertical bits on dots 280-304 of
+// the pre-render scanline.
+
+// renderingEnabled returns true when either the background or sprites are shown
+func (p *RP2C02) renderingEnabled() bool {
+	return p.mask&(MaskBg|MaskSprites) != 0
+}
+
+// renderBackground performs the background fetches and scroll updates for the current dot of
+// a visible or pre-render scanline
+func (p *RP2C02) renderBackground() {
+	if (p.dot >= 2 && p.dot <= 257) || (p.dot >= 322 && p.dot <= 337) {
+		p.shiftBackground()
+	}
+
+	if (p.dot >= 1 && p.dot <= 256) || (p.dot >= 321 && p.dot <= 336) {
+		switch (p.dot - 1) % 8 {
+		case 0:
+			p.loadBackground()
+			p.fetchNametable()
+		case 2:
+			p.fetchAttribute()
+		case 4:
+			p.fetchPatternLo()
+		case 6:
+			p.fetchPatternHi()
+		case 7:
+			p.incrementX()
+		}
+	}
+
+	if p.dot == 256 {
+		p.incrementY()
+	}
+
+	if p.dot == 257 {
+		p.loadBackground()
+		p.copyX()
+	}
+
+	// unused nametable fetches at the end of the scanline
+	if p.dot == 337 || p.dot == 339 {
+		p.fetchNametable()
+	}
+
+	if p.scanline == p.preRenderScanline() && p.dot >= 280 && p.dot <= 304 {
+		p.copyY()
+	}
+}
+
+// fetchNametable reads the tile index for the tile at v
+func (p *RP2C02) fetchNametable() {
+	p.bgNextTile = p.Bus.Read(0x2000|(p.v&0x0FFF), false)
+}
+
+// fetchAttribute reads the attribute byte covering the tile at v and keeps the two bits that
+// select the palette for the tile's quadrant
+func (p *RP2C02) fetchAttribute() {
+	addr := 0x23C0 | (p.v & 0x0C00) | ((p.v >> 4) & 0x38) | ((p.v >> 2) & 0x07)
+	attr := p.Bus.Read(addr, false)
+
+	// coarse Y bit 1 selects the top or bottom half, coarse X bit 1 the left or right half
+	if p.v&0x0040 != 0 {
+		attr >>= 4
+	}
+	if p.v&0x0002 != 0 {
+		attr >>= 2
+	}
+	p.bgNextAttr = attr & 0x03
+}
+
+// bgPatternAddr returns the address of the low bit plane of the row of the next tile selected
+// by fine Y
+func (p *RP2C02) bgPatternAddr() uint16 {
+	var table uint16
+	if p.ctrl&CtrlBgTable != 0 {
+		table = 0x1000
+	}
+	return table | uint16(p.bgNextTile)<<4 | (p.v>>12)&0x07
+}
+
+func (p *RP2C02) fetchPatternLo() {
+	p.bgNextLo = p.Bus.Read(p.bgPatternAddr(), false)
+}
+
+func (p *RP2C02) fetchPatternHi() {
+	p.bgNextHi = p.Bus.Read(p.bgPatternAddr()+8, false)
+}
+
+// loadBackground loads the next tile into the low byte of the shift registers. The attribute
+// bits are the same for every pixel of a tile, so they are expanded to 8 bits.
+func (p *RP2C02) loadBackground() {
+	p.bgShiftLo = p.bgShiftLo&0xFF00 | uint16(p.bgNextLo)
+	p.bgShiftHi = p.bgShiftHi&0xFF00 | uint16(p.bgNextHi)
+
+	var lo, hi uint16
+	if p.bgNextAttr&0x01 != 0 {
+		lo = 0x00FF
+	}
+	if p.bgNextAttr&0x02 != 0 {
+		hi = 0x00FF
+	}
+	p.bgShiftAttrLo = p.bgShiftAttrLo&0xFF00 | lo
+	p.bgShiftAttrHi = p.bgShiftAttrHi&0xFF00 | hi
+}
+
+func (p *RP2C02) shiftBackground() {
+	p.bgShiftLo <<= 1
+	p.bgShiftHi <<= 1
+	p.bgShiftAttrLo <<= 1
+	p.bgShiftAttrHi <<= 1
+}
+
+// backgroundPixel returns the 2-bit pixel and 2-bit palette of the background at the current
+// dot. A pixel of 0 is transparent.
+func (p *RP2C02) backgroundPixel() (pixel uint8, palette uint8) {
+	if p.mask&MaskBg == 0 || (p.dot <= 8 && p.mask&MaskBgLeft == 0) {
+		return 0, 0
+	}
+
+	mux := uint16(0x8000) >> p.x
+	if p.bgShiftLo&mux != 0 {
+		pixel |= 0x01
+	}
+	if p.bgShiftHi&mux != 0 {
+		pixel |= 0x02
+	}
+	if p.bgShiftAttrLo&mux != 0 {
+		palette |= 0x01
+	}
+	if p.bgShiftAttrHi&mux != 0 {
+		palette |= 0x02
+	}
+	return pixel, palette
+}
+
+// incrementX moves v to the next tile, switching horizontal nametable when coarse X wraps
+func (p *RP2C02) incrementX() {
+	if p.v&0x001F == 31 {
+		p.v &^= 0x001F
+		p.v ^= 0x0400
+	} else {
+		p.v++
+	}
+}
+
+// incrementY moves v to the next pixel row. Coarse Y wraps after row 29 and switches vertical
+// nametable; if it was set out of bounds (30 or 31) it wraps at 31 without switching.
+func (p *RP2C02) incrementY() {
+	if p.v&0x7000 != 0x7000 {
+		p.v += 0x1000
+		return
+	}
+
+	p.v &^= 0x7000
+	y := (p.v & 0x03E0) >> 5
+	switch y {
+	case 29:
+		y = 0
+		p.v ^= 0x0800
+	case 31:
+		y = 0
+	default:
+		y++
+	}
+	p.v = p.v&^0x03E0 | y<<5
+}
+
+// copyX copies coarse X and the horizontal nametable bit from t to v
+func (p *RP2C02) copyX() {
+	p.v = p.v&^0x041F | p.t&0x041F
+}
+
+// copyY copies fine Y, coarse Y and the vertical nametable bit from t to v
+func (p *RP2C02) copyY() {
+	p.v = p.v&^0x7BE0 | p.t&0x7BE0
+}
@@ -0,0 +1,134 @@
+package ppu
+
+import (
+	"testing"
+)
+
+// TestIncrementX checks that coarse X wraps into the next horizontal nametable
+func TestIncrementX(t *testing.T) {
+	p := RP2C02{}
+
+	p.v = 0x001E
+	p.incrementX()
+	if p.v != 0x001F {
+		t.Errorf("Expected v = %#04x, got %#04x", 0x001F, p.v)
+	}
+
+	p.incrementX()
+	if p.v != 0x0400 {
+		t.Errorf("Expected v = %#04x, got %#04x", 0x0400, p.v)
+	}
+}
+
+// TestIncrementY checks fine Y overflow into coarse Y and the wrapping of coarse Y
+func TestIncrementY(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        uint16
+		expected uint16
+	}{
+		{"fine Y", 0x0000, 0x1000},
+		{"coarse Y", 0x7000, 0x0020},
+		{"next nametable", 0x73A0, 0x0800},
+		{"out of bounds", 0x7BE0, 0x0800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RP2C02{}
+			p.v = tt.v
+
+			p.incrementY()
+
+			if p.v != tt.expected {
+				t.Errorf("Expected v = %#04x, got %#04x", tt.expected, p.v)
+			}
+		})
+	}
+}
+
+// TestOddFrameSkip checks that the last dot of the pre-render scanline is skipped on odd
+// frames only when rendering is enabled
+func TestOddFrameSkip(t *testing.T) {
+	tests := []struct {
+		name     string
+		mask     uint8
+		oddFrame bool
+		dots     int
+	}{
+		{"even frame", MaskBg, false, 341 * 262},
+		{"odd frame", MaskBg, true, 341*262 - 1},
+		{"rendering disabled", 0, true, 341 * 262},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RP2C02{Bus: &DevBus{}}
+			p.mask = tt.mask
+			p.oddFrame = tt.oddFrame
+
+			dots := 0
+			for p.frame == 0 {
+				p.Clock()
+				dots++
+			}
+
+			if dots != tt.dots {
+				t.Errorf("Expected %d dots, got %d", tt.dots, dots)
+			}
+		})
+	}
+}
+
+// TestRenderBackground draws a single tile and checks the scrolled output
+func TestRenderBackground(t *testing.T) {
+	b := DevBus{}
+	p := RP2C02{Bus: &b}
+
+	// tile 1 is a vertical bar of color 3 in its leftmost column
+	for row := uint16(0); row < 8; row++ {
+		b.ram[0x0010+row] = 0x80
+		b.ram[0x0018+row] = 0x80
+	}
+	// place it at tile (2, 1) using palette 1
+	b.ram[0x2000+32+2] = 0x01
+	b.ram[0x23C0] = 0x04 // top right quadrant
+	b.ram[0x3F00] = 0x0F
+	b.ram[0x3F07] = 0x16
+
+	// scroll 3 pixels right and 2 down
+	p.Write(0x2005, 3)
+	p.Write(0x2005, 2)
+	p.Write(0x2001, MaskBg|MaskBgLeft)
+
+	// the first frame starts with v = 0, so render a second one to pick up the scroll
+	for p.frame < 2 {
+		p.Clock()
+	}
+
+	x, y := 2*8-3, 1*8-2
+	if c := p.Frame()[y*Width+x]; c != 0x16 {
+		t.Errorf("Expected %#02x at (%d, %d), got %#02x", 0x16, x, y, c)
+	}
+
+	if c := p.Frame()[y*Width+x+1]; c != 0x0F {
+		t.Errorf("Expected backdrop %#02x at (%d, %d), got %#02x", 0x0F, x+1, y, c)
+	}
+}
+
+// TestRenderEmphasis checks that the emphasis bits are stored with the palette index
+func TestRenderEmphasis(t *testing.T) {
+	b := DevBus{}
+	p := RP2C02{Bus: &b}
+	b.ram[0x3F00] = 0x21
+
+	p.Write(0x2001, MaskEmphasizeRed|MaskEmphasizeBlue|MaskGreyscale)
+	for p.frame < 1 {
+		p.Clock()
+	}
+
+	expected := uint16(0x20 | 0x05<<6)
+	if c := p.Frame()[0]; c != expected {
+		t.Errorf("Expected %#03x, got %#03x", expected, c)
+	}
+}
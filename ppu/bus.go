@@ -0,0 +1,20 @@
+package ppu
+
+// Bus interface for the 14-bit PPU address space
+type Bus interface {
+	Read(address uint16, readOnly bool) uint8
+	Write(address uint16, data uint8)
+}
+
+// DevBus is a simple bus that consists only of RAM
+type DevBus struct {
+	ram [16 * 1024]uint8 // 16k of RAM
+}
+
+func (b *DevBus) Read(address uint16, readOnly bool) uint8 {
+	return b.ram[address&0x3FFF]
+}
+
+func (b *DevBus) Write(address uint16, data uint8) {
+	b.ram[address&0x3FFF] = data
+}
@@ -0,0 +1,163 @@
+package ppu
+
+// PPU memory map
+// --------------
+//     - 0x0000-0x1FFF: pattern tables, CHR ROM or CHR RAM on the cartridge
+//     - 0x2000-0x2FFF: four 1k nametables, backed by the 2k of CIRAM in the console
+//     - 0x3000-0x3EFF: mirror of 0x2000-0x2EFF
+//     - 0x3F00-0x3F1F: palette RAM, mirrored through 0x3FFF
+//
+// CIRAM only holds two nametables, so two of the four are mirrors. The cartridge decides
+// which by wiring CIRAM A10 to PPU A10 (vertical mirroring) or A11 (horizontal mirroring),
+// and some mappers can switch it or select a single nametable. Four-screen cartridges carry
+// another 2k of RAM for the other two nametables.
+
+// Mirroring selects how the four nametables map onto CIRAM
+type Mirroring uint8
+
+const (
+	Horizontal    Mirroring = iota // 0x2000 = 0x2400, 0x2800 = 0x2C00
+	Vertical                       // 0x2000 = 0x2800, 0x2400 = 0x2C00
+	SingleScreenA                  // all four nametables use the first 1k of CIRAM
+	SingleScreenB                  // all four nametables use the second 1k of CIRAM
+	FourScreen                     // no mirroring, using RAM on the cartridge
+)
+
+// ppuMapping associates a range of PPU addresses with a device
+type ppuMapping struct {
+	lo     uint16
+	hi     uint16
+	device Bus
+}
+
+// MappedBus is the PPU address space of the NES. Like the CPU bus, address ranges can be
+// routed to attached devices, which is how the cartridge provides the pattern tables and how
+// mappers with their own nametable logic override CIRAM. Palette RAM is inside the PPU and
+// cannot be overridden.
+type MappedBus struct {
+	ciram     [2 * 1024]uint8 // 2k of nametable RAM
+	vram      [2 * 1024]uint8 // extra nametable RAM of four-screen cartridges
+	palette   [32]uint8
+	mirroring Mirroring
+	mappings  []ppuMapping
+}
+
+// Attach maps the inclusive address range lo-hi to a device. Devices receive the full PPU
+// address. Ranges attached later take precedence over earlier ones where they overlap.
+func (b *MappedBus) Attach(lo uint16, hi uint16, device Bus) {
+	b.mappings = append([]ppuMapping{{lo, hi, device}}, b.mappings...)
+}
+
+// SetMirroring selects the nametable mirroring. It is called by the cartridge, either once
+// from the header or whenever the mapper switches it.
+func (b *MappedBus) SetMirroring(m Mirroring) {
+	b.mirroring = m
+}
+
+// Mirroring returns the current nametable mirroring
+func (b *MappedBus) Mirroring() Mirroring {
+	return b.mirroring
+}
+
+// CIRAM returns the console's 2k of nametable RAM, for mappers that map it themselves in place
+// of the mirroring, such as the Namco 163
+func (b *MappedBus) CIRAM() []uint8 {
+	return b.ciram[:]
+}
+
+// device returns the device mapped at an address, or nil if the address is unmapped
+func (b *MappedBus) device(address uint16) Bus {
+	for _, m := range b.mappings {
+		if address >= m.lo && address <= m.hi {
+			return m.device
+		}
+	}
+	return nil
+}
+
+func (b *MappedBus) Read(address uint16, readOnly bool) uint8 {
+	address &= 0x3FFF
+
+	if address >= 0x3F00 {
+		return b.palette[paletteIndex(address)]
+	}
+
+	if d := b.device(address); d != nil {
+		return d.Read(address, readOnly)
+	}
+
+	if address >= 0x2000 {
+		return *b.nametable(address)
+	}
+
+	// Nothing drives the data lines, so the low byte of the multiplexed address is read back
+	return uint8(address)
+}
+
+func (b *MappedBus) Write(address uint16, data uint8) {
+	address &= 0x3FFF
+
+	if address >= 0x3F00 {
+		b.palette[paletteIndex(address)] = data & 0x3F
+		return
+	}
+
+	if d := b.device(address); d != nil {
+		d.Write(address, data)
+		return
+	}
+
+	if address >= 0x2000 {
+		*b.nametable(address) = data
+	}
+}
+
+// nametable returns the byte of nametable RAM an address in 0x2000-0x3EFF maps to
+func (b *MappedBus) nametable(address uint16) *uint8 {
+	table := (address >> 10) & 0x03
+	offset := address & 0x03FF
+
+	switch b.mirroring {
+	case Horizontal:
+		table >>= 1
+	case Vertical:
+		table &= 0x01
+	case SingleScreenA:
+		table = 0
+	case SingleScreenB:
+		table = 1
+	case FourScreen:
+		if table >= 2 {
+			return &b.vram[(table-2)<<10|offset]
+		}
+	}
+	return &b.ciram[table<<10|offset]
+}
+
+// paletteIndex returns the index into palette RAM of an address in 0x3F00-0x3FFF. The
+// backdrop entries of the sprite palettes (0x3F10, 0x3F14, 0x3F18 and 0x3F1C) are mirrors of
+// the background palette entries below them.
+func paletteIndex(address uint16) uint16 {
+	index := address & 0x001F
+	if index&0x13 == 0x10 {
+		index &^= 0x10
+	}
+	return index
+}
+
+// CHR is 8k of pattern table memory for cartridges without CHR bank switching. It ignores
+// writes unless it is CHR RAM.
+type CHR struct {
+	Data     [8 * 1024]uint8
+	Writable bool // CHR RAM rather than ROM
+}
+
+func (c *CHR) Read(address uint16, readOnly bool) uint8 {
+	return c.Data[address&0x1FFF]
+}
+
+func (c *CHR) Write(address uint16, data uint8) {
+	if c.Writable {
+		c.Data[address&0x1FFF] = data
+	}
+}
@@ -0,0 +1,141 @@
+package ppu
+
+import (
+	"testing"
+)
+
+// TestMirroring checks which nametables share memory in each mirroring mode
+func TestMirroring(t *testing.T) {
+	tests := []struct {
+		name      string
+		mirroring Mirroring
+		same      []uint16 // addresses sharing memory with 0x2000, among 0x2400, 0x2800, 0x2C00
+	}{
+		{"horizontal", Horizontal, []uint16{0x2400}},
+		{"vertical", Vertical, []uint16{0x2800}},
+		{"single screen A", SingleScreenA, []uint16{0x2400, 0x2800, 0x2C00}},
+		{"single screen B", SingleScreenB, []uint16{0x2400, 0x2800, 0x2C00}},
+		{"four screen", FourScreen, []uint16{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := MappedBus{}
+			b.SetMirroring(tt.mirroring)
+
+			b.Write(0x2005, 0xAB)
+
+			for _, addr := range []uint16{0x2400, 0x2800, 0x2C00} {
+				expected := uint8(0x00)
+				for _, same := range tt.same {
+					if addr == same {
+						expected = 0xAB
+					}
+				}
+
+				if data := b.Read(addr+5, false); data != expected {
+					t.Errorf("Expected %#02x at %#04x, got %#02x", expected, addr+5, data)
+				}
+			}
+		})
+	}
+}
+
+// TestSingleScreenSelect checks that the two single screen modes use different halves of
+// CIRAM
+func TestSingleScreenSelect(t *testing.T) {
+	b := MappedBus{}
+
+	b.SetMirroring(SingleScreenA)
+	b.Write(0x2000, 0x11)
+	b.SetMirroring(SingleScreenB)
+	b.Write(0x2000, 0x22)
+
+	b.SetMirroring(SingleScreenA)
+	if data := b.Read(0x2C00, false); data != 0x11 {
+		t.Errorf("Expected %#02x, got %#02x", 0x11, data)
+	}
+}
+
+// TestNametableMirror checks that 0x3000-0x3EFF mirrors the nametables
+func TestNametableMirror(t *testing.T) {
+	b := MappedBus{}
+	b.Write(0x2123, 0x5A)
+
+	if data := b.Read(0x3123, false); data != 0x5A {
+		t.Errorf("Expected %#02x, got %#02x", 0x5A, data)
+	}
+}
+
+// TestPaletteMirrors checks the sprite backdrop mirrors and the mirroring of palette RAM
+// through 0x3FFF
+func TestPaletteMirrors(t *testing.T) {
+	tests := []struct {
+		write uint16
+		read  uint16
+	}{
+		{0x3F10, 0x3F00},
+		{0x3F04, 0x3F14},
+		{0x3F18, 0x3F08},
+		{0x3F1C, 0x3F0C},
+		{0x3F01, 0x3F21},
+		{0x3F1F, 0x3FFF},
+	}
+
+	for _, tt := range tests {
+		b := MappedBus{}
+		b.Write(tt.write, 0x2C)
+
+		if data := b.Read(tt.read, false); data != 0x2C {
+			t.Errorf("Expected write to %#04x to be read at %#04x, got %#02x", tt.write, tt.read, data)
+		}
+	}
+
+	b := MappedBus{}
+	b.Write(0x3F11, 0x2C)
+	if data := b.Read(0x3F01, false); data != 0x00 {
+		t.Errorf("0x3F11 is not a mirror of 0x3F01")
+	}
+}
+
+// TestAttachCHR checks that pattern table accesses go to the cartridge and that CHR ROM
+// ignores writes
+func TestAttachCHR(t *testing.T) {
+	b := MappedBus{}
+	c := CHR{}
+	c.Data[0x1234] = 0x77
+	b.Attach(0x0000, 0x1FFF, &c)
+
+	if data := b.Read(0x1234, false); data != 0x77 {
+		t.Errorf("Expected %#02x, got %#02x", 0x77, data)
+	}
+
+	b.Write(0x1234, 0x00)
+	if c.Data[0x1234] != 0x77 {
+		t.Errorf("CHR ROM was written")
+	}
+
+	c.Writable = true
+	b.Write(0x1234, 0x00)
+	if c.Data[0x1234] != 0x00 {
+		t.Errorf("CHR RAM was not written")
+	}
+}
+
+// TestAttachNametables checks that a device attached over the nametables takes precedence
+// over CIRAM
+func TestAttachNametables(t *testing.T) {
+	b := MappedBus{}
+	d := DevBus{}
+	b.Attach(0x2000, 0x2FFF, &d)
+
+	b.Write(0x2400, 0x99)
+
+	if d.ram[0x2400] != 0x99 {
+		t.Errorf("Nametable write not routed to device")
+	}
+
+	if b.ciram[0x0000] != 0x00 || b.ciram[0x0400] != 0x00 {
+		t.Errorf("CIRAM written while overridden")
+	}
+}
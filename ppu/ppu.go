@@ -0,0 +1,274 @@
+package ppu
+
+// The 2C02 is the picture processing unit of the NTSC NES. It has its own 14-bit address space
+// holding the pattern tables, nametables and palette, and is controlled by the CPU through
+// eight registers mirrored across 0x2000-0x3FFF.
+
+// NMILine is the CPU input driven by the PPU's /NMI output
+type NMILine interface {
+	SetNMI(asserted bool)
+}
+
+// RP2C02 represents the state of the PPU
+type RP2C02 struct {
+	Bus   Bus     // PPU address space
+	CPU   NMILine // CPU whose NMI input the PPU drives
+	Model Model   // PPU variant, which sets the timing and registers
+
+	// DisableSpriteLimit draws every sprite on a scanline instead of the first 8. It removes
+	// the flicker games use to work around the limit, but the overflow flag still behaves
+	// as on hardware.
+	DisableSpriteLimit bool
+
+	ctrl       uint8 // PPUCTRL
+	mask       uint8 // PPUMASK
+	status     uint8 // PPUSTATUS
+	oamAddr    uint8 // OAMADDR
+	oam        [256]uint8
+	v          uint16 // Current VRAM address (15 bits)
+	t          uint16 // Temporary VRAM address (15 bits)
+	x          uint8  // Fine X scroll (3 bits)
+	w          bool   // Write toggle shared by PPUSCROLL and PPUADDR
+	readBuffer uint8  // PPUDATA read buffer
+	latch      uint8  // I/O latch holding the last value driven on the CPU data bus
+	latchFrame [8]uint64
+	scanline   int
+	dot        int
+	frame      uint64
+	oddFrame   bool
+	phase      int  // Phase of the color subcarrier, in 12ths of a cycle
+	framePhase int  // Phase at the first dot of the current frame
+	preventVbl bool // PPUSTATUS was read just before vblank, so the flag is not set this frame
+
+	// Background fetch latches and shift registers
+	bgNextTile    uint8
+	bgNextAttr    uint8
+	bgNextLo      uint8
+	bgNextHi      uint8
+	bgShiftLo     uint16
+	bgShiftHi     uint16
+	bgShiftAttrLo uint16
+	bgShiftAttrHi uint16
+
+	// Sprite evaluation state
+	secondaryOAM [32]uint8
+	oamLatch     uint8 // Last value read from OAM or secondary OAM while rendering
+	evalN        int   // Sprite index in OAM
+	evalM        int   // Byte index within the sprite
+	evalStart    int
+	evalCount    int // Sprites copied to secondary OAM
+	evalDone     bool
+	evalZero     bool // The first sprite evaluated is in secondary OAM
+	corruptRows  [32]bool
+	oamCorrupt   bool
+
+	// Sprites fetched for the current scanline
+	spriteCount int
+	spriteZero  bool
+	sprites     []sprite
+	fetchLo     uint8
+	fetchHi     uint8
+
+	frameBuffer [Width * Height]uint16
+}
+
+// PPUCTRL
+const (
+	CtrlNametableX  uint8 = 1 << iota // Base nametable address, horizontal
+	CtrlNametableY                    // Base nametable address, vertical
+	CtrlIncrement                     // VRAM address increment per PPUDATA access (1 or 32)
+	CtrlSpriteTable                   // Sprite pattern table for 8x8 sprites
+	CtrlBgTable                       // Background pattern table
+	CtrlSpriteSize                    // 8x16 sprites
+	CtrlMasterSlave                   // EXT pin direction (unused)
+	CtrlNMI                           // Generate an NMI at the start of vblank
+)
+
+// PPUMASK
+const (
+	MaskGreyscale      uint8 = 1 << iota // Greyscale
+	MaskBgLeft                           // Show background in the leftmost 8 pixels
+	MaskSpriteLeft                       // Show sprites in the leftmost 8 pixels
+	MaskBg                               // Show background
+	MaskSprites                          // Show sprites
+	MaskEmphasizeRed                     // Emphasize red
+	MaskEmphasizeGreen                   // Emphasize green
+	MaskEmphasizeBlue                    // Emphasize blue
+)
+
+// PPUSTATUS, only the top three bits are driven by the PPU
+const (
+	StatusOverflow uint8 = 1 << (iota + 5) // Sprite overflow
+	StatusSprite0                          // Sprite 0 hit
+	StatusVBlank                           // Vertical blank has started
+)
+
+// Dimensions of the picture
+const (
+	Width  = 256
+	Height = 240
+)
+
+const (
+	dotsPerScanline = 341
+
+	// Bits on the I/O latch decay to 0 when they have not been refreshed for about 600 ms
+	latchDecayFrames = 36
+)
+
+// Create2C02 returns an instance of the PPU
+func Create2C02() RP2C02 {
+	return RP2C02{}
+}
+
+// Reset puts the PPU in the state it has after the reset button is pressed. Unlike power on,
+// OAM, the VRAM address, PPUSTATUS and the I/O latch are left alone, and the frame count keeps
+// running.
+func (p *RP2C02) Reset() {
+	p.ctrl = 0
+	p.mask = 0
+	p.w = false
+	p.x = 0
+	p.t = 0
+	p.readBuffer = 0
+	p.scanline = 0
+	p.dot = 0
+	p.oddFrame = false
+	p.updateNMI()
+}
+
+// Scanline returns the scanline the next dot belongs to. Scanlines 0-239 are visible and the
+// last scanline of the frame is the pre-render scanline. On NTSC, vblank starts on scanline
+// 241 and the pre-render scanline is 261.
+func (p *RP2C02) Scanline() int {
+	return p.scanline
+}
+
+// Dot returns the next dot to be rendered on the current scanline (0-340)
+func (p *RP2C02) Dot() int {
+	return p.dot
+}
+
+// FrameCount returns the number of frames completed since power on
+func (p *RP2C02) FrameCount() uint64 {
+	return p.frame
+}
+
+// Frame returns the frame buffer, one entry per pixel in row order. Each entry holds the
+// 6-bit palette index of the pixel in bits 0-5 and the PPUMASK color emphasis bits that were
+// set when it was drawn in bits 6-8, which together index a 512 color palette. The buffer is
+// complete when vblank starts and is overwritten as the next frame renders.
+func (p *RP2C02) Frame() []uint16 {
+	return p.frameBuffer[:]
+}
+
+// ColorPhase returns the phase of the NTSC color subcarrier at the first dot of the current
+// frame, from 0 to 11. Each dot advances the phase by 8 and each scanline by 4, so it is needed
+// to reproduce the composite signal of the frame.
+func (p *RP2C02) ColorPhase() int {
+	return p.framePhase
+}
+
+// Clock advances the PPU by one dot
+func (p *RP2C02) Clock() {
+	if p.renderLine() {
+		if p.renderingEnabled() {
+			if p.oamCorrupt {
+				p.processOAMCorruption()
+			}
+			p.renderBackground()
+			p.renderSprites()
+		}
+
+		if p.scanline < Height && p.dot >= 1 && p.dot <= Width {
+			p.renderPixel()
+		}
+	}
+
+	if p.scanline == p.vblankScanline() && p.dot == 1 {
+		if !p.preventVbl {
+			p.status |= StatusVBlank
+		}
+		p.preventVbl = false
+		p.updateNMI()
+	}
+
+	if p.scanline == p.preRenderScanline() && p.dot == 1 {
+		p.status &^= StatusVBlank | StatusSprite0 | StatusOverflow
+		p.updateNMI()
+	}
+
+	// The last dot of the pre-render scanline is skipped on odd frames when rendering, on NTSC
+	if p.scanline == p.preRenderScanline() && p.dot == 339 && p.oddFrame && p.oddFrameSkip() && p.renderingEnabled() {
+		p.dot++
+	}
+
+	// a dot lasts 8 of the 12 steps of the color cycle
+	p.phase = (p.phase + 8) % 12
+
+	p.dot++
+	if p.dot == dotsPerScanline {
+		p.dot = 0
+		p.scanline++
+		if p.scanline == p.scanlinesPerFrame() {
+			p.scanline = 0
+			p.frame++
+			p.oddFrame = !p.oddFrame
+			p.framePhase = p.phase
+		}
+	}
+}
+
+// renderLine returns true on the scanlines where the PPU fetches data for rendering: the
+// visible scanlines and the pre-render scanline
+func (p *RP2C02) renderLine() bool {
+	return p.scanline < Height || p.scanline == p.preRenderScanline()
+}
+
+// renderPixel writes the pixel for the current dot to the frame buffer. When rendering is
+// disabled the backdrop color is drawn, unless v points into the palette, in which case the
+// color at v is drawn instead.
+//
+// Sprite 0 hit is flagged when an opaque pixel of sprite 0 overlaps an opaque background
+// pixel, except at x = 255.
+func (p *RP2C02) renderPixel() {
+	var color uint8
+	if p.renderingEnabled() {
+		bgPixel, bgPalette := p.backgroundPixel()
+		spPixel, spPalette, behind, zero := p.spritePixel()
+
+		if bgPixel != 0 && spPixel != 0 && zero && p.dot != Width {
+			p.status |= StatusSprite0
+		}
+
+		pixel, palette := bgPixel, bgPalette
+		if spPixel != 0 && (bgPixel == 0 || !behind) {
+			pixel, palette = spPixel, spPalette
+		}
+
+		addr := uint16(0x3F00)
+		if pixel != 0 {
+			addr |= uint16(palette)<<2 | uint16(pixel)
+		}
+		color = p.Bus.Read(addr, false)
+	} else if p.v&0x3F00 == 0x3F00 {
+		color = p.Bus.Read(p.v&0x3FFF, false)
+	} else {
+		color = p.Bus.Read(0x3F00, false)
+	}
+
+	color &= 0x3F
+	if p.mask&MaskGreyscale != 0 {
+		color &= 0x30
+	}
+
+	p.frameBuffer[p.scanline*Width+p.dot-1] = uint16(color) | uint16(p.emphasis())<<6
+}
+
+// updateNMI drives the CPU's NMI line, which is asserted for as long as vblank is flagged and
+// NMI generation is enabled in PPUCTRL
+func (p *RP2C02) updateNMI() {
+	if p.CPU != nil {
+		p.CPU.SetNMI(p.status&StatusVBlank != 0 && p.ctrl&CtrlNMI != 0)
+	}
+}
@@ -0,0 +1,113 @@
+package ppu
+
+import (
+	"testing"
+)
+
+// testCPU records the level of the NMI line
+type testCPU struct {
+	nmi bool
+}
+
+func (c *testCPU) SetNMI(asserted bool) {
+	c.nmi = asserted
+}
+
+// runTo clocks the PPU until the given dot is the next to be rendered
+func runTo(p *RP2C02, scanline int, dot int) {
+	for p.scanline != scanline || p.dot != dot {
+		p.Clock()
+	}
+}
+
+// TestVBlank checks that the vblank flag is set at the start of scanline 241 and cleared on
+// the pre-render scanline
+func TestVBlank(t *testing.T) {
+	c := testCPU{}
+	p := RP2C02{Bus: &DevBus{}, CPU: &c}
+	p.Write(0x2000, CtrlNMI)
+
+	runTo(&p, 241, 1)
+	if p.status&StatusVBlank != 0 || c.nmi {
+		t.Errorf("vblank set early")
+	}
+
+	p.Clock()
+	if p.status&StatusVBlank == 0 {
+		t.Errorf("vblank not set at scanline 241, dot 1")
+	}
+	if !c.nmi {
+		t.Errorf("NMI not asserted at start of vblank")
+	}
+
+	runTo(&p, 261, 2)
+	if p.status&StatusVBlank != 0 || c.nmi {
+		t.Errorf("vblank not cleared on pre-render scanline")
+	}
+}
+
+// TestNMIEnable checks that enabling NMI during vblank asserts the line immediately
+func TestNMIEnable(t *testing.T) {
+	c := testCPU{}
+	p := RP2C02{Bus: &DevBus{}, CPU: &c}
+
+	runTo(&p, 241, 10)
+	if c.nmi {
+		t.Errorf("NMI asserted while disabled")
+	}
+
+	p.Write(0x2000, CtrlNMI)
+	if !c.nmi {
+		t.Errorf("NMI not asserted when enabled during vblank")
+	}
+
+	p.Write(0x2000, 0x00)
+	if c.nmi {
+		t.Errorf("NMI still asserted after being disabled")
+	}
+}
+
+// TestVBlankRace checks that reading PPUSTATUS on the dot before vblank suppresses the flag
+// and the NMI for that frame
+func TestVBlankRace(t *testing.T) {
+	c := testCPU{}
+	p := RP2C02{Bus: &DevBus{}, CPU: &c}
+	p.Write(0x2000, CtrlNMI)
+
+	runTo(&p, 241, 1)
+	if data := p.Read(0x2002, false); data&StatusVBlank != 0 {
+		t.Errorf("vblank read as set before it started")
+	}
+
+	p.Clock()
+	if p.status&StatusVBlank != 0 || c.nmi {
+		t.Errorf("vblank set after racing read")
+	}
+
+	runTo(&p, 0, 0)
+	runTo(&p, 241, 2)
+	if p.status&StatusVBlank == 0 {
+		t.Errorf("vblank not set on the following frame")
+	}
+}
+
+// TestColorPhase checks that the color phase of a frame advances by 4 when no dot is skipped
+// and by 8 when one is
+func TestColorPhase(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+
+	runTo(&p, 0, 0)
+	p.Clock()
+	runTo(&p, 0, 0)
+	if phase := p.ColorPhase(); phase != 4 {
+		t.Errorf("Expected phase 4, got %d", phase)
+	}
+
+	p.mask = MaskBg
+	p.oddFrame = true
+	p.Clock()
+	runTo(&p, 0, 0)
+	if phase := p.ColorPhase(); phase != 0 {
+		t.Errorf("Expected phase 0, got %d", phase)
+	}
+}
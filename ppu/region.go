@@ -0,0 +1,258 @@
+package ppu
+
+// PPU variants
+// ------------
+// The 2C02 is the PPU of NTSC consoles. Other regions and arcade systems use variants that
+// differ in their timing, registers and palettes:
+//     - 2C07 (PAL): 312 scanlines with 70 scanlines of vblank, no skipped dot on odd frames
+//       and the red and green emphasis bits of PPUMASK swapped. The PPU runs 3.2 dots per CPU
+//       cycle instead of 3.
+//     - Dendy (UA6538 and other PAL famiclones): 312 scanlines with the PAL frame rate, but
+//       vblank starts 51 scanlines after the picture so that NMI handlers get the same
+//       20 scanlines of vblank as on NTSC. The PPU runs 3 dots per CPU cycle.
+//     - 2C03 (PlayChoice-10, Vs. System, Famicom Titler): 2C02 timing with RGB output from a
+//       palette of 9-bit colors
+//     - 2C04 (Vs. System): a 2C03 with one of four scrambled palettes, to stop games being
+//       swapped between cabinets
+//     - 2C05 (Vs. System): a 2C03 with PPUCTRL and PPUMASK swapped and an identification
+//       value in the low bits of PPUSTATUS
+
+// Region is the TV system of the console, which sets the CPU and PPU timing
+type Region uint8
+
+const (
+	NTSC Region = iota
+	PAL
+	Dendy
+)
+
+// Model identifies a PPU variant
+type Model uint8
+
+const (
+	Model2C02  Model = iota // NTSC
+	Model2C07               // PAL
+	ModelDendy              // Dendy
+	Model2C03               // RGB
+	Model2C04A              // RGB, RP2C04-0001 palette
+	Model2C04B              // RGB, RP2C04-0002 palette
+	Model2C04C              // RGB, RP2C04-0003 palette
+	Model2C04D              // RGB, RP2C04-0004 palette
+	Model2C05A              // RGB with swapped registers, RC2C05-01
+	Model2C05B              // RGB with swapped registers, RC2C05-02
+	Model2C05C              // RGB with swapped registers, RC2C05-03
+	Model2C05D              // RGB with swapped registers, RC2C05-04
+	Model2C05E              // RGB with swapped registers, RC2C05-05
+)
+
+// Region returns the timing used by a PPU model
+func (m Model) Region() Region {
+	switch m {
+	case Model2C07:
+		return PAL
+	case ModelDendy:
+		return Dendy
+	}
+	return NTSC
+}
+
+// RGB returns true for the PPUs with RGB output
+func (m Model) RGB() bool {
+	return m >= Model2C03
+}
+
+// swapsCtrlMask returns true for the 2C05, which has PPUCTRL at 0x2001 and PPUMASK at 0x2000
+func (m Model) swapsCtrlMask() bool {
+	return m >= Model2C05A
+}
+
+// statusID returns the value the 2C05 returns in the low 5 bits of PPUSTATUS, and false for
+// the other models, which return the I/O latch
+func (m Model) statusID() (uint8, bool) {
+	switch m {
+	case Model2C05A, Model2C05D:
+		return 0x1B, true
+	case Model2C05B:
+		return 0x3D, true
+	case Model2C05C:
+		return 0x1C, true
+	case Model2C05E:
+		return 0x00, true
+	}
+	return 0, false
+}
+
+// RGBPalette returns the palette of an RGB PPU as 9-bit colors, with 3 bits each of red,
+// green and blue from the most significant, and false for the PPUs with composite output
+func (m Model) RGBPalette() ([64]uint16, bool) {
+	if !m.RGB() {
+		return [64]uint16{}, false
+	}
+	if m < Model2C04A || m > Model2C04D {
+		return rgbPalette, true
+	}
+
+	palette := [64]uint16{}
+	for i, j := range rc2C04Order[m-Model2C04A] {
+		palette[i] = rgbPalette[j]
+	}
+	return palette, true
+}
+
+// rgbPalette is the palette of the 2C03 and 2C05
+var rgbPalette = [64]uint16{
+	0o333, 0o014, 0o006, 0o326, 0o403, 0o503, 0o510, 0o420, 0o320, 0o120, 0o031, 0o040, 0o022, 0o000, 0o000, 0o000,
+	0o555, 0o036, 0o027, 0o407, 0o507, 0o704, 0o700, 0o630, 0o430, 0o140, 0o040, 0o053, 0o044, 0o000, 0o000, 0o000,
+	0o777, 0o357, 0o447, 0o637, 0o707, 0o737, 0o740, 0o750, 0o660, 0o360, 0o070, 0o276, 0o077, 0o000, 0o000, 0o000,
+	0o777, 0o567, 0o657, 0o757, 0o747, 0o755, 0o764, 0o772, 0o773, 0o572, 0o473, 0o276, 0o467, 0o000, 0o000, 0o000,
+}
+
+// rc2C04Order gives, for each color of the RP2C04-0001 to -0004 palettes, the index of the same
+// color in the 2C03 palette. The four palettes hold the same colors in a different order.
+var rc2C04Order = [4][64]uint8{
+	{
+		0x35, 0x23, 0x16, 0x22, 0x1C, 0x09, 0x1D, 0x15, 0x20, 0x00, 0x27, 0x05, 0x04, 0x28, 0x08, 0x20,
+		0x21, 0x3E, 0x1F, 0x29, 0x3C, 0x32, 0x36, 0x12, 0x3F, 0x2B, 0x2E, 0x1E, 0x3D, 0x2D, 0x24, 0x01,
+		0x0E, 0x31, 0x33, 0x2A, 0x2C, 0x0C, 0x1B, 0x14, 0x2E, 0x07, 0x34, 0x06, 0x13, 0x02, 0x26, 0x2E,
+		0x2E, 0x19, 0x10, 0x0A, 0x39, 0x03, 0x37, 0x17, 0x0F, 0x11, 0x0B, 0x0D, 0x38, 0x25, 0x18, 0x3A,
+	},
+	{
+		0x2E, 0x27, 0x18, 0x39, 0x3A, 0x25, 0x1C, 0x31, 0x16, 0x13, 0x38, 0x34, 0x20, 0x23, 0x3C, 0x0B,
+		0x0F, 0x21, 0x06, 0x3D, 0x1B, 0x29, 0x1E, 0x22, 0x1D, 0x24, 0x0E, 0x2B, 0x32, 0x08, 0x2E, 0x03,
+		0x04, 0x36, 0x26, 0x33, 0x11, 0x1F, 0x10, 0x02, 0x14, 0x3F, 0x00, 0x09, 0x12, 0x2E, 0x28, 0x20,
+		0x3E, 0x0D, 0x2A, 0x17, 0x0C, 0x01, 0x15, 0x19, 0x2E, 0x2C, 0x07, 0x37, 0x35, 0x05, 0x0A, 0x2D,
+	},
+	{
+		0x14, 0x25, 0x3A, 0x10, 0x0B, 0x20, 0x31, 0x09, 0x01, 0x2E, 0x36, 0x08, 0x15, 0x3D, 0x3E, 0x3C,
+		0x22, 0x1C, 0x05, 0x12, 0x19, 0x18, 0x17, 0x1B, 0x00, 0x03, 0x2E, 0x02, 0x16, 0x06, 0x34, 0x35,
+		0x23, 0x0F, 0x0E, 0x37, 0x0D, 0x27, 0x26, 0x20, 0x29, 0x04, 0x21, 0x24, 0x11, 0x2D, 0x2E, 0x1F,
+		0x2C, 0x1E, 0x39, 0x33, 0x07, 0x2A, 0x28, 0x1D, 0x0A, 0x2E, 0x32, 0x38, 0x13, 0x2B, 0x3F, 0x0C,
+	},
+	{
+		0x18, 0x03, 0x1C, 0x28, 0x2E, 0x35, 0x01, 0x17, 0x10, 0x1F, 0x2A, 0x0E, 0x36, 0x37, 0x0B, 0x39,
+		0x25, 0x1E, 0x12, 0x34, 0x2E, 0x1D, 0x06, 0x26, 0x3E, 0x1B, 0x22, 0x19, 0x04, 0x2E, 0x3A, 0x21,
+		0x05, 0x0A, 0x07, 0x02, 0x13, 0x14, 0x00, 0x15, 0x0C, 0x3D, 0x11, 0x0F, 0x0D, 0x38, 0x2D, 0x24,
+		0x33, 0x20, 0x08, 0x16, 0x3F, 0x2B, 0x20, 0x3C, 0x2E, 0x27, 0x23, 0x31, 0x29, 0x32, 0x2C, 0x09,
+	},
+}
+
+// Scanlines returns the number of scanlines per frame
+func (r Region) Scanlines() int {
+	if r == NTSC {
+		return 262
+	}
+	return 312
+}
+
+// vblankScanline returns the scanline at the start of which the vblank flag is set
+func (r Region) vblankScanline() int {
+	if r == Dendy {
+		return 291
+	}
+	return 241
+}
+
+// ClockDividers returns the number of master clock cycles per CPU cycle and per PPU dot. The
+// PPU runs cpu/ppu dots for every CPU cycle: 3 on NTSC and Dendy, 3.2 on PAL.
+func (r Region) ClockDividers() (cpu int, ppu int) {
+	switch r {
+	case PAL:
+		return 16, 5
+	case Dendy:
+		return 15, 5
+	}
+	return 12, 4
+}
+
+// MasterClock returns the frequency of the master clock crystal in Hz
+func (r Region) MasterClock() float64 {
+	if r == NTSC {
+		return 236250000.0 / 11 // 21.477272 MHz
+	}
+	return 26601712.5
+}
+
+// CPUClock returns the frequency of the CPU clock in Hz
+func (r Region) CPUClock() float64 {
+	cpu, _ := r.ClockDividers()
+	return r.MasterClock() / float64(cpu)
+}
+
+// FrameRate returns the number of frames per second
+func (r Region) FrameRate() float64 {
+	master := r.MasterClock()
+	_, ppu := r.ClockDividers()
+	dots := float64(r.Scanlines() * dotsPerScanline)
+	if r == NTSC {
+		// one dot is skipped on every other frame
+		dots -= 0.5
+	}
+	return master / float64(ppu) / dots
+}
+
+// ModelFromHeader returns the PPU model for a cartridge from its 16 byte iNES header. NES 2.0
+// headers give the timing in byte 12 and, for Vs. System games, the PPU in byte 13. iNES 1.0
+// headers can only flag PAL in byte 9. Multi-region games run as NTSC.
+func ModelFromHeader(header []uint8) Model {
+	if len(header) < 16 {
+		return Model2C02
+	}
+
+	if header[7]&0x0C != 0x08 {
+		// iNES 1.0
+		if header[9]&0x01 != 0 {
+			return Model2C07
+		}
+		return Model2C02
+	}
+
+	switch header[7] & 0x03 {
+	case 1: // Vs. System
+		vs := []Model{
+			Model2C03, Model2C03, Model2C04A, Model2C04B, Model2C04C, Model2C04D, Model2C03, Model2C03,
+			Model2C05A, Model2C05B, Model2C05C, Model2C05D, Model2C05E,
+		}
+		if ppu := int(header[13] & 0x0F); ppu < len(vs) {
+			return vs[ppu]
+		}
+		return Model2C03
+	case 2: // PlayChoice-10
+		return Model2C03
+	}
+
+	switch header[12] & 0x03 {
+	case 1:
+		return Model2C07
+	case 3:
+		return ModelDendy
+	}
+	return Model2C02
+}
+
+// scanlinesPerFrame, vblankScanline and preRenderScanline return the frame timing of the PPU
+func (p *RP2C02) scanlinesPerFrame() int {
+	return p.Model.Region().Scanlines()
+}
+
+func (p *RP2C02) vblankScanline() int {
+	return p.Model.Region().vblankScanline()
+}
+
+func (p *RP2C02) preRenderScanline() int {
+	return p.scanlinesPerFrame() - 1
+}
+
+// oddFrameSkip returns true if the PPU skips a dot on odd frames, which only NTSC PPUs do
+func (p *RP2C02) oddFrameSkip() bool {
+	return p.Model.Region() == NTSC
+}
+
+// emphasis returns the color emphasis bits of PPUMASK in the order red, green, blue from bit 0.
+// The 2C07 swaps the red and green bits.
+func (p *RP2C02) emphasis() uint8 {
+	e := p.mask >> 5
+	if p.Model == Model2C07 {
+		e = e&0x04 | (e&0x01)<<1 | (e&0x02)>>1
+	}
+	return e
+}
@@ -0,0 +1,211 @@
+package ppu
+
+import (
+	"math"
+	"testing"
+)
+
+// TestRegionTiming checks the frame length and vblank position of each region
+func TestRegionTiming(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  Model
+		vblank int
+		dots   int // dots in an odd frame with rendering enabled
+	}{
+		{"NTSC", Model2C02, 241, 341*262 - 1},
+		{"PAL", Model2C07, 241, 341 * 312},
+		{"Dendy", ModelDendy, 291, 341 * 312},
+		{"RGB", Model2C03, 241, 341*262 - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RP2C02{Bus: &DevBus{}, Model: tt.model}
+			p.mask = MaskBg
+			p.oddFrame = true
+
+			dots := 0
+			vblank := -1
+			for p.frame == 0 {
+				p.Clock()
+				dots++
+				if vblank < 0 && p.status&StatusVBlank != 0 {
+					vblank = p.scanline
+				}
+			}
+
+			if dots != tt.dots {
+				t.Errorf("Expected %d dots, got %d", tt.dots, dots)
+			}
+
+			if vblank != tt.vblank {
+				t.Errorf("Expected vblank on scanline %d, got %d", tt.vblank, vblank)
+			}
+		})
+	}
+}
+
+// TestPALEmphasis checks that the red and green emphasis bits are swapped on the 2C07
+func TestPALEmphasis(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    Model
+		expected uint8
+	}{
+		{"NTSC", Model2C02, 0x01},
+		{"PAL", Model2C07, 0x02},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RP2C02{Bus: &DevBus{}, Model: tt.model}
+			p.Write(0x2001, MaskEmphasizeRed)
+
+			if e := p.emphasis(); e != tt.expected {
+				t.Errorf("Expected emphasis %#02x, got %#02x", tt.expected, e)
+			}
+		})
+	}
+}
+
+// TestRegisterSwap checks that PPUCTRL and PPUMASK trade places on the 2C05
+func TestRegisterSwap(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}, Model: Model2C05A}
+
+	p.Write(0x2000, MaskBg)
+	p.Write(0x2001, CtrlIncrement)
+
+	if p.mask != MaskBg || p.ctrl != CtrlIncrement {
+		t.Errorf("Registers not swapped: ctrl = %#02x, mask = %#02x", p.ctrl, p.mask)
+	}
+}
+
+// TestStatusID checks the identification value in the low bits of PPUSTATUS on the 2C05
+func TestStatusID(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}, Model: Model2C05B}
+	p.status = StatusVBlank
+	p.Write(0x2003, 0xFF)
+
+	if data := p.Read(0x2002, false); data != StatusVBlank|0x1D {
+		t.Errorf("Expected %#02x, got %#02x", StatusVBlank|0x1D, data)
+	}
+}
+
+// TestModelFromHeader checks the PPU model selected by iNES and NES 2.0 headers
+func TestModelFromHeader(t *testing.T) {
+	header := func(b7, b9, b12, b13 uint8) []uint8 {
+		return []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0, b7, 0, b9, 0, 0, b12, b13, 0, 0}
+	}
+
+	tests := []struct {
+		name     string
+		header   []uint8
+		expected Model
+	}{
+		{"iNES NTSC", header(0x00, 0x00, 0x00, 0x00), Model2C02},
+		{"iNES PAL", header(0x00, 0x01, 0x00, 0x00), Model2C07},
+		{"NES 2.0 NTSC", header(0x08, 0x00, 0x00, 0x00), Model2C02},
+		{"NES 2.0 PAL", header(0x08, 0x00, 0x01, 0x00), Model2C07},
+		{"NES 2.0 multi-region", header(0x08, 0x00, 0x02, 0x00), Model2C02},
+		{"NES 2.0 Dendy", header(0x08, 0x00, 0x03, 0x00), ModelDendy},
+		{"Vs. System 2C04", header(0x09, 0x00, 0x00, 0x03), Model2C04B},
+		{"Vs. System 2C05", header(0x09, 0x00, 0x00, 0x0C), Model2C05E},
+		{"PlayChoice-10", header(0x0A, 0x00, 0x00, 0x00), Model2C03},
+		{"short", []uint8{'N', 'E', 'S'}, Model2C02},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m := ModelFromHeader(tt.header); m != tt.expected {
+				t.Errorf("Expected model %d, got %d", tt.expected, m)
+			}
+		})
+	}
+}
+
+// TestRGBPalette checks a few colors of the 2C04 palettes against the 2C03 palette
+func TestRGBPalette(t *testing.T) {
+	if _, ok := Model2C02.RGBPalette(); ok {
+		t.Errorf("Expected no RGB palette for the 2C02")
+	}
+
+	tests := []struct {
+		model    Model
+		index    int
+		expected uint16
+	}{
+		{Model2C03, 0x30, 0o777},
+		{Model2C04A, 0x00, rgbPalette[0x35]},
+		{Model2C04A, 0x09, rgbPalette[0x00]},
+		{Model2C04B, 0x00, 0o000},
+		{Model2C04B, 0x2A, rgbPalette[0x00]},
+		{Model2C04C, 0x18, rgbPalette[0x00]},
+		{Model2C04C, 0x3E, rgbPalette[0x3F]},
+		{Model2C04D, 0x26, rgbPalette[0x00]},
+		{Model2C04D, 0x00, rgbPalette[0x18]},
+	}
+
+	for _, tt := range tests {
+		palette, ok := tt.model.RGBPalette()
+		if !ok || palette[tt.index] != tt.expected {
+			t.Errorf("Model %d: expected %#03o at %#02x, got %#03o", tt.model, tt.expected, tt.index, palette[tt.index])
+		}
+	}
+
+	// every palette holds the 2C03's colors
+	colors := map[uint16]bool{}
+	for _, c := range rgbPalette {
+		colors[c] = true
+	}
+	for m := Model2C04A; m <= Model2C04D; m++ {
+		palette, _ := m.RGBPalette()
+		found := map[uint16]bool{}
+		for _, c := range palette {
+			if !colors[c] {
+				t.Errorf("Model %d: unexpected color %#03o", m, c)
+			}
+			found[c] = true
+		}
+		if len(found) != len(colors) {
+			t.Errorf("Model %d: expected %d colors, got %d", m, len(colors), len(found))
+		}
+	}
+}
+
+// TestClockDividers checks the number of dots per CPU cycle of each region
+func TestClockDividers(t *testing.T) {
+	tests := []struct {
+		region   Region
+		expected float64
+	}{
+		{NTSC, 3},
+		{PAL, 3.2},
+		{Dendy, 3},
+	}
+
+	for _, tt := range tests {
+		cpu, ppu := tt.region.ClockDividers()
+		if ratio := float64(cpu) / float64(ppu); ratio != tt.expected {
+			t.Errorf("Expected %v dots per CPU cycle for region %d, got %v", tt.expected, tt.region, ratio)
+		}
+	}
+}
+
+// TestCPUClock checks the CPU clock rate of each region
+func TestCPUClock(t *testing.T) {
+	tests := []struct {
+		region   Region
+		expected float64
+	}{
+		{NTSC, 1789773},
+		{PAL, 1662607},
+		{Dendy, 1773448},
+	}
+
+	for _, tt := range tests {
+		if clock := tt.region.CPUClock(); math.Abs(clock-tt.expected) > 1 {
+			t.Errorf("Expected a CPU clock of %v Hz for region %d, got %v", tt.expected, tt.region, clock)
+		}
+	}
+}
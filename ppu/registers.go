@@ -0,0 +1,217 @@
+package ppu
+
+// CPU-visible registers
+// ---------------------
+// The PPU exposes eight registers to the CPU at 0x2000-0x2007, mirrored every 8 bytes up to
+// 0x3FFF:
+//     - 0x2000 PPUCTRL   (write)
+//     - 0x2001 PPUMASK   (write)
+//     - 0x2002 PPUSTATUS (read)
+//     - 0x2003 OAMADDR   (write)
+//     - 0x2004 OAMDATA   (read/write)
+//     - 0x2005 PPUSCROLL (write x2)
+//     - 0x2006 PPUADDR   (write x2)
+//     - 0x2007 PPUDATA   (read/write)
+//
+// The registers sit behind an I/O latch that holds the last value driven on the data bus.
+// Reading a write-only register returns the latch, as do the bits of a readable register that
+// the PPU does not drive. Bits of the latch that are not refreshed decay to 0 after a while.
+
+const (
+	regCtrl uint16 = iota
+	regMask
+	regStatus
+	regOAMAddr
+	regOAMData
+	regScroll
+	regAddr
+	regData
+)
+
+// Read returns the value of the register mapped at a CPU address. A readOnly read returns
+// the same value without the side effects of the access, for use by debuggers.
+func (p *RP2C02) Read(address uint16, readOnly bool) uint8 {
+	switch address & 0x0007 {
+	case regStatus:
+		return p.readStatus(readOnly)
+	case regOAMData:
+		return p.readOAMData(readOnly)
+	case regData:
+		return p.readData(readOnly)
+	}
+	return p.ioLatch()
+}
+
+// Write stores a value to the register mapped at a CPU address
+func (p *RP2C02) Write(address uint16, data uint8) {
+	p.refreshLatch(data, 0xFF)
+
+	reg := address & 0x0007
+	if p.Model.swapsCtrlMask() && reg <= regMask {
+		reg ^= 0x01
+	}
+
+	switch reg {
+	case regCtrl:
+		p.ctrl = data
+		// t: ...GH.. ........ <- d: ......GH
+		p.t = (p.t & 0xF3FF) | (uint16(data)&0x03)<<10
+		p.updateNMI()
+	case regMask:
+		if p.renderingEnabled() && data&(MaskBg|MaskSprites) == 0 && p.renderLine() {
+			p.corruptOAM()
+		}
+		p.mask = data
+	case regOAMAddr:
+		p.oamAddr = data
+	case regOAMData:
+		p.writeOAMData(data)
+	case regScroll:
+		if !p.w {
+			// t: ....... ...ABCDE <- d: ABCDE...
+			// x:              FGH <- d: .....FGH
+			p.t = (p.t & 0xFFE0) | uint16(data)>>3
+			p.x = data & 0x07
+		} else {
+			// t: FGH..AB CDE..... <- d: ABCDEFGH
+			p.t = (p.t & 0x8C1F) | (uint16(data)&0x07)<<12 | (uint16(data)&0xF8)<<2
+		}
+		p.w = !p.w
+	case regAddr:
+		if !p.w {
+			// t: .CDEFGH ........ <- d: ..CDEFGH, bit 14 of t is cleared
+			p.t = (p.t & 0x00FF) | (uint16(data)&0x3F)<<8
+		} else {
+			// t: ....... ABCDEFGH <- d: ABCDEFGH, then v = t
+			p.t = (p.t & 0xFF00) | uint16(data)
+			p.v = p.t
+		}
+		p.w = !p.w
+	case regData:
+		p.Bus.Write(p.v&0x3FFF, data)
+		p.incrementAddr()
+	}
+}
+
+// readStatus returns PPUSTATUS. Reading it clears the vblank flag and the write toggle.
+//
+// Reading the register on the dot before vblank starts returns the flag clear and stops it
+// from being set for the frame. Reading it on the dots just after returns the flag set but
+// clears it before the CPU has sampled the NMI line, so no NMI is generated for the frame.
+//
+// The 2C05 returns an identification value in the low bits instead of the I/O latch.
+func (p *RP2C02) readStatus(readOnly bool) uint8 {
+	data := p.status&0xE0 | p.ioLatch()&0x1F
+	if id, ok := p.Model.statusID(); ok {
+		data = p.status&0xE0 | id&0x1F
+	}
+	if readOnly {
+		return data
+	}
+
+	p.refreshLatch(data, 0xE0)
+	p.status &^= StatusVBlank
+	p.w = false
+	if p.scanline == p.vblankScanline() && p.dot == 1 {
+		p.preventVbl = true
+	}
+	p.updateNMI()
+
+	return data
+}
+
+// readOAMData returns the OAM byte at OAMADDR. Bits 2-4 of the sprite attribute bytes do not
+// exist and read back as 0. While rendering, the value the PPU is reading for sprite
+// evaluation is returned instead.
+func (p *RP2C02) readOAMData(readOnly bool) uint8 {
+	var data uint8
+	if p.renderingEnabled() && p.renderLine() {
+		data = p.oamLatch
+	} else {
+		data = p.oam[p.oamAddr]
+		if p.oamAddr&0x03 == 0x02 {
+			data &= 0xE3
+		}
+	}
+	if !readOnly {
+		p.refreshLatch(data, 0xFF)
+	}
+	return data
+}
+
+// writeOAMData stores a byte at OAMADDR and advances the address. Writes while rendering are
+// ignored, but bump the sprite index of the address.
+func (p *RP2C02) writeOAMData(data uint8) {
+	if p.renderingEnabled() && p.renderLine() {
+		p.oamAddr += 4
+		return
+	}
+	p.oam[p.oamAddr] = data
+	p.oamAddr++
+}
+
+// readData returns the byte at the current VRAM address. Reads below the palette are
+// buffered: the value returned is the one fetched by the previous read, and the buffer is
+// refilled from the current address. Palette reads are returned immediately, with the top two
+// bits taken from the I/O latch, while the buffer is filled from the nametable "underneath"
+// the palette.
+func (p *RP2C02) readData(readOnly bool) uint8 {
+	addr := p.v & 0x3FFF
+
+	var data uint8
+	if addr >= 0x3F00 {
+		data = p.ioLatch()&0xC0 | p.Bus.Read(addr, readOnly)&0x3F
+		if readOnly {
+			return data
+		}
+		p.refreshLatch(data, 0x3F)
+		p.readBuffer = p.Bus.Read(addr-0x1000, false)
+	} else {
+		data = p.readBuffer
+		if readOnly {
+			return data
+		}
+		p.refreshLatch(data, 0xFF)
+		p.readBuffer = p.Bus.Read(addr, false)
+	}
+
+	p.incrementAddr()
+	return data
+}
+
+// incrementAddr advances the VRAM address by 1 or 32 after a PPUDATA access. While the PPU is
+// rendering, v is the scroll position and the access increments both coarse X and Y instead.
+func (p *RP2C02) incrementAddr() {
+	if p.renderingEnabled() && p.renderLine() {
+		p.incrementX()
+		p.incrementY()
+		return
+	}
+
+	if p.ctrl&CtrlIncrement != 0 {
+		p.v += 32
+	} else {
+		p.v++
+	}
+	p.v &= 0x7FFF
+}
+
+// refreshLatch drives the bits of the I/O latch selected by mask with the bits of data
+func (p *RP2C02) refreshLatch(data uint8, mask uint8) {
+	p.latch = p.latch&^mask | data&mask
+	for i := uint(0); i < 8; i++ {
+		if mask&(1<<i) != 0 {
+			p.latchFrame[i] = p.frame
+		}
+	}
+}
+
+// ioLatch returns the I/O latch with bits that have decayed cleared
+func (p *RP2C02) ioLatch() uint8 {
+	for i := uint(0); i < 8; i++ {
+		if p.frame-p.latchFrame[i] > latchDecayFrames {
+			p.latch &^= 1 << i
+		}
+	}
+	return p.latch
+}
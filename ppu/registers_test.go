@@ -0,0 +1,184 @@
+package ppu
+
+import (
+	"testing"
+)
+
+// TestStatusRead checks that reading PPUSTATUS clears the vblank flag and the write toggle
+func TestStatusRead(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+	p.status = StatusVBlank | StatusSprite0
+	p.w = true
+
+	if data := p.Read(0x2002, false); data != StatusVBlank|StatusSprite0 {
+		t.Errorf("Expected status = %#02x, got %#02x", StatusVBlank|StatusSprite0, data)
+	}
+
+	if p.status&StatusVBlank != 0 {
+		t.Errorf("vblank flag not cleared by read")
+	}
+
+	if p.w {
+		t.Errorf("write toggle not cleared by read")
+	}
+}
+
+// TestStatusOpenBus checks that the low bits of PPUSTATUS come from the I/O latch
+func TestStatusOpenBus(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+	p.Write(0x2000, 0x1F)
+
+	if data := p.Read(0x2002, false); data != 0x1F {
+		t.Errorf("Expected status = %#02x, got %#02x", 0x1F, data)
+	}
+
+	p.frame += latchDecayFrames + 1
+
+	if data := p.Read(0x2001, false); data != 0x00 {
+		t.Errorf("Expected latch to decay, got %#02x", data)
+	}
+}
+
+// TestOpenBusReset checks that the I/O latch keeps its value across a reset
+func TestOpenBusReset(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+	p.frame = 5
+	p.Write(0x2000, 0xA5)
+	p.Reset()
+
+	if data := p.Read(0x2001, false); data != 0xA5 {
+		t.Errorf("Expected the latch to survive a reset, got %#02x", data)
+	}
+}
+
+// TestScroll checks the loopy register updates of two PPUSCROLL writes
+func TestScroll(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+
+	p.Write(0x2005, 0x7D) // coarse X = 15, fine X = 5
+	p.Write(0x2005, 0x5E) // coarse Y = 11, fine Y = 6
+
+	expected := uint16(6<<12 | 11<<5 | 15)
+	if p.t != expected {
+		t.Errorf("Expected t = %#04x, got %#04x", expected, p.t)
+	}
+
+	if p.x != 5 {
+		t.Errorf("Expected x = %d, got %d", 5, p.x)
+	}
+
+	if p.w {
+		t.Errorf("write toggle not reset after second write")
+	}
+}
+
+// TestAddr checks that the second PPUADDR write copies t into v and that both registers share
+// the write toggle with PPUSCROLL
+func TestAddr(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+
+	p.Write(0x2006, 0xFF) // bit 14 is dropped
+	p.Write(0x2006, 0x08)
+
+	if p.v != 0x3F08 {
+		t.Errorf("Expected v = %#04x, got %#04x", 0x3F08, p.v)
+	}
+
+	p.Write(0x2005, 0x00)
+	p.Write(0x2006, 0x24)
+
+	// the PPUSCROLL write leaves the toggle set, so this is taken as the low byte
+	if p.v != 0x3F24 {
+		t.Errorf("PPUADDR did not share write toggle with PPUSCROLL: v = %#04x", p.v)
+	}
+}
+
+// TestDataReadBuffer checks that PPUDATA reads are delayed by the read buffer
+func TestDataReadBuffer(t *testing.T) {
+	b := DevBus{}
+	p := RP2C02{Bus: &b}
+
+	b.ram[0x2400] = 0xCA
+	b.ram[0x2401] = 0xFE
+
+	p.Write(0x2006, 0x24)
+	p.Write(0x2006, 0x00)
+
+	if data := p.Read(0x2007, false); data != 0x00 {
+		t.Errorf("Expected stale buffer %#02x, got %#02x", 0x00, data)
+	}
+
+	if data := p.Read(0x2007, false); data != 0xCA {
+		t.Errorf("Expected %#02x, got %#02x", 0xCA, data)
+	}
+
+	if data := p.Read(0x2007, false); data != 0xFE {
+		t.Errorf("Expected %#02x, got %#02x", 0xFE, data)
+	}
+}
+
+// TestDataPaletteRead checks that palette reads bypass the read buffer, which is filled from
+// the nametable below the palette
+func TestDataPaletteRead(t *testing.T) {
+	b := DevBus{}
+	p := RP2C02{Bus: &b}
+
+	b.ram[0x3F01] = 0x2A
+	b.ram[0x2F01] = 0x55
+
+	p.Write(0x2006, 0x3F)
+	p.Write(0x2006, 0x01)
+
+	if data := p.Read(0x2007, false); data&0x3F != 0x2A {
+		t.Errorf("Expected palette %#02x, got %#02x", 0x2A, data&0x3F)
+	}
+
+	if p.readBuffer != 0x55 {
+		t.Errorf("Expected buffer = %#02x, got %#02x", 0x55, p.readBuffer)
+	}
+}
+
+// TestDataIncrement checks the VRAM address increment selected by PPUCTRL
+func TestDataIncrement(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctrl     uint8
+		expected uint16
+	}{
+		{"across", 0x00, 0x2001},
+		{"down", CtrlIncrement, 0x2020},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RP2C02{Bus: &DevBus{}}
+			p.Write(0x2000, tt.ctrl)
+			p.Write(0x2006, 0x20)
+			p.Write(0x2006, 0x00)
+
+			p.Write(0x2007, 0xAB)
+
+			if p.v != tt.expected {
+				t.Errorf("Expected v = %#04x, got %#04x", tt.expected, p.v)
+			}
+		})
+	}
+}
+
+// TestOAMData checks that OAMDATA writes increment OAMADDR and reads do not
+func TestOAMData(t *testing.T) {
+	p := RP2C02{Bus: &DevBus{}}
+
+	p.Write(0x2003, 0x02)
+	p.Write(0x2004, 0xFF)
+	p.Write(0x2004, 0x10)
+
+	p.Write(0x2003, 0x02)
+	if data := p.Read(0x2004, false); data != 0xE3 {
+		t.Errorf("Expected attribute %#02x, got %#02x", 0xE3, data)
+	}
+
+	if data := p.Read(0x2004, false); data != 0xE3 {
+		t.Errorf("OAMDATA read advanced OAMADDR")
+	}
+}
@@ -0,0 +1,302 @@
+package ppu
+
+// Sprite rendering
+// ----------------
+// OAM holds 64 sprites of 4 bytes each:
+//     - byte 0: Y position of the top of the sprite, minus 1
+//     - byte 1: tile index. For 8x16 sprites bit 0 selects the pattern table.
+//     - byte 2: attributes
+//           76543210
+//           |||   ++- palette (4 to 7)
+//           ||+------ priority (0: in front of background, 1: behind background)
+//           |+------- flip horizontally
+//           +-------- flip vertically
+//     - byte 3: X position of the left of the sprite
+//
+// On every visible scanline the PPU finds the sprites on the next scanline and copies up to 8
+// of them into the 32 bytes of secondary OAM:
+//     - dots 1-64: secondary OAM is cleared to 0xFF
+//     - dots 65-256: sprite evaluation. OAM is read on odd dots and secondary OAM written on
+//       even dots.
+//     - dots 257-320: the pattern data of the sprites in secondary OAM is fetched
+//
+// Once 8 sprites have been found the PPU keeps scanning for a ninth to set the overflow flag,
+// but it increments the byte index along with the sprite index, so it checks a diagonal of
+// bytes across the remaining sprites instead of their Y positions. This produces both false
+// positives and false negatives.
+
+const (
+	attrPalette  uint8 = 0x03
+	attrPriority uint8 = 0x20
+	attrFlipH    uint8 = 0x40
+	attrFlipV    uint8 = 0x80
+)
+
+// sprite is a sprite fetched for the current scanline
+type sprite struct {
+	x    uint8
+	attr uint8
+	lo   uint8 // pattern bit planes, already flipped horizontally if required
+	hi   uint8
+	zero bool // the sprite was the first one evaluated, which is used for sprite 0 hit
+}
+
+// spriteHeight returns 8 or 16 depending on the sprite size in PPUCTRL
+func (p *RP2C02) spriteHeight() int {
+	if p.ctrl&CtrlSpriteSize != 0 {
+		return 16
+	}
+	return 8
+}
+
+// inRange returns true if a sprite with the given Y position is on the scanline after the
+// current one
+func (p *RP2C02) inRange(y uint8) bool {
+	row := p.scanline - int(y)
+	return row >= 0 && row < p.spriteHeight()
+}
+
+// renderSprites performs the sprite evaluation and fetches for the current dot of a visible
+// or pre-render scanline
+func (p *RP2C02) renderSprites() {
+	switch {
+	case p.dot >= 1 && p.dot <= 64:
+		p.oamLatch = 0xFF
+		if p.dot&1 == 0 {
+			p.secondaryOAM[(p.dot-1)>>1] = 0xFF
+		}
+	case p.dot >= 65 && p.dot <= 256:
+		if p.scanline != p.preRenderScanline() {
+			p.evaluateSprites()
+		}
+	case p.dot >= 257 && p.dot <= 320:
+		p.oamAddr = 0
+		p.fetchSprites()
+	default:
+		p.oamLatch = p.secondaryOAM[0]
+	}
+}
+
+// evaluateSprites performs one dot of sprite evaluation
+func (p *RP2C02) evaluateSprites() {
+	if p.dot == 65 {
+		p.evalN = int(p.oamAddr >> 2)
+		p.evalM = int(p.oamAddr & 0x03)
+		p.evalStart = p.evalN
+		p.evalCount = 0
+		p.evalDone = false
+		p.evalZero = false
+	}
+
+	// odd dots read from OAM
+	if p.dot&1 == 1 {
+		p.oamLatch = p.oam[p.evalN<<2|p.evalM]
+		return
+	}
+
+	// even dots write to secondary OAM, or only read it once it is full
+	if p.evalDone {
+		p.evalN = (p.evalN + 1) & 0x3F
+		return
+	}
+
+	if p.evalCount < 8 {
+		p.secondaryOAM[p.evalCount<<2|p.evalM] = p.oamLatch
+
+		if p.evalM == 0 && !p.inRange(p.oamLatch) {
+			p.nextSprite()
+			return
+		}
+
+		if p.evalM == 0 && p.evalN == p.evalStart {
+			p.evalZero = true
+		}
+
+		p.evalM++
+		if p.evalM == 4 {
+			p.evalM = 0
+			p.evalCount++
+			p.nextSprite()
+		}
+		return
+	}
+
+	// Secondary OAM is full. The byte read is treated as a Y coordinate whatever it is,
+	// and a miss increments both the sprite and byte indexes.
+	if p.inRange(p.oamLatch) {
+		p.status |= StatusOverflow
+		p.evalDone = true
+		return
+	}
+
+	p.evalM = (p.evalM + 1) & 0x03
+	p.nextSprite()
+}
+
+// nextSprite moves evaluation to the next sprite in OAM, ending it after the last one
+func (p *RP2C02) nextSprite() {
+	p.evalN = (p.evalN + 1) & 0x3F
+	if p.evalN == p.evalStart {
+		p.evalDone = true
+	}
+}
+
+// fetchSprites performs one dot of the sprite pattern fetches. Each of the 8 slots takes 8
+// dots: two garbage nametable fetches followed by the two pattern bytes. Empty slots fetch
+// tile 0xFF and are discarded.
+func (p *RP2C02) fetchSprites() {
+	slot := (p.dot - 257) >> 3
+	p.oamLatch = p.secondaryOAM[slot<<2|min((p.dot-257)&0x07, 3)]
+
+	if p.dot == 257 {
+		p.spriteCount = p.evalCount
+		p.spriteZero = p.evalZero
+		if p.scanline == p.preRenderScanline() {
+			p.spriteCount = 0
+			p.spriteZero = false
+		}
+		p.sprites = p.sprites[:0]
+	}
+
+	switch (p.dot - 257) & 0x07 {
+	case 5:
+		p.fetchLo = p.Bus.Read(p.spritePatternAddr(slot), false)
+	case 7:
+		p.fetchHi = p.Bus.Read(p.spritePatternAddr(slot)+8, false)
+		if slot < p.spriteCount {
+			p.addSprite(p.secondaryOAM[slot<<2:slot<<2+4], slot == 0 && p.spriteZero)
+		}
+	}
+
+	// With the sprite limit disabled, sprites beyond the eighth on the scanline are fetched
+	// all at once from OAM at the end of the sprite fetches
+	if p.dot == 320 && p.DisableSpriteLimit && p.spriteCount == 8 && p.scanline != p.preRenderScanline() {
+		found := 0
+		for n := 0; n < 64; n++ {
+			entry := p.oam[n<<2 : n<<2+4]
+			if !p.inRange(entry[0]) {
+				continue
+			}
+			found++
+			if found <= 8 {
+				continue
+			}
+			p.fetchLo = p.Bus.Read(p.patternAddr(entry), false)
+			p.fetchHi = p.Bus.Read(p.patternAddr(entry)+8, false)
+			p.addSprite(entry, false)
+		}
+	}
+}
+
+// spritePatternAddr returns the address of the low bit plane of the sprite in a secondary OAM
+// slot, or of tile 0xFF for an empty slot
+func (p *RP2C02) spritePatternAddr(slot int) uint16 {
+	if slot >= p.spriteCount {
+		return p.patternAddr([]uint8{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	return p.patternAddr(p.secondaryOAM[slot<<2 : slot<<2+4])
+}
+
+// patternAddr returns the address of the low bit plane of the row of a sprite that is drawn
+// on the next scanline
+func (p *RP2C02) patternAddr(entry []uint8) uint16 {
+	row := uint16(p.scanline-int(entry[0])) & 0x0F
+	tile := uint16(entry[1])
+	attr := entry[2]
+
+	if p.ctrl&CtrlSpriteSize == 0 {
+		if attr&attrFlipV != 0 {
+			row = 7 - row
+		}
+		var table uint16
+		if p.ctrl&CtrlSpriteTable != 0 {
+			table = 0x1000
+		}
+		return table | tile<<4 | row&0x07
+	}
+
+	// 8x16 sprites take the pattern table from bit 0 of the tile index, and the top and
+	// bottom halves from consecutive tiles
+	if attr&attrFlipV != 0 {
+		row = 15 - row
+	}
+	table := (tile & 0x01) << 12
+	tile &= 0xFE
+	if row >= 8 {
+		tile++
+	}
+	return table | tile<<4 | row&0x07
+}
+
+// addSprite adds a sprite to the list drawn on the next scanline using the last fetched
+// pattern bytes
+func (p *RP2C02) addSprite(entry []uint8, zero bool) {
+	s := sprite{x: entry[3], attr: entry[2], lo: p.fetchLo, hi: p.fetchHi, zero: zero}
+	if s.attr&attrFlipH != 0 {
+		s.lo = reverse(s.lo)
+		s.hi = reverse(s.hi)
+	}
+	p.sprites = append(p.sprites, s)
+}
+
+// spritePixel returns the sprite pixel at the current dot: its 2-bit value, palette (4-7),
+// whether it is behind the background and whether it belongs to sprite 0. The first opaque
+// sprite in OAM order wins.
+func (p *RP2C02) spritePixel() (pixel uint8, palette uint8, behind bool, zero bool) {
+	if p.mask&MaskSprites == 0 || (p.dot <= 8 && p.mask&MaskSpriteLeft == 0) {
+		return 0, 0, false, false
+	}
+
+	x := p.dot - 1
+	for _, s := range p.sprites {
+		offset := x - int(s.x)
+		if offset < 0 || offset > 7 {
+			continue
+		}
+
+		shift := 7 - uint(offset)
+		pixel = (s.lo>>shift)&0x01 | ((s.hi>>shift)&0x01)<<1
+		if pixel != 0 {
+			return pixel, s.attr&attrPalette + 4, s.attr&attrPriority != 0, s.zero
+		}
+	}
+	return 0, 0, false, false
+}
+
+// reverse reverses the bits of a byte
+func reverse(b uint8) uint8 {
+	b = (b&0xF0)>>4 | (b&0x0F)<<4
+	b = (b&0xCC)>>2 | (b&0x33)<<2
+	b = (b&0xAA)>>1 | (b&0x55)<<1
+	return b
+}
+
+// corruptOAM records the OAM row that is corrupted when rendering is disabled on the current
+// dot. The PPU is in the middle of an OAM access; when rendering resumes, the row it was
+// accessing is overwritten with the first row of OAM.
+func (p *RP2C02) corruptOAM() {
+	switch {
+	case p.dot < 64:
+		// clearing secondary OAM moves to the next row every two dots
+		p.corruptRows[p.dot>>1] = true
+	case p.dot >= 256 && p.dot < 320:
+		// each sprite fetch advances the row for the first three dots and then holds it
+		base := (p.dot - 256) >> 3
+		offset := min((p.dot-256)&0x07, 3)
+		p.corruptRows[base*4+offset] = true
+	default:
+		return
+	}
+	p.oamCorrupt = true
+}
+
+// processOAMCorruption copies the first 8 bytes of OAM over every corrupted row
+func (p *RP2C02) processOAMCorruption() {
+	p.oamCorrupt = false
+	for row, corrupt := range p.corruptRows {
+		if corrupt {
+			copy(p.oam[row*8:row*8+8], p.oam[:8])
+			p.corruptRows[row] = false
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package ppu
+
+import (
+	"testing"
+)
+
+// setupSprites returns a PPU whose tile 1 is a solid 8x8 block of color 1 and whose palettes
+// are filled with distinct colors
+func setupSprites() (*RP2C02, *DevBus) {
+	b := DevBus{}
+	p := RP2C02{Bus: &b}
+
+	for row := uint16(0); row < 8; row++ {
+		b.ram[0x0010+row] = 0xFF
+	}
+	for i := uint16(0); i < 32; i++ {
+		b.ram[0x3F00+i] = uint8(i)
+	}
+	// hide every sprite below the picture
+	for i := range p.oam {
+		p.oam[i] = 0xFF
+	}
+	return &p, &b
+}
+
+// runFrame clocks the PPU until the next frame starts
+func runFrame(p *RP2C02) {
+	frame := p.frame
+	for p.frame == frame {
+		p.Clock()
+	}
+}
+
+// TestSpriteRender checks that a sprite is drawn one scanline below its Y position with its
+// palette
+func TestSpriteRender(t *testing.T) {
+	p, _ := setupSprites()
+	copy(p.oam[:], []uint8{19, 0x01, 0x02, 40})
+	p.mask = MaskSprites | MaskSpriteLeft
+
+	runFrame(p)
+
+	if c := p.Frame()[20*Width+40]; c != 0x19 {
+		t.Errorf("Expected %#02x, got %#02x", 0x19, c)
+	}
+
+	if c := p.Frame()[19*Width+40]; c != 0x00 {
+		t.Errorf("Sprite drawn above its position: %#02x", c)
+	}
+
+	if c := p.Frame()[20*Width+48]; c != 0x00 {
+		t.Errorf("Sprite drawn wider than 8 pixels: %#02x", c)
+	}
+}
+
+// TestSpriteOverflow checks that a ninth sprite on a scanline sets the overflow flag and is not
+// drawn unless the sprite limit is disabled
+func TestSpriteOverflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		noLimit bool
+		color   uint16
+	}{
+		{"limit", false, 0x00},
+		{"no limit", true, 0x11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := setupSprites()
+			p.DisableSpriteLimit = tt.noLimit
+			for n := 0; n < 9; n++ {
+				copy(p.oam[n*4:], []uint8{29, 0x01, 0x00, uint8(n * 16)})
+			}
+			p.mask = MaskSprites | MaskSpriteLeft
+
+			runTo(p, 31, 0)
+
+			if p.status&StatusOverflow == 0 {
+				t.Errorf("Overflow flag not set")
+			}
+
+			if c := p.Frame()[30*Width+7*16]; c != 0x11 {
+				t.Errorf("Eighth sprite not drawn: %#02x", c)
+			}
+
+			if c := p.Frame()[30*Width+8*16]; c != tt.color {
+				t.Errorf("Expected ninth sprite %#02x, got %#02x", tt.color, c)
+			}
+		})
+	}
+}
+
+// TestSpriteOverflowBug checks that the overflow scan steps diagonally through OAM and misses
+// a ninth sprite that is on the scanline
+func TestSpriteOverflowBug(t *testing.T) {
+	p, _ := setupSprites()
+	for n := 0; n < 8; n++ {
+		copy(p.oam[n*4:], []uint8{29, 0x01, 0x00, uint8(n * 16)})
+	}
+	// sprite 9 is on the scanline, but sprite 8 misses so sprite 9 is checked at its tile
+	// index instead of its Y position
+	copy(p.oam[8*4:], []uint8{0xFF, 0xFF, 0xFF, 0xFF})
+	copy(p.oam[9*4:], []uint8{29, 0xFF, 0x00, 0x00})
+	p.mask = MaskSprites
+
+	runTo(p, 31, 0)
+
+	if p.status&StatusOverflow != 0 {
+		t.Errorf("Overflow flag set for a sprite checked at the wrong byte")
+	}
+
+	// a tile index that looks like a Y position on the scanline is a false positive
+	p, _ = setupSprites()
+	for n := 0; n < 8; n++ {
+		copy(p.oam[n*4:], []uint8{29, 0x01, 0x00, uint8(n * 16)})
+	}
+	copy(p.oam[9*4:], []uint8{0xFF, 28, 0xFF, 0xFF})
+	p.mask = MaskSprites
+
+	runTo(p, 31, 0)
+
+	if p.status&StatusOverflow == 0 {
+		t.Errorf("Overflow flag not set by false positive")
+	}
+}
+
+// TestSpriteZeroHit checks that sprite 0 hit is flagged where sprite 0 overlaps the background
+// but not in the clipped left column
+func TestSpriteZeroHit(t *testing.T) {
+	tests := []struct {
+		name string
+		x    uint8
+		mask uint8
+		hit  bool
+	}{
+		{"hit", 100, MaskBg | MaskSprites | MaskBgLeft | MaskSpriteLeft, true},
+		{"left column clipped", 0, MaskBg | MaskSprites | MaskSpriteLeft, false},
+		{"left column", 0, MaskBg | MaskSprites | MaskBgLeft | MaskSpriteLeft, true},
+		{"background disabled", 100, MaskSprites | MaskSpriteLeft, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, b := setupSprites()
+			// fill the first nametable with tile 1
+			for i := uint16(0); i < 960; i++ {
+				b.ram[0x2000+i] = 0x01
+			}
+			copy(p.oam[:], []uint8{50, 0x01, 0x00, tt.x})
+			p.mask = tt.mask
+
+			runTo(p, 60, 0)
+
+			if hit := p.status&StatusSprite0 != 0; hit != tt.hit {
+				t.Errorf("Expected hit = %t, got %t", tt.hit, hit)
+			}
+		})
+	}
+}
+
+// TestSpritePatternAddr checks the pattern addresses of flipped and 8x16 sprites
+func TestSpritePatternAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctrl     uint8
+		entry    []uint8
+		expected uint16
+	}{
+		{"8x8", 0x00, []uint8{10, 0x21, 0x00, 0}, 0x0212},
+		{"8x8 table", CtrlSpriteTable, []uint8{10, 0x21, 0x00, 0}, 0x1212},
+		{"8x8 flipped", 0x00, []uint8{10, 0x21, attrFlipV, 0}, 0x0215},
+		{"8x16 top", CtrlSpriteSize, []uint8{10, 0x21, 0x00, 0}, 0x1202},
+		{"8x16 bottom", CtrlSpriteSize, []uint8{2, 0x20, 0x00, 0}, 0x0212},
+		{"8x16 flipped", CtrlSpriteSize, []uint8{2, 0x20, attrFlipV, 0}, 0x0205},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RP2C02{}
+			p.ctrl = tt.ctrl
+			p.scanline = 12
+
+			if addr := p.patternAddr(tt.entry); addr != tt.expected {
+				t.Errorf("Expected %#04x, got %#04x", tt.expected, addr)
+			}
+		})
+	}
+}
+
+// TestSpriteFlipH checks horizontal flipping and background priority
+func TestSpriteFlipH(t *testing.T) {
+	p, b := setupSprites()
+	// tile 2 has only its leftmost column set
+	for row := uint16(0); row < 8; row++ {
+		b.ram[0x0020+row] = 0x80
+	}
+	copy(p.oam[:], []uint8{9, 0x02, attrFlipH, 0})
+	copy(p.oam[4:], []uint8{9, 0x02, 0x00, 16})
+	p.mask = MaskSprites | MaskSpriteLeft
+
+	runTo(p, 11, 0)
+
+	if c := p.Frame()[10*Width+7]; c != 0x11 {
+		t.Errorf("Flipped sprite not drawn at its right edge: %#02x", c)
+	}
+
+	if c := p.Frame()[10*Width+16]; c != 0x11 {
+		t.Errorf("Sprite not drawn at its left edge: %#02x", c)
+	}
+}
+
+// TestOAMCorruption checks that disabling rendering during the secondary OAM clear corrupts
+// the row being accessed when rendering resumes
+func TestOAMCorruption(t *testing.T) {
+	p, _ := setupSprites()
+	for i := 0; i < 8; i++ {
+		p.oam[i] = uint8(i)
+	}
+	p.mask = MaskBg
+
+	runTo(p, 10, 20)
+	p.Write(0x2001, 0x00)
+	runTo(p, 11, 0)
+	p.Write(0x2001, MaskBg)
+	p.Clock()
+
+	for i := 0; i < 8; i++ {
+		if p.oam[10*8+i] != uint8(i) {
+			t.Errorf("Expected row 10 to be a copy of row 0, got %v", p.oam[10*8:11*8])
+			break
+		}
+	}
+
+	if p.oam[11*8] != 0xFF {
+		t.Errorf("Row 11 was corrupted")
+	}
+}
@@ -0,0 +1,119 @@
+package rom
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Archives
+// --------
+// ROM sets are often stored compressed. Extract recognizes the format from the first bytes of
+// the data rather than the file name:
+//     - gzip: the single compressed file
+//     - zip: the entry with the requested name or, without one, the only entry with a .nes,
+//       .fds, .nsf or .nsfe extension. Several such entries are ambiguous, and a name must be
+//       given to choose between them.
+//
+// Anything else is returned unchanged, so that uncompressed files can be passed through the
+// same path.
+
+var (
+	gzipMagic = []byte{0x1F, 0x8B}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// romExtensions are the extensions of the files that can be loaded
+var romExtensions = []string{".nes", ".fds", ".nsf", ".nsfe"}
+
+// Extract returns the contents of a file and its name if it is a zip or gzip archive, or data
+// itself with an empty name otherwise. name selects an entry of a zip archive, or may be empty
+// to choose the only loadable one.
+func Extract(data []byte, name string) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return extractGzip(data)
+	case bytes.HasPrefix(data, zipMagic):
+		return extractZip(data, name)
+	}
+	return data, "", nil
+}
+
+// extractGzip decompresses gzip data
+func extractGzip(data []byte) ([]byte, string, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("rom: %w", err)
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("rom: %w", err)
+	}
+	return out, r.Name, nil
+}
+
+// extractZip decompresses an entry of a zip archive
+func extractZip(data []byte, name string) ([]byte, string, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", fmt.Errorf("rom: %w", err)
+	}
+
+	var candidates []*zip.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if name != "" {
+			if f.Name == name || path.Base(f.Name) == name {
+				candidates = append(candidates, f)
+			}
+		} else if isROM(f.Name) {
+			candidates = append(candidates, f)
+		}
+	}
+
+	switch {
+	case len(candidates) == 0 && name != "":
+		return nil, "", fmt.Errorf("rom: no file %q in archive", name)
+	case len(candidates) == 0:
+		return nil, "", errors.New("rom: no .nes, .fds, .nsf or .nsfe file in archive")
+	case len(candidates) > 1:
+		names := make([]string, len(candidates))
+		for i, f := range candidates {
+			names[i] = f.Name
+		}
+		return nil, "", fmt.Errorf("rom: archive holds several files to choose from: %s", strings.Join(names, ", "))
+	}
+
+	f := candidates[0]
+	rc, err := f.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("rom: %w", err)
+	}
+	defer rc.Close()
+
+	out, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, "", fmt.Errorf("rom: %w", err)
+	}
+	return out, f.Name, nil
+}
+
+// isROM returns whether a file name has the extension of a loadable file
+func isROM(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range romExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
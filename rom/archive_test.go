@@ -0,0 +1,85 @@
+package rom
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+// zipOf returns a zip archive of files, given as name and contents pairs
+func zipOf(t *testing.T, files ...string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		f, err := w.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(files[i+1]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestExtractGzip checks that gzip data is decompressed with its name
+func TestExtractGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Name = "game.nes"
+	w.Write([]byte("NES\x1A"))
+	w.Close()
+
+	data, name, err := Extract(buf.Bytes(), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "NES\x1A" || name != "game.nes" {
+		t.Errorf("Expected game.nes, got %q from %q", data, name)
+	}
+}
+
+// TestExtractZip checks the choice of the entry of a zip archive
+func TestExtractZip(t *testing.T) {
+	archive := zipOf(t, "readme.txt", "text", "roms/game.nes", "game")
+
+	data, name, err := Extract(archive, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "game" || name != "roms/game.nes" {
+		t.Errorf("Expected the only ROM, got %q from %q", data, name)
+	}
+
+	if data, _, err := Extract(archive, "readme.txt"); err != nil || string(data) != "text" {
+		t.Errorf("Expected the named file, got %q (%v)", data, err)
+	}
+	if _, _, err := Extract(archive, "other.nes"); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+// TestExtractZipErrors checks archives without a single ROM
+func TestExtractZipErrors(t *testing.T) {
+	if _, _, err := Extract(zipOf(t, "readme.txt", "text"), ""); err == nil {
+		t.Errorf("Expected an error for an archive without a ROM")
+	}
+
+	archive := zipOf(t, "a.nes", "a", "b.NSF", "b")
+	if _, _, err := Extract(archive, ""); err == nil {
+		t.Errorf("Expected an error for an archive with two ROMs")
+	}
+	if data, _, err := Extract(archive, "b.NSF"); err != nil || string(data) != "b" {
+		t.Errorf("Expected the named ROM, got %q (%v)", data, err)
+	}
+}
+
+// TestExtractUncompressed checks that other data is passed through
+func TestExtractUncompressed(t *testing.T) {
+	data, name, err := Extract([]byte("NES\x1A"), "")
+	if err != nil || string(data) != "NES\x1A" || name != "" {
+		t.Errorf("Expected the data unchanged, got %q from %q (%v)", data, name, err)
+	}
+}
@@ -0,0 +1,38 @@
+package rom
+
+import (
+	"fmt"
+	"os"
+)
+
+// Load reads a ROM file, extracting it if it is a zip or gzip archive (see Extract), and
+// applies the patch found next to it by FindPatch, if any. entry selects an entry of a zip
+// archive, or may be empty to choose the only loadable one.
+func Load(path string, entry string) ([]byte, error) {
+	return LoadPatched(path, entry, FindPatch(path))
+}
+
+// LoadPatched reads a ROM file like Load, but applies the patch at patchPath instead of looking
+// for one, or none if patchPath is empty. The files are not modified.
+func LoadPatched(path string, entry string, patchPath string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rom, _, err := Extract(data, entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+	if patchPath == "" {
+		return rom, nil
+	}
+
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return nil, err
+	}
+	if rom, err = Patch(rom, patch); err != nil {
+		return nil, fmt.Errorf("%w, applying %s", err, patchPath)
+	}
+	return rom, nil
+}
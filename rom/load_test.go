@@ -0,0 +1,57 @@
+package rom
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoad checks that archives are extracted transparently
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("NES\x1A"))
+	w.Close()
+	gz := filepath.Join(dir, "game.nes.gz")
+	if err := os.WriteFile(gz, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Load(gz, ""); err != nil || string(data) != "NES\x1A" {
+		t.Errorf("Expected the gzip contents, got %q (%v)", data, err)
+	}
+
+	archive := filepath.Join(dir, "set.zip")
+	if err := os.WriteFile(archive, zipOf(t, "a.nes", "a", "b.nes", "b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Load(archive, "b.nes"); err != nil || string(data) != "b" {
+		t.Errorf("Expected the named entry, got %q (%v)", data, err)
+	}
+	if _, err := Load(archive, ""); err == nil {
+		t.Errorf("Expected an error for an ambiguous archive")
+	}
+}
+
+// TestLoadPatch checks that a patch next to a ROM is applied in memory
+func TestLoadPatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "game.zip")
+	if err := os.WriteFile(path, zipOf(t, "game.nes", "ABCD"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	patch := append([]byte("PATCH\x00\x00\x01\x00\x01x"), "EOF"...)
+	if err := os.WriteFile(filepath.Join(dir, "game.ips"), patch, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := Load(path, ""); err != nil || string(data) != "AxCD" {
+		t.Errorf("Expected the patched ROM, got %q (%v)", data, err)
+	}
+	if data, err := LoadPatched(path, "", ""); err != nil || string(data) != "ABCD" {
+		t.Errorf("Expected the ROM without a patch, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,371 @@
+package rom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Patches
+// -------
+// Translations and hacks are distributed as patches to the original ROM, which are applied in
+// memory so that the ROM file is never modified. Three formats are supported:
+//     - IPS: records of bytes to write at 24-bit offsets, with run-length encoded records and
+//       the extension that truncates the output to a length given after the EOF marker
+//     - UPS: the ROM XORed with the target, in runs separated by skipped bytes, with the sizes
+//       and CRC32s of both
+//     - BPS: the target built by copying from the source, the patch or the target itself, with
+//       the sizes and CRC32s of both
+//
+// The CRC32s of UPS and BPS patches are checked, so that a patch for another ROM is rejected
+// rather than producing garbage. IPS patches can also be created, which is how the changes the
+// games make to FDS disks are saved.
+
+var (
+	ipsMagic = []byte("PATCH")
+	upsMagic = []byte("UPS1")
+	bpsMagic = []byte("BPS1")
+)
+
+// patchExtensions are the extensions FindPatch looks for, in order
+var patchExtensions = []string{".ips", ".bps", ".ups"}
+
+// Patch applies an IPS, UPS or BPS patch to a ROM, recognized by its first bytes, and returns
+// the patched copy
+func Patch(rom []byte, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsMagic):
+		return ApplyIPS(rom, patch)
+	case bytes.HasPrefix(patch, upsMagic):
+		return ApplyUPS(rom, patch)
+	case bytes.HasPrefix(patch, bpsMagic):
+		return ApplyBPS(rom, patch)
+	}
+	return nil, errors.New("rom: not an IPS, UPS or BPS patch")
+}
+
+// FindPatch returns the path of a patch with the same name as a ROM next to it, such as
+// game.ips for game.nes, game.zip or game.nes.gz, or an empty string if there is none
+func FindPatch(romPath string) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	if ext := strings.ToLower(filepath.Ext(romPath)); (ext == ".gz" || ext == ".zip") && isROM(base) {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	for _, ext := range patchExtensions {
+		if info, err := os.Stat(base + ext); err == nil && !info.IsDir() {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+var errTruncated = errors.New("rom: truncated patch")
+
+// ApplyIPS applies an IPS patch to a ROM and returns the patched copy
+func ApplyIPS(rom []byte, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, ipsMagic) {
+		return nil, errors.New("rom: not an IPS patch")
+	}
+	out := append([]byte(nil), rom...)
+
+	// write grows out as needed to write data at offset
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	p := patch[len(ipsMagic):]
+	for {
+		if len(p) < 3 {
+			return nil, errTruncated
+		}
+		if string(p[:3]) == "EOF" {
+			p = p[3:]
+			break
+		}
+		if len(p) < 5 {
+			return nil, errTruncated
+		}
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(binary.BigEndian.Uint16(p[3:]))
+		p = p[5:]
+
+		if size > 0 {
+			if len(p) < size {
+				return nil, errTruncated
+			}
+			write(offset, p[:size])
+			p = p[size:]
+		} else {
+			// run-length encoded: a 16-bit count and the byte to repeat
+			if len(p) < 3 {
+				return nil, errTruncated
+			}
+			count := int(binary.BigEndian.Uint16(p))
+			write(offset, bytes.Repeat(p[2:3], count))
+			p = p[3:]
+		}
+	}
+
+	// truncation extension
+	if len(p) >= 3 {
+		size := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		if size < len(out) {
+			out = out[:size]
+		}
+	}
+	return out, nil
+}
+
+// ipsEOF is the offset that cannot start an IPS record, as it reads as the EOF marker
+const ipsEOF = 0x454F46
+
+// CreateIPS returns an IPS patch turning original into modified, or nil if they are the same.
+// A shorter modified is recorded with the truncation extension. Both must be under 16MB.
+func CreateIPS(original []byte, modified []byte) []byte {
+	if bytes.Equal(original, modified) {
+		return nil
+	}
+	patch := append([]byte(nil), ipsMagic...)
+	for i := 0; i < len(modified); {
+		if i < len(original) && original[i] == modified[i] {
+			i++
+			continue
+		}
+		start := i
+		if start == ipsEOF {
+			start--
+		}
+		end := i
+		for end < len(modified) && end-start < 0xFFFF &&
+			(end >= len(original) || original[end] != modified[end]) {
+			end++
+		}
+		patch = append(patch, byte(start>>16), byte(start>>8), byte(start))
+		patch = binary.BigEndian.AppendUint16(patch, uint16(end-start))
+		patch = append(patch, modified[start:end]...)
+		i = end
+	}
+	patch = append(patch, "EOF"...)
+	if len(modified) < len(original) {
+		patch = append(patch, byte(len(modified)>>16), byte(len(modified)>>8), byte(len(modified)))
+	}
+	return patch
+}
+
+// patchReader reads the variable length numbers and bytes of a UPS or BPS patch, up to the
+// 12 byte footer
+type patchReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// byte returns the next byte, or 0 once the data has run out
+func (r *patchReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = errTruncated
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+// number decodes a variable length number: 7 bits per byte from the least significant, the
+// last byte flagged by bit 7, with each continuation adding one to the next 7 bits so that
+// every number has a single encoding
+func (r *patchReader) number() int {
+	n, shift := 0, 1
+	for r.err == nil {
+		b := r.byte()
+		n += int(b&0x7F) * shift
+		if b&0x80 != 0 {
+			break
+		}
+		shift <<= 7
+		n += shift
+		if shift > 1<<42 {
+			r.err = errors.New("rom: number too large in patch")
+		}
+	}
+	return n
+}
+
+// done returns whether only the footer is left
+func (r *patchReader) done() bool {
+	return r.pos >= len(r.data)
+}
+
+// footer checks the CRC32 of the patch itself and returns those of the source and target, in
+// the last 12 bytes of the patch
+func footer(patch []byte) (source uint32, target uint32, err error) {
+	n := len(patch) - 12
+	le := binary.LittleEndian
+	if crc := crc32.ChecksumIEEE(patch[:n+8]); crc != le.Uint32(patch[n+8:]) {
+		return 0, 0, errors.New("rom: patch is corrupt, its CRC32 does not match")
+	}
+	return le.Uint32(patch[n:]), le.Uint32(patch[n+4:]), nil
+}
+
+// checkCRC returns an error if the CRC32 of the source or target of a patch is not as expected
+func checkCRC(what string, data []byte, expected uint32) error {
+	if crc := crc32.ChecksumIEEE(data); crc != expected {
+		return fmt.Errorf("rom: %s CRC32 is %08X, the patch expects %08X", what, crc, expected)
+	}
+	return nil
+}
+
+// ApplyUPS applies a UPS patch to a ROM and returns the patched copy
+func ApplyUPS(rom []byte, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, upsMagic) || len(patch) < len(upsMagic)+12 {
+		return nil, errors.New("rom: not a UPS patch")
+	}
+	sourceCRC, targetCRC, err := footer(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCRC("source", rom, sourceCRC); err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: patch[len(upsMagic) : len(patch)-12]}
+	sourceSize := r.number()
+	targetSize := r.number()
+	if r.err == nil && sourceSize != len(rom) {
+		return nil, fmt.Errorf("rom: ROM is %d bytes, the patch expects %d", len(rom), sourceSize)
+	}
+	if targetSize > 1<<28 {
+		return nil, fmt.Errorf("rom: patch target of %d bytes is too large", targetSize)
+	}
+
+	out := make([]byte, targetSize)
+	copy(out, rom)
+	offset := 0
+	for r.err == nil && !r.done() {
+		offset += r.number()
+		for r.err == nil {
+			b := r.byte()
+			if b == 0 {
+				offset++
+				break
+			}
+			if offset < len(out) {
+				out[offset] ^= b
+			}
+			offset++
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := checkCRC("patched ROM", out, targetCRC); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BPS actions
+const (
+	bpsSourceRead = iota // copy from the source at the same offset
+	bpsTargetRead        // copy from the patch
+	bpsSourceCopy        // copy from a relative offset in the source
+	bpsTargetCopy        // copy from a relative offset in the target, which may overlap
+)
+
+// ApplyBPS applies a BPS patch to a ROM and returns the patched copy
+func ApplyBPS(rom []byte, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, bpsMagic) || len(patch) < len(bpsMagic)+12 {
+		return nil, errors.New("rom: not a BPS patch")
+	}
+	sourceCRC, targetCRC, err := footer(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCRC("source", rom, sourceCRC); err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: patch[len(bpsMagic) : len(patch)-12]}
+	sourceSize := r.number()
+	targetSize := r.number()
+	r.pos += r.number() // metadata
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != len(rom) {
+		return nil, fmt.Errorf("rom: ROM is %d bytes, the patch expects %d", len(rom), sourceSize)
+	}
+	if targetSize > 1<<28 {
+		return nil, fmt.Errorf("rom: patch target of %d bytes is too large", targetSize)
+	}
+
+	out := make([]byte, 0, targetSize)
+	sourceOffset, targetOffset := 0, 0
+	for r.err == nil && !r.done() {
+		action := r.number()
+		length := action>>2 + 1
+		if len(out)+length > targetSize {
+			return nil, errors.New("rom: patch writes past the end of the target")
+		}
+
+		switch action & 3 {
+		case bpsSourceRead:
+			if len(out)+length > len(rom) {
+				return nil, errors.New("rom: patch reads past the end of the ROM")
+			}
+			out = append(out, rom[len(out):len(out)+length]...)
+		case bpsTargetRead:
+			if r.pos+length > len(r.data) {
+				return nil, errTruncated
+			}
+			out = append(out, r.data[r.pos:r.pos+length]...)
+			r.pos += length
+		case bpsSourceCopy:
+			sourceOffset += relative(r.number())
+			if sourceOffset < 0 || sourceOffset+length > len(rom) {
+				return nil, errors.New("rom: patch reads past the end of the ROM")
+			}
+			out = append(out, rom[sourceOffset:sourceOffset+length]...)
+			sourceOffset += length
+		case bpsTargetCopy:
+			targetOffset += relative(r.number())
+			if targetOffset < 0 || targetOffset >= len(out) {
+				return nil, errors.New("rom: patch copies from outside the target")
+			}
+			// byte by byte, as the copy may overlap what it writes
+			for i := 0; i < length; i++ {
+				out = append(out, out[targetOffset])
+				targetOffset++
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(out) != targetSize {
+		return nil, fmt.Errorf("rom: patch produced %d bytes, expected %d", len(out), targetSize)
+	}
+
+	if err := checkCRC("patched ROM", out, targetCRC); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// relative decodes the offset of a BPS copy: the magnitude shifted left by one, with the sign
+// in bit 0
+func relative(n int) int {
+	if n&1 != 0 {
+		return -(n >> 1)
+	}
+	return n >> 1
+}
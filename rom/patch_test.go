@@ -0,0 +1,177 @@
+package rom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// number encodes a variable length number of a UPS or BPS patch
+func number(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(out, 0x80|b)
+		}
+		out = append(out, b)
+		n--
+	}
+}
+
+// withFooter appends the CRC32s of the source, the target and the patch to a patch
+func withFooter(patch []byte, source []byte, target []byte) []byte {
+	le := binary.LittleEndian
+	patch = le.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = le.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return le.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+// TestIPS checks plain and run-length encoded records and the truncation extension
+func TestIPS(t *testing.T) {
+	rom := []byte("ABCDEFGH")
+	patch := []byte("PATCH")
+	patch = append(patch, 0x00, 0x00, 0x01, 0x00, 0x02, 'x', 'y')        // "xy" at 1
+	patch = append(patch, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x04, 'z') // 4 'z' at 6
+	patch = append(patch, "EOF"...)
+
+	out, err := Patch(rom, patch)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(out) != "AxyDEFzzzz" {
+		t.Errorf("Expected AxyDEFzzzz, got %q", out)
+	}
+	if string(rom) != "ABCDEFGH" {
+		t.Errorf("Expected the ROM to be unchanged, got %q", rom)
+	}
+
+	out, err = ApplyIPS(rom, append(patch, 0x00, 0x00, 0x05))
+	if err != nil || string(out) != "AxyDE" {
+		t.Errorf("Expected the output to be truncated to AxyDE, got %q (%v)", out, err)
+	}
+
+	if _, err := ApplyIPS(rom, patch[:10]); err == nil {
+		t.Errorf("Expected an error for a truncated patch")
+	}
+}
+
+// TestCreateIPS checks that created patches reproduce changed, extended and truncated files
+func TestCreateIPS(t *testing.T) {
+	original := make([]byte, ipsEOF+16)
+	changed := append([]byte(nil), original...)
+	changed[3], changed[4], changed[ipsEOF] = 1, 2, 3
+
+	tests := []struct {
+		name     string
+		modified []byte
+	}{
+		{"changed", changed},
+		{"extended", append(append([]byte(nil), changed...), 4, 5)},
+		{"truncated", changed[:ipsEOF+1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ApplyIPS(original, CreateIPS(original, tt.modified))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(out, tt.modified) {
+				t.Errorf("Expected the patch to reproduce the modified file")
+			}
+		})
+	}
+
+	if patch := CreateIPS(original, original); patch != nil {
+		t.Errorf("Expected no patch for an unchanged file, got %q", patch)
+	}
+}
+
+// TestUPS checks a patch that changes and extends a ROM, and the CRC32 checks
+func TestUPS(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	target := []byte("ABXDEFGHIJ")
+
+	patch := []byte("UPS1")
+	patch = append(patch, number(8)...)
+	patch = append(patch, number(10)...)
+	patch = append(patch, number(2)...)
+	patch = append(patch, 'C'^'X', 0x00) // offset 2, then 3 is skipped
+	patch = append(patch, number(4)...)
+	patch = append(patch, 'I', 'J', 0x00) // offset 8
+	patch = withFooter(patch, source, target)
+
+	out, err := Patch(source, patch)
+	if err != nil || string(out) != string(target) {
+		t.Errorf("Expected %q, got %q (%v)", target, out, err)
+	}
+
+	if _, err := ApplyUPS([]byte("ABCDEFGX"), patch); err == nil {
+		t.Errorf("Expected an error for the wrong ROM")
+	}
+	patch[6] ^= 0xFF
+	if _, err := ApplyUPS(source, patch); err == nil {
+		t.Errorf("Expected an error for a corrupt patch")
+	}
+}
+
+// TestBPS checks each action and the CRC32 checks
+func TestBPS(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	target := []byte("ABCDxyxyxyEFAB")
+
+	action := func(kind int, length int) []byte {
+		return number((length-1)<<2 | kind)
+	}
+	patch := []byte("BPS1")
+	patch = append(patch, number(8)...)
+	patch = append(patch, number(14)...)
+	patch = append(patch, number(0)...) // no metadata
+	patch = append(patch, action(bpsSourceRead, 4)...)
+	patch = append(patch, action(bpsTargetRead, 2)...)
+	patch = append(patch, 'x', 'y')
+	patch = append(patch, action(bpsTargetCopy, 4)...)
+	patch = append(patch, number(4<<1)...) // xyxy from 4, overlapping what it writes
+	patch = append(patch, action(bpsSourceCopy, 2)...)
+	patch = append(patch, number(4<<1)...) // EF from 4
+	patch = append(patch, action(bpsSourceCopy, 2)...)
+	patch = append(patch, number(6<<1|1)...) // AB from 6 - 6
+	patch = withFooter(patch, source, target)
+
+	out, err := Patch(source, patch)
+	if err != nil || string(out) != string(target) {
+		t.Errorf("Expected %q, got %q (%v)", target, out, err)
+	}
+
+	if _, err := ApplyBPS([]byte("ABCDEFGX"), patch); err == nil {
+		t.Errorf("Expected an error for the wrong ROM")
+	}
+	if _, err := ApplyBPS(source, patch[:len(patch)-13]); err == nil {
+		t.Errorf("Expected an error for a truncated patch")
+	}
+}
+
+// TestFindPatch checks that a patch next to a ROM is found
+func TestFindPatch(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "game.nes")
+
+	if p := FindPatch(rom); p != "" {
+		t.Errorf("Expected no patch, got %q", p)
+	}
+
+	patch := filepath.Join(dir, "game.bps")
+	if err := os.WriteFile(patch, []byte("BPS1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if p := FindPatch(rom); p != patch {
+		t.Errorf("Expected %q, got %q", patch, p)
+	}
+	if p := FindPatch(filepath.Join(dir, "game.nes.gz")); p != patch {
+		t.Errorf("Expected %q for a compressed ROM, got %q", patch, p)
+	}
+}
@@ -0,0 +1,128 @@
+package video
+
+import (
+	"image/color"
+	"math"
+)
+
+// Generated palettes
+// ------------------
+// The PPU outputs each pixel as a square wave alternating between a high and a low voltage
+// level, with the phase of the wave giving the hue. A palette can be generated by sampling
+// that signal as the PPU does, 12 times per color cycle, and decoding it to YIQ and then RGB
+// the way a TV would. Emphasis attenuates the signal during the parts of the cycle matching
+// the emphasized color.
+
+// NTSCParams are the settings of the decoder used to generate a palette
+type NTSCParams struct {
+	Hue        float64 // Hue shift in degrees
+	Saturation float64 // 1 is unchanged
+	Contrast   float64 // 1 is unchanged
+	Brightness float64 // 1 is unchanged
+	Gamma      float64 // Gamma of the display the signal is decoded for
+}
+
+// DefaultNTSCParams are the settings of a typical TV
+var DefaultNTSCParams = NTSCParams{
+	Hue:        0,
+	Saturation: 1,
+	Contrast:   1,
+	Brightness: 1,
+	Gamma:      1.8,
+}
+
+// Voltage levels of the composite signal relative to sync
+var (
+	signalLow  = [4]float64{0.350, 0.518, 0.962, 1.550}
+	signalHigh = [4]float64{1.094, 1.506, 1.962, 1.962}
+)
+
+const (
+	signalBlack       = 0.518
+	signalWhite       = 1.962
+	signalAttenuation = 0.746
+)
+
+// inColorPhase returns true during the half of the 12 step color cycle in which a wave of
+// the given hue is high
+func inColorPhase(phase int, hue int) bool {
+	return (hue+phase+8)%12 < 6
+}
+
+// Signal returns the 12 samples of the composite signal for a PPU color, normalized so that
+// black is 0 and white 1
+func Signal(c uint16) [12]float64 {
+	hue := int(c & 0x0F)
+	level := int(c>>4) & 0x03
+	if hue >= 0x0E {
+		// columns E and F are black
+		level = 1
+	}
+
+	low := signalLow[level]
+	high := signalHigh[level]
+	if hue == 0x00 {
+		// column 0 is high for the whole cycle
+		low = high
+	}
+	if hue >= 0x0D {
+		// columns D to F are low for the whole cycle
+		high = low
+	}
+
+	var samples [12]float64
+	for phase := range samples {
+		v := low
+		if inColorPhase(phase, hue) {
+			v = high
+		}
+
+		// the emphasis bits attenuate the parts of the cycle of red, green and blue
+		if (c&0x040 != 0 && inColorPhase(phase, 12)) ||
+			(c&0x080 != 0 && inColorPhase(phase, 4)) ||
+			(c&0x100 != 0 && inColorPhase(phase, 8)) {
+			v *= signalAttenuation
+		}
+
+		samples[phase] = (v - signalBlack) / (signalWhite - signalBlack)
+	}
+	return samples
+}
+
+// GeneratePalette returns a palette decoded from the NTSC signal of each color
+func GeneratePalette(params NTSCParams) *Palette {
+	p := Palette{}
+	for i := range p {
+		var y, ci, cq float64
+		for phase, v := range Signal(uint16(i)) {
+			v = ((v-0.5)*params.Contrast + 0.5) * params.Brightness / 12
+			angle := math.Pi / 6 * (float64(phase) + params.Hue/30)
+			y += v
+			ci += v * math.Cos(angle)
+			cq += v * math.Sin(angle)
+		}
+		ci *= params.Saturation
+		cq *= params.Saturation
+
+		p[i] = color.RGBA{
+			gammaCorrect(y+0.946882*ci+0.623557*cq, params.Gamma),
+			gammaCorrect(y-0.274788*ci-0.635691*cq, params.Gamma),
+			gammaCorrect(y-1.108545*ci+1.709007*cq, params.Gamma),
+			0xFF,
+		}
+	}
+	return &p
+}
+
+// gammaCorrect converts a linear intensity to an 8-bit channel value for a display with the
+// given gamma, relative to the 2.2 of sRGB
+func gammaCorrect(v float64, gamma float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	v = math.Pow(v, 2.2/gamma) * 255
+	if v >= 255 {
+		return 255
+	}
+	return uint8(math.Round(v))
+}
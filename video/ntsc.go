@@ -0,0 +1,183 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// NTSC filter
+// -----------
+// Instead of mapping each pixel to a color, the filter rebuilds the composite signal of the
+// whole frame and decodes it like a TV. The PPU generates 8 samples of its square wave per dot
+// against a color cycle of 12 samples, so the phase of each pixel depends on its position:
+// consecutive dots are 8 samples apart and consecutive scanlines (341 dots) 4 samples apart,
+// and the phase of a frame depends on the frames before it. Because luma and chroma share the
+// signal, decoding it produces the artifacts of a real TV:
+//     - artifacting: chroma leaking into luma, seen as dot crawl and the checkerboard patterns
+//       along colored edges
+//     - fringing: luma leaking into chroma, seen as colored fringes along sharp brightness
+//       changes. Some games use it to produce colors the palette does not have.
+//
+// The decoded picture has 4 samples per output pixel, so it is twice as wide as the PPU
+// output.
+
+// NTSCWidth is the width of the output of the NTSC filter
+const NTSCWidth = ppu.Width * 2
+
+const (
+	samplesPerDot   = 8
+	samplesPerPixel = 4
+	samplesPerLine  = ppu.Width * samplesPerDot
+	chromaWindow    = 24 // samples over which I and Q are demodulated, two color cycles
+	lumaWindow      = 12 // samples over which chroma cancels out of luma, one color cycle
+	sharpLumaWindow = 4  // samples over which luma is taken when artifacting is enabled
+)
+
+// NTSCFilter decodes PPU frames through a simulated composite video signal
+type NTSCFilter struct {
+	NTSCParams
+
+	Sharpness   float64 // -1 blurs, 0 is unchanged and 1 sharpens
+	Fringing    float64 // 0 removes luma from the chroma signal, 1 keeps it all
+	Artifacting float64 // 0 filters chroma out of the luma signal, 1 keeps it all
+
+	signal [512][12]float64
+	cos    [12]float64 // demodulation carriers for each phase, with the hue shift
+	sin    [12]float64
+	params NTSCParams
+	valid  bool
+	line   [samplesPerLine]float64
+	luma   [samplesPerLine]float64
+	y      [NTSCWidth]float64
+}
+
+// NewNTSCFilter returns a filter with the artifacts of a composite video connection
+func NewNTSCFilter() *NTSCFilter {
+	return &NTSCFilter{
+		NTSCParams:  DefaultNTSCParams,
+		Sharpness:   0,
+		Fringing:    1,
+		Artifacting: 1,
+	}
+}
+
+// Image decodes a PPU frame buffer to a new image of NTSCWidth x 240 pixels. phase is the
+// color phase of the frame, as returned by the PPU's ColorPhase.
+func (f *NTSCFilter) Image(frame []uint16, phase int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, NTSCWidth, ppu.Height))
+	f.Draw(img, frame, phase)
+	return img
+}
+
+// Draw decodes a PPU frame buffer into an existing image of at least NTSCWidth x 240 pixels
+func (f *NTSCFilter) Draw(img *image.RGBA, frame []uint16, phase int) {
+	if !f.valid || f.params != f.NTSCParams {
+		f.buildSignal()
+	}
+
+	for y := 0; y < ppu.Height; y++ {
+		// the first pixel of a scanline is output on its second dot
+		linePhase := (phase + (y*341+1)*samplesPerDot) % 12
+		f.encodeLine(frame[y*ppu.Width:(y+1)*ppu.Width], linePhase)
+		f.decodeLine(img, y, linePhase)
+	}
+}
+
+// buildSignal precomputes the signal of every color at each phase with the contrast and
+// brightness settings applied, and the carriers used to demodulate it
+func (f *NTSCFilter) buildSignal() {
+	for c := range f.signal {
+		for phase, v := range Signal(uint16(c)) {
+			f.signal[c][phase] = ((v-0.5)*f.Contrast + 0.5) * f.Brightness
+		}
+	}
+
+	for phase := range f.cos {
+		angle := math.Pi / 6 * (float64(phase) + f.Hue/30)
+		f.cos[phase] = math.Cos(angle)
+		f.sin[phase] = math.Sin(angle)
+	}
+	f.params = f.NTSCParams
+	f.valid = true
+}
+
+// encodeLine generates the samples of a scanline and their luma
+func (f *NTSCFilter) encodeLine(pixels []uint16, phase int) {
+	for x, c := range pixels {
+		signal := &f.signal[c&0x01FF]
+		for i := 0; i < samplesPerDot; i++ {
+			f.line[x*samplesPerDot+i] = signal[(phase+x*samplesPerDot+i)%12]
+		}
+	}
+
+	// luma of each sample is the mean of the color cycle around it
+	for i := range f.luma {
+		f.luma[i] = f.mean(i-lumaWindow/2, lumaWindow)
+	}
+}
+
+// sample returns a sample of the current line, or blanking outside of it
+func (f *NTSCFilter) sample(i int) float64 {
+	if i < 0 || i >= samplesPerLine {
+		return 0
+	}
+	return f.line[i]
+}
+
+// mean returns the mean of n samples from start
+func (f *NTSCFilter) mean(start int, n int) float64 {
+	sum := 0.0
+	for i := start; i < start+n; i++ {
+		sum += f.sample(i)
+	}
+	return sum / float64(n)
+}
+
+// decodeLine demodulates a scanline into a row of the image
+func (f *NTSCFilter) decodeLine(img *image.RGBA, row int, phase int) {
+	// luma, with some of the chroma left in when artifacting
+	for x := range f.y {
+		center := x*samplesPerPixel + samplesPerPixel/2
+		clean := f.luma[center]
+		sharp := f.mean(center-sharpLumaWindow/2, sharpLumaWindow)
+		f.y[x] = clean + f.Artifacting*(sharp-clean)
+	}
+
+	pix := img.Pix[row*img.Stride:]
+	for x := range f.y {
+		center := x*samplesPerPixel + samplesPerPixel/2
+
+		var i, q float64
+		for k := center - chromaWindow/2; k < center+chromaWindow/2; k++ {
+			v := f.sample(k)
+			if k >= 0 && k < samplesPerLine {
+				// remove the luma so that only chroma is demodulated, unless fringing
+				v -= (1 - f.Fringing) * f.luma[k]
+			}
+			carrier := ((phase+k)%12 + 12) % 12
+			i += v * f.cos[carrier]
+			q += v * f.sin[carrier]
+		}
+		i *= f.Saturation / chromaWindow
+		q *= f.Saturation / chromaWindow
+
+		y := f.y[x]
+		if x > 0 && x < NTSCWidth-1 {
+			y += f.Sharpness * (y - (f.y[x-1]+f.y[x+1])/2)
+		}
+
+		c := color.RGBA{
+			gammaCorrect(y+0.946882*i+0.623557*q, f.Gamma),
+			gammaCorrect(y-0.274788*i-0.635691*q, f.Gamma),
+			gammaCorrect(y-1.108545*i+1.709007*q, f.Gamma),
+			0xFF,
+		}
+		pix[x*4] = c.R
+		pix[x*4+1] = c.G
+		pix[x*4+2] = c.B
+		pix[x*4+3] = c.A
+	}
+}
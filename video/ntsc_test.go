@@ -0,0 +1,98 @@
+package video
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// fill returns a frame buffer of a single color
+func fill(c uint16) []uint16 {
+	frame := make([]uint16, ppu.Width*ppu.Height)
+	for i := range frame {
+		frame[i] = c
+	}
+	return frame
+}
+
+// near returns true if two colors differ by at most tolerance in each channel
+func near(a color.RGBA, b color.RGBA, tolerance int) bool {
+	diff := func(x uint8, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return diff(a.R, b.R) <= tolerance && diff(a.G, b.G) <= tolerance && diff(a.B, b.B) <= tolerance
+}
+
+// TestNTSCFlatColor checks that a flat field decodes to the generated palette color when
+// artifacts are disabled
+func TestNTSCFlatColor(t *testing.T) {
+	f := NewNTSCFilter()
+	f.Fringing = 0
+	f.Artifacting = 0
+	p := GeneratePalette(DefaultNTSCParams)
+
+	for _, c := range []uint16{0x00, 0x16, 0x2A, 0x12 | 0x040, 0x30} {
+		for phase := 0; phase < 12; phase += 4 {
+			img := f.Image(fill(c), phase)
+
+			if got := img.RGBAAt(NTSCWidth/2, 100); !near(got, p[c], 2) {
+				t.Errorf("Color %#03x at phase %d: expected %v, got %v", c, phase, p[c], got)
+			}
+		}
+	}
+}
+
+// TestNTSCArtifacting checks that artifacting produces a pattern in a flat colored field and
+// that it moves with the frame phase
+func TestNTSCArtifacting(t *testing.T) {
+	f := NewNTSCFilter()
+	f.Fringing = 0
+
+	img := f.Image(fill(0x16), 0)
+	a, b := img.RGBAAt(NTSCWidth/2, 100), img.RGBAAt(NTSCWidth/2+1, 100)
+	if a == b {
+		t.Errorf("Expected dot crawl between neighboring pixels, got %v and %v", a, b)
+	}
+
+	shifted := f.Image(fill(0x16), 4)
+	if shifted.RGBAAt(NTSCWidth/2, 100) == a {
+		t.Errorf("Expected the pattern to move with the frame phase")
+	}
+}
+
+// TestNTSCFringing checks that fringing colors the edge between black and white
+func TestNTSCFringing(t *testing.T) {
+	frame := fill(0x0F)
+	for y := 0; y < ppu.Height; y++ {
+		for x := ppu.Width / 2; x < ppu.Width; x++ {
+			frame[y*ppu.Width+x] = 0x30
+		}
+	}
+
+	saturation := func(fringing float64) int {
+		f := NewNTSCFilter()
+		f.Artifacting = 0
+		f.Fringing = fringing
+		c := f.Image(frame, 0).RGBAAt(NTSCWidth/2, 100)
+		hi := max(c.R, c.G, c.B)
+		lo := min(c.R, c.G, c.B)
+		return int(hi) - int(lo)
+	}
+
+	if with, without := saturation(1), saturation(0); with <= without {
+		t.Errorf("Expected fringing to add color at the edge, got saturation %d with and %d without", with, without)
+	}
+}
+
+// TestNTSCSize checks the dimensions of the output
+func TestNTSCSize(t *testing.T) {
+	img := NewNTSCFilter().Image(fill(0x00), 0)
+
+	if img.Bounds().Dx() != NTSCWidth || img.Bounds().Dy() != ppu.Height {
+		t.Errorf("Expected %dx%d, got %v", NTSCWidth, ppu.Height, img.Bounds())
+	}
+}
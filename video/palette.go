@@ -0,0 +1,163 @@
+package video
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// Palettes
+// --------
+// The PPU does not output RGB. Each pixel of its frame buffer is one of 64 palette indices,
+// and the three emphasis bits of PPUMASK darken the channels that are not emphasized, for 512
+// colors in all. The greyscale bit has already been applied by the PPU, which masks the index
+// to the grey column.
+//
+// The colors depend on the TV that decodes the composite signal, so there is no single
+// correct palette. A palette can be:
+//     - the built-in default
+//     - loaded from a .pal file of 64 or 512 RGB triplets
+//     - generated from the parameters of an NTSC decoder
+//
+// RGB PPUs output their colors directly from a palette of 9-bit colors, and emphasis sets the
+// emphasized channels to full intensity instead.
+
+// Palette maps the 512 PPU colors to RGBA. Entries are indexed by the frame buffer values of
+// the PPU: the palette index in bits 0-5 and the red, green and blue emphasis bits in 6-8.
+type Palette [512]color.RGBA
+
+// emphasisAttenuation is the factor applied to the channels that are not emphasized
+const emphasisAttenuation = 0.816328
+
+// defaultColors is the built-in palette of the 2C02, without emphasis
+var defaultColors = [64][3]uint8{
+	{84, 84, 84}, {0, 30, 116}, {8, 16, 144}, {48, 0, 136}, {68, 0, 100}, {92, 0, 48}, {84, 4, 0}, {60, 24, 0},
+	{32, 42, 0}, {8, 58, 0}, {0, 64, 0}, {0, 60, 0}, {0, 50, 60}, {0, 0, 0}, {0, 0, 0}, {0, 0, 0},
+	{152, 150, 152}, {8, 76, 196}, {48, 50, 236}, {92, 30, 228}, {136, 20, 176}, {160, 20, 100}, {152, 34, 32}, {120, 60, 0},
+	{84, 90, 0}, {40, 114, 0}, {8, 124, 0}, {0, 118, 40}, {0, 102, 120}, {0, 0, 0}, {0, 0, 0}, {0, 0, 0},
+	{236, 238, 236}, {76, 154, 236}, {120, 124, 236}, {176, 98, 236}, {228, 84, 236}, {236, 88, 180}, {236, 106, 100}, {212, 136, 32},
+	{160, 170, 0}, {116, 196, 0}, {76, 208, 32}, {56, 204, 108}, {56, 180, 204}, {60, 60, 60}, {0, 0, 0}, {0, 0, 0},
+	{236, 238, 236}, {168, 204, 236}, {188, 188, 236}, {212, 178, 236}, {236, 174, 236}, {236, 174, 212}, {236, 180, 176}, {228, 196, 144},
+	{204, 210, 120}, {180, 222, 120}, {168, 226, 144}, {152, 226, 180}, {160, 214, 228}, {160, 162, 160}, {0, 0, 0}, {0, 0, 0},
+}
+
+// DefaultPalette returns the built-in palette
+func DefaultPalette() *Palette {
+	return expand(defaultColors)
+}
+
+// PaletteForModel returns the palette of an RGB PPU, or the built-in palette for the PPUs
+// with composite output
+func PaletteForModel(m ppu.Model) *Palette {
+	rgb, ok := m.RGBPalette()
+	if !ok {
+		return DefaultPalette()
+	}
+
+	p := Palette{}
+	for i := range p {
+		c := rgb[i&0x3F]
+		// 3 bits per channel, scaled to 8 bits
+		channels := [3]uint8{
+			uint8((c >> 6 & 0x07) * 255 / 7),
+			uint8((c >> 3 & 0x07) * 255 / 7),
+			uint8((c & 0x07) * 255 / 7),
+		}
+		for ch := uint(0); ch < 3; ch++ {
+			if i>>(6+ch)&0x01 != 0 {
+				channels[ch] = 0xFF
+			}
+		}
+		p[i] = color.RGBA{channels[0], channels[1], channels[2], 0xFF}
+	}
+	return &p
+}
+
+// LoadPalette reads a palette of 64 or 512 RGB triplets. Palettes of 64 colors are expanded
+// to 512 by attenuating the channels that are not emphasized.
+func LoadPalette(r io.Reader) (*Palette, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(data) {
+	case 64 * 3:
+		var colors [64][3]uint8
+		for i := range colors {
+			copy(colors[i][:], data[i*3:])
+		}
+		return expand(colors), nil
+	case 512 * 3:
+		p := Palette{}
+		for i := range p {
+			p[i] = color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xFF}
+		}
+		return &p, nil
+	}
+	return nil, fmt.Errorf("video: palette is %d bytes, expected %d or %d", len(data), 64*3, 512*3)
+}
+
+// LoadPaletteFile reads a .pal file, see LoadPalette
+func LoadPaletteFile(path string) (*Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadPalette(f)
+}
+
+// expand builds a 512 color palette from 64 colors
+func expand(colors [64][3]uint8) *Palette {
+	p := Palette{}
+	for i := range p {
+		c := colors[i&0x3F]
+		channels := [3]float64{float64(c[0]), float64(c[1]), float64(c[2])}
+
+		emphasis := i >> 6
+		for ch := uint(0); ch < 3; ch++ {
+			// every channel but the emphasized one is attenuated by each emphasis bit
+			for bit := uint(0); bit < 3; bit++ {
+				if emphasis>>bit&0x01 != 0 && bit != ch {
+					channels[ch] *= emphasisAttenuation
+				}
+			}
+		}
+		p[i] = color.RGBA{
+			uint8(math.Round(channels[0])),
+			uint8(math.Round(channels[1])),
+			uint8(math.Round(channels[2])),
+			0xFF,
+		}
+	}
+	return &p
+}
+
+// Image converts a PPU frame buffer to a new image
+func (p *Palette) Image(frame []uint16) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ppu.Width, ppu.Height))
+	p.Draw(img, frame)
+	return img
+}
+
+// Draw converts a PPU frame buffer into an existing image of at least 256x240 pixels, which
+// avoids an allocation per frame
+func (p *Palette) Draw(img *image.RGBA, frame []uint16) {
+	for y := 0; y < ppu.Height; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+ppu.Width*4]
+		for x := 0; x < ppu.Width; x++ {
+			c := p[frame[y*ppu.Width+x]&0x01FF]
+			row[x*4] = c.R
+			row[x*4+1] = c.G
+			row[x*4+2] = c.B
+			row[x*4+3] = c.A
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package video
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/cbertinato/go-nes/ppu"
+)
+
+// TestLoadPalette checks that 64 and 512 color palettes are loaded and other sizes rejected
+func TestLoadPalette(t *testing.T) {
+	small := make([]uint8, 64*3)
+	small[0x21*3], small[0x21*3+1], small[0x21*3+2] = 100, 150, 200
+
+	large := make([]uint8, 512*3)
+	large[0x1A1*3], large[0x1A1*3+1], large[0x1A1*3+2] = 1, 2, 3
+
+	tests := []struct {
+		name     string
+		data     []uint8
+		index    int
+		expected color.RGBA
+	}{
+		{"64 colors", small, 0x21, color.RGBA{100, 150, 200, 0xFF}},
+		{"64 colors with emphasis", small, 0x21 | 0x040, color.RGBA{100, 122, 163, 0xFF}},
+		{"512 colors", large, 0x1A1, color.RGBA{1, 2, 3, 0xFF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := LoadPalette(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if p[tt.index] != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, p[tt.index])
+			}
+		})
+	}
+
+	if _, err := LoadPalette(bytes.NewReader(make([]uint8, 100))); err == nil {
+		t.Errorf("Expected an error for a palette of the wrong size")
+	}
+}
+
+// TestDefaultEmphasis checks that emphasis darkens the other channels and that all three bits
+// darken everything
+func TestDefaultEmphasis(t *testing.T) {
+	p := DefaultPalette()
+	white := p[0x30]
+
+	red := p[0x30|0x040]
+	if red.R != white.R || red.G >= white.G || red.B >= white.B {
+		t.Errorf("Red emphasis: expected only green and blue darkened, got %v from %v", red, white)
+	}
+
+	all := p[0x30|0x1C0]
+	if all.R >= white.R || all.G >= white.G || all.B >= white.B {
+		t.Errorf("Full emphasis: expected all channels darkened, got %v from %v", all, white)
+	}
+}
+
+// TestRGBPalette checks that emphasis sets channels to full intensity on RGB PPUs
+func TestRGBPalette(t *testing.T) {
+	p := PaletteForModel(ppu.Model2C03)
+
+	if c := p[0x30]; c != (color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Errorf("Expected white, got %v", c)
+	}
+
+	// 0x0F is black
+	if c := p[0x0F|0x100]; c != (color.RGBA{0x00, 0x00, 0xFF, 0xFF}) {
+		t.Errorf("Expected blue emphasis to set blue, got %v", c)
+	}
+
+	// 0x00 of the RP2C04-0001 is 0x35 of the 2C03
+	if c := PaletteForModel(ppu.Model2C04A)[0x00]; c != p[0x35] {
+		t.Errorf("Expected the 2C04 palette to be reordered, got %v", c)
+	}
+}
+
+// TestGeneratePalette checks the greys and blacks of a generated palette
+func TestGeneratePalette(t *testing.T) {
+	p := GeneratePalette(DefaultNTSCParams)
+
+	for _, i := range []int{0x00, 0x10, 0x20, 0x30, 0x2D, 0x3D} {
+		c := p[i]
+		if c.R != c.G || c.G != c.B {
+			t.Errorf("Expected %#02x to be grey, got %v", i, c)
+		}
+	}
+
+	if c := p[0x0F]; c != (color.RGBA{0, 0, 0, 0xFF}) {
+		t.Errorf("Expected black, got %v", c)
+	}
+
+	if c := p[0x30]; c.R < 0xF0 {
+		t.Errorf("Expected white, got %v", c)
+	}
+
+	// 0x16 is red
+	if c := p[0x16]; c.R <= c.G || c.R <= c.B {
+		t.Errorf("Expected red, got %v", c)
+	}
+
+	brighter := GeneratePalette(NTSCParams{Saturation: 1, Contrast: 1, Brightness: 1.5, Gamma: 1.8})
+	if brighter[0x10].R <= p[0x10].R {
+		t.Errorf("Brightness had no effect")
+	}
+}
+
+// TestDraw checks that frame buffer entries are converted through the palette
+func TestDraw(t *testing.T) {
+	p := DefaultPalette()
+	frame := make([]uint16, ppu.Width*ppu.Height)
+	frame[10*ppu.Width+20] = 0x16 | 0x080
+
+	img := p.Image(frame)
+
+	if c := img.RGBAAt(20, 10); c != p[0x16|0x080] {
+		t.Errorf("Expected %v, got %v", p[0x16|0x080], c)
+	}
+
+	if c := img.RGBAAt(0, 0); c != p[0x00] {
+		t.Errorf("Expected %v, got %v", p[0x00], c)
+	}
+}
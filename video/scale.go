@@ -0,0 +1,494 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Upscaling
+// ---------
+// The filters below enlarge a frame for display, screenshots and recordings. They run on the
+// CPU and work on any image.RGBA, so they can be applied to the output of a palette or of the
+// NTSC filter, and chained:
+//     - ScaleNearest: every pixel becomes an n x n block
+//     - Scale2x, Scale3x: nearest neighbor, except that corners between matching neighbors
+//       are filled in to smooth diagonals (also known as EPX and AdvMAME)
+//     - XBR2x, XBR3x, XBR4x: detect edges from the colors of a 5x5 neighborhood and blend
+//       along them, which rounds curves and keeps shallow and steep lines straight
+//     - EdgeBlend2x, EdgeBlend3x, EdgeBlend4x: detect edges from which of the 8 neighbors
+//       match in YUV, with the thresholds of the hqx filters, and interpolate the corners they
+//       cut. This is a simplification by rules, not hqx: the hqx lookup tables are not provided.
+//     - Scanlines: darkens every other row, to look like the gaps between the lines of a CRT
+//     - CorrectAspect: stretches the picture horizontally by 8:7, the pixel aspect ratio of
+//       the NTSC NES
+
+// ScaleNearest enlarges an image by an integer factor by repeating pixels
+func ScaleNearest(src *image.RGBA, n int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*n, b.Dy()*n))
+	for y := 0; y < b.Dy()*n; y++ {
+		for x := 0; x < b.Dx()*n; x++ {
+			dst.SetRGBA(x, y, src.RGBAAt(b.Min.X+x/n, b.Min.Y+y/n))
+		}
+	}
+	return dst
+}
+
+// neighborhood reads the pixels around a point of an image, repeating the edge pixels
+type neighborhood struct {
+	img *image.RGBA
+	x   int
+	y   int
+}
+
+// at returns the pixel at an offset from the center
+func (n neighborhood) at(dx int, dy int) color.RGBA {
+	b := n.img.Bounds()
+	x := min(max(n.x+dx, b.Min.X), b.Max.X-1)
+	y := min(max(n.y+dy, b.Min.Y), b.Max.Y-1)
+	return n.img.RGBAAt(x, y)
+}
+
+// Scale2x doubles the size of an image. With the neighbors of E named
+//
+//	A B C
+//	D E F
+//	G H I
+//
+// each output pixel is a copy of E, unless the two neighbors next to its corner match and the
+// other two do not, in which case it takes their color.
+func Scale2x(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*2, b.Dy()*2))
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			n := neighborhood{src, b.Min.X + x, b.Min.Y + y}
+			B, D, E, F, H := n.at(0, -1), n.at(-1, 0), n.at(0, 0), n.at(1, 0), n.at(0, 1)
+
+			e0, e1, e2, e3 := E, E, E, E
+			if B != H && D != F {
+				if D == B {
+					e0 = D
+				}
+				if B == F {
+					e1 = F
+				}
+				if D == H {
+					e2 = D
+				}
+				if H == F {
+					e3 = F
+				}
+			}
+
+			dst.SetRGBA(x*2, y*2, e0)
+			dst.SetRGBA(x*2+1, y*2, e1)
+			dst.SetRGBA(x*2, y*2+1, e2)
+			dst.SetRGBA(x*2+1, y*2+1, e3)
+		}
+	}
+	return dst
+}
+
+// Scale3x triples the size of an image with the same rules as Scale2x, where the edge pixels
+// of each 3x3 block are also filled when the corner they touch is not already E's color
+func Scale3x(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*3, b.Dy()*3))
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			n := neighborhood{src, b.Min.X + x, b.Min.Y + y}
+			A, B, C := n.at(-1, -1), n.at(0, -1), n.at(1, -1)
+			D, E, F := n.at(-1, 0), n.at(0, 0), n.at(1, 0)
+			G, H, I := n.at(-1, 1), n.at(0, 1), n.at(1, 1)
+
+			e := [9]color.RGBA{E, E, E, E, E, E, E, E, E}
+			if B != H && D != F {
+				if D == B {
+					e[0] = D
+				}
+				if (D == B && E != C) || (B == F && E != A) {
+					e[1] = B
+				}
+				if B == F {
+					e[2] = F
+				}
+				if (D == B && E != G) || (D == H && E != A) {
+					e[3] = D
+				}
+				if (B == F && E != I) || (H == F && E != C) {
+					e[5] = F
+				}
+				if D == H {
+					e[6] = D
+				}
+				if (D == H && E != I) || (H == F && E != G) {
+					e[7] = H
+				}
+				if H == F {
+					e[8] = F
+				}
+			}
+
+			for i, c := range e {
+				dst.SetRGBA(x*3+i%3, y*3+i/3, c)
+			}
+		}
+	}
+	return dst
+}
+
+// yuv converts a color to the YUV components used to compare colors
+func yuv(c color.RGBA) (y int, u int, v int) {
+	r, g, b := int(c.R), int(c.G), int(c.B)
+	y = (299*r + 587*g + 114*b) / 1000
+	u = (-169*r - 331*g + 500*b) / 1000
+	v = (500*r - 419*g - 81*b) / 1000
+	return y, u, v
+}
+
+// colorDistance returns a perceptual distance between two colors, weighting brightness most
+func colorDistance(a color.RGBA, b color.RGBA) int {
+	ya, ua, va := yuv(a)
+	yb, ub, vb := yuv(b)
+	return 48*abs(ya-yb) + 7*abs(ua-ub) + 6*abs(va-vb)
+}
+
+// similar returns true if two colors are close enough to be treated as the same
+func similar(a color.RGBA, b color.RGBA) bool {
+	ya, ua, va := yuv(a)
+	yb, ub, vb := yuv(b)
+	return abs(ya-yb) <= 48 && abs(ua-ub) <= 7 && abs(va-vb) <= 6
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// blend moves dst towards src by weight/256
+func blend(dst color.RGBA, src color.RGBA, weight int) color.RGBA {
+	mix := func(d uint8, s uint8) uint8 {
+		return uint8(int(d) + (int(s)-int(d))*weight/256)
+	}
+	return color.RGBA{mix(dst.R, src.R), mix(dst.G, src.G), mix(dst.B, src.B), mix(dst.A, src.A)}
+}
+
+// Edges and coverage
+// ------------------
+// The edge detecting filters decide, for each corner of a pixel, how an edge cuts across it.
+// The output pixels of the block are then blended with the color beyond the edge by the
+// fraction of their area that lies beyond it, so that one set of rules serves every scale.
+// Coordinates are those of the corner facing down and right, with the pixel spanning -1/2 to
+// 1/2 on each axis:
+//     - edgeDiagonal: a 45 degree edge, x + y >= 1/2
+//     - edgeShallow: a shallow edge, y + x/2 >= 1/4
+//     - edgeSteep: a steep edge, x + y/2 >= 1/4
+//     - edgeShallowSteep: both of the above
+//     - edgeThin: a 45 degree edge along a line 1 pixel wide, blended at half strength so that
+//       the line is kept
+//     - edgeWeak: no edge, but the outermost pixel of the corner is tinted by a quarter
+
+// edge is the shape of an edge cutting a corner of a pixel
+type edge int
+
+const (
+	edgeNone edge = iota
+	edgeWeak
+	edgeDiagonal
+	edgeThin
+	edgeShallow
+	edgeSteep
+	edgeShallowSteep
+	numEdges
+)
+
+// beyond returns how far a point lies beyond an edge, negative when it lies before it
+func (e edge) beyond(x float64, y float64) float64 {
+	shallow := y + x/2 - 0.25
+	steep := x + y/2 - 0.25
+	switch e {
+	case edgeDiagonal, edgeThin:
+		return x + y - 0.5
+	case edgeShallow:
+		return shallow
+	case edgeSteep:
+		return steep
+	case edgeShallowSteep:
+		return math.Max(shallow, steep)
+	}
+	return -1
+}
+
+// coverage returns the weight out of 256 of the color beyond an edge for each pixel of an
+// n x n output block, in row order. Areas are estimated from 16 x 16 samples per pixel, those
+// on the edge counting half.
+func coverage(n int, e edge) []int {
+	const samples = 16
+	weights := make([]int, n*n)
+	if e == edgeWeak {
+		weights[n*n-1] = 64
+		return weights
+	}
+
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			count := 0
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					x := (float64(i)+(float64(sx)+0.5)/samples)/float64(n) - 0.5
+					y := (float64(j)+(float64(sy)+0.5)/samples)/float64(n) - 0.5
+					if d := e.beyond(x, y); d > 0 {
+						count += 2
+					} else if d == 0 {
+						count++
+					}
+				}
+			}
+			weights[j*n+i] = count * 256 / (2 * samples * samples)
+			if e == edgeThin {
+				weights[j*n+i] /= 2
+			}
+		}
+	}
+	return weights
+}
+
+// cornerRule finds the edge cutting the corner of a pixel facing down and right after a
+// rotation, and the color beyond it
+type cornerRule func(n neighborhood, rotation int) (edge, color.RGBA)
+
+// scaleByRules enlarges an image by n, blending each corner of every pixel as found by rule
+func scaleByRules(src *image.RGBA, n int, rule cornerRule) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*n, b.Dy()*n))
+
+	var weights [numEdges][]int
+	for e := range weights {
+		weights[e] = coverage(n, edge(e))
+	}
+
+	out := make([]color.RGBA, n*n)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			nb := neighborhood{src, b.Min.X + x, b.Min.Y + y}
+			E := nb.at(0, 0)
+			for i := range out {
+				out[i] = E
+			}
+
+			for rotation := 0; rotation < 4; rotation++ {
+				e, px := rule(nb, rotation)
+				if e == edgeNone {
+					continue
+				}
+				for j := 0; j < n; j++ {
+					for i := 0; i < n; i++ {
+						w := weights[e][j*n+i]
+						if w == 0 {
+							continue
+						}
+						// offsets from the center of the block in half pixels, which are odd
+						u, v := rotate(2*i+1-n, 2*j+1-n, rotation)
+						k := (v+n-1)/2*n + (u+n-1)/2
+						out[k] = blend(out[k], px, w)
+					}
+				}
+			}
+
+			for i, c := range out {
+				dst.SetRGBA(x*n+i%n, y*n+i/n, c)
+			}
+		}
+	}
+	return dst
+}
+
+// rotate turns an offset by a number of quarter turns counterclockwise
+func rotate(dx int, dy int, rotation int) (int, int) {
+	for i := 0; i < rotation; i++ {
+		dx, dy = dy, -dx
+	}
+	return dx, dy
+}
+
+// XBR2x doubles the size of an image with the xBR (scale by rules) algorithm. Each of the four
+// corners of a pixel is handled by the same rules after rotating the neighborhood so that the
+// corner faces down and right. With the 5x5 neighborhood named
+//
+//	   A1 B1 C1
+//	A0 A  B  C  C4
+//	D0 D  E  F  F4
+//	G0 G  H  I  I4
+//	   G5 H5 I5
+//
+// an edge is found between E and I when the colors along the F-H diagonal are more alike than
+// those across it. The corner of E is then blended with F or H, whichever is closer to E. The
+// slope of the edge decides whether the neighboring output pixels are blended too, which keeps
+// shallow and steep lines straight.
+func XBR2x(src *image.RGBA) *image.RGBA {
+	return scaleByRules(src, 2, xbrCorner)
+}
+
+// XBR3x triples the size of an image with the rules of XBR2x
+func XBR3x(src *image.RGBA) *image.RGBA {
+	return scaleByRules(src, 3, xbrCorner)
+}
+
+// XBR4x quadruples the size of an image with the rules of XBR2x
+func XBR4x(src *image.RGBA) *image.RGBA {
+	return scaleByRules(src, 4, xbrCorner)
+}
+
+// xbrCorner applies the xBR rules to the corner facing down and right after a rotation
+func xbrCorner(n neighborhood, rotation int) (edge, color.RGBA) {
+	at := func(dx int, dy int) color.RGBA {
+		return n.at(rotate(dx, dy, rotation))
+	}
+
+	B, C, D, E, F := at(0, -1), at(1, -1), at(-1, 0), at(0, 0), at(1, 0)
+	G, H, I := at(-1, 1), at(0, 1), at(1, 1)
+	F4, I4, H5, I5 := at(2, 0), at(2, 1), at(0, 2), at(1, 2)
+
+	if E == H || E == F {
+		return edgeNone, E
+	}
+
+	d := colorDistance
+	e := d(E, C) + d(E, G) + d(I, H5) + d(I, F4) + 4*d(H, F)
+	i := d(H, D) + d(H, I5) + d(F, I4) + d(F, B) + 4*d(E, I)
+
+	px := H
+	if d(E, F) <= d(E, H) {
+		px = F
+	}
+
+	if e < i && ((!similar(F, B) && !similar(H, D)) ||
+		(similar(E, I) && !similar(F, I4) && !similar(H, I5)) ||
+		similar(E, G) || similar(E, C)) {
+		ke := d(F, G)
+		ki := d(H, C)
+		shallow := ke*2 <= ki && E != G && D != G
+		steep := ke >= ki*2 && E != C && B != C
+
+		switch {
+		case shallow && steep:
+			return edgeShallowSteep, px
+		case shallow:
+			return edgeShallow, px
+		case steep:
+			return edgeSteep, px
+		}
+		return edgeDiagonal, px
+	} else if e <= i {
+		return edgeWeak, px
+	}
+	return edgeNone, E
+}
+
+// EdgeBlend2x doubles the size of an image by blending the corners of pixels that an edge cuts.
+// Like the hqx filters, it compares E with its eight neighbors using thresholds on the
+// differences of their Y, U and V components, but it decides each corner with a few rules
+// instead of hqx's 256 entry tables, so its output differs from hq2x:
+//   - when F and H match each other but not E, a 45 degree edge cuts the corner, and E is
+//     blended with the mean of F and H, at half strength if I matches E as then E belongs to
+//     a line 1 pixel wide
+//   - when F and H both match E but I does not, the corner is tinted with I by a quarter
+//   - otherwise E is kept, so that straight edges stay sharp
+//
+// At 2x the blends are 2:1:1 (E:F:H) and 3:1 (E:I).
+func EdgeBlend2x(src *image.RGBA) *image.RGBA {
+	return scaleByRules(src, 2, edgeBlendCorner)
+}
+
+// EdgeBlend3x triples the size of an image with the rules of EdgeBlend2x
+func EdgeBlend3x(src *image.RGBA) *image.RGBA {
+	return scaleByRules(src, 3, edgeBlendCorner)
+}
+
+// EdgeBlend4x quadruples the size of an image with the rules of EdgeBlend2x
+func EdgeBlend4x(src *image.RGBA) *image.RGBA {
+	return scaleByRules(src, 4, edgeBlendCorner)
+}
+
+// edgeBlendCorner applies the rules of EdgeBlend2x to the corner facing down and right after a
+// rotation
+func edgeBlendCorner(n neighborhood, rotation int) (edge, color.RGBA) {
+	at := func(dx int, dy int) color.RGBA {
+		return n.at(rotate(dx, dy, rotation))
+	}
+	E, F, H, I := at(0, 0), at(1, 0), at(0, 1), at(1, 1)
+
+	switch {
+	case similar(F, H) && !similar(E, F):
+		if similar(E, I) {
+			return edgeThin, blend(F, H, 128)
+		}
+		return edgeDiagonal, blend(F, H, 128)
+	case similar(E, F) && similar(E, H) && !similar(E, I):
+		return edgeWeak, I
+	}
+	return edgeNone, E
+}
+
+// Scanlines darkens every other row of an image by intensity, from 0 (no effect) to 1 (black).
+// It is meant for images that have been scaled vertically by at least 2.
+func Scanlines(src *image.RGBA, intensity float64) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	scale := 1 - math.Min(math.Max(intensity, 0), 1)
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := src.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			if y%2 == 1 {
+				c.R = uint8(float64(c.R) * scale)
+				c.G = uint8(float64(c.G) * scale)
+				c.B = uint8(float64(c.B) * scale)
+			}
+			dst.SetRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// CorrectAspect stretches an image horizontally by 8/7, so that its pixels have the aspect
+// ratio of NES pixels on an NTSC TV. Each output pixel is the average of the source pixels it
+// covers, weighted by the area covered.
+func CorrectAspect(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	width := (b.Dx()*8 + 6) / 7
+	dst := image.NewRGBA(image.Rect(0, 0, width, b.Dy()))
+	step := float64(b.Dx()) / float64(width) // source pixels per output pixel
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < width; x++ {
+			start := float64(x) * step
+			end := math.Min(start+step, float64(b.Dx()))
+
+			var r, g, bl, a float64
+			for sx := int(start); float64(sx) < end; sx++ {
+				weight := math.Min(end, float64(sx+1)) - math.Max(start, float64(sx))
+				c := src.RGBAAt(b.Min.X+sx, b.Min.Y+y)
+				r += float64(c.R) * weight
+				g += float64(c.G) * weight
+				bl += float64(c.B) * weight
+				a += float64(c.A) * weight
+			}
+
+			total := end - start
+			dst.SetRGBA(x, y, color.RGBA{
+				uint8(math.Round(r / total)),
+				uint8(math.Round(g / total)),
+				uint8(math.Round(bl / total)),
+				uint8(math.Round(a / total)),
+			})
+		}
+	}
+	return dst
+}
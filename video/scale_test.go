@@ -0,0 +1,239 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	black = color.RGBA{0, 0, 0, 0xFF}
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// diagonal returns a 4x4 image with white on and below the diagonal from top left to bottom
+// right
+func diagonal() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x <= y {
+				img.SetRGBA(x, y, white)
+			} else {
+				img.SetRGBA(x, y, black)
+			}
+		}
+	}
+	return img
+}
+
+// TestScaleNearest checks that pixels are repeated into blocks
+func TestScaleNearest(t *testing.T) {
+	img := ScaleNearest(diagonal(), 3)
+	if img.Bounds().Dx() != 12 || img.Bounds().Dy() != 12 {
+		t.Fatalf("Expected 12x12, got %v", img.Bounds())
+	}
+
+	for _, p := range []image.Point{{0, 0}, {2, 2}, {3, 5}, {11, 11}} {
+		if c := img.RGBAAt(p.X, p.Y); c != white {
+			t.Errorf("Expected white at %v, got %v", p, c)
+		}
+	}
+	if c := img.RGBAAt(3, 2); c != black {
+		t.Errorf("Expected black at (3,2), got %v", c)
+	}
+}
+
+// TestScale2x checks that the steps of a diagonal are filled in and flat areas are unchanged
+func TestScale2x(t *testing.T) {
+	img := Scale2x(diagonal())
+
+	// the pixel at (1,0) is black with white to its left and below, so the corner between them
+	// is filled in
+	if c := img.RGBAAt(2, 1); c != white {
+		t.Errorf("Expected the corner of the step to be white, got %v", c)
+	}
+	if c := img.RGBAAt(3, 0); c != black {
+		t.Errorf("Expected the far corner to stay black, got %v", c)
+	}
+
+	flat := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for i := range flat.Pix {
+		flat.Pix[i] = 0x80
+	}
+	for i, v := range Scale2x(flat).Pix {
+		if v != 0x80 {
+			t.Fatalf("Expected a flat image to stay flat, got %#02x at %d", v, i)
+		}
+	}
+}
+
+// TestScale3x checks that the steps of a diagonal are filled in
+func TestScale3x(t *testing.T) {
+	img := Scale3x(diagonal())
+	if img.Bounds().Dx() != 12 {
+		t.Fatalf("Expected a width of 12, got %d", img.Bounds().Dx())
+	}
+
+	// bottom left corner of the black pixel at (1,0)
+	if c := img.RGBAAt(3, 2); c != white {
+		t.Errorf("Expected the corner of the step to be white, got %v", c)
+	}
+	if c := img.RGBAAt(4, 1); c != black {
+		t.Errorf("Expected the center to stay black, got %v", c)
+	}
+}
+
+// TestXBR2x checks that a diagonal edge is smoothed by blending the corners of the step
+func TestXBR2x(t *testing.T) {
+	img := XBR2x(diagonal())
+	if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 8 {
+		t.Fatalf("Expected 8x8, got %v", img.Bounds())
+	}
+
+	// the corner of the black pixel at (1,0) facing the white pixels is blended towards white
+	c := img.RGBAAt(2, 1)
+	if c == black || c == white {
+		t.Errorf("Expected the corner of the step to be blended, got %v", c)
+	}
+	if c := img.RGBAAt(3, 0); c != black {
+		t.Errorf("Expected the far corner to stay black, got %v", c)
+	}
+	if c := img.RGBAAt(0, 7); c != white {
+		t.Errorf("Expected the inside of the white area to stay white, got %v", c)
+	}
+}
+
+// TestXBR3x4x checks the larger xBR scales on the same step as TestXBR2x
+func TestXBR3x4x(t *testing.T) {
+	for _, tt := range []struct {
+		scale func(*image.RGBA) *image.RGBA
+		n     int
+	}{{XBR3x, 3}, {XBR4x, 4}} {
+		img := tt.scale(diagonal())
+		n := tt.n
+		if img.Bounds().Dx() != 4*n || img.Bounds().Dy() != 4*n {
+			t.Fatalf("%dx: expected %dx%d, got %v", n, 4*n, 4*n, img.Bounds())
+		}
+
+		// bottom left corner of the black pixel at (1,0), and its top right corner
+		if c := img.RGBAAt(n, n-1); c == black {
+			t.Errorf("%dx: expected the corner of the step to be blended, got %v", n, c)
+		}
+		if c := img.RGBAAt(2*n-1, 0); c != black {
+			t.Errorf("%dx: expected the far corner to stay black, got %v", n, c)
+		}
+		if c := img.RGBAAt(0, 4*n-1); c != white {
+			t.Errorf("%dx: expected the inside of the white area to stay white, got %v", n, c)
+		}
+	}
+}
+
+// TestCoverage checks that the edges give the blending weights of 2x xBR
+func TestCoverage(t *testing.T) {
+	for _, tt := range []struct {
+		e        edge
+		expected []int
+	}{
+		{edgeDiagonal, []int{0, 0, 0, 128}},
+		{edgeShallow, []int{0, 0, 64, 192}},
+		{edgeSteep, []int{0, 64, 0, 192}},
+		{edgeWeak, []int{0, 0, 0, 64}},
+	} {
+		weights := coverage(2, tt.e)
+		for i := range weights {
+			if weights[i] != tt.expected[i] {
+				t.Errorf("Edge %d: expected %v, got %v", tt.e, tt.expected, weights)
+				break
+			}
+		}
+	}
+}
+
+// TestEdgeBlend checks that the step of a diagonal is interpolated on both sides of the edge at every
+// scale, and that flat areas are unchanged
+func TestEdgeBlend(t *testing.T) {
+	for _, tt := range []struct {
+		scale func(*image.RGBA) *image.RGBA
+		n     int
+	}{{EdgeBlend2x, 2}, {EdgeBlend3x, 3}, {EdgeBlend4x, 4}} {
+		img := tt.scale(diagonal())
+		n := tt.n
+		if img.Bounds().Dx() != 4*n || img.Bounds().Dy() != 4*n {
+			t.Fatalf("%dx: expected %dx%d, got %v", n, 4*n, 4*n, img.Bounds())
+		}
+
+		// bottom left corner of the black pixel at (1,0) and top right corner of the white pixel
+		// at (1,1), which face each other across the edge
+		if c := img.RGBAAt(n, n-1); c == black {
+			t.Errorf("%dx: expected the black corner to be blended, got %v", n, c)
+		}
+		if c := img.RGBAAt(2*n-1, n); c == white {
+			t.Errorf("%dx: expected the white corner to be blended, got %v", n, c)
+		}
+		if c := img.RGBAAt(2*n-1, 0); c != black {
+			t.Errorf("%dx: expected the far corner to stay black, got %v", n, c)
+		}
+
+		flat := image.NewRGBA(image.Rect(0, 0, 3, 3))
+		for i := range flat.Pix {
+			flat.Pix[i] = 0x80
+		}
+		for i, v := range tt.scale(flat).Pix {
+			if v != 0x80 {
+				t.Fatalf("%dx: expected a flat image to stay flat, got %#02x at %d", n, v, i)
+			}
+		}
+	}
+
+	// 2:1:1 of black, white and white
+	if c := EdgeBlend2x(diagonal()).RGBAAt(2, 1); c != (color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}) {
+		t.Errorf("Expected the corner to be half white, got %v", c)
+	}
+}
+
+// TestScanlines checks that only odd rows are darkened
+func TestScanlines(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range src.Pix {
+		src.Pix[i] = 200
+	}
+
+	img := Scanlines(src, 0.25)
+	if c := img.RGBAAt(0, 0); c != (color.RGBA{200, 200, 200, 200}) {
+		t.Errorf("Expected even rows to be unchanged, got %v", c)
+	}
+	if c := img.RGBAAt(1, 1); c != (color.RGBA{150, 150, 150, 200}) {
+		t.Errorf("Expected odd rows to be darkened, got %v", c)
+	}
+}
+
+// TestCorrectAspect checks the output width and that pixels are averaged across boundaries
+func TestCorrectAspect(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		if x < 128 {
+			src.SetRGBA(x, 0, black)
+		} else {
+			src.SetRGBA(x, 0, white)
+		}
+	}
+
+	img := CorrectAspect(src)
+	if img.Bounds().Dx() != 293 {
+		t.Fatalf("Expected a width of 293, got %d", img.Bounds().Dx())
+	}
+
+	if c := img.RGBAAt(0, 0); c != black {
+		t.Errorf("Expected black on the left, got %v", c)
+	}
+	if c := img.RGBAAt(292, 0); c != white {
+		t.Errorf("Expected white on the right, got %v", c)
+	}
+	for x := 0; x < 293; x++ {
+		c := img.RGBAAt(x, 0)
+		if c != black && c != white && (x < 144 || x > 147) {
+			t.Errorf("Expected a blended pixel only at the boundary, got %v at %d", c, x)
+		}
+	}
+}